@@ -38,6 +38,10 @@ const EnvVaultToken = "VAULT_TOKEN"
 const EnvVaultMFA = "VAULT_MFA"
 const EnvRateLimit = "VAULT_RATE_LIMIT"
 
+// unixSocketHost is the host requests are made to when connecting to Vault
+// over a unix domain socket
+const unixSocketHost = "localhost"
+
 // WrappingLookupFunc is a function that, given an HTTP verb and a path,
 // returns an optional string duration to be used for response wrapping (e.g.
 // "15s", or simply "15"). The path will not begin with "/v1/" or "v1/" or "/",
@@ -85,6 +89,47 @@ type Config struct {
 	Limiter *rate.Limiter
 }
 
+// parseAddress parses the address of the Vault server. Unix domain socket
+// addresses of the form "unix://<path>" are turned into an http URL for the
+// requests made over the socket, and the path of the socket is returned.
+func parseAddress(address string) (*url.URL, string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if u.Scheme != "unix" {
+		return u, "", nil
+	}
+
+	socket := strings.TrimPrefix(address, "unix://")
+	if socket == "" {
+		return nil, "", fmt.Errorf("unix socket address %q has no path", address)
+	}
+
+	// The URL describes the HTTP requests rather than the connection, so the
+	// socket path is replaced by a placeholder host
+	return &url.URL{
+		Scheme: "http",
+		Host:   unixSocketHost,
+	}, socket, nil
+}
+
+// unixSocketHTTPClient returns a copy of the HTTP client that dials the unix
+// domain socket. The copy has a transport of its own, so that the transport
+// of the client, which may be shared, is never changed.
+func unixSocketHTTPClient(httpClient *http.Client, socket string) *http.Client {
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+
+	client := *httpClient
+	client.Transport = transport
+	return &client
+}
+
 // TLSConfig contains the parameters needed to configure TLS on the HTTP client
 // used to communicate with Vault.
 type TLSConfig struct {
@@ -325,6 +370,8 @@ func parseRateLimit(val string) (rate float64, burst int, err error) {
 type Client struct {
 	modifyLock         sync.RWMutex
 	addr               *url.URL
+	unixAddress        string
+	unixHTTPClient     *http.Client
 	config             *Config
 	token              string
 	headers            http.Header
@@ -357,11 +404,6 @@ func NewClient(c *Config) (*Client, error) {
 	c.modifyLock.Lock()
 	defer c.modifyLock.Unlock()
 
-	u, err := url.Parse(c.Address)
-	if err != nil {
-		return nil, err
-	}
-
 	if c.HttpClient == nil {
 		c.HttpClient = def.HttpClient
 	}
@@ -370,9 +412,11 @@ func NewClient(c *Config) (*Client, error) {
 	}
 
 	client := &Client{
-		addr:   u,
 		config: c,
 	}
+	if err := client.setAddress(c.Address, c.HttpClient); err != nil {
+		return nil, err
+	}
 
 	if token := os.Getenv(EnvVaultToken); token != "" {
 		client.token = token
@@ -382,26 +426,61 @@ func NewClient(c *Config) (*Client, error) {
 }
 
 // Sets the address of Vault in the client. The format of address should be
-// "<Scheme>://<Host>:<Port>". Setting this on a client will override the
-// value of VAULT_ADDR environment variable.
+// "<Scheme>://<Host>:<Port>", or "unix://<path>" for a unix domain socket.
+// Setting this on a client will override the value of VAULT_ADDR environment
+// variable.
+//
+// Requests to a unix domain socket are made with a copy of the configured
+// http.Client that has a transport of its own.
 func (c *Client) SetAddress(addr string) error {
 	c.modifyLock.Lock()
 	defer c.modifyLock.Unlock()
 
-	parsedAddr, err := url.Parse(addr)
-	if err != nil {
+	c.config.modifyLock.RLock()
+	httpClient := c.config.HttpClient
+	c.config.modifyLock.RUnlock()
+
+	if err := c.setAddress(addr, httpClient); err != nil {
 		return errwrap.Wrapf("failed to set address: {{err}}", err)
 	}
+	return nil
+}
+
+// setAddress sets the address of Vault, which is reached over a copy of the
+// HTTP client for unix domain sockets; the lock must be held
+func (c *Client) setAddress(addr string, httpClient *http.Client) error {
+	parsedAddr, socket, err := parseAddress(addr)
+	if err != nil {
+		return err
+	}
 
 	c.addr = parsedAddr
+	c.unixAddress = ""
+	c.unixHTTPClient = nil
+	if socket != "" {
+		c.unixHTTPClient = unixSocketHTTPClient(httpClient, socket)
+		c.unixAddress = addr
+	}
 	return nil
 }
 
+// httpClient returns the HTTP client for requests to Vault; the locks must
+// be held
+func (c *Client) httpClient() *http.Client {
+	if c.unixHTTPClient != nil {
+		return c.unixHTTPClient
+	}
+	return c.config.HttpClient
+}
+
 // Address returns the Vault URL the client is configured to connect to
 func (c *Client) Address() string {
 	c.modifyLock.RLock()
 	defer c.modifyLock.RUnlock()
 
+	if c.unixAddress != "" {
+		return c.unixAddress
+	}
 	return c.addr.String()
 }
 
@@ -557,7 +636,7 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 	// if SRV records exist (see https://tools.ietf.org/html/draft-andrews-http-srv-02), lookup the SRV
 	// record and take the highest match; this is not designed for high-availability, just discovery
 	var host string = addr.Host
-	if addr.Port() == "" {
+	if addr.Port() == "" && addr.Host != unixSocketHost {
 		// Internet Draft specifies that the SRV record is ignored if a port is given
 		_, addrs, err := net.LookupSRV("http", "tcp", addr.Hostname())
 		if err == nil && len(addrs) > 0 {
@@ -622,7 +701,7 @@ func (c *Client) RawRequestWithContext(ctx context.Context, r *Request) (*Respon
 	limiter := c.config.Limiter
 	maxRetries := c.config.MaxRetries
 	backoff := c.config.Backoff
-	httpClient := c.config.HttpClient
+	httpClient := c.httpClient()
 	timeout := c.config.Timeout
 	c.config.modifyLock.RUnlock()
 
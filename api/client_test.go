@@ -3,8 +3,11 @@ package api
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -69,6 +72,96 @@ func TestClientSetAddress(t *testing.T) {
 	}
 }
 
+func TestClientUnixSocket(t *testing.T) {
+	td, err := ioutil.TempDir("", "vault-api-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	socket := filepath.Join(td, "vault.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var gotHost string
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotHost = req.Host
+		w.Write([]byte(`{"initialized":true}`))
+	}))
+
+	config := DefaultConfig()
+	config.Address = "unix://" + socket
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.addr.Scheme != "http" || client.addr.Host != unixSocketHost {
+		t.Fatalf("bad address: %s", client.addr)
+	}
+
+	status, err := client.Sys().InitStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status {
+		t.Fatal("expected initialized status")
+	}
+	if gotHost != unixSocketHost {
+		t.Fatalf("bad host: %q", gotHost)
+	}
+	if addr := client.Address(); addr != "unix://"+socket {
+		t.Fatalf("bad address: %q", addr)
+	}
+
+	clone, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clone.Sys().InitStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting a TCP address stops dialing the socket
+	tcpConfig, tcpLn := testHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"initialized":false}`))
+	}))
+	defer tcpLn.Close()
+
+	// The configured HTTP client, which may be shared, does not dial the
+	// socket
+	shared, err := NewClient(&Config{
+		Address:    tcpConfig.Address,
+		HttpClient: config.HttpClient,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := shared.Sys().InitStatus(); err != nil || status {
+		t.Fatalf("expected the status of the TCP server, got %v, err: %v", status, err)
+	}
+
+	if err := client.SetAddress(tcpConfig.Address); err != nil {
+		t.Fatal(err)
+	}
+	if addr := client.Address(); addr != tcpConfig.Address {
+		t.Fatalf("bad address: %q", addr)
+	}
+	status, err = client.Sys().InitStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status {
+		t.Fatal("expected the status of the TCP server")
+	}
+
+	if err := client.SetAddress("unix://"); err == nil {
+		t.Fatal("expected error for an empty socket path")
+	}
+}
+
 func TestClientToken(t *testing.T) {
 	tokenValue := "foo"
 	handler := func(w http.ResponseWriter, req *http.Request) {}
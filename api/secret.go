@@ -295,6 +295,24 @@ type SecretAuth struct {
 
 	LeaseDuration int  `json:"lease_duration"`
 	Renewable     bool `json:"renewable"`
+
+	// MFARequirement is set instead of a client token when the login must
+	// be completed through sys/mfa/validate
+	MFARequirement *MFARequirement `json:"mfa_requirement"`
+}
+
+// MFARequirement lists the MFA methods that must be validated to complete a
+// login.
+type MFARequirement struct {
+	MFARequestID string         `json:"mfa_request_id"`
+	MFAMethods   []*MFAMethodID `json:"mfa_methods"`
+}
+
+// MFAMethodID identifies an MFA method of a login MFA requirement.
+type MFAMethodID struct {
+	Type         string `json:"type"`
+	Name         string `json:"name"`
+	UsesPasscode bool   `json:"uses_passcode"`
 }
 
 // ParseSecret is used to parse a secret value from JSON from an io.Reader.
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+)
+
+// MFAValidate completes a login that returned an MFA requirement. The
+// payload maps the name of each required MFA method to the passcodes
+// collected for it; methods that don't use a passcode, such as a Duo push,
+// take an empty list.
+func (c *Sys) MFAValidate(requestID string, payload map[string][]string) (*Secret, error) {
+	body := map[string]interface{}{
+		"mfa_request_id": requestID,
+		"mfa_payload":    payload,
+	}
+
+	r := c.c.NewRequest("POST", "/v1/sys/mfa/validate")
+	if err := r.SetJSONBody(body); err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSecret(resp.Body)
+}
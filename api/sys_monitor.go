@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+)
+
+// Monitor streams the log of the node the client is connected to at the given
+// level. Lines are sent on the returned channel until ctx is canceled or the
+// server closes the stream, at which point the channel is closed.
+//
+// The stream is not subject to the client timeout, as it is expected to be
+// long lived; cancel ctx to end it.
+func (c *Sys) Monitor(ctx context.Context, logLevel string) (chan string, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/monitor")
+	if logLevel != "" {
+		r.Params.Set("log_level", logLevel)
+	}
+
+	req, err := r.ToHTTP()
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	c.c.modifyLock.RLock()
+	c.c.config.modifyLock.RLock()
+	httpClient := *c.c.httpClient()
+	c.c.config.modifyLock.RUnlock()
+	c.c.modifyLock.RUnlock()
+	httpClient.Timeout = 0
+
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{Response: httpResp}
+	if err := resp.Error(); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	logCh := make(chan string, 64)
+
+	go func() {
+		defer close(logCh)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case logCh <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			select {
+			case logCh <- fmt.Sprintf("[monitor] error reading log stream: %s", err):
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return logCh, nil
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/version"
+)
+
+func TestFormatCEF_formatRequest(t *testing.T) {
+	salter, err := salt.NewSalt(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saltFunc := func(context.Context) (*salt.Salt, error) {
+		return salter, nil
+	}
+
+	header := fmt.Sprintf("CEF:0|HashiCorp|Vault|%s|request|", version.GetVersion().VersionNumber())
+
+	cases := map[string]struct {
+		Auth        *logical.Auth
+		Req         *logical.Request
+		Err         error
+		Prefix      string
+		ExpectedStr string
+	}{
+		"auth, request": {
+			&logical.Auth{ClientToken: "foo", Accessor: "bar", DisplayName: "testtoken", Policies: []string{"root", "default"}},
+			&logical.Request{
+				ID:        "abc",
+				Operation: logical.UpdateOperation,
+				Path:      "secret/foo|bar",
+				Connection: &logical.Connection{
+					RemoteAddr: "127.0.0.1",
+				},
+				WrapInfo: &logical.RequestWrapInfo{
+					TTL: 60 * time.Second,
+				},
+			},
+			errors.New("bad key=value"),
+			"",
+			header + `update secret/foo\|bar|7|externalId=abc act=update request=secret/foo|bar outcome=failure reason=bad key\=value suser=testtoken src=127.0.0.1 cs1=root,default cs1Label=policies cs2=bar cs2Label=accessor cn1=60 cn1Label=wrap_ttl`,
+		},
+		"request with prefix": {
+			nil,
+			&logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "sys/health",
+				Connection: &logical.Connection{
+					RemoteAddr: "not-an-ip",
+				},
+			},
+			nil,
+			"@cef: ",
+			"@cef: " + header + `read sys/health|3|act=read request=sys/health outcome=success`,
+		},
+	}
+
+	for name, tc := range cases {
+		var buf bytes.Buffer
+		formatter := AuditFormatter{
+			AuditFormatWriter: &CEFFormatWriter{
+				Prefix:   tc.Prefix,
+				SaltFunc: saltFunc,
+			},
+		}
+		config := FormatterConfig{
+			OmitTime:     true,
+			HMACAccessor: false,
+		}
+		in := &LogInput{
+			Auth:     tc.Auth,
+			Request:  tc.Req,
+			OuterErr: tc.Err,
+		}
+		if err := formatter.FormatRequest(context.Background(), &buf, config, in); err != nil {
+			t.Fatalf("bad: %s\nerr: %s", name, err)
+		}
+
+		if !strings.HasSuffix(buf.String(), "\n") {
+			t.Fatalf("bad: %s\nentry is not newline terminated", name)
+		}
+
+		if got := strings.TrimSpace(buf.String()); got != tc.ExpectedStr {
+			t.Fatalf("bad: %s\nResult:\n\n'%s'\n\nExpected:\n\n'%s'", name, got, tc.ExpectedStr)
+		}
+	}
+}
+
+func TestFormatCEF_formatResponse(t *testing.T) {
+	salter, err := salt.NewSalt(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatter := AuditFormatter{
+		AuditFormatWriter: &CEFFormatWriter{
+			SaltFunc: func(context.Context) (*salt.Salt, error) {
+				return salter, nil
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	in := &LogInput{
+		Auth: &logical.Auth{DisplayName: "testtoken", EntityID: "entity1"},
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "database/creds/readonly",
+		},
+		Response: &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{TTL: time.Hour},
+				LeaseID:      "database/creds/readonly/1234",
+			},
+			Data: map[string]interface{}{
+				"password": "secret",
+			},
+		},
+	}
+	if err := formatter.FormatResponse(context.Background(), &buf, FormatterConfig{}, in); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := buf.String()
+	for _, expected := range []string{
+		"|response|read database/creds/readonly|3|rt=",
+		" suid=entity1 ",
+		" cs5=database/creds/readonly/1234 cs5Label=lease_id",
+	} {
+		if !strings.Contains(entry, expected) {
+			t.Fatalf("expected %q in entry: %s", expected, entry)
+		}
+	}
+	if strings.Contains(entry, "secret\n") || strings.Contains(entry, "password") {
+		t.Fatalf("response data should not be in entry: %s", entry)
+	}
+}
@@ -0,0 +1,276 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/salt"
+)
+
+const (
+	// DefaultRFC5424EnterpriseID is the private enterprise number used to
+	// qualify the structured data IDs when none is configured. It is the
+	// number reserved for documentation by RFC 5612; operators that own a
+	// private enterprise number should configure it instead.
+	DefaultRFC5424EnterpriseID = "32473"
+
+	// rfc5424SeverityInfo is the syslog severity every audit entry is
+	// written with, matching the syslog audit device.
+	rfc5424SeverityInfo = 6
+
+	rfc5424NilValue   = "-"
+	rfc5424TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var (
+	// syslogFacilities maps the facility names accepted by the syslog audit
+	// device to their numeric codes.
+	syslogFacilities = map[string]int{
+		"KERN":     0,
+		"USER":     1,
+		"MAIL":     2,
+		"DAEMON":   3,
+		"AUTH":     4,
+		"SYSLOG":   5,
+		"LPR":      6,
+		"NEWS":     7,
+		"UUCP":     8,
+		"CRON":     9,
+		"AUTHPRIV": 10,
+		"FTP":      11,
+		"LOCAL0":   16,
+		"LOCAL1":   17,
+		"LOCAL2":   18,
+		"LOCAL3":   19,
+		"LOCAL4":   20,
+		"LOCAL5":   21,
+		"LOCAL6":   22,
+		"LOCAL7":   23,
+	}
+
+	rfc5424ParamEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`]`, `\]`,
+	)
+)
+
+// ParseSyslogFacility returns the numeric syslog facility code for the given
+// facility name, e.g. "AUTH" or "local0".
+func ParseSyslogFacility(facility string) (int, error) {
+	code, ok := syslogFacilities[strings.ToUpper(facility)]
+	if !ok {
+		return 0, fmt.Errorf("invalid syslog facility %q", facility)
+	}
+	return code, nil
+}
+
+// RFC5424FormatWriter is an AuditFormatWriter implementation that structures
+// data into RFC 5424 syslog messages. Indexable fields are emitted as
+// structured data elements and the message body carries the full entry as
+// JSON.
+type RFC5424FormatWriter struct {
+	// Prefix is written at the start of the message body, which allows e.g. a
+	// CEE cookie to be placed before the JSON entry.
+	Prefix   string
+	SaltFunc func(context.Context) (*salt.Salt, error)
+
+	Facility     int
+	AppName      string
+	Hostname     string
+	EnterpriseID string
+}
+
+func (f *RFC5424FormatWriter) WriteRequest(w io.Writer, req *AuditRequestEntry) error {
+	if req == nil {
+		return fmt.Errorf("request entry was nil, cannot encode")
+	}
+
+	elements := []rfc5424Element{
+		rfc5424RequestElement(&req.Request, req.Error),
+		rfc5424AuthElement(&req.Auth),
+	}
+	return f.write(w, req.Time, req.Type, elements, req)
+}
+
+func (f *RFC5424FormatWriter) WriteResponse(w io.Writer, resp *AuditResponseEntry) error {
+	if resp == nil {
+		return fmt.Errorf("response entry was nil, cannot encode")
+	}
+
+	elements := []rfc5424Element{
+		rfc5424RequestElement(&resp.Request, ""),
+		rfc5424AuthElement(&resp.Auth),
+		rfc5424ResponseElement(&resp.Response, resp.Error),
+	}
+	return f.write(w, resp.Time, resp.Type, elements, resp)
+}
+
+func (f *RFC5424FormatWriter) Salt(ctx context.Context) (*salt.Salt, error) {
+	return f.SaltFunc(ctx)
+}
+
+func (f *RFC5424FormatWriter) write(w io.Writer, entryTime, msgID string, elements []rfc5424Element, entry interface{}) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	timestamp := rfc5424NilValue
+	if entryTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, entryTime)
+		if err != nil {
+			return err
+		}
+		timestamp = t.UTC().Format(rfc5424TimeFormat)
+	}
+
+	hostname := f.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	appName := f.AppName
+	if appName == "" {
+		appName = "vault"
+	}
+
+	enterpriseID := f.EnterpriseID
+	if enterpriseID == "" {
+		enterpriseID = DefaultRFC5424EnterpriseID
+	}
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "<%d>1 %s %s %s %d %s ",
+		f.Facility*8+rfc5424SeverityInfo,
+		timestamp,
+		rfc5424HeaderField(hostname, 255),
+		rfc5424HeaderField(appName, 48),
+		os.Getpid(),
+		rfc5424HeaderField(msgID, 32))
+
+	wroteElement := false
+	for _, e := range elements {
+		if len(e.params) == 0 {
+			continue
+		}
+		wroteElement = true
+		line.WriteString("[" + e.id + "@" + enterpriseID)
+		for _, p := range e.params {
+			line.WriteString(" " + p.name + `="` + rfc5424ParamEscaper.Replace(p.value) + `"`)
+		}
+		line.WriteByte(']')
+	}
+	if !wroteElement {
+		line.WriteString(rfc5424NilValue)
+	}
+
+	line.WriteByte(' ')
+	line.WriteString(f.Prefix)
+	line.Write(body)
+	line.WriteByte('\n')
+
+	_, err = io.WriteString(w, line.String())
+	return err
+}
+
+// rfc5424Element is a single SD-ELEMENT. Parameters with empty values are
+// dropped by the helpers that build them, and elements without parameters are
+// not written.
+type rfc5424Element struct {
+	id     string
+	params []rfc5424Param
+}
+
+type rfc5424Param struct {
+	name  string
+	value string
+}
+
+func (e *rfc5424Element) add(name, value string) {
+	if value == "" {
+		return
+	}
+	e.params = append(e.params, rfc5424Param{name, value})
+}
+
+func rfc5424RequestElement(req *AuditRequest, errString string) rfc5424Element {
+	e := rfc5424Element{id: "request"}
+	e.add("id", req.ID)
+	e.add("operation", string(req.Operation))
+	e.add("path", req.Path)
+	e.add("remote_address", req.RemoteAddr)
+	e.add("client_token", req.ClientToken)
+	e.add("client_token_accessor", req.ClientTokenAccessor)
+	e.add("replication_cluster", req.ReplicationCluster)
+	if req.PolicyOverride {
+		e.add("policy_override", "true")
+	}
+	if req.WrapTTL > 0 {
+		e.add("wrap_ttl", strconv.Itoa(req.WrapTTL))
+	}
+	e.add("error", errString)
+	return e
+}
+
+func rfc5424AuthElement(auth *AuditAuth) rfc5424Element {
+	e := rfc5424Element{id: "auth"}
+	e.add("display_name", auth.DisplayName)
+	e.add("entity_id", auth.EntityID)
+	e.add("accessor", auth.Accessor)
+	e.add("client_token", auth.ClientToken)
+	e.add("policies", strings.Join(auth.Policies, ","))
+	e.add("token_policies", strings.Join(auth.TokenPolicies, ","))
+	e.add("identity_policies", strings.Join(auth.IdentityPolicies, ","))
+	if auth.RemainingUses > 0 {
+		e.add("remaining_uses", strconv.Itoa(auth.RemainingUses))
+	}
+	return e
+}
+
+func rfc5424ResponseElement(resp *AuditResponse, errString string) rfc5424Element {
+	e := rfc5424Element{id: "response"}
+	if resp.Auth != nil {
+		e.add("auth_accessor", resp.Auth.Accessor)
+		e.add("auth_display_name", resp.Auth.DisplayName)
+		e.add("auth_policies", strings.Join(resp.Auth.Policies, ","))
+	}
+	if resp.Secret != nil {
+		e.add("lease_id", resp.Secret.LeaseID)
+	}
+	if resp.WrapInfo != nil {
+		e.add("wrap_accessor", resp.WrapInfo.Accessor)
+		e.add("wrapped_accessor", resp.WrapInfo.WrappedAccessor)
+		e.add("wrap_creation_path", resp.WrapInfo.CreationPath)
+		if resp.WrapInfo.TTL > 0 {
+			e.add("wrap_ttl", strconv.Itoa(resp.WrapInfo.TTL))
+		}
+	}
+	e.add("redirect", resp.Redirect)
+	e.add("error", errString)
+	return e
+}
+
+// rfc5424HeaderField makes s safe for use as a header field, which must be
+// printable US-ASCII without spaces and is limited in length.
+func rfc5424HeaderField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return rfc5424NilValue
+	}
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	return s
+}
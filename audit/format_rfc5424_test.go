@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestFormatRFC5424_formatRequest(t *testing.T) {
+	salter, err := salt.NewSalt(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saltFunc := func(context.Context) (*salt.Salt, error) {
+		return salter, nil
+	}
+
+	fooSalted := salter.GetIdentifiedHMAC("foo")
+	pid := os.Getpid()
+
+	cases := map[string]struct {
+		Auth           *logical.Auth
+		Req            *logical.Request
+		Err            error
+		Prefix         string
+		EnterpriseID   string
+		ExpectedHeader string
+	}{
+		"auth, request": {
+			&logical.Auth{ClientToken: "foo", Accessor: "bar", DisplayName: "testtoken", Policies: []string{"root"}},
+			&logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "/foo",
+				Connection: &logical.Connection{
+					RemoteAddr: "127.0.0.1",
+				},
+				WrapInfo: &logical.RequestWrapInfo{
+					TTL: 60 * time.Second,
+				},
+			},
+			errors.New(`this is an "error"]`),
+			"",
+			"",
+			fmt.Sprintf(`<38>1 - testhost vault %d request [request@32473 operation="update" path="/foo" remote_address="127.0.0.1" wrap_ttl="60" error="this is an \"error\"\]"][auth@32473 display_name="testtoken" accessor="bar" client_token="%s" policies="root"] `,
+				pid, fooSalted),
+		},
+		"request with prefix and enterprise id": {
+			nil,
+			&logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "sys/health",
+			},
+			nil,
+			"@cee: ",
+			"1234",
+			fmt.Sprintf(`<38>1 - testhost vault %d request [request@1234 operation="read" path="sys/health"] @cee: `, pid),
+		},
+	}
+
+	for name, tc := range cases {
+		var buf bytes.Buffer
+		formatter := AuditFormatter{
+			AuditFormatWriter: &RFC5424FormatWriter{
+				Prefix:       tc.Prefix,
+				SaltFunc:     saltFunc,
+				Facility:     4,
+				Hostname:     "testhost",
+				EnterpriseID: tc.EnterpriseID,
+			},
+		}
+		config := FormatterConfig{
+			OmitTime:     true,
+			HMACAccessor: false,
+		}
+		in := &LogInput{
+			Auth:     tc.Auth,
+			Request:  tc.Req,
+			OuterErr: tc.Err,
+		}
+		if err := formatter.FormatRequest(context.Background(), &buf, config, in); err != nil {
+			t.Fatalf("bad: %s\nerr: %s", name, err)
+		}
+
+		entry := buf.String()
+		if !strings.HasPrefix(entry, tc.ExpectedHeader) {
+			t.Fatalf("bad: %s\nResult:\n\n'%s'\n\nExpected prefix:\n\n'%s'", name, entry, tc.ExpectedHeader)
+		}
+
+		var msg AuditRequestEntry
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(entry, tc.ExpectedHeader)), &msg); err != nil {
+			t.Fatalf("bad: %s\nmessage body is not a JSON entry: %s", name, err)
+		}
+		if msg.Request.Path != tc.Req.Path {
+			t.Fatalf("bad: %s\nexpected path %q in message body, got %q", name, tc.Req.Path, msg.Request.Path)
+		}
+	}
+}
+
+func TestFormatRFC5424_formatResponse(t *testing.T) {
+	salter, err := salt.NewSalt(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatter := AuditFormatter{
+		AuditFormatWriter: &RFC5424FormatWriter{
+			SaltFunc: func(context.Context) (*salt.Salt, error) {
+				return salter, nil
+			},
+			Facility: 16,
+			AppName:  "vault audit",
+		},
+	}
+
+	var buf bytes.Buffer
+	in := &LogInput{
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "database/creds/readonly",
+		},
+		Response: &logical.Response{
+			Secret: &logical.Secret{
+				LeaseID: "database/creds/readonly/1234",
+			},
+		},
+	}
+	if err := formatter.FormatResponse(context.Background(), &buf, FormatterConfig{}, in); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := strings.SplitN(buf.String(), " ", 7)
+	if len(fields) != 7 {
+		t.Fatalf("bad entry: %s", buf.String())
+	}
+	if fields[0] != "<134>1" {
+		t.Fatalf("bad priority and version: %s", fields[0])
+	}
+	if _, err := time.Parse(rfc5424TimeFormat, fields[1]); err != nil {
+		t.Fatalf("bad timestamp %q: %s", fields[1], err)
+	}
+	if fields[3] != "vaultaudit" {
+		t.Fatalf("bad app name: %s", fields[3])
+	}
+	if fields[5] != "response" {
+		t.Fatalf("bad msg id: %s", fields[5])
+	}
+	if !strings.Contains(fields[6], `[response@32473 lease_id="database/creds/readonly/1234"]`) {
+		t.Fatalf("missing response structured data: %s", fields[6])
+	}
+}
+
+func TestParseSyslogFacility(t *testing.T) {
+	code, err := ParseSyslogFacility("local0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 16 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	if _, err := ParseSyslogFacility("bogus"); err == nil {
+		t.Fatal("expected error")
+	}
+}
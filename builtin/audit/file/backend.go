@@ -45,11 +45,25 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		format = "json"
 	}
 	switch format {
-	case "json", "jsonx":
+	case "json", "jsonx", "cef", "rfc5424":
 	default:
 		return nil, fmt.Errorf("unknown format type %q", format)
 	}
 
+	// Get the syslog facility used in the priority of RFC 5424 messages
+	facility := 0
+	if format == "rfc5424" {
+		facilityRaw, ok := conf.Config["facility"]
+		if !ok {
+			facilityRaw = "AUTH"
+		}
+		code, err := audit.ParseSyslogFacility(facilityRaw)
+		if err != nil {
+			return nil, err
+		}
+		facility = code
+	}
+
 	// Check if hashing of accessor is disabled
 	hmacAccessor := true
 	if hmacAccessorRaw, ok := conf.Config["hmac_accessor"]; ok {
@@ -104,6 +118,19 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 			Prefix:   conf.Config["prefix"],
 			SaltFunc: b.Salt,
 		}
+	case "cef":
+		b.formatter.AuditFormatWriter = &audit.CEFFormatWriter{
+			Prefix:   conf.Config["prefix"],
+			SaltFunc: b.Salt,
+		}
+	case "rfc5424":
+		b.formatter.AuditFormatWriter = &audit.RFC5424FormatWriter{
+			Prefix:       conf.Config["prefix"],
+			SaltFunc:     b.Salt,
+			Facility:     facility,
+			AppName:      conf.Config["tag"],
+			EnterpriseID: conf.Config["enterprise_id"],
+		}
 	}
 
 	switch path {
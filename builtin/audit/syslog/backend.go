@@ -38,7 +38,7 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		format = "json"
 	}
 	switch format {
-	case "json", "jsonx":
+	case "json", "jsonx", "cef", "rfc5424":
 	default:
 		return nil, fmt.Errorf("unknown format type %q", format)
 	}
@@ -63,10 +63,27 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		logRaw = b
 	}
 
-	// Get the logger
-	logger, err := gsyslog.NewLogger(gsyslog.LOG_INFO, facility, tag)
-	if err != nil {
-		return nil, err
+	// Get the logger. RFC 5424 messages carry their own header, so they are
+	// written to the local daemon as-is instead of through gsyslog.
+	var logger gsyslog.Syslogger
+	var facilityCode int
+	if format == "rfc5424" {
+		code, err := audit.ParseSyslogFacility(facility)
+		if err != nil {
+			return nil, err
+		}
+		facilityCode = code
+
+		logger, err = newRFC5424Logger()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		logger, err = gsyslog.NewLogger(gsyslog.LOG_INFO, facility, tag)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	b := &Backend{
@@ -90,6 +107,19 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 			Prefix:   conf.Config["prefix"],
 			SaltFunc: b.Salt,
 		}
+	case "cef":
+		b.formatter.AuditFormatWriter = &audit.CEFFormatWriter{
+			Prefix:   conf.Config["prefix"],
+			SaltFunc: b.Salt,
+		}
+	case "rfc5424":
+		b.formatter.AuditFormatWriter = &audit.RFC5424FormatWriter{
+			Prefix:       conf.Config["prefix"],
+			SaltFunc:     b.Salt,
+			Facility:     facilityCode,
+			AppName:      tag,
+			EnterpriseID: conf.Config["enterprise_id"],
+		}
 	}
 
 	return b, nil
@@ -0,0 +1,82 @@
+package syslog
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-syslog"
+)
+
+// rfc5424Logger delivers pre-formatted RFC 5424 messages to the local syslog
+// daemon. gsyslog always adds its own RFC 3164 header, so messages that
+// already carry a header have to be written to the socket directly.
+type rfc5424Logger struct {
+	l    sync.Mutex
+	conn net.Conn
+}
+
+var _ gsyslog.Syslogger = (*rfc5424Logger)(nil)
+
+func newRFC5424Logger() (*rfc5424Logger, error) {
+	l := &rfc5424Logger{}
+	if err := l.connect(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// connect opens a connection to the local syslog daemon, trying the same
+// sockets as the standard library.
+func (l *rfc5424Logger) connect() error {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := net.DialTimeout(network, path, time.Second)
+			if err != nil {
+				continue
+			}
+			l.conn = conn
+			return nil
+		}
+	}
+	return errors.New("unable to connect to the local syslog daemon")
+}
+
+// WriteLevel ignores the given priority, as it is already encoded in the
+// message header.
+func (l *rfc5424Logger) WriteLevel(_ gsyslog.Priority, buf []byte) error {
+	_, err := l.Write(buf)
+	return err
+}
+
+func (l *rfc5424Logger) Write(buf []byte) (int, error) {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if l.conn != nil {
+		if n, err := l.conn.Write(buf); err == nil {
+			return n, nil
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	// The daemon may have been restarted; reconnect and retry once
+	if err := l.connect(); err != nil {
+		return 0, err
+	}
+	return l.conn.Write(buf)
+}
+
+func (l *rfc5424Logger) Close() error {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.conn = nil
+	return err
+}
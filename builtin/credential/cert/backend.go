@@ -2,11 +2,15 @@ package cert
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -33,12 +37,16 @@ func Backend() *backend {
 			pathCerts(&b),
 			pathCRLs(&b),
 		}),
-		AuthRenew:   b.pathLoginRenew,
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeCredential,
+		AuthRenew:    b.pathLoginRenew,
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
+		BackendType:  logical.TypeCredential,
 	}
 
 	b.crlUpdateMutex = &sync.RWMutex{}
+	b.ocspCache = cache.New(0, 5*time.Minute)
+	b.httpClient = cleanhttp.DefaultClient()
+	b.httpClient.Timeout = 10 * time.Second
 
 	return &b
 }
@@ -49,6 +57,12 @@ type backend struct {
 
 	crls           map[string]CRLInfo
 	crlUpdateMutex *sync.RWMutex
+
+	// ocspCache holds OCSP responses until their next update
+	ocspCache *cache.Cache
+
+	// httpClient is used to query OCSP responders and fetch CRLs
+	httpClient *http.Client
 }
 
 func (b *backend) invalidate(_ context.Context, key string) {
@@ -60,6 +74,10 @@ func (b *backend) invalidate(_ context.Context, key string) {
 	}
 }
 
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	return b.refreshCRLs(ctx, req.Storage)
+}
+
 const backendHelp = `
 The "cert" credential provider allows authentication using
 TLS client certificates. A client connects to Vault and uses
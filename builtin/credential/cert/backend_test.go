@@ -3,7 +3,10 @@ package cert
 import (
 	"context"
 	"crypto/rand"
+	"encoding/pem"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 
 	"golang.org/x/net/http2"
 
@@ -29,6 +32,7 @@ import (
 	"github.com/hashicorp/go-rootcerts"
 	"github.com/hashicorp/vault/builtin/logical/pki"
 	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/ocsputil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	logicaltest "github.com/hashicorp/vault/logical/testing"
@@ -1757,3 +1761,314 @@ func Test_Renew(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+// testGenerateCAAndClientCert generates a CA and a client certificate issued
+// by it that lists the given OCSP server
+func testGenerateCAAndClientCert(t *testing.T, ocspServer string) (*x509.Certificate, *rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if ocspServer != "" {
+		clientTemplate.OCSPServer = []string{ocspServer}
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, clientKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := x509.ParseCertificate(clientDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ca, caKey, client
+}
+
+func TestBackend_OCSP(t *testing.T) {
+	var lock sync.Mutex
+	var hits int
+	status := ocsputil.Good
+	var nextUpdate time.Time
+	var ca *x509.Certificate
+	var caKey *rsa.PrivateKey
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		hits++
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ocspReq, err := ocsputil.ParseRequest(body)
+		if err != nil || !ocspReq.MatchesIssuer(ca) {
+			w.Write(ocsputil.CreateErrorResponse(ocsputil.Malformed))
+			return
+		}
+		resp, err := ocsputil.CreateResponse(ca, ca, ocsputil.Response{
+			Status:       status,
+			SerialNumber: ocspReq.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   nextUpdate,
+			RevokedAt:    time.Now(),
+		}, caKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	ca, caKey, client := testGenerateCAAndClientCert(t, responder.URL)
+
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certData := map[string]interface{}{
+		"certificate":  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+		"policies":     "abc",
+		"ocsp_enabled": true,
+	}
+	writeCert := func() {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "certs/ca",
+			Storage:   storage,
+			Data:      certData,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+	}
+	writeCert()
+
+	login := func() *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Connection: &logical.Connection{
+				ConnState: &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{client},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	setResponder := func(s int, n time.Time) {
+		lock.Lock()
+		defer lock.Unlock()
+		status = s
+		nextUpdate = n
+	}
+
+	if resp := login(); resp == nil || resp.IsError() {
+		t.Fatalf("expected a successful login: %#v", resp)
+	}
+
+	// Responses without a next update are not cached
+	setResponder(ocsputil.Revoked, time.Time{})
+	if resp := login(); resp == nil || !resp.IsError() {
+		t.Fatalf("expected failure due to revoked certificate: %#v", resp)
+	}
+
+	// Unknown statuses fail closed unless the role fails open
+	setResponder(ocsputil.Unknown, time.Time{})
+	if resp := login(); resp == nil || !resp.IsError() {
+		t.Fatalf("expected failure due to unknown status: %#v", resp)
+	}
+	certData["ocsp_fail_open"] = true
+	writeCert()
+	if resp := login(); resp == nil || resp.IsError() {
+		t.Fatalf("expected a successful login: %#v", resp)
+	}
+
+	// Revoked certificates are rejected even when failing open
+	setResponder(ocsputil.Revoked, time.Time{})
+	if resp := login(); resp == nil || !resp.IsError() {
+		t.Fatalf("expected failure due to revoked certificate: %#v", resp)
+	}
+
+	// Unreachable responders fail open or closed, and the override replaces
+	// the responder of the certificate
+	certData["ocsp_servers_override"] = "http://127.0.0.1:1"
+	writeCert()
+	if resp := login(); resp == nil || resp.IsError() {
+		t.Fatalf("expected a successful login: %#v", resp)
+	}
+	certData["ocsp_fail_open"] = false
+	writeCert()
+	if resp := login(); resp == nil || !resp.IsError() {
+		t.Fatalf("expected failure due to an unreachable responder: %#v", resp)
+	}
+
+	// Later servers are tried when earlier ones fail, and responses are
+	// cached until their next update
+	certData["ocsp_servers_override"] = "http://127.0.0.1:1," + responder.URL
+	writeCert()
+	setResponder(ocsputil.Good, time.Now().Add(time.Hour))
+	lock.Lock()
+	hits = 0
+	lock.Unlock()
+	for i := 0; i < 3; i++ {
+		if resp := login(); resp == nil || resp.IsError() {
+			t.Fatalf("expected a successful login: %#v", resp)
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if hits != 1 {
+		t.Fatalf("expected a single OCSP request, got %d", hits)
+	}
+}
+
+func TestBackend_CRLURL(t *testing.T) {
+	var lock sync.Mutex
+	var crl []byte
+	crlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		w.Write(crl)
+	}))
+	defer crlServer.Close()
+
+	ca, caKey, client := testGenerateCAAndClientCert(t, "")
+	setCRL := func(revoked []pkix.RevokedCertificate, nextUpdate time.Time) {
+		t.Helper()
+		der, err := ca.CreateCRL(rand.Reader, caKey, revoked, time.Now(), nextUpdate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		crl = der
+	}
+
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crlReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "crls/fetched",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"url": crlServer.URL,
+		},
+	}
+
+	// CRLs must be signed by a trusted certificate
+	setCRL([]pkix.RevokedCertificate{
+		{SerialNumber: client.SerialNumber, RevocationTime: time.Now()},
+	}, time.Now())
+	resp, err := b.HandleRequest(context.Background(), crlReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an untrusted CRL; err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "certs/ca",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"certificate": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+			"policies":    "abc",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	resp, err = b.HandleRequest(context.Background(), crlReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	login := func() *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "login",
+			Storage:   storage,
+			Connection: &logical.Connection{
+				ConnState: &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{client},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := login(); resp == nil || !resp.IsError() {
+		t.Fatalf("expected failure due to revoked certificate: %#v", resp)
+	}
+
+	// The CRL is fetched again once it reaches its next update
+	setCRL(nil, time.Now().Add(time.Hour))
+	if err := b.(*backend).periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := login(); resp == nil || resp.IsError() {
+		t.Fatalf("expected a successful login: %#v", resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "crls/fetched",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["url"] != crlServer.URL || len(resp.Data["serials"].(map[string]interface{})) != 0 {
+		t.Fatalf("bad: CRL: %#v", resp.Data)
+	}
+}
@@ -0,0 +1,123 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/ocsputil"
+)
+
+const (
+	maxOCSPResponseSize = 1024 * 1024
+
+	// ocspClockSkew is the tolerance when checking the validity period of
+	// OCSP responses
+	ocspClockSkew = 5 * time.Minute
+)
+
+// checkForChainInOCSP returns whether the client certificate of the chain
+// must be rejected based on its OCSP status
+func (b *backend) checkForChainInOCSP(ctx context.Context, chain []*x509.Certificate, entry *CertEntry) bool {
+	if !entry.OCSPEnabled || len(chain) < 2 {
+		return false
+	}
+
+	resp, err := b.ocspStatus(ctx, chain[0], chain[1], entry.OCSPServersOverride)
+	switch {
+	case err != nil:
+		b.Logger().Warn("failed to check OCSP status", "cert_name", entry.Name, "serial_number", chain[0].SerialNumber.String(), "error", err)
+		return !entry.OCSPFailOpen
+	case resp.Status == ocsputil.Revoked:
+		return true
+	case resp.Status == ocsputil.Unknown:
+		b.Logger().Warn("OCSP status of the certificate is unknown", "cert_name", entry.Name, "serial_number", chain[0].SerialNumber.String())
+		return !entry.OCSPFailOpen
+	}
+	return false
+}
+
+// ocspStatus returns the OCSP status of cert. The servers are queried in order
+// until one returns a valid response, and responses are cached until their
+// next update.
+func (b *backend) ocspStatus(ctx context.Context, cert, issuer *x509.Certificate, servers []string) (*ocsputil.Response, error) {
+	issuerHash := sha256.Sum256(issuer.Raw)
+	cacheKey := hex.EncodeToString(issuerHash[:]) + ":" + cert.SerialNumber.String()
+	if cached, ok := b.ocspCache.Get(cacheKey); ok {
+		return cached.(*ocsputil.Response), nil
+	}
+
+	if len(servers) == 0 {
+		servers = cert.OCSPServer
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no OCSP servers are configured and the certificate does not include any")
+	}
+
+	ocspReq, err := ocsputil.CreateRequest(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs *multierror.Error
+	for _, server := range servers {
+		resp, err := b.queryOCSP(ctx, server, ocspReq, cert, issuer)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %v", server, err))
+			continue
+		}
+
+		if !resp.NextUpdate.IsZero() {
+			b.ocspCache.Set(cacheKey, resp, time.Until(resp.NextUpdate))
+		}
+		return resp, nil
+	}
+
+	return nil, errs.ErrorOrNil()
+}
+
+func (b *backend) queryOCSP(ctx context.Context, server string, ocspReq []byte, cert, issuer *x509.Certificate) (*ocsputil.Response, error) {
+	httpReq, err := http.NewRequest(http.MethodPost, server, bytes.NewReader(ocspReq))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	httpReq.Header.Set("Accept", "application/ocsp-response")
+
+	httpResp, err := b.httpClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", httpResp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ocsputil.ParseResponse(body, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if resp.ThisUpdate.After(now.Add(ocspClockSkew)) {
+		return nil, errors.New("OCSP response is not yet valid")
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now.Add(-ocspClockSkew)) {
+		return nil, errors.New("OCSP response has expired")
+	}
+
+	return resp, nil
+}
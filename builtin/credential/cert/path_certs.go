@@ -123,6 +123,26 @@ TTL will be set to the value of this parameter.`,
 				Description: `Comma separated string or list of CIDR blocks. If set, specifies the blocks of
 IP addresses which can perform the login operation.`,
 			},
+
+			"ocsp_enabled": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the revocation status of the client
+certificate is checked with OCSP during login and renewal.`,
+			},
+
+			"ocsp_servers_override": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `A comma-separated list of OCSP responder URLs
+to query instead of the ones in the Authority Information
+Access extension of the client certificate.`,
+			},
+
+			"ocsp_fail_open": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, logins are allowed when no OCSP
+responder returns a status or the status is unknown.
+Revoked certificates are always rejected.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -179,18 +199,21 @@ func (b *backend) pathCertRead(ctx context.Context, req *logical.Request, d *fra
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"certificate":          cert.Certificate,
-			"display_name":         cert.DisplayName,
-			"policies":             cert.Policies,
-			"ttl":                  cert.TTL / time.Second,
-			"max_ttl":              cert.MaxTTL / time.Second,
-			"period":               cert.Period / time.Second,
-			"allowed_names":        cert.AllowedNames,
-			"allowed_common_names": cert.AllowedCommonNames,
-			"allowed_dns_sans":     cert.AllowedDNSSANs,
-			"allowed_email_sans":   cert.AllowedEmailSANs,
-			"allowed_uri_sans":     cert.AllowedURISANs,
-			"required_extensions":  cert.RequiredExtensions,
+			"certificate":           cert.Certificate,
+			"display_name":          cert.DisplayName,
+			"policies":              cert.Policies,
+			"ttl":                   cert.TTL / time.Second,
+			"max_ttl":               cert.MaxTTL / time.Second,
+			"period":                cert.Period / time.Second,
+			"allowed_names":         cert.AllowedNames,
+			"allowed_common_names":  cert.AllowedCommonNames,
+			"allowed_dns_sans":      cert.AllowedDNSSANs,
+			"allowed_email_sans":    cert.AllowedEmailSANs,
+			"allowed_uri_sans":      cert.AllowedURISANs,
+			"required_extensions":   cert.RequiredExtensions,
+			"ocsp_enabled":          cert.OCSPEnabled,
+			"ocsp_servers_override": cert.OCSPServersOverride,
+			"ocsp_fail_open":        cert.OCSPFailOpen,
 		},
 	}, nil
 }
@@ -206,6 +229,9 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 	allowedEmailSANs := d.Get("allowed_email_sans").([]string)
 	allowedURISANs := d.Get("allowed_uri_sans").([]string)
 	requiredExtensions := d.Get("required_extensions").([]string)
+	ocspEnabled := d.Get("ocsp_enabled").(bool)
+	ocspServersOverride := d.Get("ocsp_servers_override").([]string)
+	ocspFailOpen := d.Get("ocsp_fail_open").(bool)
 
 	var resp logical.Response
 
@@ -278,20 +304,23 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 	}
 
 	certEntry := &CertEntry{
-		Name:               name,
-		Certificate:        certificate,
-		DisplayName:        displayName,
-		Policies:           policies,
-		AllowedNames:       allowedNames,
-		AllowedCommonNames: allowedCommonNames,
-		AllowedDNSSANs:     allowedDNSSANs,
-		AllowedEmailSANs:   allowedEmailSANs,
-		AllowedURISANs:     allowedURISANs,
-		RequiredExtensions: requiredExtensions,
-		TTL:                ttl,
-		MaxTTL:             maxTTL,
-		Period:             period,
-		BoundCIDRs:         parsedCIDRs,
+		Name:                name,
+		Certificate:         certificate,
+		DisplayName:         displayName,
+		Policies:            policies,
+		AllowedNames:        allowedNames,
+		AllowedCommonNames:  allowedCommonNames,
+		AllowedDNSSANs:      allowedDNSSANs,
+		AllowedEmailSANs:    allowedEmailSANs,
+		AllowedURISANs:      allowedURISANs,
+		RequiredExtensions:  requiredExtensions,
+		TTL:                 ttl,
+		MaxTTL:              maxTTL,
+		Period:              period,
+		BoundCIDRs:          parsedCIDRs,
+		OCSPEnabled:         ocspEnabled,
+		OCSPServersOverride: ocspServersOverride,
+		OCSPFailOpen:        ocspFailOpen,
 	}
 
 	// Store it
@@ -311,20 +340,23 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 }
 
 type CertEntry struct {
-	Name               string
-	Certificate        string
-	DisplayName        string
-	Policies           []string
-	TTL                time.Duration
-	MaxTTL             time.Duration
-	Period             time.Duration
-	AllowedNames       []string
-	AllowedCommonNames []string
-	AllowedDNSSANs     []string
-	AllowedEmailSANs   []string
-	AllowedURISANs     []string
-	RequiredExtensions []string
-	BoundCIDRs         []*sockaddr.SockAddrMarshaler
+	Name                string
+	Certificate         string
+	DisplayName         string
+	Policies            []string
+	TTL                 time.Duration
+	MaxTTL              time.Duration
+	Period              time.Duration
+	AllowedNames        []string
+	AllowedCommonNames  []string
+	AllowedDNSSANs      []string
+	AllowedEmailSANs    []string
+	AllowedURISANs      []string
+	RequiredExtensions  []string
+	BoundCIDRs          []*sockaddr.SockAddrMarshaler
+	OCSPEnabled         bool
+	OCSPServersOverride []string
+	OCSPFailOpen        bool
 }
 
 const pathCertHelpSyn = `
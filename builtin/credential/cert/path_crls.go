@@ -3,9 +3,15 @@ package cert
 import (
 	"context"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/big"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/fatih/structs"
 	"github.com/hashicorp/errwrap"
@@ -30,6 +36,14 @@ May be DER or PEM encoded. Note: the expiration time
 is ignored; if the CRL is no longer valid, delete it
 using the same name as specified here.`,
 			},
+
+			"url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The URL to fetch the CRL from, such as a CRL
+distribution point of the CA. The CRL must be signed by a
+trusted certificate and is fetched again when it reaches its
+next update. Cannot be used with "crl".`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -190,13 +204,27 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		return logical.ErrorResponse(`"name" parameter cannot be empty`), nil
 	}
 	crl := d.Get("crl").(string)
+	crlURL := d.Get("url").(string)
 
-	certList, err := x509.ParseCRL([]byte(crl))
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("failed to parse CRL: %v", err)), nil
-	}
-	if certList == nil {
-		return logical.ErrorResponse("parsed CRL is nil"), nil
+	var crlInfo CRLInfo
+	switch {
+	case crl != "" && crlURL != "":
+		return logical.ErrorResponse(`only one of "crl" and "url" can be set`), nil
+	case crlURL != "":
+		var err error
+		crlInfo, err = b.fetchCRL(ctx, req.Storage, crlURL)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to fetch CRL: %v", err)), nil
+		}
+	default:
+		certList, err := x509.ParseCRL([]byte(crl))
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse CRL: %v", err)), nil
+		}
+		if certList == nil {
+			return logical.ErrorResponse("parsed CRL is nil"), nil
+		}
+		crlInfo = newCRLInfo(certList)
 	}
 
 	if err := b.populateCRLs(ctx, req.Storage); err != nil {
@@ -206,28 +234,137 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 	b.crlUpdateMutex.Lock()
 	defer b.crlUpdateMutex.Unlock()
 
+	if err := b.storeCRL(ctx, req.Storage, name, crlInfo); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// storeCRL persists the CRL and adds it to the in-memory set. The caller
+// must hold the write lock.
+func (b *backend) storeCRL(ctx context.Context, storage logical.Storage, name string, crlInfo CRLInfo) error {
+	entry, err := logical.StorageEntryJSON("crls/"+name, crlInfo)
+	if err != nil {
+		return err
+	}
+	if err = storage.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	b.crls[name] = crlInfo
+
+	return nil
+}
+
+func newCRLInfo(certList *pkix.CertificateList) CRLInfo {
 	crlInfo := CRLInfo{
-		Serials: map[string]RevokedSerialInfo{},
+		Serials:    map[string]RevokedSerialInfo{},
+		NextUpdate: certList.TBSCertList.NextUpdate,
 	}
 	for _, revokedCert := range certList.TBSCertList.RevokedCertificates {
 		crlInfo.Serials[revokedCert.SerialNumber.String()] = RevokedSerialInfo{}
 	}
+	return crlInfo
+}
 
-	entry, err := logical.StorageEntryJSON("crls/"+name, crlInfo)
+// fetchCRL downloads the CRL at the given URL and verifies that it is signed
+// by one of the trusted certificates
+func (b *backend) fetchCRL(ctx context.Context, storage logical.Storage, crlURL string) (CRLInfo, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, crlURL, nil)
 	if err != nil {
-		return nil, err
+		return CRLInfo{}, err
 	}
-	if err = req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
+	httpResp, err := b.httpClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return CRLInfo{}, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return CRLInfo{}, fmt.Errorf("unexpected status code %d", httpResp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxFetchedCRLSize))
+	if err != nil {
+		return CRLInfo{}, err
 	}
 
-	b.crls[name] = crlInfo
+	certList, err := x509.ParseCRL(body)
+	if err != nil {
+		return CRLInfo{}, errwrap.Wrapf("failed to parse CRL: {{err}}", err)
+	}
 
-	return nil, nil
+	_, trusted, trustedNonCAs := b.loadTrustedCerts(ctx, storage, "")
+	verified := false
+	for _, trust := range append(trusted, trustedNonCAs...) {
+		for _, cert := range trust.Certificates {
+			if cert.CheckCRLSignature(certList) == nil {
+				verified = true
+				break
+			}
+		}
+	}
+	if !verified {
+		return CRLInfo{}, errors.New("CRL is not signed by a trusted certificate")
+	}
+
+	crlInfo := newCRLInfo(certList)
+	crlInfo.URL = crlURL
+	if crlInfo.NextUpdate.IsZero() {
+		crlInfo.NextUpdate = time.Now().Add(defaultCRLRefreshInterval)
+	}
+	return crlInfo, nil
 }
 
+// refreshCRLs fetches the CRLs that were configured with a URL again once
+// they reach their next update. Failures are logged and the previous CRL
+// stays in effect.
+func (b *backend) refreshCRLs(ctx context.Context, storage logical.Storage) error {
+	if err := b.populateCRLs(ctx, storage); err != nil {
+		return err
+	}
+
+	b.crlUpdateMutex.RLock()
+	due := map[string]string{}
+	for name, crl := range b.crls {
+		if crl.URL != "" && time.Now().After(crl.NextUpdate) {
+			due[name] = crl.URL
+		}
+	}
+	b.crlUpdateMutex.RUnlock()
+
+	for name, crlURL := range due {
+		crlInfo, err := b.fetchCRL(ctx, storage, crlURL)
+		if err != nil {
+			b.Logger().Warn("failed to refresh CRL", "name", name, "url", crlURL, "error", err)
+			continue
+		}
+
+		b.crlUpdateMutex.Lock()
+		// Skip CRLs that were deleted or replaced while fetching
+		if current, ok := b.crls[name]; ok && current.URL == crlURL {
+			err = b.storeCRL(ctx, storage, name, crlInfo)
+		}
+		b.crlUpdateMutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const (
+	// defaultCRLRefreshInterval is how often fetched CRLs without a next
+	// update are fetched again
+	defaultCRLRefreshInterval = time.Hour
+
+	maxFetchedCRLSize = 32 * 1024 * 1024
+)
+
 type CRLInfo struct {
-	Serials map[string]RevokedSerialInfo `json:"serials" structs:"serials" mapstructure:"serials"`
+	Serials    map[string]RevokedSerialInfo `json:"serials" structs:"serials" mapstructure:"serials"`
+	URL        string                       `json:"url" structs:"url" mapstructure:"url"`
+	NextUpdate time.Time                    `json:"next_update" structs:"next_update,omitnested" mapstructure:"next_update"`
 }
 
 type RevokedSerialInfo struct {
@@ -249,4 +386,8 @@ in which none of the serials are revoked by any CRL -- allows authentication.
 This allows authentication to succeed when interim parts of one chain have been
 revoked; for instance, if a certificate is signed by two intermediate CAs due to
 one of them expiring.
+
+Instead of uploading a CRL, a URL such as a CRL distribution point of the CA
+can be given. Vault fetches the CRL from it, verifies that it is signed by a
+trusted certificate, and fetches it again when it reaches its next update.
 `
@@ -2,6 +2,7 @@ package github
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/google/go-github/github"
 	"github.com/hashicorp/go-cleanhttp"
@@ -43,16 +44,21 @@ func Backend() *backend {
 			Root: mfa.MFARootPaths(),
 			Unauthenticated: []string{
 				"login",
+				"device/code",
 			},
 		},
 
 		Paths: append([]*framework.Path{
 			pathConfig(&b),
+			pathDeviceCode(&b),
+			pathOrgTeamMap(&b),
 		}, append(allPaths, mfa.MFAPaths(b.Backend, pathLogin(&b))...)...),
 		AuthRenew:   b.pathLoginRenew,
 		BackendType: logical.TypeCredential,
 	}
 
+	b.httpClient = cleanhttp.DefaultPooledClient()
+
 	return &b
 }
 
@@ -62,6 +68,9 @@ type backend struct {
 	TeamMap *framework.PolicyMap
 
 	UserMap *framework.PolicyMap
+
+	// httpClient is used for the device flow
+	httpClient *http.Client
 }
 
 // Client returns the GitHub client to communicate to GitHub via the
@@ -88,10 +97,10 @@ func (t *tokenSource) Token() (*oauth2.Token, error) {
 const backendHelp = `
 The GitHub credential provider allows authentication via GitHub.
 
-Users provide a personal access token to log in, and the credential
-provider verifies they're part of the correct organization and then
-maps the user to a set of Vault policies according to the teams they're
-part of.
+Users provide a personal access token to log in, or log in through the
+OAuth device flow, and the credential provider verifies they're part of
+one of the configured organizations and then maps the user to a set of
+Vault policies according to the teams they're part of.
 
 After enabling the credential provider, use the "config" route to
 configure it.
@@ -3,7 +3,12 @@ package github
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -199,3 +204,271 @@ func testAccLogin(t *testing.T, policies []string) logicaltest.TestStep {
 		Check: logicaltest.TestCheckAuth(policies),
 	}
 }
+
+// testGitHub is a fake of the parts of the GitHub API used by the backend
+type testGitHub struct {
+	server *httptest.Server
+
+	// pendingPolls is the number of device flow token requests answered
+	// with authorization_pending
+	pendingPolls int
+}
+
+func newTestGitHub(t *testing.T) *testGitHub {
+	g := &testGitHub{pendingPolls: 1}
+	mux := http.NewServeMux()
+
+	// Pages are linked the same way GitHub does it
+	paged := func(w http.ResponseWriter, r *http.Request, pages []string) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		if page < len(pages) {
+			next := *r.URL
+			q := next.Query()
+			q.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, g.server.URL, next.RequestURI()))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pages[page-1])
+	}
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		switch r.Header.Get("Authorization") {
+		case "Bearer pattoken", "Bearer devicetoken":
+			return true
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "Bad credentials"}`)
+		return false
+	}
+
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			fmt.Fprint(w, `{"login": "octocat", "id": 1}`)
+		}
+	})
+	mux.HandleFunc("/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			paged(w, r, []string{
+				`[{"login": "other-org", "id": 10}]`,
+				`[{"login": "Org-A", "id": 20}, {"login": "org-b", "id": 30}]`,
+			})
+		}
+	})
+	mux.HandleFunc("/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			paged(w, r, []string{
+				`[{"name": "Admins", "slug": "admins", "organization": {"login": "other-org", "id": 10}}]`,
+				`[{"name": "Developers", "slug": "dev-team", "organization": {"login": "Org-A", "id": 20}}]`,
+				`[{"name": "ops", "slug": "ops", "organization": {"login": "org-b", "id": 30}}, {"name": "Admins", "slug": "admins", "organization": {"login": "org-b", "id": 30}}]`,
+			})
+		}
+	})
+
+	mux.HandleFunc("/login/device/code", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("client_id") != "testclient" || r.FormValue("scope") != deviceFlowScope {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"device_code": "devicecode", "user_code": "ABCD-1234", "verification_uri": "%s/login/device", "expires_in": 900, "interval": 5}`, g.server.URL)
+	})
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodPost || r.FormValue("client_id") != "testclient" || r.FormValue("grant_type") != deviceGrantType:
+			w.WriteHeader(http.StatusBadRequest)
+		case r.FormValue("device_code") != "devicecode":
+			fmt.Fprint(w, `{"error": "expired_token"}`)
+		case g.pendingPolls > 0:
+			g.pendingPolls--
+			fmt.Fprint(w, `{"error": "authorization_pending"}`)
+		default:
+			fmt.Fprint(w, `{"access_token": "devicetoken", "token_type": "bearer", "scope": "read:org"}`)
+		}
+	})
+
+	g.server = httptest.NewServer(mux)
+	return g
+}
+
+func TestBackend_testGitHub(t *testing.T) {
+	g := newTestGitHub(t)
+	defer g.server.Close()
+
+	storage := &logical.InmemStorage{}
+	b, err := Factory(context.Background(), &logical.BackendConfig{
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour,
+			MaxLeaseTTLVal:     2 * time.Hour,
+		},
+		StorageView: storage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+			Storage:   storage,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return resp
+	}
+	expectError := func(resp *logical.Response, contains string) {
+		t.Helper()
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response, got %#v", resp)
+		}
+		if msg := resp.Data["error"].(string); !strings.Contains(msg, contains) {
+			t.Fatalf("expected error containing %q, got %q", contains, msg)
+		}
+	}
+	expectLogin := func(resp *logical.Response, policies []string, orgs string) {
+		t.Helper()
+		if resp == nil || resp.IsError() || resp.Auth == nil {
+			t.Fatalf("expected successful login, got %#v", resp)
+		}
+		sort.Strings(resp.Auth.Policies)
+		if !reflect.DeepEqual(resp.Auth.Policies, policies) {
+			t.Fatalf("expected policies %v, got %v", policies, resp.Auth.Policies)
+		}
+		if resp.Auth.Metadata["org"] != orgs {
+			t.Fatalf("expected orgs %q, got %q", orgs, resp.Auth.Metadata["org"])
+		}
+	}
+
+	for team, policy := range map[string]string{
+		"admins":     "adminpol",
+		"developers": "namepol",
+		"dev-team":   "slugpol",
+		"ops":        "opspol",
+	} {
+		request("map/teams/"+team, map[string]interface{}{"value": policy})
+	}
+
+	// Both the deprecated and the new field are accepted, and the base URL
+	// does not need a trailing slash
+	request("config", map[string]interface{}{
+		"organization":  "org-a",
+		"organizations": "ORG-B",
+		"base_url":      g.server.URL,
+	})
+	resp := request("login", map[string]interface{}{"token": "pattoken"})
+	expectLogin(resp, []string{"adminpol", "namepol", "opspol", "slugpol"}, "Org-A,org-b")
+	if !reflect.DeepEqual(resp.Auth.GroupAliases, []*logical.Alias{{Name: "Admins"}, {Name: "Developers"}, {Name: "admins"}, {Name: "dev-team"}, {Name: "ops"}}) {
+		t.Fatalf("unexpected group aliases %#v", resp.Auth.GroupAliases)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Storage:   storage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Data["organizations"], []string{"org-a", "org-b"}) || resp.Data["team_mapping"] != teamMappingNameAndSlug {
+		t.Fatalf("unexpected config %#v", resp.Data)
+	}
+
+	// Team names are ignored when mapping by slug
+	request("config", map[string]interface{}{
+		"organizations": "org-a,org-b",
+		"team_mapping":  "slug",
+		"base_url":      g.server.URL,
+	})
+	expectLogin(request("login", map[string]interface{}{"token": "pattoken"}), []string{"adminpol", "opspol", "slugpol"}, "Org-A,org-b")
+
+	// Team names are not unique across organizations: "admins" was mapped
+	// for another organization, yet applies to the admins team of org-b
+	// until that team has a mapping qualified with its organization
+	request("map/teams/org-b/admins", map[string]interface{}{"value": "orgbadminpol"})
+	resp = request("login", map[string]interface{}{"token": "pattoken"})
+	expectLogin(resp, []string{"opspol", "orgbadminpol", "slugpol"}, "Org-A,org-b")
+	if !reflect.DeepEqual(resp.Auth.GroupAliases, []*logical.Alias{{Name: "admins"}, {Name: "dev-team"}, {Name: "ops"}}) {
+		t.Fatalf("unexpected group aliases %#v", resp.Auth.GroupAliases)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "map/teams/org-b/admins",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.Data["value"] != "orgbadminpol" {
+		t.Fatalf("unexpected mapping: err: %v, resp: %#v", err, resp)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "map/teams/org-b/admins",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("failed to delete mapping: err: %v, resp: %#v", err, resp)
+	}
+	expectLogin(request("login", map[string]interface{}{"token": "pattoken"}), []string{"adminpol", "opspol", "slugpol"}, "Org-A,org-b")
+
+	expectError(request("config", map[string]interface{}{
+		"organizations": "org-a",
+		"team_mapping":  "name",
+	}), "invalid team_mapping")
+
+	request("config", map[string]interface{}{
+		"organizations": "org-c",
+		"base_url":      g.server.URL,
+	})
+	expectError(request("login", map[string]interface{}{"token": "pattoken"}), "not part of required org")
+
+	// Configurations of earlier versions only have a single organization
+	entry, err := logical.StorageEntryJSON("config", map[string]interface{}{
+		"organization": "org-b",
+		"base_url":     g.server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	expectLogin(request("login", map[string]interface{}{"token": "pattoken"}), []string{"adminpol", "opspol"}, "org-b")
+
+	// The organization of earlier configurations is compared in lowercase
+	entry, err = logical.StorageEntryJSON("config", map[string]interface{}{
+		"organization": "Org-A",
+		"base_url":     g.server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	expectLogin(request("login", map[string]interface{}{"token": "pattoken"}), []string{"namepol", "slugpol"}, "Org-A")
+
+	// Device flow
+	expectError(request("device/code", nil), "require a client_id")
+	request("config", map[string]interface{}{
+		"organizations": "org-a",
+		"base_url":      g.server.URL,
+		"client_id":     "testclient",
+		"web_base_url":  g.server.URL,
+	})
+	resp = request("device/code", nil)
+	if resp.IsError() || resp.Data["device_code"] != "devicecode" || resp.Data["user_code"] != "ABCD-1234" || resp.Data["interval"] != 5 {
+		t.Fatalf("unexpected device code response %#v", resp)
+	}
+
+	expectError(request("login", map[string]interface{}{"token": "pattoken", "device_code": "devicecode"}), "cannot both be set")
+	expectError(request("login", map[string]interface{}{"device_code": "other"}), "expired_token")
+	expectError(request("login", map[string]interface{}{"device_code": "devicecode"}), errAuthorizationPending)
+	resp = request("login", map[string]interface{}{"device_code": "devicecode"})
+	expectLogin(resp, []string{"namepol", "slugpol"}, "Org-A")
+	if resp.Auth.InternalData["token"] != "devicetoken" {
+		t.Fatalf("expected the device flow token to be kept for renewals, got %#v", resp.Auth.InternalData)
+	}
+}
@@ -1,16 +1,30 @@
 package github
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/password"
 )
 
+const (
+	// Used when the credential provider does not return the values
+	defaultPollInterval  = 5 * time.Second
+	defaultDeviceCodeTTL = 15 * time.Minute
+
+	// slowDownIncrement is added to the poll interval when GitHub asks for
+	// slower polling
+	slowDownIncrement = 5 * time.Second
+)
+
 type CLIHandler struct {
 	// for tests
 	testStdout io.Writer
@@ -22,18 +36,28 @@ func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, erro
 		mount = "github"
 	}
 
+	// Override the output
+	stdout := h.testStdout
+	if stdout == nil {
+		stdout = os.Stderr
+	}
+
+	if v, ok := m["device_flow"]; ok {
+		deviceFlow, err := parseutil.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse device_flow: %v", err)
+		}
+		if deviceFlow {
+			return h.deviceFlowAuth(c, mount, stdout)
+		}
+	}
+
 	// Extract or prompt for token
 	token := m["token"]
 	if token == "" {
 		token = os.Getenv("VAULT_AUTH_GITHUB_TOKEN")
 	}
 	if token == "" {
-		// Override the output
-		stdout := h.testStdout
-		if stdout == nil {
-			stdout = os.Stderr
-		}
-
 		var err error
 		fmt.Fprintf(stdout, "GitHub Personal Access Token (will be hidden): ")
 		token, err = password.Read(os.Stdin)
@@ -66,6 +90,77 @@ func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, erro
 	return secret, nil
 }
 
+// deviceFlowAuth logs in through the device flow. The user authorizes the
+// login at GitHub while the CLI polls Vault with the device code.
+func (h *CLIHandler) deviceFlowAuth(c *api.Client, mount string, stdout io.Writer) (*api.Secret, error) {
+	secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/device/code", mount), nil)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("empty response from credential provider")
+	}
+	deviceCode, _ := secret.Data["device_code"].(string)
+	userCode, _ := secret.Data["user_code"].(string)
+	verificationURI, _ := secret.Data["verification_uri"].(string)
+	if deviceCode == "" || userCode == "" {
+		return nil, fmt.Errorf("credential provider did not return a device code")
+	}
+	interval := durationField(secret.Data["interval"], defaultPollInterval)
+	expiresIn := durationField(secret.Data["expires_in"], defaultDeviceCodeTTL)
+
+	fmt.Fprintf(stdout, "To complete the login, open the following URL in a browser:\n\n    %s\n\nand enter the code: %s\n\n", verificationURI, userCode)
+	fmt.Fprintf(stdout, "Waiting for authorization...\n")
+
+	sigintCh := make(chan os.Signal, 1)
+	signal.Notify(sigintCh, os.Interrupt)
+	defer signal.Stop(sigintCh)
+
+	deadline := time.After(expiresIn)
+	path := fmt.Sprintf("auth/%s/login", mount)
+	for {
+		select {
+		case <-time.After(interval):
+		case <-sigintCh:
+			return nil, fmt.Errorf("user interrupted")
+		case <-deadline:
+			return nil, fmt.Errorf("timed out waiting for the device authorization")
+		}
+
+		secret, err := c.Logical().Write(path, map[string]interface{}{
+			"device_code": deviceCode,
+		})
+		switch {
+		case err == nil && secret == nil:
+			return nil, fmt.Errorf("empty response from credential provider")
+		case err == nil:
+			return secret, nil
+		case strings.Contains(err.Error(), errSlowDown):
+			interval += slowDownIncrement
+		case !strings.Contains(err.Error(), errAuthorizationPending):
+			return nil, err
+		}
+	}
+}
+
+// durationField converts a number of seconds returned by the credential
+// provider to a duration
+func durationField(raw interface{}, defaultValue time.Duration) time.Duration {
+	var seconds int64
+	switch v := raw.(type) {
+	case json.Number:
+		seconds, _ = v.Int64()
+	case float64:
+		seconds = int64(v)
+	case int:
+		seconds = int64(v)
+	}
+	if seconds <= 0 {
+		return defaultValue
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 func (h *CLIHandler) Help() string {
 	help := `
 Usage: vault login -method=github [CONFIG K=V...]
@@ -78,6 +173,11 @@ Usage: vault login -method=github [CONFIG K=V...]
 
       $ vault login -method=github token=abcd1234
 
+  Alternatively, users can authorize the login at GitHub through the device
+  flow, if the auth method is configured with a client ID:
+
+      $ vault login -method=github device_flow=true
+
 Configuration:
 
   mount=<string>
@@ -89,6 +189,11 @@ Configuration:
   token=<string>
       GitHub personal access token to use for authentication. If not provided,
       Vault will prompt for the value.
+
+  device_flow=<bool>
+      Log in through the device flow instead of with a personal access token.
+      The CLI prints a URL and a code to enter there, and waits until the
+      login is authorized. The default value is false.
 `
 
 	return strings.TrimSpace(help)
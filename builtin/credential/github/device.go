@@ -0,0 +1,141 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+)
+
+const (
+	defaultWebBaseURL = "https://github.com"
+
+	// deviceFlowScope grants access to the organization and team
+	// memberships of the user
+	deviceFlowScope = "read:org"
+
+	deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	maxDeviceFlowResponseSize = 64 * 1024
+)
+
+// Errors returned by GitHub while the user has not completed the device
+// authorization. The CLI keeps polling when it sees them.
+const (
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+)
+
+// deviceCode is the response of GitHub to a device authorization request
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// deviceTokenResponse is the response of GitHub to an access token request
+// of the device flow. Pending authorizations are reported as errors.
+type deviceTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// deviceFlowError is an error of the device flow reported by GitHub
+type deviceFlowError struct {
+	Code        string
+	Description string
+}
+
+func (e *deviceFlowError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// Pending returns whether the user has not completed the device
+// authorization yet
+func (e *deviceFlowError) Pending() bool {
+	return e.Code == errAuthorizationPending || e.Code == errSlowDown
+}
+
+// requestDeviceCode starts a device flow login for the OAuth or GitHub App
+// of the configuration
+func (b *backend) requestDeviceCode(ctx context.Context, config *config) (*deviceCode, error) {
+	var result deviceCode
+	if err := b.postDeviceFlow(ctx, config, "login/device/code", url.Values{
+		"client_id": {config.ClientID},
+		"scope":     {deviceFlowScope},
+	}, &result); err != nil {
+		return nil, err
+	}
+	if result.DeviceCode == "" || result.UserCode == "" {
+		return nil, fmt.Errorf("device code missing from response")
+	}
+	return &result, nil
+}
+
+// exchangeDeviceCode returns the access token of a device flow login. A
+// *deviceFlowError is returned while the authorization is pending.
+func (b *backend) exchangeDeviceCode(ctx context.Context, config *config, code string) (string, error) {
+	var result deviceTokenResponse
+	if err := b.postDeviceFlow(ctx, config, "login/oauth/access_token", url.Values{
+		"client_id":   {config.ClientID},
+		"device_code": {code},
+		"grant_type":  {deviceGrantType},
+	}, &result); err != nil {
+		return "", err
+	}
+	if result.Error != "" {
+		return "", &deviceFlowError{
+			Code:        result.Error,
+			Description: result.ErrorDescription,
+		}
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("access token missing from response")
+	}
+	return result.AccessToken, nil
+}
+
+func (b *backend) postDeviceFlow(ctx context.Context, config *config, path string, form url.Values, result interface{}) error {
+	webBaseURL := config.WebBaseURL
+	if webBaseURL == "" {
+		webBaseURL = defaultWebBaseURL
+	}
+	endpoint := strings.TrimSuffix(webBaseURL, "/") + "/" + path
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDeviceFlowResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, endpoint)
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return errwrap.Wrapf("failed to decode device flow response: {{err}}", err)
+	}
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -17,7 +19,32 @@ func pathConfig(b *backend) *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"organization": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "The organization users must be part of",
+				Description: `The organization users must be part of. Deprecated in favor of "organizations".`,
+			},
+
+			"organizations": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma-separated list of organizations; users must be part of at least one of them",
+			},
+
+			"team_mapping": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: teamMappingNameAndSlug,
+				Description: `How teams are mapped to policies and group aliases. With
+"name_and_slug" both the name and the slug of a team are
+used; with "slug" only the slug is used.`,
+			},
+
+			"client_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Client ID of the OAuth or GitHub App used for device flow logins",
+			},
+
+			"web_base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The web endpoint used for device flow logins. Defaults
+to "https://github.com"; set it when running GitHub
+Enterprise.`,
 			},
 
 			"base_url": &framework.FieldSchema{
@@ -44,7 +71,19 @@ API-compatible authentication server.`,
 }
 
 func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	organization := data.Get("organization").(string)
+	var organizations []string
+	if organization := data.Get("organization").(string); organization != "" {
+		organizations = append(organizations, organization)
+	}
+	organizations = strutil.RemoveDuplicates(append(organizations, data.Get("organizations").([]string)...), true)
+
+	teamMapping := data.Get("team_mapping").(string)
+	switch teamMapping {
+	case teamMappingNameAndSlug, teamMappingSlug:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid team_mapping %q, must be %q or %q", teamMapping, teamMappingNameAndSlug, teamMappingSlug)), nil
+	}
+
 	baseURL := data.Get("base_url").(string)
 	if len(baseURL) != 0 {
 		_, err := url.Parse(baseURL)
@@ -52,6 +91,13 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 			return logical.ErrorResponse(fmt.Sprintf("Error parsing given base_url: %s", err)), nil
 		}
 	}
+	webBaseURL := data.Get("web_base_url").(string)
+	if len(webBaseURL) != 0 {
+		_, err := url.Parse(webBaseURL)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("Error parsing given web_base_url: %s", err)), nil
+		}
+	}
 
 	var ttl time.Duration
 	var err error
@@ -77,10 +123,13 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 	}
 
 	entry, err := logical.StorageEntryJSON("config", config{
-		Organization: organization,
-		BaseURL:      baseURL,
-		TTL:          ttl,
-		MaxTTL:       maxTTL,
+		Organizations: organizations,
+		TeamMapping:   teamMapping,
+		BaseURL:       baseURL,
+		ClientID:      data.Get("client_id").(string),
+		WebBaseURL:    webBaseURL,
+		TTL:           ttl,
+		MaxTTL:        maxTTL,
 	})
 
 	if err != nil {
@@ -109,10 +158,14 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"organization": config.Organization,
-			"base_url":     config.BaseURL,
-			"ttl":          config.TTL,
-			"max_ttl":      config.MaxTTL,
+			"organization":  config.Organization,
+			"organizations": config.Organizations,
+			"team_mapping":  config.TeamMapping,
+			"base_url":      config.BaseURL,
+			"client_id":     config.ClientID,
+			"web_base_url":  config.WebBaseURL,
+			"ttl":           config.TTL,
+			"max_ttl":       config.MaxTTL,
 		},
 	}
 	return resp, nil
@@ -132,12 +185,34 @@ func (b *backend) Config(ctx context.Context, s logical.Storage) (*config, error
 		}
 	}
 
+	// Configurations written before multiple organizations were supported
+	// only have a single organization, which is compared in lowercase like
+	// the ones written since
+	if len(result.Organizations) == 0 && result.Organization != "" {
+		result.Organizations = []string{strings.ToLower(result.Organization)}
+	}
+	if len(result.Organizations) > 0 {
+		result.Organization = result.Organizations[0]
+	}
+	if result.TeamMapping == "" {
+		result.TeamMapping = teamMappingNameAndSlug
+	}
+
 	return &result, nil
 }
 
+const (
+	teamMappingNameAndSlug = "name_and_slug"
+	teamMappingSlug        = "slug"
+)
+
 type config struct {
-	Organization string        `json:"organization" structs:"organization" mapstructure:"organization"`
-	BaseURL      string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
-	TTL          time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
-	MaxTTL       time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
+	Organization  string        `json:"organization,omitempty" structs:"organization" mapstructure:"organization"`
+	Organizations []string      `json:"organizations" structs:"organizations" mapstructure:"organizations"`
+	TeamMapping   string        `json:"team_mapping" structs:"team_mapping" mapstructure:"team_mapping"`
+	BaseURL       string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
+	ClientID      string        `json:"client_id" structs:"client_id" mapstructure:"client_id"`
+	WebBaseURL    string        `json:"web_base_url" structs:"web_base_url" mapstructure:"web_base_url"`
+	TTL           time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
+	MaxTTL        time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
 }
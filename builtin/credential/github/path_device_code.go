@@ -0,0 +1,63 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathDeviceCode(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "device/code$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDeviceCode,
+		},
+
+		HelpSynopsis:    pathDeviceCodeHelpSyn,
+		HelpDescription: pathDeviceCodeHelpDesc,
+	}
+}
+
+func (b *backend) pathDeviceCode(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.Config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Organizations) == 0 {
+		return logical.ErrorResponse(
+			"configure the github credential backend first"), nil
+	}
+	if config.ClientID == "" {
+		return logical.ErrorResponse("device flow logins require a client_id in the configuration"), nil
+	}
+
+	code, err := b.requestDeviceCode(ctx, config)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to start device flow: %v", err)), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"device_code":      code.DeviceCode,
+			"user_code":        code.UserCode,
+			"verification_uri": code.VerificationURI,
+			"expires_in":       code.ExpiresIn,
+			"interval":         code.Interval,
+		},
+	}, nil
+}
+
+const pathDeviceCodeHelpSyn = `
+Start a device flow login.
+`
+
+const pathDeviceCodeHelpDesc = `
+This endpoint starts a login through the OAuth device flow of GitHub. The
+user enters the returned user code at the verification URI, after which the
+returned device code can be passed to "login" in place of a personal access
+token. Until the user has done so, logins with the device code fail with
+"authorization_pending" and should be retried after the returned interval.
+`
@@ -9,6 +9,7 @@ import (
 	"github.com/google/go-github/github"
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -21,6 +22,11 @@ func pathLogin(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "GitHub personal API token",
 			},
+
+			"device_code": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Device code returned by "device/code", used in place of a token`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -31,6 +37,11 @@ func pathLogin(b *backend) *framework.Path {
 }
 
 func (b *backend) pathLoginAliasLookahead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Device codes can only be exchanged once, so they are left for the
+	// login itself
+	if data.Get("device_code").(string) != "" {
+		return logical.ErrorResponse("alias lookahead is not supported for device flow logins"), nil
+	}
 	token := data.Get("token").(string)
 
 	var verifyResp *verifyCredentialsResp
@@ -52,7 +63,10 @@ func (b *backend) pathLoginAliasLookahead(ctx context.Context, req *logical.Requ
 }
 
 func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	token := data.Get("token").(string)
+	token, resp, err := b.loginToken(ctx, req, data)
+	if err != nil || resp != nil {
+		return resp, err
+	}
 
 	var verifyResp *verifyCredentialsResp
 	if verifyResponse, resp, err := b.verifyCredentials(ctx, req, token); err != nil {
@@ -68,7 +82,7 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 		return nil, err
 	}
 
-	resp := &logical.Response{
+	resp = &logical.Response{
 		Auth: &logical.Auth{
 			InternalData: map[string]interface{}{
 				"token": token,
@@ -76,7 +90,7 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 			Policies: verifyResp.Policies,
 			Metadata: map[string]string{
 				"username": *verifyResp.User.Login,
-				"org":      *verifyResp.Org.Login,
+				"org":      strings.Join(verifyResp.OrgNames, ","),
 			},
 			DisplayName: *verifyResp.User.Login,
 			LeaseOptions: logical.LeaseOptions{
@@ -102,6 +116,37 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 	return resp, nil
 }
 
+// loginToken returns the token to log in with, which is either given
+// directly or obtained from GitHub for a completed device flow
+func (b *backend) loginToken(ctx context.Context, req *logical.Request, data *framework.FieldData) (string, *logical.Response, error) {
+	token := data.Get("token").(string)
+	deviceCode := data.Get("device_code").(string)
+	if deviceCode == "" {
+		return token, nil, nil
+	}
+	if token != "" {
+		return "", logical.ErrorResponse(`"token" and "device_code" cannot both be set`), nil
+	}
+
+	config, err := b.Config(ctx, req.Storage)
+	if err != nil {
+		return "", nil, err
+	}
+	if config.ClientID == "" {
+		return "", logical.ErrorResponse("device flow logins require a client_id in the configuration"), nil
+	}
+
+	token, err = b.exchangeDeviceCode(ctx, config, deviceCode)
+	switch err.(type) {
+	case nil:
+		return token, nil, nil
+	case *deviceFlowError:
+		return "", logical.ErrorResponse(err.Error()), nil
+	default:
+		return "", logical.ErrorResponse(fmt.Sprintf("failed to complete device flow: %v", err)), nil
+	}
+}
+
 func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	if req.Auth == nil {
 		return nil, fmt.Errorf("request auth was nil")
@@ -151,7 +196,7 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 	if err != nil {
 		return nil, nil, err
 	}
-	if config.Organization == "" {
+	if len(config.Organizations) == 0 {
 		return nil, logical.ErrorResponse(
 			"configure the github credential backend first"), nil
 	}
@@ -166,6 +211,10 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		if err != nil {
 			return nil, nil, errwrap.Wrapf("successfully parsed base_url when set but failing to parse now: {{err}}", err)
 		}
+		// The client resolves API paths relative to the base URL
+		if !strings.HasSuffix(parsedURL.Path, "/") {
+			parsedURL.Path += "/"
+		}
 		client.BaseURL = parsedURL
 	}
 
@@ -175,9 +224,7 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		return nil, nil, err
 	}
 
-	// Verify that the user is part of the organization
-	var org *github.Organization
-
+	// Verify that the user is part of at least one of the organizations
 	orgOpt := &github.ListOptions{
 		PerPage: 100,
 	}
@@ -195,18 +242,23 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		orgOpt.Page = resp.NextPage
 	}
 
+	var orgNames []string
+	orgIDs := make(map[int64]bool)
 	for _, o := range allOrgs {
-		if strings.ToLower(*o.Login) == strings.ToLower(config.Organization) {
-			org = o
-			break
+		if o.ID == nil || o.Login == nil {
+			continue
+		}
+		if strutil.StrListContains(config.Organizations, strings.ToLower(*o.Login)) {
+			orgNames = append(orgNames, *o.Login)
+			orgIDs[*o.ID] = true
 		}
 	}
-	if org == nil {
+	if len(orgNames) == 0 {
 		return nil, logical.ErrorResponse("user is not part of required org"), nil
 	}
 
 	// Get the teams that this user is part of to determine the policies
-	var teamNames []string
+	var teamNames, teamKeys []string
 
 	teamOpt := &github.ListOptions{
 		PerPage: 100,
@@ -226,19 +278,41 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 	}
 
 	for _, t := range allTeams {
-		// We only care about teams that are part of the organization we use
-		if *t.Organization.ID != *org.ID {
+		// We only care about teams that are part of the organizations we use
+		if t.Organization == nil || t.Organization.ID == nil || !orgIDs[*t.Organization.ID] {
 			continue
 		}
 
 		// Append the names so we can get the policies
-		teamNames = append(teamNames, *t.Name)
-		if *t.Name != *t.Slug {
-			teamNames = append(teamNames, *t.Slug)
+		var names []string
+		if t.Slug != nil {
+			names = append(names, *t.Slug)
+		}
+		if config.TeamMapping != teamMappingSlug && t.Name != nil {
+			names = append(names, *t.Name)
+		}
+		teamNames = append(teamNames, names...)
+
+		// Team names are only unique within an organization, so mappings
+		// qualified with the organization of the team are preferred
+		for _, name := range names {
+			if t.Organization.Login != nil {
+				key := *t.Organization.Login + "/" + name
+				mapping, err := b.TeamMap.Get(ctx, req.Storage, key)
+				if err != nil {
+					return nil, nil, err
+				}
+				if mapping != nil {
+					teamKeys = append(teamKeys, key)
+					continue
+				}
+			}
+			teamKeys = append(teamKeys, name)
 		}
 	}
+	teamNames = strutil.RemoveDuplicates(teamNames, false)
 
-	groupPoliciesList, err := b.TeamMap.Policies(ctx, req.Storage, teamNames...)
+	groupPoliciesList, err := b.TeamMap.Policies(ctx, req.Storage, teamKeys...)
 
 	if err != nil {
 		return nil, nil, err
@@ -252,7 +326,7 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 
 	return &verifyCredentialsResp{
 		User:      user,
-		Org:       org,
+		OrgNames:  orgNames,
 		Policies:  append(groupPoliciesList, userPoliciesList...),
 		TeamNames: teamNames,
 	}, nil, nil
@@ -260,7 +334,7 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 
 type verifyCredentialsResp struct {
 	User      *github.User
-	Org       *github.Organization
+	OrgNames  []string
 	Policies  []string
 	TeamNames []string
 }
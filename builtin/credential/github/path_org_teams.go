@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// pathOrgTeamMap maps the teams of a single organization to policies. The
+// mappings are stored in the team map under "<org>/<team>", and are preferred
+// over the mapping of the team name alone.
+func pathOrgTeamMap(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `map/teams/(?P<org>[-\w]+)/(?P<key>[-\w]+)`,
+
+		Fields: map[string]*framework.FieldSchema{
+			"org": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Organization of the team",
+			},
+			"key": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name or slug of the team",
+			},
+			"value": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Comma-separated list of policies for the team",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.CreateOperation: b.pathOrgTeamMapWrite,
+			logical.ReadOperation:   b.pathOrgTeamMapRead,
+			logical.UpdateOperation: b.pathOrgTeamMapWrite,
+			logical.DeleteOperation: b.pathOrgTeamMapDelete,
+		},
+
+		ExistenceCheck: b.pathOrgTeamMapExistenceCheck,
+
+		HelpSynopsis:    pathOrgTeamMapHelpSyn,
+		HelpDescription: pathOrgTeamMapHelpDesc,
+	}
+}
+
+func orgTeamMapKey(data *framework.FieldData) string {
+	return data.Get("org").(string) + "/" + data.Get("key").(string)
+}
+
+func (b *backend) pathOrgTeamMapRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	v, err := b.TeamMap.Get(ctx, req.Storage, orgTeamMapKey(data))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: v,
+	}, nil
+}
+
+func (b *backend) pathOrgTeamMapWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return nil, b.TeamMap.Put(ctx, req.Storage, orgTeamMapKey(data), data.Raw)
+}
+
+func (b *backend) pathOrgTeamMapDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return nil, b.TeamMap.Delete(ctx, req.Storage, orgTeamMapKey(data))
+}
+
+func (b *backend) pathOrgTeamMapExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	v, err := b.TeamMap.Get(ctx, req.Storage, orgTeamMapKey(data))
+	if err != nil {
+		return false, err
+	}
+	return v != nil, nil
+}
+
+const pathOrgTeamMapHelpSyn = `
+Read/write/delete the policies of a team of an organization.
+`
+
+const pathOrgTeamMapHelpDesc = `
+Team names are only unique within an organization. When several organizations
+are configured, a team is mapped to policies under "map/teams/<org>/<team>";
+this mapping is used in place of "map/teams/<team>", which applies to teams
+of that name in every configured organization.
+`
@@ -475,6 +475,15 @@ func TestBackend_configDefaultsAfterUpdate(t *testing.T) {
 						t.Errorf("Default mismatch: deny_null_bind. Expected: '%t', received :'%s'", defaultDenyNullBind, cfg["deny_null_bind"])
 					}
 
+					defaultRequestTimeout := 90
+					if cfg["request_timeout"] != defaultRequestTimeout {
+						t.Errorf("Default mismatch: request_timeout. Expected: '%d', received :'%v'", defaultRequestTimeout, cfg["request_timeout"])
+					}
+
+					if cfg["nested_groups"] != false || cfg["page_size"] != 0 {
+						t.Errorf("Default mismatch: nested_groups and page_size should be disabled, received: '%v' and '%v'", cfg["nested_groups"], cfg["page_size"])
+					}
+
 					return nil
 				},
 			},
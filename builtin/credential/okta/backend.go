@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/vault/helper/mfa"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -49,14 +50,27 @@ func Backend() *backend {
 		BackendType: logical.TypeCredential,
 	}
 
+	b.newClient = (*ConfigEntry).OktaClient
+	b.challenges = cache.New(challengeTTL, time.Minute)
+
 	return &b
 }
 
 type backend struct {
 	*framework.Backend
+
+	// newClient returns the Okta client for a configuration
+	newClient func(*ConfigEntry) *okta.Client
+
+	// challenges holds the logins waiting for the passcode that Okta sent
+	// by SMS or call, by challenge ID
+	challenges *cache.Cache
 }
 
-func (b *backend) Login(ctx context.Context, req *logical.Request, username string, password string) ([]string, *logical.Response, []string, error) {
+// Login authenticates the user with Okta and returns their policies and
+// groups. If factor is nil, as it is for renewals, the password is checked
+// but Okta's MFA requirement is considered met without verifying a factor.
+func (b *backend) Login(ctx context.Context, req *logical.Request, username string, password string, factor *factorRequest) ([]string, *logical.Response, []string, error) {
 	cfg, err := b.Config(ctx, req.Storage)
 	if err != nil {
 		return nil, nil, nil, err
@@ -65,41 +79,30 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		return nil, logical.ErrorResponse("Okta auth method not configured"), nil, nil
 	}
 
-	client := cfg.OktaClient()
-
-	type mfaFactor struct {
-		Id       string `json:"id"`
-		Type     string `json:"factorType"`
-		Provider string `json:"provider"`
-	}
-
-	type embeddedResult struct {
-		User    okta.User   `json:"user"`
-		Factors []mfaFactor `json:"factors"`
-	}
-
-	type authResult struct {
-		Embedded     embeddedResult `json:"_embedded"`
-		Status       string         `json:"status"`
-		FactorResult string         `json:"factorResult"`
-		StateToken   string         `json:"stateToken"`
-	}
-
-	authReq, err := client.NewRequest("POST", "authn", map[string]interface{}{
-		"username": username,
-		"password": password,
-	})
-	if err != nil {
-		return nil, nil, nil, err
-	}
+	client := b.newClient(cfg)
 
 	var result authResult
-	rsp, err := client.Do(authReq, &result)
-	if err != nil {
-		return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed: %v", err)), nil, nil
-	}
-	if rsp == nil {
-		return nil, logical.ErrorResponse("okta auth method unexpected failure"), nil, nil
+	if factor != nil && factor.challenge != nil {
+		// The password was checked by the login that sent the challenge
+		if resp, err := b.completeChallenge(client, &result, factor); resp != nil || err != nil {
+			return nil, resp, nil, err
+		}
+	} else {
+		authReq, err := client.NewRequest("POST", "authn", map[string]interface{}{
+			"username": username,
+			"password": password,
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		rsp, err := client.Do(authReq, &result)
+		if err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed: %v", err)), nil, nil
+		}
+		if rsp == nil {
+			return nil, logical.ErrorResponse("okta auth method unexpected failure"), nil, nil
+		}
 	}
 
 	oktaResponse := &logical.Response{
@@ -142,72 +145,14 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		// active factor enrollment). This bypass removes visibility
 		// into the authenticating user's password expiry, but still ensures the
 		// credentials are valid and the user is not locked out.
-		if cfg.BypassOktaMFA {
+		if cfg.BypassOktaMFA || factor == nil {
 			result.Status = "SUCCESS"
 			break
 		}
 
-		factorAvailable := false
-
-		var selectedFactor mfaFactor
-		// only okta push is currently supported
-		for _, v := range result.Embedded.Factors {
-			if v.Type == "push" && v.Provider == "OKTA" {
-				factorAvailable = true
-				selectedFactor = v
-			}
-		}
-
-		if !factorAvailable {
-			return nil, logical.ErrorResponse("Okta Verify Push factor is required in order to perform MFA"), nil, nil
-		}
-
-		requestPath := fmt.Sprintf("authn/factors/%s/verify", selectedFactor.Id)
-		payload := map[string]interface{}{
-			"stateToken": result.StateToken,
-		}
-		verifyReq, err := client.NewRequest("POST", requestPath, payload)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-
-		rsp, err := client.Do(verifyReq, &result)
-		if err != nil {
-			return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed: %v", err)), nil, nil
-		}
-		if rsp == nil {
-			return nil, logical.ErrorResponse("okta auth backend unexpected failure"), nil, nil
-		}
-		for result.Status == "MFA_CHALLENGE" {
-			switch result.FactorResult {
-			case "WAITING":
-				verifyReq, err := client.NewRequest("POST", requestPath, payload)
-				rsp, err := client.Do(verifyReq, &result)
-				if err != nil {
-					return nil, logical.ErrorResponse(fmt.Sprintf("Okta auth failed checking loop: %v", err)), nil, nil
-				}
-				if rsp == nil {
-					return nil, logical.ErrorResponse("okta auth backend unexpected failure"), nil, nil
-				}
-
-				select {
-				case <-time.After(500 * time.Millisecond):
-					// Continue
-				case <-ctx.Done():
-					return nil, logical.ErrorResponse("exiting pending mfa challenge"), nil, nil
-				}
-			case "REJECTED":
-				return nil, logical.ErrorResponse("multi-factor authentication denied"), nil, nil
-			case "TIMEOUT":
-				return nil, logical.ErrorResponse("failed to complete multi-factor authentication"), nil, nil
-			case "SUCCESS":
-				// Allowed
-			default:
-				if b.Logger().IsDebug() {
-					b.Logger().Debug("unhandled result status", "status", result.Status, "factorstatus", result.FactorResult)
-				}
-				return nil, logical.ErrorResponse("okta authentication failed"), nil, nil
-			}
+		resp, err := b.verifyFactor(ctx, client, username, password, &result, factor)
+		if resp != nil || err != nil {
+			return nil, resp, nil, err
 		}
 
 	case "SUCCESS":
@@ -311,5 +256,6 @@ configured groups are pulled down from Okta.
 
 Configuration of the connection is done through the "config" and "policies"
 endpoints by a user with root access. Authentication is then done
-by supplying the two fields for "login".
+by supplying the two fields for "login", along with a passcode or a
+factor selection for users who must complete Okta MFA.
 `
@@ -2,11 +2,17 @@ package okta
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/chrismalek/oktasdk-go/okta"
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/helper/policyutil"
@@ -195,3 +201,205 @@ func testAccLogin(t *testing.T, user, password string, keys []string) logicaltes
 		Check: logicaltest.TestCheckAuth(keys),
 	}
 }
+
+// testOkta is a fake of the authentication API of Okta
+type testOkta struct {
+	server *httptest.Server
+
+	// verifications counts the factor verification requests
+	verifications int
+}
+
+func newTestOkta(t *testing.T) *testOkta {
+	o := &testOkta{}
+
+	factors := map[string]string{
+		"push-user": `[{"id": "f-push", "factorType": "push", "provider": "OKTA"}, {"id": "f-google", "factorType": "token:software:totp", "provider": "GOOGLE"}]`,
+		"sms-user":  `[{"id": "f-sms", "factorType": "sms", "provider": "OKTA"}, {"id": "f-totp", "factorType": "token:software:totp", "provider": "OKTA"}]`,
+	}
+	success := `{"status": "SUCCESS", "_embedded": {"user": {"id": "00u1"}}}`
+	failure := func(w http.ResponseWriter, status int, code string) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"errorCode": %q, "errorSummary": "Authentication failed"}`, code)
+	}
+
+	pushPolls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/authn", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["password"] != "password" {
+			failure(w, http.StatusUnauthorized, "E0000004")
+			return
+		}
+		if factors[body["username"]] == "" {
+			fmt.Fprint(w, success)
+			return
+		}
+		fmt.Fprintf(w, `{"status": "MFA_REQUIRED", "stateToken": "state-%s", "_embedded": {"factors": %s}}`, body["username"], factors[body["username"]])
+	})
+	mux.HandleFunc("/api/v1/authn/factors/", func(w http.ResponseWriter, r *http.Request) {
+		o.verifications++
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !strings.HasPrefix(body["stateToken"], "state-") {
+			failure(w, http.StatusForbidden, "E0000011")
+			return
+		}
+
+		switch strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/authn/factors/"), "/verify") {
+		case "f-push":
+			if pushPolls++; pushPolls < 2 {
+				fmt.Fprintf(w, `{"status": "MFA_CHALLENGE", "factorResult": "WAITING", "stateToken": %q}`, body["stateToken"])
+				return
+			}
+			fmt.Fprint(w, success)
+		case "f-google", "f-totp":
+			if body["passCode"] != "123456" {
+				failure(w, http.StatusForbidden, "E0000068")
+				return
+			}
+			fmt.Fprint(w, success)
+		case "f-sms":
+			switch body["passCode"] {
+			case "":
+				fmt.Fprintf(w, `{"status": "MFA_CHALLENGE", "stateToken": %q}`, body["stateToken"])
+			case "654321":
+				fmt.Fprint(w, success)
+			default:
+				failure(w, http.StatusForbidden, "E0000068")
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	o.server = httptest.NewServer(mux)
+	return o
+}
+
+func TestBackend_testOktaFactors(t *testing.T) {
+	o := newTestOkta(t)
+	defer o.server.Close()
+
+	storage := &logical.InmemStorage{}
+	b := Backend()
+	if err := b.Setup(context.Background(), &logical.BackendConfig{
+		Logger: logging.NewVaultLogger(log.Trace),
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour,
+			MaxLeaseTTLVal:     2 * time.Hour,
+		},
+		StorageView: storage,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	baseURL, err := url.Parse(o.server.URL + "/api/v1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.newClient = func(*ConfigEntry) *okta.Client {
+		return okta.NewClientWithBaseURL(nil, baseURL, "")
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+			Storage:   storage,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return resp
+	}
+	login := func(username string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		if _, ok := data["password"]; !ok {
+			data["password"] = "password"
+		}
+		return request(logical.UpdateOperation, "login/"+username, data)
+	}
+	expectError := func(resp *logical.Response, contains string) {
+		t.Helper()
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response, got %#v", resp)
+		}
+		if msg := resp.Error().Error(); !strings.Contains(msg, contains) {
+			t.Fatalf("expected error containing %q, got %q", contains, msg)
+		}
+	}
+	expectLogin := func(resp *logical.Response) *logical.Response {
+		t.Helper()
+		if resp == nil || resp.IsError() || resp.Auth == nil {
+			t.Fatalf("expected successful login, got %#v", resp)
+		}
+		if !reflect.DeepEqual(resp.Auth.Policies, []string{"user_policy"}) {
+			t.Fatalf("unexpected policies %v", resp.Auth.Policies)
+		}
+		return resp
+	}
+
+	request(logical.CreateOperation, "config", map[string]interface{}{
+		"org_name": "test",
+	})
+	for _, user := range []string{"plain-user", "push-user", "sms-user"} {
+		request(logical.UpdateOperation, "users/"+user, map[string]interface{}{
+			"policies": "user_policy",
+		})
+	}
+
+	expectError(login("plain-user", map[string]interface{}{"password": "wrong"}), "E0000004")
+	expectLogin(login("plain-user", map[string]interface{}{}))
+
+	// Push is preferred without a passcode, and TOTP with one
+	pushResp := expectLogin(login("push-user", map[string]interface{}{}))
+	expectLogin(login("push-user", map[string]interface{}{"passcode": "123456"}))
+	expectLogin(login("push-user", map[string]interface{}{"factor": "totp", "provider": "google", "passcode": "123456"}))
+	expectError(login("push-user", map[string]interface{}{"factor": "totp", "passcode": "000000"}), "E0000068")
+	expectError(login("push-user", map[string]interface{}{"factor": "totp"}), "passcode is required")
+	expectError(login("push-user", map[string]interface{}{"factor": "totp", "provider": "OKTA", "passcode": "123456"}), "enrolled factors: push (OKTA), totp (GOOGLE)")
+	expectError(login("push-user", map[string]interface{}{"factor": "sms"}), "no supported MFA factor")
+	expectError(login("push-user", map[string]interface{}{"factor": "email"}), "unsupported factor")
+
+	// SMS passcodes are entered with a second login
+	expectError(login("sms-user", map[string]interface{}{"factor": "sms", "passcode": "654321"}), "challenge_id")
+	challenge := func() string {
+		t.Helper()
+		resp := login("sms-user", map[string]interface{}{})
+		if resp == nil || resp.IsError() || resp.Auth != nil || resp.Data["factor"] != "sms" {
+			t.Fatalf("expected challenge, got %#v", resp)
+		}
+		return resp.Data["challenge_id"].(string)
+	}
+
+	challengeID := challenge()
+	expectError(login("push-user", map[string]interface{}{"challenge_id": challengeID, "passcode": "654321"}), "different user")
+	expectError(login("sms-user", map[string]interface{}{"challenge_id": challengeID, "passcode": "654321"}), "expired or was already completed")
+
+	challengeID = challenge()
+	expectError(login("sms-user", map[string]interface{}{"challenge_id": challengeID, "passcode": "000000"}), "E0000068")
+
+	challengeID = challenge()
+	resp := expectLogin(login("sms-user", map[string]interface{}{"challenge_id": challengeID, "passcode": "654321", "password": ""}))
+	if resp.Auth.InternalData["password"] != "password" {
+		t.Fatal("expected the password of the challenged login to be kept for renewals")
+	}
+	expectError(login("sms-user", map[string]interface{}{"challenge_id": challengeID, "passcode": "654321"}), "expired or was already completed")
+
+	// Renewals check the password without verifying a factor again
+	verifications := o.verifications
+	pushResp.Auth.TokenPolicies = pushResp.Auth.Policies
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Path:      "login/push-user",
+		Auth:      pushResp.Auth,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("expected renewal, got %#v, %v", resp, err)
+	}
+	if o.verifications != verifications {
+		t.Fatal("expected no factor verification on renewal")
+	}
+}
@@ -1,10 +1,13 @@
 package okta
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/api"
 	pwd "github.com/hashicorp/vault/helper/password"
 )
@@ -46,6 +49,21 @@ func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, erro
 	if ok {
 		data["passcode"] = mfa_passcode
 	}
+	if provider, ok := m["provider"]; ok {
+		data["provider"] = provider
+	}
+	if factor, ok := m["factor"]; ok {
+		data["factor"] = factor
+
+		// TOTP passcodes have to be given with the login
+		if strings.ToLower(factor) == "totp" && mfa_passcode == "" {
+			passcode, err := readPasscode("Passcode: ")
+			if err != nil {
+				return nil, err
+			}
+			data["passcode"] = passcode
+		}
+	}
 
 	path := fmt.Sprintf("auth/%s/login/%s", mount, username)
 	secret, err := c.Logical().Write(path, data)
@@ -56,9 +74,39 @@ func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, erro
 		return nil, fmt.Errorf("empty response from credential provider")
 	}
 
+	// For SMS and call factors, Okta sends the passcode to the user, who
+	// completes the login with it
+	if challengeID, ok := secret.Data["challenge_id"].(string); ok && secret.Auth == nil {
+		passcode, err := readPasscode(fmt.Sprintf("Passcode sent by %s: ", secret.Data["factor"]))
+		if err != nil {
+			return nil, err
+		}
+
+		secret, err = c.Logical().Write(path, map[string]interface{}{
+			"challenge_id": challengeID,
+			"passcode":     passcode,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("empty response from credential provider")
+		}
+	}
+
 	return secret, nil
 }
 
+// readPasscode prompts for an MFA passcode on stdin
+func readPasscode(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passcode, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || passcode == "") {
+		return "", errwrap.Wrapf("failed to read passcode: {{err}}", err)
+	}
+	return strings.TrimSpace(passcode), nil
+}
+
 // Help method for okta cli
 func (h *CLIHandler) Help() string {
 	help := `
@@ -75,6 +123,12 @@ Usage: vault login -method=okta [CONFIG K=V...]
 
       $ vault login -method=okta username=bob password=password
 
+  Authenticate as "sally" with a passcode sent by SMS:
+
+      $ vault login -method=okta username=sally factor=sms
+      Password (will be hidden):
+      Passcode sent by sms:
+
 Configuration:
 
   password=<string>
@@ -83,6 +137,19 @@ Configuration:
 
   username=<string>
       Okta username to use for authentication.
+
+  factor=<string>
+      MFA factor to verify if Okta requires MFA: "push", "totp", "sms" or
+      "call". If not provided, a factor is chosen among the enrolled ones. The
+      CLI prompts for the passcode of TOTP, SMS and call factors.
+
+  provider=<string>
+      Provider of the MFA factor, such as "OKTA" or "GOOGLE". Needed only if
+      factors of several providers are enrolled.
+
+  passcode=<string>
+      Passcode of a TOTP factor. If not provided with factor=totp, the CLI will
+      prompt for this on stdin.
 `
 
 	return strings.TrimSpace(help)
@@ -0,0 +1,242 @@
+package okta
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chrismalek/oktasdk-go/okta"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+)
+
+// Factors that can be selected at login
+const (
+	factorPush = "push"
+	factorTOTP = "totp"
+	factorSMS  = "sms"
+	factorCall = "call"
+)
+
+// oktaFactorTypes maps the factors that can be selected at login to the
+// factor types of Okta
+var oktaFactorTypes = map[string]string{
+	factorPush: "push",
+	factorTOTP: "token:software:totp",
+	factorSMS:  "sms",
+	factorCall: "call",
+}
+
+// Preference of the factors if the login does not select one. Factors that
+// need a passcode come last, as the passcode must be entered in advance.
+var (
+	defaultFactorOrder  = []string{factorPush, factorSMS, factorCall, factorTOTP}
+	passcodeFactorOrder = []string{factorTOTP}
+)
+
+// challengeTTL is how long a passcode sent by SMS or call can be entered,
+// which matches the lifetime of Okta's state tokens
+const challengeTTL = 5 * time.Minute
+
+type mfaFactor struct {
+	Id       string `json:"id"`
+	Type     string `json:"factorType"`
+	Provider string `json:"provider"`
+}
+
+type embeddedResult struct {
+	User    okta.User   `json:"user"`
+	Factors []mfaFactor `json:"factors"`
+}
+
+type authResult struct {
+	Embedded     embeddedResult `json:"_embedded"`
+	Status       string         `json:"status"`
+	FactorResult string         `json:"factorResult"`
+	StateToken   string         `json:"stateToken"`
+}
+
+// factorRequest holds the MFA parameters of a login
+type factorRequest struct {
+	// Provider and Factor restrict the factors that can be verified
+	Provider string
+	Factor   string
+
+	Passcode string
+
+	// challenge is set when the login completes a challenge of a previous
+	// login with its passcode
+	challenge *pendingChallenge
+}
+
+// pendingChallenge is a login waiting for the passcode that Okta sent by
+// SMS or call. It is only kept in memory.
+type pendingChallenge struct {
+	Username   string
+	Password   string
+	Factor     string
+	FactorID   string
+	StateToken string
+}
+
+// name returns the name under which a factor type can be selected
+func (f *mfaFactor) name() string {
+	for name, factorType := range oktaFactorTypes {
+		if f.Type == factorType {
+			return name
+		}
+	}
+	return f.Type
+}
+
+// selectFactor returns the enrolled factor to verify for the login
+func selectFactor(factors []mfaFactor, fr *factorRequest) (*mfaFactor, error) {
+	order := defaultFactorOrder
+	switch {
+	case fr.Factor != "":
+		order = []string{fr.Factor}
+	case fr.Passcode != "":
+		order = passcodeFactorOrder
+	}
+
+	for _, name := range order {
+		for i, f := range factors {
+			if f.Type != oktaFactorTypes[name] {
+				continue
+			}
+			if fr.Provider != "" && !strings.EqualFold(f.Provider, fr.Provider) {
+				continue
+			}
+			return &factors[i], nil
+		}
+	}
+
+	var enrolled []string
+	for _, f := range factors {
+		enrolled = append(enrolled, fmt.Sprintf("%s (%s)", f.name(), f.Provider))
+	}
+	sort.Strings(enrolled)
+	return nil, fmt.Errorf("no supported MFA factor matching the request is enrolled; enrolled factors: %s", strings.Join(enrolled, ", "))
+}
+
+// verifyFactor performs the MFA of a login that Okta requires. result is
+// updated with the outcome of the verification. A non-nil response ends
+// the login, either with an error or with a pending challenge.
+func (b *backend) verifyFactor(ctx context.Context, client *okta.Client, username, password string, result *authResult, fr *factorRequest) (*logical.Response, error) {
+	factor, err := selectFactor(result.Embedded.Factors, fr)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	requestPath := fmt.Sprintf("authn/factors/%s/verify", factor.Id)
+	payload := map[string]interface{}{
+		"stateToken": result.StateToken,
+	}
+
+	switch name := factor.name(); name {
+	case factorTOTP:
+		if fr.Passcode == "" {
+			return logical.ErrorResponse(fmt.Sprintf("a passcode is required for the %s factor", name)), nil
+		}
+		payload["passCode"] = fr.Passcode
+		return b.verifyRequest(client, requestPath, payload, result)
+
+	case factorSMS, factorCall:
+		if fr.Passcode != "" {
+			return logical.ErrorResponse(fmt.Sprintf("the passcode of the %s factor must be given with the challenge_id of the login that sent it", name)), nil
+		}
+		// Without a passcode, Okta sends one to the user
+		if resp, err := b.verifyRequest(client, requestPath, payload, result); resp != nil || err != nil {
+			return resp, err
+		}
+		if result.Status != "MFA_CHALLENGE" {
+			if b.Logger().IsDebug() {
+				b.Logger().Debug("unexpected challenge status", "status", result.Status)
+			}
+			return logical.ErrorResponse("okta authentication failed"), nil
+		}
+
+		challengeID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		b.challenges.SetDefault(challengeID, &pendingChallenge{
+			Username:   username,
+			Password:   password,
+			Factor:     name,
+			FactorID:   factor.Id,
+			StateToken: result.StateToken,
+		})
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"challenge_id": challengeID,
+				"factor":       name,
+			},
+		}, nil
+
+	case factorPush:
+		if resp, err := b.verifyRequest(client, requestPath, payload, result); resp != nil || err != nil {
+			return resp, err
+		}
+		for result.Status == "MFA_CHALLENGE" {
+			switch result.FactorResult {
+			case "WAITING":
+				select {
+				case <-time.After(500 * time.Millisecond):
+					// Continue
+				case <-ctx.Done():
+					return logical.ErrorResponse("exiting pending mfa challenge"), nil
+				}
+
+				if resp, err := b.verifyRequest(client, requestPath, payload, result); resp != nil || err != nil {
+					return resp, err
+				}
+			case "REJECTED":
+				return logical.ErrorResponse("multi-factor authentication denied"), nil
+			case "TIMEOUT":
+				return logical.ErrorResponse("failed to complete multi-factor authentication"), nil
+			default:
+				if b.Logger().IsDebug() {
+					b.Logger().Debug("unhandled result status", "status", result.Status, "factorstatus", result.FactorResult)
+				}
+				return logical.ErrorResponse("okta authentication failed"), nil
+			}
+		}
+		return nil, nil
+
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unsupported MFA factor %q", name)), nil
+	}
+}
+
+// completeChallenge verifies the passcode of a challenge sent by a previous
+// login
+func (b *backend) completeChallenge(client *okta.Client, result *authResult, fr *factorRequest) (*logical.Response, error) {
+	if fr.Passcode == "" {
+		return logical.ErrorResponse(fmt.Sprintf("a passcode is required for the %s factor", fr.challenge.Factor)), nil
+	}
+
+	return b.verifyRequest(client, fmt.Sprintf("authn/factors/%s/verify", fr.challenge.FactorID), map[string]interface{}{
+		"stateToken": fr.challenge.StateToken,
+		"passCode":   fr.Passcode,
+	}, result)
+}
+
+func (b *backend) verifyRequest(client *okta.Client, requestPath string, payload map[string]interface{}, result *authResult) (*logical.Response, error) {
+	verifyReq, err := client.NewRequest("POST", requestPath, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := client.Do(verifyReq, result)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Okta auth failed: %v", err)), nil
+	}
+	if rsp == nil {
+		return logical.ErrorResponse("okta auth backend unexpected failure"), nil
+	}
+	return nil, nil
+}
@@ -25,6 +25,26 @@ func pathLogin(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Password for this user.",
 			},
+
+			"provider": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Provider of the MFA factor to verify, such as "OKTA" or "GOOGLE".`,
+			},
+
+			"factor": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `MFA factor to verify: "push", "totp", "sms" or "call". If not set, a factor is chosen among the enrolled ones.`,
+			},
+
+			"passcode": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Passcode of a TOTP factor, or the passcode sent by SMS or call.",
+			},
+
+			"challenge_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "ID of the challenge of a previous login whose passcode was sent by SMS or call.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -56,14 +76,42 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 	username := d.Get("username").(string)
 	password := d.Get("password").(string)
 
-	policies, resp, groupNames, err := b.Login(ctx, req, username, password)
+	factor := &factorRequest{
+		Provider: d.Get("provider").(string),
+		Factor:   strings.ToLower(d.Get("factor").(string)),
+		Passcode: d.Get("passcode").(string),
+	}
+	if factor.Factor != "" {
+		if _, ok := oktaFactorTypes[factor.Factor]; !ok {
+			return logical.ErrorResponse(fmt.Sprintf("unsupported factor %q", factor.Factor)), nil
+		}
+	}
+
+	// Each challenge can only be completed once
+	if challengeID := d.Get("challenge_id").(string); challengeID != "" {
+		challengeRaw, ok := b.challenges.Get(challengeID)
+		if !ok {
+			return logical.ErrorResponse("challenge expired or was already completed"), nil
+		}
+		b.challenges.Delete(challengeID)
+
+		challenge := challengeRaw.(*pendingChallenge)
+		if challenge.Username != username {
+			return logical.ErrorResponse("challenge was issued for a different user"), nil
+		}
+		factor.challenge = challenge
+		password = challenge.Password
+	}
+
+	policies, resp, groupNames, err := b.Login(ctx, req, username, password, factor)
 	// Handle an internal error
 	if err != nil {
 		return nil, err
 	}
 	if resp != nil {
-		// Handle a logical error
-		if resp.IsError() {
+		// Handle a logical error, or a passcode challenge the user has to
+		// complete with another login
+		if resp.IsError() || resp.Data["challenge_id"] != nil {
 			return resp, nil
 		}
 	} else {
@@ -113,7 +161,8 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 	username := req.Auth.Metadata["username"]
 	password := req.Auth.InternalData["password"].(string)
 
-	loginPolicies, resp, groupNames, err := b.Login(ctx, req, username, password)
+	// The MFA factors are not verified again
+	loginPolicies, resp, groupNames, err := b.Login(ctx, req, username, password, nil)
 	if len(loginPolicies) == 0 {
 		return resp, err
 	}
@@ -162,5 +211,9 @@ Log in with a username and password.
 `
 
 const pathLoginDesc = `
-This endpoint authenticates using a username and password.
+This endpoint authenticates using a username and password. Users who must
+complete Okta MFA verify one of their factors: Okta Verify push, a TOTP
+passcode of Okta Verify or Google Authenticator, or a passcode sent by SMS or
+call. For SMS and call factors, the login returns a challenge ID, and a
+second login with the challenge ID and the received passcode completes it.
 `
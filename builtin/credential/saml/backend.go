@@ -0,0 +1,90 @@
+package saml
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
+)
+
+const (
+	configPath = "config"
+	rolePrefix = "role/"
+
+	// pendingRequestTTL is how long users have to log in at the IdP
+	pendingRequestTTL = 10 * time.Minute
+)
+
+func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
+	b := Backend()
+	if err := b.Setup(ctx, conf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func Backend() *backend {
+	httpClient := cleanhttp.DefaultClient()
+	httpClient.Timeout = 30 * time.Second
+
+	b := &backend{
+		pendingRequests: cache.New(pendingRequestTTL, time.Minute),
+		httpClient:      httpClient,
+	}
+	b.Backend = &framework.Backend{
+		Help: backendHelp,
+
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"sso_service_url",
+				"callback",
+			},
+		},
+
+		Paths: []*framework.Path{
+			pathConfig(b),
+			pathRoleList(b),
+			pathRole(b),
+			pathSSOServiceURL(b),
+			pathCallback(b),
+		},
+
+		AuthRenew:   b.pathLoginRenew,
+		BackendType: logical.TypeCredential,
+	}
+
+	return b
+}
+
+type backend struct {
+	*framework.Backend
+
+	// pendingRequests holds the authentication requests that were sent to
+	// the IdP by relay state, until the IdP's response is posted to the
+	// callback
+	pendingRequests *cache.Cache
+
+	httpClient *http.Client
+}
+
+// pendingRequest is an authentication request that was sent to the IdP
+type pendingRequest struct {
+	ID     string
+	Role   string
+	ACSURL string
+}
+
+const backendHelp = `
+The "saml" credential provider allows authentication using a SAML 2.0
+identity provider (IdP).
+
+The IdP is configured through the "config" endpoint and roles map the
+assertions of the IdP to policies. Logins start at "sso_service_url",
+which returns the URL of the IdP to open in a browser. The IdP posts its
+response to an assertion consumer service (ACS) URL, from where it is
+passed to "callback" to obtain a token.
+`
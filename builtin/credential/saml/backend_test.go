@@ -0,0 +1,535 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	testEntityID = "https://vault.example.com/v1/auth/saml"
+	testACSURL   = "http://localhost:8250/saml/callback"
+)
+
+// testIDP is a self-signed SAML identity provider that issues signed
+// responses
+type testIDP struct {
+	EntityID string
+	SSOURL   string
+	Key      *rsa.PrivateKey
+	Cert     *x509.Certificate
+}
+
+func newTestIDP(t *testing.T) *testIDP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+	}, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIDP{
+		EntityID: "https://idp.example.com/metadata",
+		SSOURL:   "https://idp.example.com/sso?tenant=1",
+		Key:      key,
+		Cert:     cert,
+	}
+}
+
+func (idp *testIDP) certPEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.Cert.Raw}))
+}
+
+func (idp *testIDP) metadata() string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+        <ds:X509Data>
+          <ds:X509Certificate>%s</ds:X509Certificate>
+        </ds:X509Data>
+      </ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%s"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, idp.EntityID, base64.StdEncoding.EncodeToString(idp.Cert.Raw), xmlEscape(idp.SSOURL))
+}
+
+type testResponse struct {
+	RequestID    string
+	NameID       string
+	Audience     string
+	Recipient    string
+	NotOnOrAfter time.Time
+	Attributes   map[string][]string
+
+	SignResponse  bool
+	SignAssertion bool
+}
+
+// response returns a base64 encoded SAML response for the request
+func (idp *testIDP) response(t *testing.T, r testResponse) string {
+	t.Helper()
+	now := time.Now().UTC()
+	if r.Audience == "" {
+		r.Audience = testEntityID
+	}
+	if r.Recipient == "" {
+		r.Recipient = testACSURL
+	}
+	if r.NotOnOrAfter.IsZero() {
+		r.NotOnOrAfter = now.Add(5 * time.Minute)
+	}
+
+	var attrs strings.Builder
+	var names []string
+	for name := range r.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&attrs, `<saml:Attribute Name="%s">`, name)
+		for _, value := range r.Attributes[name] {
+			fmt.Fprintf(&attrs, `<saml:AttributeValue xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">%s</saml:AttributeValue>`, xmlEscape(value))
+		}
+		attrs.WriteString(`</saml:Attribute>`)
+	}
+
+	doc := fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_response" Version="2.0" IssueInstant="%[1]s" Destination="%[2]s" InResponseTo="%[3]s">
+  <saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">%[4]s</saml:Issuer>{{signature:_response}}
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_assertion" Version="2.0" IssueInstant="%[1]s">
+    <saml:Issuer>%[4]s</saml:Issuer>{{signature:_assertion}}
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">%[5]s</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData InResponseTo="%[3]s" NotOnOrAfter="%[6]s" Recipient="%[7]s"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="%[8]s" NotOnOrAfter="%[6]s">
+      <saml:AudienceRestriction><saml:Audience>%[9]s</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AttributeStatement>%[10]s</saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`,
+		now.Format(time.RFC3339), testACSURL, r.RequestID, idp.EntityID, xmlEscape(r.NameID),
+		r.NotOnOrAfter.Format(time.RFC3339), r.Recipient, now.Add(-time.Minute).Format(time.RFC3339), r.Audience, attrs.String())
+
+	// The assertion is signed before the response, whose digest covers the
+	// signature of the assertion
+	if r.SignAssertion {
+		doc = idp.sign(t, doc, "_assertion")
+	}
+	if r.SignResponse {
+		doc = idp.sign(t, doc, "_response")
+	}
+	doc = strings.NewReplacer("{{signature:_assertion}}", "", "{{signature:_response}}", "").Replace(doc)
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+// sign replaces the signature placeholder of the element with the given ID
+// with its enveloped signature
+func (idp *testIDP) sign(t *testing.T, doc, id string) string {
+	t.Helper()
+	placeholder := "{{signature:" + id + "}}"
+	unsigned := strings.NewReplacer("{{signature:_assertion}}", "", "{{signature:_response}}", "").Replace(doc)
+	root, err := parseXML([]byte(unsigned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	el := findByID(root, id)
+	if el == nil {
+		t.Fatalf("element %q not found", id)
+	}
+
+	digest := crypto.SHA256.New()
+	digest.Write((&canonicalizer{inclusivePrefixes: []string{"xs"}}).canonicalize(el))
+	signedInfo := fmt.Sprintf(`<ds:SignedInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/><ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/><ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/><ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"><ec:InclusiveNamespaces xmlns:ec="http://www.w3.org/2001/10/xml-exc-c14n#" PrefixList="xs"/></ds:Transform></ds:Transforms><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference></ds:SignedInfo>`,
+		id, base64.StdEncoding.EncodeToString(digest.Sum(nil)))
+
+	signedInfoEl, err := parseXML([]byte(signedInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := crypto.SHA256.New()
+	hashed.Write((&canonicalizer{}).canonicalize(signedInfoEl))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.Key, crypto.SHA256, hashed.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := fmt.Sprintf(`<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">%s<ds:SignatureValue>%s</ds:SignatureValue></ds:Signature>`,
+		signedInfo, base64.StdEncoding.EncodeToString(sig))
+	return strings.Replace(doc, placeholder, signature, 1)
+}
+
+func findByID(e *xmlElement, id string) *xmlElement {
+	if e.attr("ID") == id {
+		return e
+	}
+	for _, child := range e.Children {
+		if el, ok := child.(*xmlElement); ok {
+			if found := findByID(el, id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func testBackend(t *testing.T) (*backend, logical.Storage) {
+	t.Helper()
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend()
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	return b, config.StorageView
+}
+
+func testRequest(t *testing.T, b *backend, s logical.Storage, path string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Path:       path,
+		Operation:  logical.UpdateOperation,
+		Storage:    s,
+		Data:       data,
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: %s: resp: %#v\nerr: %v", path, resp, err)
+	}
+	return resp
+}
+
+// startLogin requests the SSO service URL and returns the ID of the
+// authentication request and the relay state
+func startLogin(t *testing.T, b *backend, s logical.Storage, idp *testIDP) (string, string) {
+	t.Helper()
+	resp := testRequest(t, b, s, "sso_service_url", map[string]interface{}{
+		"acs_url": testACSURL,
+	})
+	u, err := url.Parse(resp.Data["sso_service_url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u.String(), "https://idp.example.com/sso?") || u.Query().Get("tenant") != "1" {
+		t.Fatalf("bad: sso_service_url: %s", u)
+	}
+
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := parseXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Space != nsSAMLProtocol || request.Local != "AuthnRequest" ||
+		request.attr("AssertionConsumerServiceURL") != testACSURL || request.attr("Destination") != idp.SSOURL ||
+		request.child(nsSAMLAssertion, "Issuer").text() != testEntityID {
+		t.Fatalf("bad: request: %s", data)
+	}
+	return request.attr("ID"), u.Query().Get("RelayState")
+}
+
+func TestBackend_Login(t *testing.T) {
+	b, s := testBackend(t)
+	idp := newTestIDP(t)
+
+	testRequest(t, b, s, "config", map[string]interface{}{
+		"entity_id":    testEntityID,
+		"acs_urls":     testACSURL,
+		"default_role": "dev",
+		"idp_metadata": idp.metadata(),
+	})
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "config",
+		Operation: logical.ReadOperation,
+		Storage:   s,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if resp.Data["idp_entity_id"] != idp.EntityID || resp.Data["idp_sso_url"] != idp.SSOURL || resp.Data["idp_cert"] != idp.certPEM() {
+		t.Fatalf("bad: config: %#v", resp.Data)
+	}
+
+	testRequest(t, b, s, "role/dev", map[string]interface{}{
+		"policies":         "dev",
+		"bound_attributes": "department=engineering,operations",
+		"groups_attribute": "groups",
+		"ttl":              "1h",
+	})
+
+	// Only ACS URLs of the configuration are allowed
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "sso_service_url",
+		Operation: logical.UpdateOperation,
+		Storage:   s,
+		Data: map[string]interface{}{
+			"acs_url": "https://attacker.example.com/callback",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error: resp: %#v\nerr: %v", resp, err)
+	}
+
+	attributes := map[string][]string{
+		"department": {"engineering"},
+		"groups":     {"admins", "developers"},
+	}
+	for _, signing := range []struct {
+		response, assertion bool
+	}{
+		{true, false},
+		{false, true},
+		{true, true},
+	} {
+		requestID, relayState := startLogin(t, b, s, idp)
+		samlResponse := idp.response(t, testResponse{
+			RequestID:     requestID,
+			NameID:        "jane@example.com",
+			Attributes:    attributes,
+			SignResponse:  signing.response,
+			SignAssertion: signing.assertion,
+		})
+
+		resp = testRequest(t, b, s, "callback", map[string]interface{}{
+			"saml_response": samlResponse,
+			"relay_state":   relayState,
+		})
+		if resp.Auth == nil || resp.Auth.Alias.Name != "jane@example.com" || !reflect.DeepEqual(resp.Auth.Policies, []string{"dev"}) ||
+			resp.Auth.TTL != time.Hour || len(resp.Auth.GroupAliases) != 2 || resp.Auth.GroupAliases[1].Name != "developers" {
+			t.Fatalf("bad: auth: %#v", resp.Auth)
+		}
+
+		// Responses cannot be replayed
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Path:      "callback",
+			Operation: logical.UpdateOperation,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"saml_response": samlResponse,
+				"relay_state":   relayState,
+			},
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error: resp: %#v\nerr: %v", resp, err)
+		}
+	}
+
+	// The user attribute replaces the name ID as alias name
+	testRequest(t, b, s, "role/dev", map[string]interface{}{
+		"user_attribute": "username",
+	})
+	requestID, relayState := startLogin(t, b, s, idp)
+	resp = testRequest(t, b, s, "callback", map[string]interface{}{
+		"saml_response": idp.response(t, testResponse{
+			RequestID: requestID,
+			NameID:    "jane@example.com",
+			Attributes: map[string][]string{
+				"department": {"operations"},
+				"username":   {"jane"},
+			},
+			SignAssertion: true,
+		}),
+		"relay_state": relayState,
+	})
+	if resp.Auth == nil || resp.Auth.Alias.Name != "jane" || resp.Auth.Metadata["name_id"] != "jane@example.com" || len(resp.Auth.GroupAliases) != 0 {
+		t.Fatalf("bad: auth: %#v", resp.Auth)
+	}
+}
+
+func TestBackend_LoginRejected(t *testing.T) {
+	b, s := testBackend(t)
+	idp := newTestIDP(t)
+	otherIDP := newTestIDP(t)
+
+	testRequest(t, b, s, "config", map[string]interface{}{
+		"entity_id":     testEntityID,
+		"acs_urls":      testACSURL,
+		"idp_entity_id": idp.EntityID,
+		"idp_sso_url":   idp.SSOURL,
+		"idp_cert":      idp.certPEM(),
+	})
+	testRequest(t, b, s, "role/dev", map[string]interface{}{
+		"policies":         "dev",
+		"bound_subjects":   "jane@example.com",
+		"bound_attributes": "department=engineering",
+	})
+	testRequest(t, b, s, "config", map[string]interface{}{
+		"default_role": "dev",
+	})
+
+	valid := testResponse{
+		NameID: "jane@example.com",
+		Attributes: map[string][]string{
+			"department": {"engineering"},
+		},
+		SignAssertion: true,
+	}
+
+	cases := map[string]func(requestID string) string{
+		"unsigned": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			r.SignAssertion = false
+			return idp.response(t, r)
+		},
+		"signed by another IdP": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			return otherIDP.response(t, r)
+		},
+		"tampered": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			data, _ := base64.StdEncoding.DecodeString(idp.response(t, r))
+			data = bytes.Replace(data, []byte("jane@example.com"), []byte("john@example.com"), -1)
+			return base64.StdEncoding.EncodeToString(data)
+		},
+		"wrapped": func(requestID string) string {
+			// A signed assertion moved next to an unsigned one
+			r := valid
+			r.RequestID = requestID
+			data, _ := base64.StdEncoding.DecodeString(idp.response(t, r))
+			doc := string(data)
+			start := strings.Index(doc, "<saml:Assertion")
+			end := strings.Index(doc, "</saml:Assertion>") + len("</saml:Assertion>")
+			forged := strings.Replace(doc[start:end], `ID="_assertion"`, `ID="_forged"`, 1)
+			forged = strings.Replace(forged, "jane@example.com", "john@example.com", -1)
+			doc = doc[:start] + forged + doc[start:]
+			return base64.StdEncoding.EncodeToString([]byte(doc))
+		},
+		"other request": func(requestID string) string {
+			r := valid
+			r.RequestID = "_other"
+			return idp.response(t, r)
+		},
+		"wrong audience": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			r.Audience = "https://other.example.com"
+			return idp.response(t, r)
+		},
+		"wrong recipient": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			r.Recipient = "https://other.example.com/callback"
+			return idp.response(t, r)
+		},
+		"expired": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			r.NotOnOrAfter = time.Now().Add(-10 * time.Minute)
+			return idp.response(t, r)
+		},
+		"unbound subject": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			r.NameID = "john@example.com"
+			return idp.response(t, r)
+		},
+		"unbound attribute": func(requestID string) string {
+			r := valid
+			r.RequestID = requestID
+			r.Attributes = map[string][]string{
+				"department": {"sales"},
+			}
+			return idp.response(t, r)
+		},
+	}
+	for name, samlResponse := range cases {
+		requestID, relayState := startLogin(t, b, s, idp)
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Path:      "callback",
+			Operation: logical.UpdateOperation,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"saml_response": samlResponse(requestID),
+				"relay_state":   relayState,
+			},
+		})
+		if err != nil || resp == nil || !resp.IsError() || resp.Auth != nil {
+			t.Fatalf("%s: expected an error: resp: %#v\nerr: %v", name, resp, err)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	root, err := parseXML([]byte(`<root xmlns="urn:default" xmlns:a="urn:a" xmlns:unused="urn:unused">
+<a:child b="2" a:attr="x" a="1">text &amp; &lt;more&gt;<![CDATA[<cdata>]]><!-- comment --><empty/><inner xmlns=""/></a:child></root>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := root.child("urn:a", "child")
+
+	expected := `<a:child xmlns:a="urn:a" a="1" b="2" a:attr="x">text &amp; &lt;more&gt;&lt;cdata&gt;<empty xmlns="urn:default"></empty><inner></inner></a:child>`
+	if actual := string((&canonicalizer{}).canonicalize(child)); actual != expected {
+		t.Fatalf("bad: canonical form:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	c := &canonicalizer{
+		withComments:      true,
+		inclusivePrefixes: []string{"unused", "undeclared"},
+		exclude:           child.child("urn:default", "empty"),
+	}
+	expected = `<a:child xmlns:a="urn:a" xmlns:unused="urn:unused" a="1" b="2" a:attr="x">text &amp; &lt;more&gt;&lt;cdata&gt;<!-- comment --><inner></inner></a:child>`
+	if actual := string(c.canonicalize(child)); actual != expected {
+		t.Fatalf("bad: canonical form:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	if _, err := parseXML([]byte(`<!DOCTYPE root [<!ENTITY x "y">]><root>&x;</root>`)); err == nil {
+		t.Fatal("expected an error for a DOCTYPE declaration")
+	}
+	if _, err := parseXML([]byte(`<root><a ID="1"/><b ID="1"/></root>`)); err == nil {
+		t.Fatal("expected an error for duplicate IDs")
+	}
+}
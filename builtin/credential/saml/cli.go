@@ -0,0 +1,210 @@
+package saml
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+const (
+	defaultMount         = "saml"
+	defaultListenAddress = "localhost"
+	defaultPort          = "8250"
+	callbackPath         = "/saml/callback"
+
+	// loginTimeout is how long the CLI waits for the IdP response
+	loginTimeout = 5 * time.Minute
+)
+
+type CLIHandler struct {
+	// for tests
+	testStdout io.Writer
+}
+
+type loginResult struct {
+	secret *api.Secret
+	err    error
+}
+
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, error) {
+	mount, ok := m["mount"]
+	if !ok {
+		mount = defaultMount
+	}
+	listenAddress, ok := m["listenaddress"]
+	if !ok {
+		listenAddress = defaultListenAddress
+	}
+	port, ok := m["port"]
+	if !ok {
+		port = defaultPort
+	}
+	skipBrowser := false
+	if v, ok := m["skip_browser"]; ok {
+		var err error
+		if skipBrowser, err = parseutil.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("failed to parse skip_browser: %v", err)
+		}
+	}
+
+	stdout := h.testStdout
+	if stdout == nil {
+		stdout = os.Stderr
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(listenAddress, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the callback listener: %v", err)
+	}
+	defer listener.Close()
+
+	acsURL := fmt.Sprintf("http://%s%s", net.JoinHostPort(listenAddress, port), callbackPath)
+	secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/sso_service_url", mount), map[string]interface{}{
+		"role":    m["role"],
+		"acs_url": acsURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("empty response from credential provider")
+	}
+	ssoURL, ok := secret.Data["sso_service_url"].(string)
+	if !ok || ssoURL == "" {
+		return nil, fmt.Errorf("credential provider did not return an SSO service URL")
+	}
+
+	// The IdP makes the browser post its response to the ACS URL, from where
+	// it is passed on to Vault
+	doneCh := make(chan loginResult, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/callback", mount), map[string]interface{}{
+			"saml_response": r.PostForm.Get("SAMLResponse"),
+			"relay_state":   r.PostForm.Get("RelayState"),
+		})
+		if err == nil && secret == nil {
+			err = fmt.Errorf("empty response from credential provider")
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err != nil {
+			fmt.Fprintf(w, callbackPage, "Vault login failed", html.EscapeString(err.Error()))
+		} else {
+			fmt.Fprintf(w, callbackPage, "Vault login successful", "You can close this window and return to the CLI.")
+		}
+
+		select {
+		case doneCh <- loginResult{secret, err}:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Fprintf(stdout, "Complete the login via your SAML provider at:\n\n    %s\n\n", ssoURL)
+	if !skipBrowser {
+		if err := openURL(ssoURL); err != nil {
+			fmt.Fprintf(stdout, "Error attempting to automatically open browser: %v\nPlease visit the URL above manually.\n\n", err)
+		}
+	}
+	fmt.Fprintf(stdout, "Waiting for SAML response...\n")
+
+	sigintCh := make(chan os.Signal, 1)
+	signal.Notify(sigintCh, os.Interrupt)
+	defer signal.Stop(sigintCh)
+
+	select {
+	case result := <-doneCh:
+		return result.secret, result.err
+	case <-sigintCh:
+		return nil, fmt.Errorf("user interrupted")
+	case <-time.After(loginTimeout):
+		return nil, fmt.Errorf("timed out waiting for the SAML response")
+	}
+}
+
+// openURL opens the URL in the default browser of the platform
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+const callbackPage = `<!DOCTYPE html>
+<html>
+<head><title>Vault</title></head>
+<body><h2>%s</h2><p>%s</p></body>
+</html>
+`
+
+func (h *CLIHandler) Help() string {
+	help := `
+Usage: vault login -method=saml [CONFIG K=V...]
+
+  The SAML auth method allows users to authenticate using a SAML 2.0
+  identity provider (IdP). The CLI opens the IdP's login page in a browser
+  and listens locally for the IdP's response, which the browser posts to
+  the assertion consumer service (ACS) URL of the listener. The ACS URL,
+  by default "http://localhost:8250/saml/callback", must be one of the
+  "acs_urls" of the auth method's configuration.
+
+  Authenticate using the "engineering" role:
+
+      $ vault login -method=saml role=engineering
+
+Configuration:
+
+  mount=<string>
+      Path where the SAML credential method is mounted. This is usually
+      provided via the -path flag in the "vault login" command, but it can be
+      specified here as well. If specified here, it takes precedence over the
+      value for -path. The default value is "saml".
+
+  role=<string>
+      Role to log in against. If not provided, the default role of the auth
+      method's configuration is used.
+
+  listenaddress=<string>
+      Address the callback listener binds to. The default value is
+      "localhost".
+
+  port=<string>
+      Port the callback listener binds to. The default value is "8250".
+
+  skip_browser=<bool>
+      Print the login URL instead of opening it in a browser. The default
+      value is false.
+`
+
+	return strings.TrimSpace(help)
+}
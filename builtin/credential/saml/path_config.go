@@ -0,0 +1,222 @@
+package saml
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const maxMetadataSize = 1024 * 1024
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config$",
+		Fields: map[string]*framework.FieldSchema{
+			"entity_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Entity ID of Vault as a service provider. Assertions must be addressed to this audience.",
+			},
+
+			"acs_urls": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma-separated list of assertion consumer service URLs that logins may request the IdP to post its response to.",
+			},
+
+			"default_role": &framework.FieldSchema{
+				Type:        framework.TypeLowerCaseString,
+				Description: "Role used for logins that do not specify a role.",
+			},
+
+			"idp_metadata_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `URL of the metadata of the IdP. The metadata is fetched when the configuration is written. Cannot be used with "idp_metadata".`,
+			},
+
+			"idp_metadata": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Metadata XML of the IdP. Cannot be used with "idp_metadata_url".`,
+			},
+
+			"idp_entity_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Entity ID of the IdP, if no metadata is given.",
+			},
+
+			"idp_sso_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Single sign-on URL of the IdP for the HTTP-Redirect binding, if no metadata is given.",
+			},
+
+			"idp_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM encoded certificates used to verify the signatures of the IdP, if no metadata is given.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+type samlConfig struct {
+	EntityID       string   `json:"entity_id"`
+	ACSURLs        []string `json:"acs_urls"`
+	DefaultRole    string   `json:"default_role"`
+	IDPMetadataURL string   `json:"idp_metadata_url"`
+	IDPEntityID    string   `json:"idp_entity_id"`
+	IDPSSOURL      string   `json:"idp_sso_url"`
+	IDPCert        string   `json:"idp_cert"`
+}
+
+func (b *backend) config(ctx context.Context, s logical.Storage) (*samlConfig, error) {
+	entry, err := s.Get(ctx, configPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result samlConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"entity_id":        config.EntityID,
+			"acs_urls":         config.ACSURLs,
+			"default_role":     config.DefaultRole,
+			"idp_metadata_url": config.IDPMetadataURL,
+			"idp_entity_id":    config.IDPEntityID,
+			"idp_sso_url":      config.IDPSSOURL,
+			"idp_cert":         config.IDPCert,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &samlConfig{}
+	}
+
+	if entityID, ok := d.GetOk("entity_id"); ok {
+		config.EntityID = entityID.(string)
+	}
+	if acsURLs, ok := d.GetOk("acs_urls"); ok {
+		config.ACSURLs = strutil.RemoveDuplicates(acsURLs.([]string), false)
+	}
+	if defaultRole, ok := d.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRole.(string)
+	}
+	if idpEntityID, ok := d.GetOk("idp_entity_id"); ok {
+		config.IDPEntityID = idpEntityID.(string)
+	}
+	if idpSSOURL, ok := d.GetOk("idp_sso_url"); ok {
+		config.IDPSSOURL = idpSSOURL.(string)
+	}
+	if idpCert, ok := d.GetOk("idp_cert"); ok {
+		config.IDPCert = idpCert.(string)
+	}
+
+	// Metadata takes precedence over the individual IdP settings
+	metadataURL := d.Get("idp_metadata_url").(string)
+	metadataXML := d.Get("idp_metadata").(string)
+	if metadataURL != "" && metadataXML != "" {
+		return logical.ErrorResponse(`"idp_metadata_url" and "idp_metadata" cannot both be set`), nil
+	}
+	if metadataURL != "" {
+		data, err := b.fetchMetadata(ctx, metadataURL)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to fetch IdP metadata: %v", err)), nil
+		}
+		metadataXML = string(data)
+	}
+	if _, ok := d.GetOk("idp_metadata_url"); ok {
+		config.IDPMetadataURL = metadataURL
+	} else if metadataXML != "" {
+		config.IDPMetadataURL = ""
+	}
+	if metadataXML != "" {
+		metadata, err := parseIDPMetadata([]byte(metadataXML))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		config.IDPEntityID = metadata.EntityID
+		config.IDPSSOURL = metadata.SSOURL
+		config.IDPCert = metadata.CertPEM
+	}
+
+	switch {
+	case config.EntityID == "":
+		return logical.ErrorResponse(`"entity_id" is required`), nil
+	case len(config.ACSURLs) == 0:
+		return logical.ErrorResponse(`"acs_urls" is required`), nil
+	case config.IDPEntityID == "" || config.IDPSSOURL == "" || config.IDPCert == "":
+		return logical.ErrorResponse(`either IdP metadata or "idp_entity_id", "idp_sso_url" and "idp_cert" are required`), nil
+	}
+	if _, err := parseCertificates(config.IDPCert); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to parse IdP certificates: %v", err)), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(configPath, config)
+	if err != nil {
+		return nil, err
+	}
+	return nil, req.Storage.Put(ctx, entry)
+}
+
+func (b *backend) fetchMetadata(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+}
+
+const pathConfigHelpSyn = `
+Configure the SAML identity provider.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures Vault as a service provider of a SAML 2.0 identity
+provider (IdP). The IdP is described either by its metadata, given as XML or
+fetched from a URL, or by its entity ID, single sign-on URL and signing
+certificates. Metadata fetched from a URL is not refreshed automatically;
+write the URL again to pick up changes such as rotated certificates.
+`
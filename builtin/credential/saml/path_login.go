@@ -0,0 +1,272 @@
+package saml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathSSOServiceURL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "sso_service_url$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeLowerCaseString,
+				Description: "The role to log in against. Defaults to the default role of the configuration.",
+			},
+
+			"acs_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The assertion consumer service URL the IdP posts its response to. Must be one of the configured ACS URLs.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathSSOServiceURL,
+		},
+
+		HelpSynopsis:    pathSSOServiceURLHelpSyn,
+		HelpDescription: pathSSOServiceURLHelpDesc,
+	}
+}
+
+func pathCallback(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "callback$",
+		Fields: map[string]*framework.FieldSchema{
+			"saml_response": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The base64 encoded SAML response that the IdP posted to the ACS URL.",
+			},
+
+			"relay_state": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The relay state that the IdP posted to the ACS URL.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCallback,
+		},
+
+		HelpSynopsis:    pathCallbackHelpSyn,
+		HelpDescription: pathCallbackHelpDesc,
+	}
+}
+
+func (b *backend) pathSSOServiceURL(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("SAML is not configured"), nil
+	}
+
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		roleName = config.DefaultRole
+	}
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", roleName)), nil
+	}
+
+	acsURL := d.Get("acs_url").(string)
+	if acsURL == "" {
+		return logical.ErrorResponse("missing acs_url"), nil
+	}
+	if !strutil.StrListContains(config.ACSURLs, acsURL) {
+		return logical.ErrorResponse(fmt.Sprintf("acs_url %q is not allowed by the configuration", acsURL)), nil
+	}
+
+	requestID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	relayState, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	// IDs must not start with a digit
+	requestID = "_" + requestID
+	url, err := ssoServiceURL(config.IDPSSOURL, config.EntityID, acsURL, requestID, relayState, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	b.pendingRequests.SetDefault(relayState, &pendingRequest{
+		ID:     requestID,
+		Role:   roleName,
+		ACSURL: acsURL,
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"sso_service_url": url,
+		},
+	}, nil
+}
+
+func (b *backend) pathCallback(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	samlResponse := d.Get("saml_response").(string)
+	if samlResponse == "" {
+		return logical.ErrorResponse("missing saml_response"), nil
+	}
+	relayState := d.Get("relay_state").(string)
+	if relayState == "" {
+		return logical.ErrorResponse("missing relay_state"), nil
+	}
+
+	// Each request can only be completed once
+	pendingRaw, ok := b.pendingRequests.Get(relayState)
+	if !ok {
+		return logical.ErrorResponse("login request expired or was already completed"), nil
+	}
+	b.pendingRequests.Delete(relayState)
+	pending := pendingRaw.(*pendingRequest)
+
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("SAML is not configured"), nil
+	}
+	role, err := b.role(ctx, req.Storage, pending.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", pending.Role)), nil
+	}
+
+	if req.Connection != nil && !cidrutil.RemoteAddrIsOk(req.Connection.RemoteAddr, role.BoundCIDRs) {
+		return logical.ErrorResponse("request originated from invalid CIDR"), nil
+	}
+
+	idpCerts, err := parseCertificates(config.IDPCert)
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := parseResponse(samlResponse, &responseValidation{
+		IDPEntityID: config.IDPEntityID,
+		IDPCerts:    idpCerts,
+		EntityID:    config.EntityID,
+		ACSURL:      pending.ACSURL,
+		RequestID:   pending.ID,
+		Now:         time.Now(),
+	})
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if err := role.matches(assertion); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	userName := assertion.NameID
+	if role.UserAttribute != "" {
+		values := assertion.Attributes[role.UserAttribute]
+		if len(values) == 0 || values[0] == "" {
+			return logical.ErrorResponse(fmt.Sprintf("%q attribute not found in assertion", role.UserAttribute)), nil
+		}
+		userName = values[0]
+	}
+
+	var groupAliases []*logical.Alias
+	if role.GroupsAttribute != "" {
+		for _, group := range strutil.RemoveDuplicates(assertion.Attributes[role.GroupsAttribute], false) {
+			if group == "" {
+				continue
+			}
+			groupAliases = append(groupAliases, &logical.Alias{
+				Name: group,
+			})
+		}
+	}
+
+	return &logical.Response{
+		Auth: &logical.Auth{
+			Policies:    role.Policies,
+			DisplayName: userName,
+			Alias: &logical.Alias{
+				Name: userName,
+			},
+			GroupAliases: groupAliases,
+			InternalData: map[string]interface{}{
+				"role": pending.Role,
+			},
+			Metadata: map[string]string{
+				"role":    pending.Role,
+				"name_id": assertion.NameID,
+			},
+			LeaseOptions: logical.LeaseOptions{
+				TTL:       role.TTL,
+				MaxTTL:    role.MaxTTL,
+				Renewable: true,
+			},
+			BoundCIDRs: role.BoundCIDRs,
+		},
+	}, nil
+}
+
+func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName, ok := req.Auth.InternalData["role"].(string)
+	if !ok || roleName == "" {
+		return nil, errors.New("failed to fetch role during renewal")
+	}
+
+	role, err := b.role(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %q does not exist during renewal", roleName)
+	}
+
+	if !policyutil.EquivalentPolicies(role.Policies, req.Auth.TokenPolicies) {
+		return nil, errors.New("policies have changed, not renewing")
+	}
+
+	resp := &logical.Response{Auth: req.Auth}
+	resp.Auth.TTL = role.TTL
+	resp.Auth.MaxTTL = role.MaxTTL
+	return resp, nil
+}
+
+const pathSSOServiceURLHelpSyn = `
+Start a login at the IdP.
+`
+
+const pathSSOServiceURLHelpDesc = `
+This endpoint returns the single sign-on URL of the IdP with an
+authentication request for the given role. Opening the URL in a browser
+lets the user log in at the IdP, which then posts its response to the given
+ACS URL. The response must be passed to "callback" within ten minutes.
+`
+
+const pathCallbackHelpSyn = `
+Log in with the response of the IdP.
+`
+
+const pathCallbackHelpDesc = `
+This endpoint validates the SAML response that the IdP posted to the ACS URL
+and returns a token for the role of the login. The response or its assertion
+must be signed by the IdP, and the assertion must be addressed to the entity
+ID and ACS URL of the login.
+`
@@ -0,0 +1,261 @@
+package saml
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sockaddr "github.com/hashicorp/go-sockaddr"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRoleList(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/?",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+
+		HelpSynopsis:    pathRoleListHelpSyn,
+		HelpDescription: pathRoleListHelpDesc,
+	}
+}
+
+func pathRole(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role.",
+			},
+
+			"policies": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma-separated list of policies on the role.",
+			},
+
+			"ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Duration after which authentication will be expired",
+			},
+
+			"max_ttl": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum duration after which authentication will be expired",
+			},
+
+			"bound_subjects": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma-separated list of name IDs of the assertion subject that are allowed to log in. If empty, any subject is allowed.",
+			},
+
+			"bound_attributes": &framework.FieldSchema{
+				Type: framework.TypeKVPairs,
+				Description: `Map of attribute names to comma-separated lists of values. Each of the
+attributes must have one of the listed values to log in.`,
+			},
+
+			"user_attribute": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Attribute whose value is used as the identity alias name. If empty, the name ID of the subject is used.",
+			},
+
+			"groups_attribute": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Attribute whose values are used as the identity group alias names.",
+			},
+
+			"bound_cidrs": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Comma separated string or list of CIDR blocks. If set, specifies the blocks of
+IP addresses which can perform the login operation.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.CreateOperation: b.pathRoleWrite,
+			logical.UpdateOperation: b.pathRoleWrite,
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+
+		ExistenceCheck: b.pathRoleExistenceCheck,
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+type samlRole struct {
+	Policies []string `json:"policies"`
+
+	// Duration after which the token will be revoked unless renewed
+	TTL time.Duration `json:"ttl"`
+
+	// Maximum duration for which the token can be valid
+	MaxTTL time.Duration `json:"max_ttl"`
+
+	BoundSubjects   []string                      `json:"bound_subjects"`
+	BoundAttributes map[string]string             `json:"bound_attributes"`
+	UserAttribute   string                        `json:"user_attribute"`
+	GroupsAttribute string                        `json:"groups_attribute"`
+	BoundCIDRs      []*sockaddr.SockAddrMarshaler `json:"bound_cidrs"`
+}
+
+func (b *backend) role(ctx context.Context, s logical.Storage, name string) (*samlRole, error) {
+	entry, err := s.Get(ctx, rolePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result samlRole
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathRoleExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	role, err := b.role(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return role != nil, nil
+}
+
+func (b *backend) pathRoleList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List(ctx, rolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(roles), nil
+}
+
+func (b *backend) pathRoleRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.role(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"policies":         role.Policies,
+			"ttl":              int64(role.TTL.Seconds()),
+			"max_ttl":          int64(role.MaxTTL.Seconds()),
+			"bound_subjects":   role.BoundSubjects,
+			"bound_attributes": role.BoundAttributes,
+			"user_attribute":   role.UserAttribute,
+			"groups_attribute": role.GroupsAttribute,
+			"bound_cidrs":      role.BoundCIDRs,
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return nil, req.Storage.Delete(ctx, rolePrefix+d.Get("name").(string))
+}
+
+func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	role, err := b.role(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	// Due to the existence check, role will only be nil if it's a create
+	// operation
+	if role == nil {
+		role = &samlRole{}
+	}
+
+	if policiesRaw, ok := d.GetOk("policies"); ok {
+		role.Policies = policyutil.ParsePolicies(policiesRaw)
+	}
+	if ttl, ok := d.GetOk("ttl"); ok {
+		role.TTL = time.Duration(ttl.(int)) * time.Second
+	}
+	if maxTTL, ok := d.GetOk("max_ttl"); ok {
+		role.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+	if role.MaxTTL > 0 && role.TTL > role.MaxTTL {
+		return logical.ErrorResponse("ttl should not be greater than max_ttl"), nil
+	}
+	if boundSubjects, ok := d.GetOk("bound_subjects"); ok {
+		role.BoundSubjects = boundSubjects.([]string)
+	}
+	if boundAttributes, ok := d.GetOk("bound_attributes"); ok {
+		role.BoundAttributes = boundAttributes.(map[string]string)
+	}
+	if userAttribute, ok := d.GetOk("user_attribute"); ok {
+		role.UserAttribute = userAttribute.(string)
+	}
+	if groupsAttribute, ok := d.GetOk("groups_attribute"); ok {
+		role.GroupsAttribute = groupsAttribute.(string)
+	}
+	if boundCIDRs, ok := d.GetOk("bound_cidrs"); ok {
+		parsed, err := parseutil.ParseAddrs(boundCIDRs)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		role.BoundCIDRs = parsed
+	}
+
+	entry, err := logical.StorageEntryJSON(rolePrefix+name, role)
+	if err != nil {
+		return nil, err
+	}
+	return nil, req.Storage.Put(ctx, entry)
+}
+
+// matches returns an error if the assertion does not meet the bindings of
+// the role
+func (r *samlRole) matches(a *assertion) error {
+	if len(r.BoundSubjects) > 0 && !strutil.StrListContains(r.BoundSubjects, a.NameID) {
+		return fmt.Errorf("subject %q is not allowed by the role", a.NameID)
+	}
+
+	for name, allowed := range r.BoundAttributes {
+		var found bool
+		for _, value := range a.Attributes[name] {
+			if strutil.StrListContains(strutil.ParseStringSlice(allowed, ","), value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("attribute %q does not have a value allowed by the role", name)
+		}
+	}
+	return nil
+}
+
+const pathRoleListHelpSyn = `
+Lists all the roles registered with the backend.
+`
+
+const pathRoleListHelpDesc = `
+The list will contain the names of the roles.
+`
+
+const pathRoleHelpSyn = `
+Register a role with the backend.
+`
+
+const pathRoleHelpDesc = `
+A role binds the assertions of the IdP to a set of policies. Assertions
+must meet the bound subjects and attributes of the role to log in. The
+identity alias of the login is the name ID of the subject, or the value of
+the user attribute, and the values of the groups attribute become group
+aliases.
+`
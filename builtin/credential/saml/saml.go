@@ -0,0 +1,366 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	nsSAMLAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsSAMLProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+
+	bindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	bindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	statusSuccess       = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	confirmationBearer  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// clockSkew is the tolerance when checking the validity period of
+	// assertions
+	clockSkew = 90 * time.Second
+
+	maxResponseSize = 1024 * 1024
+)
+
+// idpMetadata is the part of the metadata of an IdP that is needed to log in
+type idpMetadata struct {
+	EntityID string
+	SSOURL   string
+	CertPEM  string
+}
+
+type entityDescriptor struct {
+	XMLName          xml.Name           `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID         string             `xml:"entityID,attr"`
+	IDPSSODescriptor []idpSSODescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type idpSSODescriptor struct {
+	KeyDescriptors []struct {
+		Use     string `xml:"use,attr"`
+		KeyInfo struct {
+			X509Data struct {
+				X509Certificates []string `xml:"X509Certificate"`
+			} `xml:"X509Data"`
+		} `xml:"KeyInfo"`
+	} `xml:"KeyDescriptor"`
+	SingleSignOnServices []struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+	} `xml:"SingleSignOnService"`
+}
+
+// parseIDPMetadata returns the entity ID, the HTTP-Redirect single sign-on
+// URL and the signing certificates of an IdP metadata document
+func parseIDPMetadata(data []byte) (*idpMetadata, error) {
+	var entity entityDescriptor
+	if err := xml.Unmarshal(data, &entity); err != nil {
+		return nil, fmt.Errorf("failed to parse IdP metadata: %v", err)
+	}
+	if len(entity.IDPSSODescriptor) == 0 {
+		return nil, errors.New("IdP metadata does not contain an IDPSSODescriptor")
+	}
+
+	result := &idpMetadata{
+		EntityID: entity.EntityID,
+	}
+	var pemBuf bytes.Buffer
+	for _, descriptor := range entity.IDPSSODescriptor {
+		for _, service := range descriptor.SingleSignOnServices {
+			if service.Binding == bindingHTTPRedirect && result.SSOURL == "" {
+				result.SSOURL = service.Location
+			}
+		}
+		for _, key := range descriptor.KeyDescriptors {
+			if key.Use != "" && key.Use != "signing" {
+				continue
+			}
+			for _, cert := range key.KeyInfo.X509Data.X509Certificates {
+				der, err := decodeBase64(cert)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode IdP certificate: %v", err)
+				}
+				pem.Encode(&pemBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+			}
+		}
+	}
+	result.CertPEM = pemBuf.String()
+
+	switch {
+	case result.EntityID == "":
+		return nil, errors.New("IdP metadata does not contain an entity ID")
+	case result.SSOURL == "":
+		return nil, errors.New("IdP metadata does not contain a single sign-on service with the HTTP-Redirect binding")
+	case result.CertPEM == "":
+		return nil, errors.New("IdP metadata does not contain a signing certificate")
+	}
+	return result, nil
+}
+
+// parseCertificates parses a PEM bundle of certificates
+func parseCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+	return certs, nil
+}
+
+type authnRequest struct {
+	XMLName                     xml.Name `xml:"samlp:AuthnRequest"`
+	NSProtocol                  string   `xml:"xmlns:samlp,attr"`
+	NSAssertion                 string   `xml:"xmlns:saml,attr"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      string   `xml:"saml:Issuer"`
+}
+
+// ssoServiceURL returns the URL that sends an AuthnRequest to the IdP with
+// the HTTP-Redirect binding
+func ssoServiceURL(idpSSOURL, entityID, acsURL, requestID, relayState string, now time.Time) (string, error) {
+	request, err := xml.Marshal(authnRequest{
+		NSProtocol:                  nsSAMLProtocol,
+		NSAssertion:                 nsSAMLAssertion,
+		ID:                          requestID,
+		Version:                     "2.0",
+		IssueInstant:                now.UTC().Format(time.RFC3339),
+		Destination:                 idpSSOURL,
+		AssertionConsumerServiceURL: acsURL,
+		ProtocolBinding:             bindingHTTPPost,
+		Issuer:                      entityID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(request); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(idpSSOURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	query.Set("RelayState", relayState)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// assertion is the verified content of a SAML assertion
+type assertion struct {
+	NameID     string
+	Attributes map[string][]string
+}
+
+// responseValidation holds the expected values of a SAML response
+type responseValidation struct {
+	IDPEntityID string
+	IDPCerts    []*x509.Certificate
+	EntityID    string
+	ACSURL      string
+	RequestID   string
+	Now         time.Time
+}
+
+// parseResponse verifies a base64 encoded SAML response that was posted to
+// the ACS URL and returns the content of its assertion. Either the response or
+// the assertion must be signed, and only signed elements are trusted.
+func parseResponse(samlResponse string, v *responseValidation) (*assertion, error) {
+	data, err := decodeBase64(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SAML response: %v", err)
+	}
+	if len(data) > maxResponseSize {
+		return nil, errors.New("SAML response is too large")
+	}
+	response, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML response: %v", err)
+	}
+	if response.Space != nsSAMLProtocol || response.Local != "Response" {
+		return nil, errors.New("document is not a SAML response")
+	}
+
+	responseSigned := true
+	switch err := verifySignature(response, v.IDPCerts); err {
+	case nil:
+	case errNotSigned:
+		responseSigned = false
+	default:
+		return nil, fmt.Errorf("failed to verify the signature of the SAML response: %v", err)
+	}
+
+	if status := response.child(nsSAMLProtocol, "Status"); status != nil {
+		code := status.child(nsSAMLProtocol, "StatusCode")
+		if code == nil || code.attr("Value") != statusSuccess {
+			value := ""
+			if code != nil {
+				value = code.attr("Value")
+			}
+			return nil, fmt.Errorf("IdP returned status %q", value)
+		}
+	}
+	if destination := response.attr("Destination"); destination != "" && destination != v.ACSURL {
+		return nil, fmt.Errorf("SAML response destination %q does not match the ACS URL", destination)
+	}
+	if inResponseTo := response.attr("InResponseTo"); inResponseTo != v.RequestID {
+		return nil, errors.New("SAML response is not a response to the pending request")
+	}
+	if issuer := response.child(nsSAMLAssertion, "Issuer"); issuer != nil && issuer.text() != v.IDPEntityID {
+		return nil, fmt.Errorf("SAML response issuer %q does not match the IdP entity ID", issuer.text())
+	}
+
+	if len(response.children(nsSAMLAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted assertions are not supported")
+	}
+	assertions := response.children(nsSAMLAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("SAML response must contain exactly one assertion")
+	}
+	assertionEl := assertions[0]
+	switch err := verifySignature(assertionEl, v.IDPCerts); {
+	case err == errNotSigned && responseSigned:
+	case err != nil && err != errNotSigned:
+		return nil, fmt.Errorf("failed to verify the signature of the assertion: %v", err)
+	case err != nil:
+		return nil, errors.New("neither the SAML response nor the assertion is signed")
+	}
+
+	return v.parseAssertion(assertionEl)
+}
+
+func (v *responseValidation) parseAssertion(el *xmlElement) (*assertion, error) {
+	issuer := el.child(nsSAMLAssertion, "Issuer")
+	if issuer == nil || issuer.text() != v.IDPEntityID {
+		return nil, errors.New("assertion issuer does not match the IdP entity ID")
+	}
+
+	if conditions := el.child(nsSAMLAssertion, "Conditions"); conditions != nil {
+		if err := v.checkValidity(conditions); err != nil {
+			return nil, fmt.Errorf("assertion is not valid: %v", err)
+		}
+		for _, restriction := range conditions.children(nsSAMLAssertion, "AudienceRestriction") {
+			var found bool
+			for _, audience := range restriction.children(nsSAMLAssertion, "Audience") {
+				if audience.text() == v.EntityID {
+					found = true
+				}
+			}
+			if !found {
+				return nil, errors.New("assertion audience does not include the entity ID")
+			}
+		}
+	}
+
+	subject := el.child(nsSAMLAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("assertion does not have a subject")
+	}
+	nameID := subject.child(nsSAMLAssertion, "NameID")
+	if nameID == nil || nameID.text() == "" {
+		return nil, errors.New("assertion subject does not have a name ID")
+	}
+
+	// The bearer confirmation binds the assertion to the ACS URL and the
+	// pending request
+	var confirmed bool
+	var confirmErr error
+	for _, confirmation := range subject.children(nsSAMLAssertion, "SubjectConfirmation") {
+		if confirmation.attr("Method") != confirmationBearer {
+			continue
+		}
+		data := confirmation.child(nsSAMLAssertion, "SubjectConfirmationData")
+		switch {
+		case data == nil:
+			confirmErr = errors.New("bearer subject confirmation does not have data")
+		case data.attr("Recipient") != v.ACSURL:
+			confirmErr = fmt.Errorf("subject confirmation recipient %q does not match the ACS URL", data.attr("Recipient"))
+		case data.attr("InResponseTo") != v.RequestID:
+			confirmErr = errors.New("subject confirmation is not for the pending request")
+		case data.attr("NotOnOrAfter") == "":
+			confirmErr = errors.New("subject confirmation does not expire")
+		default:
+			if confirmErr = v.checkValidity(data); confirmErr == nil {
+				confirmed = true
+			}
+		}
+	}
+	if !confirmed {
+		if confirmErr == nil {
+			confirmErr = errors.New("assertion does not have a bearer subject confirmation")
+		}
+		return nil, confirmErr
+	}
+
+	result := &assertion{
+		NameID:     nameID.text(),
+		Attributes: map[string][]string{},
+	}
+	for _, statement := range el.children(nsSAMLAssertion, "AttributeStatement") {
+		for _, attr := range statement.children(nsSAMLAssertion, "Attribute") {
+			name := attr.attr("Name")
+			for _, value := range attr.children(nsSAMLAssertion, "AttributeValue") {
+				result.Attributes[name] = append(result.Attributes[name], value.text())
+			}
+		}
+	}
+	return result, nil
+}
+
+// checkValidity checks the NotBefore and NotOnOrAfter attributes of the
+// element
+func (v *responseValidation) checkValidity(el *xmlElement) error {
+	if notBefore := el.attr("NotBefore"); notBefore != "" {
+		t, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil {
+			return fmt.Errorf("invalid NotBefore: %v", err)
+		}
+		if v.Now.Add(clockSkew).Before(t) {
+			return errors.New("not yet valid")
+		}
+	}
+	if notOnOrAfter := el.attr("NotOnOrAfter"); notOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339, notOnOrAfter)
+		if err != nil {
+			return fmt.Errorf("invalid NotOnOrAfter: %v", err)
+		}
+		if !v.Now.Add(-clockSkew).Before(t) {
+			return errors.New("expired")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const nsXML = "http://www.w3.org/XML/1998/namespace"
+
+// xmlElement is an element of a parsed XML document. Unlike the structs
+// populated by encoding/xml, it retains the namespace prefixes and
+// declarations that are needed to canonicalize the element.
+type xmlElement struct {
+	Prefix string
+	Local  string
+	Space  string
+
+	Attrs    []xmlAttr
+	NSDecls  []xmlNSDecl
+	Children []interface{}
+	Parent   *xmlElement
+}
+
+// xmlAttr is an attribute of an element. Namespace declarations are kept
+// separately as xmlNSDecl.
+type xmlAttr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+// xmlNSDecl declares the namespace URI of a prefix, where the empty prefix
+// is the default namespace
+type xmlNSDecl struct {
+	Prefix string
+	URI    string
+}
+
+// The children of an element are *xmlElement, xmlText or xmlComment values
+type xmlText string
+type xmlComment string
+
+// parseXML parses a document into a tree of elements. Documents with
+// directives, such as DOCTYPE declarations, and documents that reuse values of
+// ID attributes are rejected.
+func parseXML(data []byte) (*xmlElement, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	var root, cur *xmlElement
+	ids := map[string]bool{}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil && cur == nil {
+				return nil, errors.New("document has more than one root element")
+			}
+
+			el := &xmlElement{
+				Prefix: t.Name.Space,
+				Local:  t.Name.Local,
+				Parent: cur,
+			}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					el.NSDecls = append(el.NSDecls, xmlNSDecl{URI: attr.Value})
+				case attr.Name.Space == "xmlns":
+					el.NSDecls = append(el.NSDecls, xmlNSDecl{Prefix: attr.Name.Local, URI: attr.Value})
+				default:
+					el.Attrs = append(el.Attrs, xmlAttr{Prefix: attr.Name.Space, Local: attr.Name.Local, Value: attr.Value})
+				}
+			}
+
+			var ok bool
+			if el.Space, ok = el.lookupNamespace(el.Prefix); !ok && el.Prefix != "" {
+				return nil, fmt.Errorf("undeclared namespace prefix %q", el.Prefix)
+			}
+			for i, attr := range el.Attrs {
+				if attr.Prefix == "" {
+					if attr.Local == "ID" {
+						if ids[attr.Value] {
+							return nil, fmt.Errorf("duplicate ID %q", attr.Value)
+						}
+						ids[attr.Value] = true
+					}
+					continue
+				}
+				if el.Attrs[i].Space, ok = el.lookupNamespace(attr.Prefix); !ok {
+					return nil, fmt.Errorf("undeclared namespace prefix %q", attr.Prefix)
+				}
+			}
+
+			if cur == nil {
+				root = el
+			} else {
+				cur.Children = append(cur.Children, el)
+			}
+			cur = el
+
+		case xml.EndElement:
+			if cur == nil || t.Name.Space != cur.Prefix || t.Name.Local != cur.Local {
+				return nil, fmt.Errorf("unexpected end element %q", t.Name.Local)
+			}
+			cur = cur.Parent
+
+		case xml.CharData:
+			if cur != nil {
+				cur.Children = append(cur.Children, xmlText(t))
+			} else if len(bytes.TrimSpace(t)) != 0 {
+				return nil, errors.New("unexpected character data outside of the root element")
+			}
+
+		case xml.Comment:
+			if cur != nil {
+				cur.Children = append(cur.Children, xmlComment(t))
+			}
+
+		case xml.Directive:
+			return nil, errors.New("XML directives are not allowed")
+		}
+	}
+
+	if root == nil || cur != nil {
+		return nil, errors.New("document is incomplete")
+	}
+	return root, nil
+}
+
+// lookupNamespace returns the namespace URI of the prefix in the scope of the
+// element, and whether it is declared
+func (e *xmlElement) lookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return nsXML, true
+	}
+	for el := e; el != nil; el = el.Parent {
+		for _, decl := range el.NSDecls {
+			if decl.Prefix == prefix {
+				return decl.URI, true
+			}
+		}
+	}
+	return "", false
+}
+
+// attr returns the value of the unqualified attribute with the given name
+func (e *xmlElement) attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Prefix == "" && attr.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// children returns the child elements with the given namespace and name
+func (e *xmlElement) children(space, local string) []*xmlElement {
+	var result []*xmlElement
+	for _, child := range e.Children {
+		if el, ok := child.(*xmlElement); ok && el.Space == space && el.Local == local {
+			result = append(result, el)
+		}
+	}
+	return result
+}
+
+// child returns the first child element with the given namespace and name,
+// or nil
+func (e *xmlElement) child(space, local string) *xmlElement {
+	children := e.children(space, local)
+	if len(children) == 0 {
+		return nil
+	}
+	return children[0]
+}
+
+// text returns the character data of the element, excluding that of its
+// child elements, with surrounding whitespace removed
+func (e *xmlElement) text() string {
+	var b strings.Builder
+	for _, child := range e.Children {
+		if text, ok := child.(xmlText); ok {
+			b.WriteString(string(text))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// canonicalizer serializes elements with Exclusive XML Canonicalization, as
+// specified by https://www.w3.org/TR/xml-exc-c14n/
+type canonicalizer struct {
+	// withComments retains comments in the output
+	withComments bool
+
+	// inclusivePrefixes are the prefixes of the InclusiveNamespaces
+	// PrefixList, which are treated as in inclusive canonicalization. The
+	// default namespace is the empty prefix.
+	inclusivePrefixes []string
+
+	// exclude is omitted from the output, as done by the enveloped signature
+	// transform
+	exclude *xmlElement
+}
+
+// canonicalize returns the canonical form of the subtree of the element
+func (c *canonicalizer) canonicalize(e *xmlElement) []byte {
+	var buf bytes.Buffer
+	c.writeElement(&buf, e, map[string]string{})
+	return buf.Bytes()
+}
+
+// writeElement writes the element and its children. rendered holds the
+// namespace declarations in effect from the output ancestors.
+func (c *canonicalizer) writeElement(buf *bytes.Buffer, e *xmlElement, rendered map[string]string) {
+	var decls []xmlNSDecl
+	declared := map[string]bool{}
+	render := func(prefix string, requireDeclared bool) {
+		if prefix == "xml" || declared[prefix] {
+			return
+		}
+		uri, ok := e.lookupNamespace(prefix)
+		if requireDeclared && !ok {
+			return
+		}
+		// The default namespace is initially empty, so that xmlns="" is
+		// only rendered to undo a default namespace of an ancestor
+		if prev, ok := rendered[prefix]; (ok || prefix == "") && prev == uri {
+			return
+		}
+		declared[prefix] = true
+		decls = append(decls, xmlNSDecl{Prefix: prefix, URI: uri})
+	}
+
+	// Render the namespaces visibly utilized by the element and its
+	// attributes, and those of the inclusive prefix list that are in scope
+	render(e.Prefix, false)
+	for _, attr := range e.Attrs {
+		if attr.Prefix != "" {
+			render(attr.Prefix, false)
+		}
+	}
+	for _, prefix := range c.inclusivePrefixes {
+		render(prefix, true)
+	}
+
+	if len(decls) > 0 {
+		inner := make(map[string]string, len(rendered)+len(decls))
+		for prefix, uri := range rendered {
+			inner[prefix] = uri
+		}
+		for _, decl := range decls {
+			inner[decl.Prefix] = decl.URI
+		}
+		rendered = inner
+	}
+
+	sort.Slice(decls, func(i, j int) bool {
+		return decls[i].Prefix < decls[j].Prefix
+	})
+	attrs := make([]xmlAttr, len(e.Attrs))
+	copy(attrs, e.Attrs)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Space != attrs[j].Space {
+			return attrs[i].Space < attrs[j].Space
+		}
+		return attrs[i].Local < attrs[j].Local
+	})
+
+	name := qualifiedName(e.Prefix, e.Local)
+	buf.WriteString("<" + name)
+	for _, decl := range decls {
+		buf.WriteString(" " + qualifiedName("xmlns", decl.Prefix) + `="`)
+		escapeAttr(buf, decl.URI)
+		buf.WriteString(`"`)
+	}
+	for _, attr := range attrs {
+		buf.WriteString(" " + qualifiedName(attr.Prefix, attr.Local) + `="`)
+		escapeAttr(buf, attr.Value)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	for _, child := range e.Children {
+		switch child := child.(type) {
+		case *xmlElement:
+			if child != c.exclude {
+				c.writeElement(buf, child, rendered)
+			}
+		case xmlText:
+			escapeText(buf, string(child))
+		case xmlComment:
+			if c.withComments {
+				buf.WriteString("<!--" + string(child) + "-->")
+			}
+		}
+	}
+
+	buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	if local == "" {
+		return prefix
+	}
+	return prefix + ":" + local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(buf *bytes.Buffer, s string) {
+	textEscaper.WriteString(buf, s)
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	attrEscaper.WriteString(buf, s)
+}
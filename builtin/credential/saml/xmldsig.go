@@ -0,0 +1,207 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// Register the hash functions of the supported algorithms
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	nsDSig       = "http://www.w3.org/2000/09/xmldsig#"
+	nsExcC14N    = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algExcC14N   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnveloped = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+
+	algExcC14NWithComments = algExcC14N + "WithComments"
+)
+
+var digestAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#sha1":  crypto.SHA1,
+	"http://www.w3.org/2001/04/xmlenc#sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmlenc#sha512": crypto.SHA512,
+}
+
+var signatureAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#rsa-sha1":          crypto.SHA1,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":   crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":   crypto.SHA512,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512": crypto.SHA512,
+}
+
+// errNotSigned is returned by verifySignature for elements without a
+// signature
+var errNotSigned = errors.New("element is not signed")
+
+// verifySignature verifies the enveloped XML signature of the element against
+// the certificates. The signature must be a child of the element and must
+// reference the element, and only the element, by its ID, so that the element
+// can be trusted as a whole once the signature is verified.
+func verifySignature(e *xmlElement, certs []*x509.Certificate) error {
+	signatures := e.children(nsDSig, "Signature")
+	switch len(signatures) {
+	case 0:
+		return errNotSigned
+	case 1:
+	default:
+		return errors.New("element has more than one signature")
+	}
+	signature := signatures[0]
+
+	signedInfo, err := exactlyOne(signature, nsDSig, "SignedInfo")
+	if err != nil {
+		return err
+	}
+	c14nMethod, err := exactlyOne(signedInfo, nsDSig, "CanonicalizationMethod")
+	if err != nil {
+		return err
+	}
+	signedInfoC14N, err := newCanonicalizer(c14nMethod)
+	if err != nil {
+		return err
+	}
+	signatureMethod, err := exactlyOne(signedInfo, nsDSig, "SignatureMethod")
+	if err != nil {
+		return err
+	}
+	signatureHash, ok := signatureAlgorithms[signatureMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %q", signatureMethod.attr("Algorithm"))
+	}
+
+	// Verify the digest of the referenced element
+	reference, err := exactlyOne(signedInfo, nsDSig, "Reference")
+	if err != nil {
+		return err
+	}
+	id := e.attr("ID")
+	if id == "" || reference.attr("URI") != "#"+id {
+		return errors.New("signature does not reference the signed element")
+	}
+
+	var c14n *canonicalizer
+	var enveloped bool
+	if transforms := reference.child(nsDSig, "Transforms"); transforms != nil {
+		for _, transform := range transforms.children(nsDSig, "Transform") {
+			if transform.attr("Algorithm") == algEnveloped {
+				enveloped = true
+				continue
+			}
+			if c14n != nil {
+				return errors.New("signature has more than one canonicalization transform")
+			}
+			if c14n, err = newCanonicalizer(transform); err != nil {
+				return err
+			}
+		}
+	}
+	if !enveloped || c14n == nil {
+		return errors.New("signature must use the enveloped signature and exclusive canonicalization transforms")
+	}
+	c14n.exclude = signature
+
+	digestMethod, err := exactlyOne(reference, nsDSig, "DigestMethod")
+	if err != nil {
+		return err
+	}
+	digestHash, ok := digestAlgorithms[digestMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("unsupported digest algorithm %q", digestMethod.attr("Algorithm"))
+	}
+	digestValue, err := exactlyOne(reference, nsDSig, "DigestValue")
+	if err != nil {
+		return err
+	}
+	expectedDigest, err := decodeBase64(digestValue.text())
+	if err != nil {
+		return fmt.Errorf("invalid digest value: %v", err)
+	}
+
+	h := digestHash.New()
+	h.Write(c14n.canonicalize(e))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expectedDigest) != 1 {
+		return errors.New("digest of the signed element does not match")
+	}
+
+	// Verify the signature of the signed info
+	signatureValue, err := exactlyOne(signature, nsDSig, "SignatureValue")
+	if err != nil {
+		return err
+	}
+	sig, err := decodeBase64(signatureValue.text())
+	if err != nil {
+		return fmt.Errorf("invalid signature value: %v", err)
+	}
+
+	h = signatureHash.New()
+	h.Write(signedInfoC14N.canonicalize(signedInfo))
+	hashed := h.Sum(nil)
+	for _, cert := range certs {
+		switch pub := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(pub, signatureHash, hashed, sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			// XML signatures hold the concatenation of r and s
+			if len(sig)%2 != 0 {
+				continue
+			}
+			r := new(big.Int).SetBytes(sig[:len(sig)/2])
+			s := new(big.Int).SetBytes(sig[len(sig)/2:])
+			if ecdsa.Verify(pub, hashed, r, s) {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("signature could not be verified with any of the IdP certificates")
+}
+
+// newCanonicalizer returns a canonicalizer for a CanonicalizationMethod or
+// Transform element
+func newCanonicalizer(method *xmlElement) (*canonicalizer, error) {
+	c := &canonicalizer{}
+	switch method.attr("Algorithm") {
+	case algExcC14N:
+	case algExcC14NWithComments:
+		c.withComments = true
+	default:
+		return nil, fmt.Errorf("unsupported canonicalization algorithm %q", method.attr("Algorithm"))
+	}
+
+	if inclusive := method.child(nsExcC14N, "InclusiveNamespaces"); inclusive != nil {
+		for _, prefix := range strings.Fields(inclusive.attr("PrefixList")) {
+			if prefix == "#default" {
+				prefix = ""
+			}
+			c.inclusivePrefixes = append(c.inclusivePrefixes, prefix)
+		}
+	}
+	return c, nil
+}
+
+func exactlyOne(e *xmlElement, space, local string) (*xmlElement, error) {
+	children := e.children(space, local)
+	if len(children) != 1 {
+		return nil, fmt.Errorf("expected exactly one %s element in %s", local, e.Local)
+	}
+	return children[0], nil
+}
+
+// decodeBase64 decodes base64 that may be wrapped over several lines
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
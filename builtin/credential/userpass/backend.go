@@ -3,6 +3,7 @@ package userpass
 import (
 	"context"
 
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/mfa"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -17,7 +18,9 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func Backend() *backend {
-	var b backend
+	b := backend{
+		lockoutLocks: locksutil.CreateLocks(),
+	}
 	b.Backend = &framework.Backend{
 		Help: backendHelp,
 
@@ -34,6 +37,9 @@ func Backend() *backend {
 			pathUsersList(&b),
 			pathUserPolicies(&b),
 			pathUserPassword(&b),
+			pathUserUnlock(&b),
+			pathConfigPasswordPolicy(&b),
+			pathConfigLockout(&b),
 		},
 			mfa.MFAPaths(b.Backend, pathLogin(&b))...,
 		),
@@ -47,6 +53,9 @@ func Backend() *backend {
 
 type backend struct {
 	*framework.Backend
+
+	// lockoutLocks guard the failed login attempts of users
+	lockoutLocks []*locksutil.LockEntry
 }
 
 const backendHelp = `
@@ -54,6 +63,10 @@ The "userpass" credential provider allows authentication using
 a combination of a username and password. No additional factors
 are supported.
 
+Passwords can be required to meet a policy configured through
+"config/password_policy", and users can be locked out after failed
+login attempts as configured through "config/lockout".
+
 The username/password combination is configured using the "users/"
 endpoints by a user with root access. Authentication is then done
 by supplying the two fields for "login".
@@ -9,6 +9,7 @@ import (
 
 	"crypto/tls"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	logicaltest "github.com/hashicorp/vault/logical/testing"
@@ -207,6 +208,192 @@ func TestBackend_policiesUpdate(t *testing.T) {
 
 }
 
+func TestBackend_passwordPolicy(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+	b, err := Factory(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:      path,
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	resp, err := write("config/password_policy", map[string]interface{}{
+		"min_length":       10,
+		"require_digit":    true,
+		"require_special":  true,
+		"banned_passwords": "Password123!",
+		"history_count":    2,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config/password_policy",
+		Operation: logical.ReadOperation,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if resp.Data["min_length"] != 10 || resp.Data["require_uppercase"] != false || resp.Data["history_count"] != 2 {
+		t.Fatalf("bad: policy: %#v", resp.Data)
+	}
+
+	for _, password := range []string{"short1!", "longpassword!", "longpassword1", "password123!"} {
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      "users/web",
+			Operation: logical.CreateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": password,
+			},
+		})
+		if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+			t.Fatalf("expected %q to be rejected: resp: %#v\nerr: %v", password, resp, err)
+		}
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/web",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "first-password1",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+
+	// The current and the previous password cannot be reused, but older
+	// passwords can
+	for i, step := range []struct {
+		password string
+		ok       bool
+	}{
+		{"first-password1", false},
+		{"second-password2", true},
+		{"first-password1", false},
+		{"second-password2", false},
+		{"third-password3", true},
+		{"first-password1", true},
+	} {
+		resp, err = write("users/web/password", map[string]interface{}{
+			"password": step.password,
+		})
+		if ok := err == nil && (resp == nil || !resp.IsError()); ok != step.ok {
+			t.Fatalf("step %d: bad: resp: %#v\nerr: %v", i, resp, err)
+		}
+	}
+}
+
+func TestBackend_lockout(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+	b, err := Factory(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:       path,
+			Operation:  logical.UpdateOperation,
+			Storage:    storage,
+			Data:       data,
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		})
+	}
+	login := func(password string) (*logical.Response, error) {
+		return write("login/web", map[string]interface{}{
+			"password": password,
+		})
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/web",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "password",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+
+	// Without a threshold users are never locked out
+	for i := 0; i < 5; i++ {
+		if resp, err = login("wrong"); err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+		}
+	}
+
+	resp, err = write("config/lockout", map[string]interface{}{
+		"lockout_threshold": 3,
+		"lockout_duration":  0,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+
+	// A successful login resets the failed attempts
+	for _, password := range []string{"wrong", "wrong", "password", "wrong", "wrong"} {
+		resp, err = login(password)
+		if err != nil || resp == nil || resp.IsError() != (password == "wrong") {
+			t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+		}
+	}
+
+	// The third failed attempt locks the user out, after which the correct
+	// password is rejected as well
+	for _, password := range []string{"wrong", "password"} {
+		resp, err = login(password)
+		if err != errUserLockedOut || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+			t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+		}
+	}
+
+	resp, err = write("users/web/unlock", nil)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if resp, err = login("password"); err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+
+	// Lockouts expire after the lockout duration
+	resp, err = write("config/lockout", map[string]interface{}{
+		"lockout_threshold": 1,
+		"lockout_duration":  1,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if _, err = login("wrong"); err != errUserLockedOut {
+		t.Fatalf("bad: err: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if resp, err = login("password"); err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+}
+
 func testUpdatePassword(t *testing.T, user, password string) logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.UpdateOperation,
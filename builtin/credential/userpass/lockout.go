@@ -0,0 +1,133 @@
+package userpass
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const lockoutPrefix = "lockout/"
+
+// errUserLockedOut is returned for login attempts of locked out users. It
+// wraps logical.ErrPermissionDenied so that it results in a 403, and, unlike
+// the data of error responses, its message is not hashed in the audit log.
+var errUserLockedOut = errwrap.Wrapf("user is locked out: {{err}}", logical.ErrPermissionDenied)
+
+// lockoutEntry tracks the failed login attempts of a user
+type lockoutEntry struct {
+	FailedAttempts int       `json:"failed_attempts"`
+	FirstFailure   time.Time `json:"first_failure"`
+	Locked         bool      `json:"locked"`
+
+	// LockedUntil is zero if the user stays locked out until unlocked
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+func (e *lockoutEntry) isLocked(now time.Time) bool {
+	return e.Locked && (e.LockedUntil.IsZero() || now.Before(e.LockedUntil))
+}
+
+func (b *backend) lockoutEntry(ctx context.Context, s logical.Storage, username string) (*lockoutEntry, error) {
+	entry, err := s.Get(ctx, lockoutPrefix+username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result lockoutEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// checkLockout returns errUserLockedOut if the user is locked out
+func (b *backend) checkLockout(ctx context.Context, s logical.Storage, config *LockoutConfig, username string) error {
+	if config.Threshold == 0 {
+		return nil
+	}
+
+	lock := locksutil.LockForKey(b.lockoutLocks, username)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	entry, err := b.lockoutEntry(ctx, s, username)
+	if err != nil {
+		return err
+	}
+	if entry != nil && entry.isLocked(time.Now()) {
+		return errUserLockedOut
+	}
+	return nil
+}
+
+// recordFailedLogin counts a failed login attempt of the user and locks the
+// user out once the threshold is reached. It returns errUserLockedOut if the
+// user got locked out.
+func (b *backend) recordFailedLogin(ctx context.Context, s logical.Storage, config *LockoutConfig, username string) error {
+	if config.Threshold == 0 {
+		return nil
+	}
+
+	lock := locksutil.LockForKey(b.lockoutLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	entry, err := b.lockoutEntry(ctx, s, username)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch {
+	case entry == nil:
+		entry = &lockoutEntry{}
+	case entry.isLocked(now):
+		return errUserLockedOut
+	}
+
+	// Start counting anew once the window of the first failure has passed or
+	// a previous lockout has expired
+	if entry.Locked || now.Sub(entry.FirstFailure) > config.Window {
+		entry = &lockoutEntry{}
+	}
+	if entry.FailedAttempts == 0 {
+		entry.FirstFailure = now
+	}
+	entry.FailedAttempts++
+
+	if entry.FailedAttempts >= config.Threshold {
+		entry.Locked = true
+		if config.Duration > 0 {
+			entry.LockedUntil = now.Add(config.Duration)
+		}
+		b.Logger().Warn("user locked out after failed login attempts", "username", username, "failed_attempts", entry.FailedAttempts)
+	}
+
+	storageEntry, err := logical.StorageEntryJSON(lockoutPrefix+username, entry)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, storageEntry); err != nil {
+		return err
+	}
+
+	if entry.Locked {
+		return errUserLockedOut
+	}
+	return nil
+}
+
+// resetLockout clears the failed login attempts and the lockout of the user
+func (b *backend) resetLockout(ctx context.Context, s logical.Storage, username string) error {
+	lock := locksutil.LockForKey(b.lockoutLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.Delete(ctx, lockoutPrefix+username)
+}
@@ -0,0 +1,336 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	passwordPolicyConfigPath = "config/password_policy"
+	lockoutConfigPath        = "config/lockout"
+)
+
+func pathConfigPasswordPolicy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/password_policy$",
+		Fields: map[string]*framework.FieldSchema{
+			"min_length": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Minimum number of characters of passwords.",
+			},
+
+			"require_uppercase": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, passwords must contain an uppercase letter.",
+			},
+
+			"require_lowercase": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, passwords must contain a lowercase letter.",
+			},
+
+			"require_digit": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, passwords must contain a digit.",
+			},
+
+			"require_special": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, passwords must contain a character that is neither a letter nor a digit.",
+			},
+
+			"banned_passwords": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma-separated list of passwords that may not be used. The comparison is case-insensitive.",
+			},
+
+			"history_count": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Number of most recent passwords of a user, including the current one, that may not be reused.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigPasswordPolicyRead,
+			logical.UpdateOperation: b.pathConfigPasswordPolicyWrite,
+		},
+
+		HelpSynopsis:    pathConfigPasswordPolicyHelpSyn,
+		HelpDescription: pathConfigPasswordPolicyHelpDesc,
+	}
+}
+
+func pathConfigLockout(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/lockout$",
+		Fields: map[string]*framework.FieldSchema{
+			"lockout_threshold": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Number of failed login attempts within the lockout window after which a user is locked out. If zero, users are never locked out.",
+			},
+
+			"lockout_window": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     900,
+				Description: "Duration after the first failed login attempt within which failed attempts are counted.",
+			},
+
+			"lockout_duration": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     900,
+				Description: "Duration for which a user is locked out. If zero, users stay locked out until they are unlocked.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigLockoutRead,
+			logical.UpdateOperation: b.pathConfigLockoutWrite,
+		},
+
+		HelpSynopsis:    pathConfigLockoutHelpSyn,
+		HelpDescription: pathConfigLockoutHelpDesc,
+	}
+}
+
+// PasswordPolicy restricts the passwords that can be set for users
+type PasswordPolicy struct {
+	MinLength        int      `json:"min_length"`
+	RequireUppercase bool     `json:"require_uppercase"`
+	RequireLowercase bool     `json:"require_lowercase"`
+	RequireDigit     bool     `json:"require_digit"`
+	RequireSpecial   bool     `json:"require_special"`
+	BannedPasswords  []string `json:"banned_passwords"`
+	HistoryCount     int      `json:"history_count"`
+}
+
+// LockoutConfig configures the lockout of users after failed login attempts
+type LockoutConfig struct {
+	Threshold int           `json:"lockout_threshold"`
+	Window    time.Duration `json:"lockout_window"`
+	Duration  time.Duration `json:"lockout_duration"`
+}
+
+func (b *backend) passwordPolicy(ctx context.Context, s logical.Storage) (*PasswordPolicy, error) {
+	entry, err := s.Get(ctx, passwordPolicyConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return &PasswordPolicy{}, nil
+	}
+
+	var result PasswordPolicy
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) lockoutConfig(ctx context.Context, s logical.Storage) (*LockoutConfig, error) {
+	entry, err := s.Get(ctx, lockoutConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return &LockoutConfig{
+			Window:   15 * time.Minute,
+			Duration: 15 * time.Minute,
+		}, nil
+	}
+
+	var result LockoutConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathConfigPasswordPolicyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	policy, err := b.passwordPolicy(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"min_length":        policy.MinLength,
+			"require_uppercase": policy.RequireUppercase,
+			"require_lowercase": policy.RequireLowercase,
+			"require_digit":     policy.RequireDigit,
+			"require_special":   policy.RequireSpecial,
+			"banned_passwords":  policy.BannedPasswords,
+			"history_count":     policy.HistoryCount,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigPasswordPolicyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	policy, err := b.passwordPolicy(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if minLength, ok := d.GetOk("min_length"); ok {
+		policy.MinLength = minLength.(int)
+	}
+	if policy.MinLength < 0 {
+		return logical.ErrorResponse("min_length cannot be negative"), nil
+	}
+	if requireUppercase, ok := d.GetOk("require_uppercase"); ok {
+		policy.RequireUppercase = requireUppercase.(bool)
+	}
+	if requireLowercase, ok := d.GetOk("require_lowercase"); ok {
+		policy.RequireLowercase = requireLowercase.(bool)
+	}
+	if requireDigit, ok := d.GetOk("require_digit"); ok {
+		policy.RequireDigit = requireDigit.(bool)
+	}
+	if requireSpecial, ok := d.GetOk("require_special"); ok {
+		policy.RequireSpecial = requireSpecial.(bool)
+	}
+	if bannedPasswords, ok := d.GetOk("banned_passwords"); ok {
+		policy.BannedPasswords = strutil.RemoveDuplicates(bannedPasswords.([]string), true)
+	}
+	if historyCount, ok := d.GetOk("history_count"); ok {
+		policy.HistoryCount = historyCount.(int)
+	}
+	if policy.HistoryCount < 0 {
+		return logical.ErrorResponse("history_count cannot be negative"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(passwordPolicyConfigPath, policy)
+	if err != nil {
+		return nil, err
+	}
+	return nil, req.Storage.Put(ctx, entry)
+}
+
+func (b *backend) pathConfigLockoutRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.lockoutConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"lockout_threshold": config.Threshold,
+			"lockout_window":    int64(config.Window.Seconds()),
+			"lockout_duration":  int64(config.Duration.Seconds()),
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigLockoutWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.lockoutConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if threshold, ok := d.GetOk("lockout_threshold"); ok {
+		config.Threshold = threshold.(int)
+	}
+	if config.Threshold < 0 {
+		return logical.ErrorResponse("lockout_threshold cannot be negative"), nil
+	}
+	if window, ok := d.GetOk("lockout_window"); ok {
+		config.Window = time.Duration(window.(int)) * time.Second
+	}
+	if config.Window <= 0 {
+		return logical.ErrorResponse("lockout_window must be positive"), nil
+	}
+	if duration, ok := d.GetOk("lockout_duration"); ok {
+		config.Duration = time.Duration(duration.(int)) * time.Second
+	}
+	if config.Duration < 0 {
+		return logical.ErrorResponse("lockout_duration cannot be negative"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(lockoutConfigPath, config)
+	if err != nil {
+		return nil, err
+	}
+	return nil, req.Storage.Put(ctx, entry)
+}
+
+// validate returns an error describing the first requirement of the policy
+// that the password does not meet. The current and previous password hashes
+// of the user are checked against the reuse history.
+func (p *PasswordPolicy) validate(password string, userEntry *UserEntry) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSpecial = true
+		}
+	}
+	switch {
+	case p.RequireUppercase && !hasUpper:
+		return fmt.Errorf("password must contain an uppercase letter")
+	case p.RequireLowercase && !hasLower:
+		return fmt.Errorf("password must contain a lowercase letter")
+	case p.RequireDigit && !hasDigit:
+		return fmt.Errorf("password must contain a digit")
+	case p.RequireSpecial && !hasSpecial:
+		return fmt.Errorf("password must contain a special character")
+	}
+
+	for _, banned := range p.BannedPasswords {
+		if strings.EqualFold(password, banned) {
+			return fmt.Errorf("password is not allowed")
+		}
+	}
+
+	if p.HistoryCount > 0 && userEntry.PasswordHash != nil {
+		hashes := append([][]byte{userEntry.PasswordHash}, userEntry.PasswordHistory...)
+		if len(hashes) > p.HistoryCount {
+			hashes = hashes[:p.HistoryCount]
+		}
+		for _, hash := range hashes {
+			if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil {
+				return fmt.Errorf("password was used recently and cannot be reused")
+			}
+		}
+	}
+
+	return nil
+}
+
+const pathConfigPasswordPolicyHelpSyn = `
+Configure the password policy of users.
+`
+
+const pathConfigPasswordPolicyHelpDesc = `
+This endpoint configures the requirements that passwords must meet when users
+are created or their passwords are updated. Existing passwords are not
+affected by changes to the policy.
+`
+
+const pathConfigLockoutHelpSyn = `
+Configure the lockout of users after failed login attempts.
+`
+
+const pathConfigLockoutHelpDesc = `
+This endpoint configures how many failed login attempts within a window lock
+out a user, and for how long. Locked out users cannot log in, even with the
+correct password, until the lockout expires or the user is unlocked through
+"users/<username>/unlock".
+`
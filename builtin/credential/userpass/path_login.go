@@ -75,16 +75,35 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		return logical.ErrorResponse("login request originated from invalid CIDR"), nil
 	}
 
+	// Locked out users are rejected before their password is checked
+	lockoutConfig, err := b.lockoutConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.checkLockout(ctx, req.Storage, lockoutConfig, username); err != nil {
+		return nil, err
+	}
+
 	// Check for a password match. Check for a hash collision for Vault 0.2+,
 	// but handle the older legacy passwords with a constant time comparison.
 	passwordBytes := []byte(password)
+	var passwordMatch bool
 	if user.PasswordHash != nil {
-		if err := bcrypt.CompareHashAndPassword(user.PasswordHash, passwordBytes); err != nil {
-			return logical.ErrorResponse("invalid username or password"), nil
-		}
+		passwordMatch = bcrypt.CompareHashAndPassword(user.PasswordHash, passwordBytes) == nil
 	} else {
-		if subtle.ConstantTimeCompare([]byte(user.Password), passwordBytes) != 1 {
-			return logical.ErrorResponse("invalid username or password"), nil
+		passwordMatch = subtle.ConstantTimeCompare([]byte(user.Password), passwordBytes) == 1
+	}
+	if !passwordMatch {
+		if err := b.recordFailedLogin(ctx, req.Storage, lockoutConfig, username); err != nil {
+			return nil, err
+		}
+		return logical.ErrorResponse("invalid username or password"), nil
+	}
+
+	// Forget the failed attempts once a login succeeds
+	if lockoutConfig.Threshold > 0 {
+		if err := b.resetLockout(ctx, req.Storage, username); err != nil {
+			return nil, err
 		}
 	}
 
@@ -45,9 +45,9 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("username does not exist")
 	}
 
-	userErr, intErr := b.updateUserPassword(req, d, userEntry)
+	userErr, intErr := b.updateUserPassword(ctx, req, d, userEntry)
 	if intErr != nil {
-		return nil, err
+		return nil, intErr
 	}
 	if userErr != nil {
 		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
@@ -56,16 +56,36 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 	return nil, b.setUser(ctx, req.Storage, username, userEntry)
 }
 
-func (b *backend) updateUserPassword(req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
+func (b *backend) updateUserPassword(ctx context.Context, req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
 	password := d.Get("password").(string)
 	if password == "" {
 		return fmt.Errorf("missing password"), nil
 	}
+
+	policy, err := b.passwordPolicy(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.validate(password, userEntry); err != nil {
+		return err, nil
+	}
+
 	// Generate a hash of the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
+
+	// Keep the hashes of previous passwords that the policy prevents from
+	// being reused
+	var history [][]byte
+	if policy.HistoryCount > 1 && userEntry.PasswordHash != nil {
+		history = append([][]byte{userEntry.PasswordHash}, userEntry.PasswordHistory...)
+		if len(history) > policy.HistoryCount-1 {
+			history = history[:policy.HistoryCount-1]
+		}
+	}
+	userEntry.PasswordHistory = history
 	userEntry.PasswordHash = hash
 	return nil, nil
 }
@@ -75,5 +95,6 @@ Reset user's password.
 `
 
 const pathUserPasswordHelpDesc = `
-This endpoint allows resetting the user's password.
+This endpoint allows resetting the user's password. The new password must
+meet the password policy configured through "config/password_policy".
 `
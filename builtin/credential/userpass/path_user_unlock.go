@@ -0,0 +1,52 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathUserUnlock(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users/" + framework.GenericNameRegex("username") + "/unlock$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username for this user.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUserUnlockUpdate,
+		},
+
+		HelpSynopsis:    pathUserUnlockHelpSyn,
+		HelpDescription: pathUserUnlockHelpDesc,
+	}
+}
+
+func (b *backend) pathUserUnlockUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	username := strings.ToLower(d.Get("username").(string))
+
+	userEntry, err := b.user(ctx, req.Storage, username)
+	if err != nil {
+		return nil, err
+	}
+	if userEntry == nil {
+		return nil, fmt.Errorf("username does not exist")
+	}
+
+	return nil, b.resetLockout(ctx, req.Storage, username)
+}
+
+const pathUserUnlockHelpSyn = `
+Unlock a user that was locked out.
+`
+
+const pathUserUnlockHelpDesc = `
+This endpoint unlocks a user that was locked out after failed login attempts
+and resets the count of failed attempts.
+`
@@ -124,12 +124,13 @@ func (b *backend) pathUserList(ctx context.Context, req *logical.Request, d *fra
 }
 
 func (b *backend) pathUserDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "user/"+strings.ToLower(d.Get("username").(string)))
+	username := strings.ToLower(d.Get("username").(string))
+	err := req.Storage.Delete(ctx, "user/"+username)
 	if err != nil {
 		return nil, err
 	}
 
-	return nil, nil
+	return nil, b.resetLockout(ctx, req.Storage, username)
 }
 
 func (b *backend) pathUserRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -163,9 +164,9 @@ func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d
 	}
 
 	if _, ok := d.GetOk("password"); ok {
-		userErr, intErr := b.updateUserPassword(req, d, userEntry)
+		userErr, intErr := b.updateUserPassword(ctx, req, d, userEntry)
 		if intErr != nil {
-			return nil, err
+			return nil, intErr
 		}
 		if userErr != nil {
 			return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
@@ -212,6 +213,10 @@ type UserEntry struct {
 	// used instead of the actual password in Vault 0.2+.
 	PasswordHash []byte
 
+	// PasswordHistory holds the bcrypt hashes of previous passwords, most
+	// recent first, as far as the password policy prevents their reuse.
+	PasswordHistory [][]byte
+
 	Policies []string
 
 	// Duration after which the user will be revoked unless renewed
@@ -0,0 +1,451 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	acmeContentType        = "application/json"
+	acmeProblemContentType = "application/problem+json"
+
+	acmeErrorPrefix = "urn:ietf:params:acme:error:"
+
+	// acmeNonceTTL is how long a nonce can be used after it was handed out
+	acmeNonceTTL = 5 * time.Minute
+
+	// acmeOrderLifetime is how long orders and their authorizations can be
+	// completed
+	acmeOrderLifetime = 24 * time.Hour
+)
+
+// Statuses of ACME resources
+const (
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+	acmeStatusExpired     = "expired"
+)
+
+// acmeSignatureAlgorithms are the algorithms that requests can be signed
+// with. MAC algorithms are never allowed.
+var acmeSignatureAlgorithms = []string{
+	string(jose.RS256),
+	string(jose.ES256),
+	string(jose.ES384),
+	string(jose.ES512),
+}
+
+type acmeAccount struct {
+	ID        string           `json:"id"`
+	Key       *jose.JSONWebKey `json:"key"`
+	Status    string           `json:"status"`
+	Contact   []string         `json:"contact"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	ID               string           `json:"id"`
+	AccountID        string           `json:"account_id"`
+	Status           string           `json:"status"`
+	Expires          time.Time        `json:"expires"`
+	Identifiers      []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs []string         `json:"authorization_ids"`
+
+	// Certificate is the PEM encoded chain issued when the order was
+	// finalized
+	Certificate  string `json:"certificate,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+}
+
+type acmeAuthorization struct {
+	ID         string           `json:"id"`
+	AccountID  string           `json:"account_id"`
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Wildcard   bool             `json:"wildcard"`
+	Challenges []*acmeChallenge `json:"challenges"`
+}
+
+type acmeChallenge struct {
+	Type      string       `json:"type"`
+	Token     string       `json:"token"`
+	Status    string       `json:"status"`
+	Validated time.Time    `json:"validated,omitempty"`
+	Error     *acmeProblem `json:"error,omitempty"`
+}
+
+// acmeProblem is an error reported to ACME clients as a problem document
+// (RFC 7807)
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status,omitempty"`
+}
+
+func newACMEProblem(status int, typ, detail string) *acmeProblem {
+	return &acmeProblem{
+		Type:   acmeErrorPrefix + typ,
+		Detail: detail,
+		Status: status,
+	}
+}
+
+// acmeRequest is a verified request signed by an ACME client
+type acmeRequest struct {
+	config  *acmeConfig
+	key     *jose.JSONWebKey
+	account *acmeAccount
+	payload []byte
+}
+
+// acmeJWSFields are the fields of the flattened JWS serialization that all
+// POST requests of ACME clients are sent as
+func acmeJWSFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["protected"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The protected header of the JWS.",
+	}
+	fields["payload"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The payload of the JWS.",
+	}
+	fields["signature"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The signature of the JWS.",
+	}
+	return fields
+}
+
+// acmeEnabledConfig returns the configuration of the ACME server, or a
+// problem if it is not enabled
+func (b *backend) acmeEnabledConfig(ctx context.Context, req *logical.Request) (*acmeConfig, *logical.Response, error) {
+	config, err := b.ACME(ctx, req.Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config == nil || !config.Enabled {
+		resp, err := b.acmeProblemResponse(nil, newACMEProblem(http.StatusNotFound, "malformed", "ACME is not enabled on this mount"))
+		return nil, resp, err
+	}
+	return config, nil, nil
+}
+
+// acmeVerify verifies the JWS of a POST request. Requests for new accounts
+// carry the key of the account, all other requests the URL of an existing
+// account. The returned response is set if the request was rejected.
+func (b *backend) acmeVerify(ctx context.Context, req *logical.Request, data *framework.FieldData, newAccount bool) (*acmeRequest, *logical.Response, error) {
+	config, resp, err := b.acmeEnabledConfig(ctx, req)
+	if resp != nil || err != nil {
+		return nil, resp, err
+	}
+
+	reject := func(status int, typ, detail string) (*acmeRequest, *logical.Response, error) {
+		resp, err := b.acmeProblemResponse(config, newACMEProblem(status, typ, detail))
+		return nil, resp, err
+	}
+
+	serialized, err := json.Marshal(map[string]string{
+		"protected": data.Get("protected").(string),
+		"payload":   data.Get("payload").(string),
+		"signature": data.Get("signature").(string),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	jws, err := jose.ParseSigned(string(serialized))
+	if err != nil {
+		return reject(http.StatusBadRequest, "malformed", fmt.Sprintf("failed to parse JWS: %v", err))
+	}
+	if len(jws.Signatures) != 1 {
+		return reject(http.StatusBadRequest, "malformed", "the JWS must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+
+	found := false
+	for _, alg := range acmeSignatureAlgorithms {
+		if header.Algorithm == alg {
+			found = true
+		}
+	}
+	if !found {
+		return reject(http.StatusBadRequest, "badSignatureAlgorithm", fmt.Sprintf("unsupported signature algorithm %q", header.Algorithm))
+	}
+
+	if !b.acmeConsumeNonce(header.Nonce) {
+		return reject(http.StatusBadRequest, "badNonce", "invalid or reused nonce")
+	}
+
+	expectedURL := config.BaseURL + "/" + req.Path
+	if u, _ := header.ExtraHeaders[jose.HeaderKey("url")].(string); u != expectedURL {
+		return reject(http.StatusUnauthorized, "unauthorized", fmt.Sprintf("the url of the JWS must be %q", expectedURL))
+	}
+
+	r := &acmeRequest{
+		config: config,
+	}
+	switch {
+	case newAccount:
+		if header.JSONWebKey == nil || header.KeyID != "" {
+			return reject(http.StatusBadRequest, "malformed", "new accounts must be requested with a jwk and without a kid")
+		}
+		key := header.JSONWebKey.Public()
+		if !key.Valid() {
+			return reject(http.StatusBadRequest, "badPublicKey", "invalid account key")
+		}
+		r.key = &key
+
+	default:
+		if header.JSONWebKey != nil || header.KeyID == "" {
+			return reject(http.StatusBadRequest, "malformed", "requests must carry the kid of the account and no jwk")
+		}
+		accountPrefix := config.BaseURL + "/acme/account/"
+		if !strings.HasPrefix(header.KeyID, accountPrefix) {
+			return reject(http.StatusBadRequest, "accountDoesNotExist", "unknown account")
+		}
+		account, err := b.acmeAccount(ctx, req.Storage, strings.TrimPrefix(header.KeyID, accountPrefix))
+		if err != nil {
+			return nil, nil, err
+		}
+		if account == nil {
+			return reject(http.StatusBadRequest, "accountDoesNotExist", "unknown account")
+		}
+		if account.Status != acmeStatusValid {
+			return reject(http.StatusUnauthorized, "unauthorized", fmt.Sprintf("account is %s", account.Status))
+		}
+		r.account = account
+		r.key = account.Key
+	}
+
+	r.payload, err = jws.Verify(r.key)
+	if err != nil {
+		return reject(http.StatusBadRequest, "malformed", "invalid JWS signature")
+	}
+
+	return r, nil, nil
+}
+
+// decodePayload decodes the JSON payload of the request. An empty payload
+// is a POST-as-GET request and leaves v untouched.
+func (r *acmeRequest) decodePayload(v interface{}) error {
+	if len(r.payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.payload, v)
+}
+
+// acmeNonce returns a new nonce for the client to sign its next request with
+func (b *backend) acmeNonce() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(raw)
+	b.acmeNonces.SetDefault(nonce, struct{}{})
+	return nonce, nil
+}
+
+// acmeConsumeNonce returns whether the nonce was handed out and not used
+// before
+func (b *backend) acmeConsumeNonce(nonce string) bool {
+	if nonce == "" {
+		return false
+	}
+
+	b.acmeNonceLock.Lock()
+	defer b.acmeNonceLock.Unlock()
+
+	if _, ok := b.acmeNonces.Get(nonce); !ok {
+		return false
+	}
+	b.acmeNonces.Delete(nonce)
+	return true
+}
+
+// acmeRawResponse returns a response with the headers that all ACME
+// responses carry. The config is nil if ACME is not enabled.
+func (b *backend) acmeRawResponse(config *acmeConfig, status int, contentType string, body []byte, location string) (*logical.Response, error) {
+	nonce, err := b.acmeNonce()
+	if err != nil {
+		return nil, err
+	}
+	headers := http.Header{}
+	headers.Set("Replay-Nonce", nonce)
+	headers.Set("Cache-Control", "no-store")
+	if config != nil {
+		headers.Set("Link", fmt.Sprintf("<%s/acme/directory>;rel=\"index\"", config.BaseURL))
+	}
+	if location != "" {
+		headers.Set("Location", location)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode: status,
+			logical.HTTPRawHeaders: headers,
+		},
+	}
+	if status != http.StatusNoContent {
+		resp.Data[logical.HTTPContentType] = contentType
+		resp.Data[logical.HTTPRawBody] = body
+	}
+	return resp, nil
+}
+
+func (b *backend) acmeJSONResponse(config *acmeConfig, status int, v interface{}, location string) (*logical.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return b.acmeRawResponse(config, status, acmeContentType, body, location)
+}
+
+func (b *backend) acmeProblemResponse(config *acmeConfig, problem *acmeProblem) (*logical.Response, error) {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return nil, err
+	}
+	return b.acmeRawResponse(config, problem.Status, acmeProblemContentType, body, "")
+}
+
+// acmeThumbprint returns the base64url encoded JWK thumbprint (RFC 7638)
+// of an account key
+func acmeThumbprint(key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// acmeKeyAuthorization returns the key authorization of a challenge token
+// (RFC 8555 section 8.1)
+func acmeKeyAuthorization(token string, key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := acmeThumbprint(key)
+	if err != nil {
+		return "", err
+	}
+	return token + "." + thumbprint, nil
+}
+
+func acmeRandomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func acmeNewID() (string, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", errwrap.Wrapf("failed to generate identifier: {{err}}", err)
+	}
+	return id, nil
+}
+
+func (b *backend) acmeAccount(ctx context.Context, s logical.Storage, id string) (*acmeAccount, error) {
+	var account acmeAccount
+	ok, err := acmeGet(ctx, s, "acme/accounts/"+id, &account)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (b *backend) acmeOrder(ctx context.Context, s logical.Storage, id string) (*acmeOrder, error) {
+	var order acmeOrder
+	ok, err := acmeGet(ctx, s, "acme/orders/"+id, &order)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (b *backend) acmeAuthorization(ctx context.Context, s logical.Storage, id string) (*acmeAuthorization, error) {
+	var authz acmeAuthorization
+	ok, err := acmeGet(ctx, s, "acme/authorizations/"+id, &authz)
+	if !ok || err != nil {
+		return nil, err
+	}
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusExpired
+	}
+	return &authz, nil
+}
+
+func acmeGet(ctx context.Context, s logical.Storage, key string, v interface{}) (bool, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := entry.DecodeJSON(v); err != nil {
+		return false, errwrap.Wrapf(fmt.Sprintf("error decoding %s: {{err}}", key), err)
+	}
+	return true, nil
+}
+
+func acmePut(ctx context.Context, s logical.Storage, key string, v interface{}) error {
+	entry, err := logical.StorageEntryJSON(key, v)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// acmeOrderStatus updates the status of a pending or ready order from its
+// authorizations
+func (b *backend) acmeOrderStatus(ctx context.Context, s logical.Storage, order *acmeOrder) error {
+	if order.Status != acmeStatusPending && order.Status != acmeStatusReady {
+		return nil
+	}
+	if time.Now().After(order.Expires) {
+		order.Status = acmeStatusInvalid
+		return nil
+	}
+
+	status := acmeStatusReady
+	for _, id := range order.AuthorizationIDs {
+		authz, err := b.acmeAuthorization(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		if authz == nil {
+			return fmt.Errorf("authorization %s of order %s not found", id, order.ID)
+		}
+		switch authz.Status {
+		case acmeStatusValid:
+		case acmeStatusPending:
+			status = acmeStatusPending
+		default:
+			order.Status = acmeStatusInvalid
+			return nil
+		}
+	}
+	order.Status = status
+	return nil
+}
@@ -0,0 +1,116 @@
+package pki
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// acmeValidationTimeout bounds the validation of a challenge
+	acmeValidationTimeout = 10 * time.Second
+
+	// maxACMEHTTPResponseSize bounds the responses of http-01 challenges,
+	// which only hold a key authorization
+	maxACMEHTTPResponseSize = 4096
+)
+
+// acmeValidateChallenge checks that the client has provisioned the
+// challenge for the identifier. A non-nil problem reports why the
+// validation failed.
+func (b *backend) acmeValidateChallenge(ctx context.Context, config *acmeConfig, authz *acmeAuthorization, challenge *acmeChallenge, key *jose.JSONWebKey) (*acmeProblem, error) {
+	keyAuthz, err := acmeKeyAuthorization(challenge.Token, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, acmeValidationTimeout)
+	defer cancel()
+
+	switch challenge.Type {
+	case acmeChallengeHTTP01:
+		return b.acmeValidateHTTP01(ctx, authz.Identifier.Value, challenge.Token, keyAuthz), nil
+	case acmeChallengeDNS01:
+		return acmeValidateDNS01(ctx, config, authz.Identifier.Value, keyAuthz), nil
+	default:
+		return nil, fmt.Errorf("unsupported challenge type %q", challenge.Type)
+	}
+}
+
+// acmeValidateHTTP01 fetches the key authorization from the identifier over
+// HTTP (RFC 8555 section 8.3)
+func (b *backend) acmeValidateHTTP01(ctx context.Context, domain, token, keyAuthz string) *acmeProblem {
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "malformed", err.Error())
+	}
+
+	resp, err := b.acmeHTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "connection", fmt.Sprintf("failed to fetch %s: %v", url, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newACMEProblem(http.StatusForbidden, "unauthorized", fmt.Sprintf("unexpected status code %d from %s", resp.StatusCode, url))
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxACMEHTTPResponseSize))
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "connection", fmt.Sprintf("failed to read response from %s: %v", url, err))
+	}
+	if strings.TrimSpace(string(body)) != keyAuthz {
+		return newACMEProblem(http.StatusForbidden, "unauthorized", fmt.Sprintf("the key authorization served at %s does not match", url))
+	}
+	return nil
+}
+
+// acmeValidateDNS01 looks up the digest of the key authorization in a TXT
+// record of the identifier (RFC 8555 section 8.4)
+func acmeValidateDNS01(ctx context.Context, config *acmeConfig, domain, keyAuthz string) *acmeProblem {
+	digest := sha256.Sum256([]byte(keyAuthz))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	name := "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+
+	var records []string
+	if config.DNSResolver == "" {
+		var err error
+		records, err = net.DefaultResolver.LookupTXT(ctx, name)
+		if err != nil {
+			return newACMEProblem(http.StatusBadRequest, "dns", fmt.Sprintf("failed to look up TXT records of %s: %v", name, err))
+		}
+	} else {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), dns.TypeTXT)
+		client := new(dns.Client)
+		in, _, err := client.ExchangeContext(ctx, msg, config.DNSResolver)
+		if err != nil {
+			return newACMEProblem(http.StatusBadRequest, "dns", fmt.Sprintf("failed to look up TXT records of %s: %v", name, err))
+		}
+		if in.Rcode != dns.RcodeSuccess {
+			return newACMEProblem(http.StatusBadRequest, "dns", fmt.Sprintf("failed to look up TXT records of %s: %s", name, dns.RcodeToString[in.Rcode]))
+		}
+		for _, rr := range in.Answer {
+			if txt, ok := rr.(*dns.TXT); ok {
+				records = append(records, strings.Join(txt.Txt, ""))
+			}
+		}
+	}
+
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+	return newACMEProblem(http.StatusForbidden, "unauthorized", fmt.Sprintf("no TXT record of %s matches the key authorization", name))
+}
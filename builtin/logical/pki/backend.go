@@ -2,12 +2,18 @@ package pki
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 // Factory creates a new backend implementing the logical.Backend interface
@@ -33,12 +39,28 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"crl/delta/pem",
+				"crl/delta",
+				"ca/issuer/*",
+				"ca_chain/issuer/*",
+				"crl/issuer/*",
+				"ocsp",
+				"ocsp/*",
+				"acme/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
+				"crls/",
+				"delta-crls/",
+				"crl-state/",
 				"certs/",
+				"cert-index/",
+				"cert-index-expiry/",
+				"cert-index-role/",
+				"cert-index-issuer/",
+				"cert-index-state",
 			},
 
 			Root: []string{
@@ -48,6 +70,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 
 			SealWrapStorage: []string{
 				"config/ca_bundle",
+				"keys/",
+				"config/ocsp",
 			},
 		},
 
@@ -55,14 +79,18 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathListRoles(&b),
 			pathRoles(&b),
 			pathGenerateRoot(&b),
+			pathRotateRoot(&b),
 			pathSignIntermediate(&b),
 			pathSignSelfIssued(&b),
 			pathDeleteRoot(&b),
 			pathGenerateIntermediate(&b),
 			pathSetSignedIntermediate(&b),
 			pathConfigCA(&b),
+			pathConfigIssuers(&b),
 			pathConfigCRL(&b),
 			pathConfigURLs(&b),
+			pathConfigOCSP(&b),
+			pathConfigACME(&b),
 			pathSignVerbatim(&b),
 			pathSign(&b),
 			pathIssue(&b),
@@ -71,22 +99,48 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathFetchCAChain(&b),
 			pathFetchCRL(&b),
 			pathFetchCRLViaCertPath(&b),
+			pathFetchIssuerCA(&b),
+			pathFetchIssuerCAChain(&b),
+			pathFetchIssuerCRL(&b),
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
+			pathSearchCerts(&b),
+			pathCertExpiryReport(&b),
+			pathOCSP(&b),
+			pathOCSPGet(&b),
 			pathRevoke(&b),
 			pathTidy(&b),
+			pathListIssuers(&b),
+			pathIssuers(&b),
+			pathListKeys(&b),
+			pathKeys(&b),
+			pathACMEDirectory(&b),
+			pathACMENewNonce(&b),
+			pathACMENewAccount(&b),
+			pathACMEAccount(&b),
+			pathACMENewOrder(&b),
+			pathACMEOrder(&b),
+			pathACMEAuthorization(&b),
+			pathACMEChallenge(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
 		},
 
-		BackendType: logical.TypeLogical,
+		PeriodicFunc: b.periodicFunc,
+		Invalidate:   b.invalidate,
+		BackendType:  logical.TypeLogical,
 	}
 
 	b.crlLifetime = time.Hour * 72
 	b.tidyCASGuard = new(uint32)
+	b.issuersMigrated = new(uint32)
+	b.certIndexed = new(uint32)
+	b.expiryReports = cache.New(expiryReportCacheTTL, expiryReportCacheTTL)
 	b.storage = conf.StorageView
+	b.acmeNonces = cache.New(acmeNonceTTL, acmeNonceTTL)
+	b.acmeHTTPClient = cleanhttp.DefaultClient()
 
 	return &b
 }
@@ -98,6 +152,130 @@ type backend struct {
 	crlLifetime       time.Duration
 	revokeStorageLock sync.RWMutex
 	tidyCASGuard      *uint32
+
+	// crlLock serializes CRL builds, which share the CRL numbers of each
+	// issuer
+	crlLock sync.Mutex
+
+	// issuersLock serializes changes to the issuers and keys of the mount
+	issuersLock sync.Mutex
+
+	// issuersMigrated is set once the CA of the mount, if created before
+	// multiple issuers were supported, was migrated to an issuer. It is
+	// cleared when the CA or the issuers are changed by another node.
+	issuersMigrated *uint32
+
+	// certIndexed is set once every stored certificate is indexed
+	certIndexed *uint32
+
+	// expiryReports caches the expiry reports by reporting window
+	expiryReports *cache.Cache
+
+	// acmeNonces holds the nonces handed out to ACME clients that were not
+	// used yet
+	acmeNonces    *cache.Cache
+	acmeNonceLock sync.Mutex
+
+	// acmeLock serializes changes to the state of ACME accounts, orders and
+	// authorizations
+	acmeLock sync.Mutex
+
+	// acmeHTTPClient fetches the responses of http-01 challenges
+	acmeHTTPClient *http.Client
+}
+
+// HandleRequest migrates the legacy CA of the mount before authenticated
+// writes and rollbacks. Other requests, which may be unauthenticated, see an
+// unmigrated legacy CA through a view of the storage that is not written to.
+func (b *backend) HandleRequest(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	if req.Storage != nil && atomic.LoadUint32(b.issuersMigrated) == 0 {
+		switch req.Operation {
+		case logical.CreateOperation, logical.UpdateOperation, logical.DeleteOperation, logical.RevokeOperation, logical.RollbackOperation:
+			if !b.unauthenticatedPath(req.Path) {
+				if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+					return nil, errutil.InternalError{Err: fmt.Sprintf("unable to migrate the legacy CA: %v", err)}
+				}
+				break
+			}
+			fallthrough
+		default:
+			s, err := b.legacyCAView(ctx, req.Storage)
+			if err != nil {
+				return nil, errutil.InternalError{Err: fmt.Sprintf("unable to read the legacy CA: %v", err)}
+			}
+			req.Storage = s
+		}
+	}
+	return b.Backend.HandleRequest(ctx, req)
+}
+
+// unauthenticatedPath reports whether the path can be requested without a
+// token
+func (b *backend) unauthenticatedPath(path string) bool {
+	for _, p := range b.PathsSpecial.Unauthenticated {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *backend) invalidate(_ context.Context, key string) {
+	switch {
+	case key == certIndexStatePath:
+		atomic.StoreUint32(b.certIndexed, 0)
+	case key == legacyBundlePath, key == legacyMigrationLogPath, key == "config/issuers",
+		strings.HasPrefix(key, issuerPrefix), strings.HasPrefix(key, keyPrefix):
+		atomic.StoreUint32(b.issuersMigrated, 0)
+	}
+}
+
+// periodicFunc of the backend will be invoked once a minute by the
+// RollbackManager. It migrates the legacy CA of the mount and indexes the
+// certificates stored before the index existed, and if automatic rebuilding
+// is enabled, it rebuilds the CRLs when one of them is within the grace
+// period of its next update.
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return err
+	}
+	if err := b.indexLegacyCerts(ctx, req.Storage); err != nil {
+		return err
+	}
+
+	config, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if config == nil || !config.AutoRebuild {
+		return nil
+	}
+	gracePeriod, err := time.ParseDuration(config.AutoRebuildGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	for _, issuerID := range issuerIDs {
+		state, err := fetchCRLState(ctx, req.Storage, issuerID)
+		if err != nil {
+			return err
+		}
+		if state == nil || time.Now().Add(gracePeriod).After(state.NextUpdate) {
+			return buildCRL(ctx, b, req)
+		}
+	}
+	return nil
 }
 
 const backendHelp = `
@@ -5,21 +5,29 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"math/big"
 	mathrand "math/rand"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,13 +37,16 @@ import (
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/ocsputil"
 	"github.com/hashicorp/vault/helper/strutil"
 	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/logical"
 	logicaltest "github.com/hashicorp/vault/logical/testing"
 	"github.com/hashicorp/vault/vault"
+	"github.com/miekg/dns"
 	"github.com/mitchellh/mapstructure"
 	"golang.org/x/net/idna"
+	jose "gopkg.in/square/go-jose.v2"
 )
 
 var (
@@ -373,6 +384,13 @@ func checkCertsAndPrivateKey(keyType string, key crypto.Signer, usage x509.KeyUs
 			if err != nil {
 				return nil, fmt.Errorf("error parsing EC key: %s", err)
 			}
+		case "ed25519":
+			parsedCertBundle.PrivateKeyType = certutil.Ed25519PrivateKey
+			parsedCertBundle.PrivateKey = key
+			parsedCertBundle.PrivateKeyBytes, err = x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing Ed25519 key: %s", err)
+			}
 		}
 	}
 
@@ -391,6 +409,8 @@ func checkCertsAndPrivateKey(keyType string, key crypto.Signer, usage x509.KeyUs
 	case parsedCertBundle.PrivateKeyType == certutil.RSAPrivateKey && keyType != "rsa":
 		fallthrough
 	case parsedCertBundle.PrivateKeyType == certutil.ECPrivateKey && keyType != "ec":
+		fallthrough
+	case parsedCertBundle.PrivateKeyType == certutil.Ed25519PrivateKey && keyType != "ed25519":
 		return nil, fmt.Errorf("given key type does not match type found in bundle")
 	}
 
@@ -2041,7 +2061,7 @@ func TestBackend_SignSelfIssued(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	signingBundle, err := fetchCAInfo(context.Background(), &logical.Request{Storage: storage})
+	signingBundle, err := fetchCAInfo(context.Background(), b, &logical.Request{Storage: storage}, defaultRef)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2351,6 +2371,7 @@ func TestBackend_AllowedSerialNumbers(t *testing.T) {
 	_, err = client.Logical().Write("root/roles/test", map[string]interface{}{
 		"allow_any_name":    true,
 		"enforce_hostnames": false,
+		"ttl":               "1h",
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -2623,3 +2644,1777 @@ var (
 	ecCAKey   string
 	ecCACert  string
 )
+
+func TestBackend_OCSP(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+	for _, mount := range []string{"root", "other"} {
+		err := client.Sys().Mount(mount, &api.MountInput{
+			Type: "pki",
+			Config: api.MountConfigInput{
+				DefaultLeaseTTL: "16h",
+				MaxLeaseTTL:     "60h",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Logical().Write(mount+"/root/generate/internal", map[string]interface{}{
+			"ttl":         "40h",
+			"common_name": mount + ".example.com",
+			"key_type":    "ec",
+			"key_bits":    256,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Logical().Write(mount+"/roles/test", map[string]interface{}{
+			"allow_any_name":    true,
+			"enforce_hostnames": false,
+			"key_type":          "ec",
+			"key_bits":          256,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := client.Logical().Write("root/roles/ocsp", map[string]interface{}{
+		"allow_any_name":    true,
+		"enforce_hostnames": false,
+		"ext_key_usage":     "OCSPSigning",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func(mount, role string) (*x509.Certificate, *api.Secret) {
+		t.Helper()
+		resp, err := client.Logical().Write(mount+"/issue/"+role, map[string]interface{}{
+			"common_name": "ocsp-test",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, resp
+	}
+
+	readCA := func(mount string) *x509.Certificate {
+		t.Helper()
+		resp, err := client.Logical().Read(mount + "/cert/ca")
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	caCert := readCA("root")
+	otherCACert := readCA("other")
+
+	goodCert, _ := issue("root", "test")
+	revokedCert, revokedResp := issue("root", "test")
+	otherCert, _ := issue("other", "test")
+	_, err = client.Logical().Write("root/revoke", map[string]interface{}{
+		"serial_number": revokedResp.Data["serial_number"],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body []byte) []byte {
+		t.Helper()
+		r := client.NewRequest("POST", "/v1/root/ocsp")
+		r.BodyBytes = body
+		r.Headers = http.Header{"Content-Type": []string{"application/ocsp-request"}}
+		resp, err := client.RawRequest(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/ocsp-response" {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		der, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	get := func(body []byte) []byte {
+		t.Helper()
+		r := client.NewRequest("GET", "/v1/root/ocsp/"+url.PathEscape(base64.StdEncoding.EncodeToString(body)))
+		resp, err := client.RawRequest(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		der, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	request := func(cert, issuer *x509.Certificate) []byte {
+		t.Helper()
+		der, err := ocsputil.CreateRequest(cert, issuer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	expectStatus := func(der []byte, cert *x509.Certificate, status int) *ocsputil.Response {
+		t.Helper()
+		resp, err := ocsputil.ParseResponse(der, cert, caCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != status {
+			t.Fatalf("expected status %d, got %d", status, resp.Status)
+		}
+		if !resp.NextUpdate.After(time.Now().Add(11*time.Hour)) || resp.NextUpdate.After(time.Now().Add(13*time.Hour)) {
+			t.Fatalf("unexpected next update %v", resp.NextUpdate)
+		}
+		return resp
+	}
+	expectError := func(der []byte, status ocsputil.ResponseStatus) {
+		t.Helper()
+		_, err := ocsputil.ParseResponse(der, nil, caCert)
+		if respErr, ok := err.(ocsputil.ResponseError); !ok || respErr.Status != status {
+			t.Fatalf("expected %v, got %v", status, err)
+		}
+	}
+
+	for _, send := range []func([]byte) []byte{post, get} {
+		resp := expectStatus(send(request(goodCert, caCert)), goodCert, ocsputil.Good)
+		if resp.Certificate != nil {
+			t.Fatal("expected the response to be signed by the CA")
+		}
+		resp = expectStatus(send(request(revokedCert, caCert)), revokedCert, ocsputil.Revoked)
+		if resp.RevokedAt.IsZero() {
+			t.Fatal("expected a revocation time")
+		}
+		expectError(send(request(otherCert, otherCACert)), ocsputil.Unauthorized)
+		expectError(send([]byte("not a request")), ocsputil.Malformed)
+	}
+
+	// Serials this mount has not issued are unknown
+	unknownCert := *goodCert
+	unknownCert.SerialNumber = big.NewInt(1)
+	expectStatus(post(request(&unknownCert, caCert)), &unknownCert, ocsputil.Unknown)
+
+	// Delegated responder
+	_, err = client.Logical().Write("root/config/ocsp", map[string]interface{}{
+		"pem_bundle": otherCertBundle(t, client),
+	})
+	if err == nil || !strings.Contains(err.Error(), "not marked for OCSP signing") {
+		t.Fatalf("expected error for a responder without OCSP signing, got %v", err)
+	}
+	responderCert, responderResp := issue("root", "ocsp")
+	_, err = client.Logical().Write("root/config/ocsp", map[string]interface{}{
+		"pem_bundle":  responderResp.Data["private_key"].(string) + "\n" + responderResp.Data["certificate"].(string),
+		"next_update": "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Logical().Read("root/config/ocsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["next_update"] != "1h" || resp.Data["responder_certificate"] != responderResp.Data["certificate"] {
+		t.Fatalf("unexpected configuration %#v", resp.Data)
+	}
+	if _, ok := resp.Data["responder_bundle"]; ok {
+		t.Fatal("expected the responder key not to be returned")
+	}
+
+	ocspResp, err := ocsputil.ParseResponse(post(request(goodCert, caCert)), goodCert, caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ocspResp.Status != ocsputil.Good || ocspResp.Certificate == nil || !ocspResp.Certificate.Equal(responderCert) {
+		t.Fatalf("expected a good status signed by the responder, got %#v", ocspResp)
+	}
+	if ocspResp.NextUpdate.After(time.Now().Add(time.Hour + time.Minute)) {
+		t.Fatalf("unexpected next update %v", ocspResp.NextUpdate)
+	}
+
+	_, err = client.Logical().Write("root/config/ocsp", map[string]interface{}{
+		"disable": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectError(post(request(goodCert, caCert)), ocsputil.Unauthorized)
+}
+
+// otherCertBundle returns the PEM bundle of a certificate without the OCSP
+// signing extended key usage
+func otherCertBundle(t *testing.T, client *api.Client) string {
+	resp, err := client.Logical().Write("root/issue/test", map[string]interface{}{
+		"common_name": "not-a-responder",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data["private_key"].(string) + "\n" + resp.Data["certificate"].(string)
+}
+
+// acmeTestClient is a minimal ACME client that signs requests with go-jose
+type acmeTestClient struct {
+	t      *testing.T
+	client *api.Client
+	key    *ecdsa.PrivateKey
+	kid    string
+	nonce  string
+}
+
+func newACMETestClient(t *testing.T, client *api.Client) *acmeTestClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &acmeTestClient{
+		t:      t,
+		client: client,
+		key:    key,
+	}
+}
+
+func (c *acmeTestClient) do(method, url string, body []byte) (int, http.Header, []byte) {
+	c.t.Helper()
+	r := c.client.NewRequest(method, strings.TrimPrefix(url, c.client.Address()))
+	if body != nil {
+		r.BodyBytes = body
+		r.Headers = http.Header{"Content-Type": []string{"application/jose+json"}}
+	}
+	resp, err := c.client.RawRequest(r)
+	if resp == nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+		c.nonce = nonce
+	}
+	return resp.StatusCode, resp.Header, respBody
+}
+
+// post signs the payload for the URL and posts it. A nil payload is sent as
+// a POST-as-GET request.
+func (c *acmeTestClient) post(url string, payload interface{}, result interface{}) (int, http.Header, []byte) {
+	c.t.Helper()
+	if c.nonce == "" {
+		c.do("HEAD", c.client.Address()+"/v1/pki/acme/new-nonce", nil)
+	}
+
+	opts := (&jose.SignerOptions{EmbedJWK: c.kid == ""}).WithHeader("url", url).WithHeader("nonce", c.nonce)
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.ES256,
+		Key: jose.JSONWebKey{
+			Key:   c.key,
+			KeyID: c.kid,
+		},
+	}, opts)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	raw := []byte{}
+	if payload != nil {
+		raw, err = json.Marshal(payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	jws, err := signer.Sign(raw)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.nonce = ""
+
+	status, headers, body := c.do("POST", url, []byte(jws.FullSerialize()))
+	if result != nil && strings.HasPrefix(headers.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(body, result); err != nil {
+			c.t.Fatalf("failed to decode %s: %v", body, err)
+		}
+	}
+	if status >= 400 {
+		problem := map[string]interface{}{}
+		if err := json.Unmarshal(body, &problem); err != nil {
+			c.t.Fatalf("failed to decode problem %s: %v", body, err)
+		}
+		if m, ok := result.(*map[string]interface{}); ok {
+			*m = problem
+		}
+	}
+	return status, headers, body
+}
+
+func (c *acmeTestClient) keyAuthorization(token string) string {
+	thumbprint, err := (&jose.JSONWebKey{Key: c.key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return token + "." + base64.RawURLEncoding.EncodeToString(thumbprint)
+}
+
+type acmeTestOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+type acmeTestAuthorization struct {
+	Status     string `json:"status"`
+	Wildcard   bool   `json:"wildcard"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []struct {
+		Type   string `json:"type"`
+		URL    string `json:"url"`
+		Token  string `json:"token"`
+		Status string `json:"status"`
+	} `json:"challenges"`
+}
+
+func TestBackend_ACME(t *testing.T) {
+	// The HTTP server that http-01 challenges of every domain are fetched
+	// from, and the DNS server that dns-01 challenges are looked up at
+	var lock sync.Mutex
+	httpTokens := map[string]string{}
+	txtRecords := map[string]string{}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		keyAuthz, ok := httpTokens[r.Host+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(keyAuthz))
+	}))
+	defer httpServer.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dnsServer := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			lock.Lock()
+			defer lock.Unlock()
+			m := new(dns.Msg)
+			m.SetReply(r)
+			for _, q := range r.Question {
+				if record, ok := txtRecords[q.Name]; ok && q.Qtype == dns.TypeTXT {
+					m.Answer = append(m.Answer, &dns.TXT{
+						Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+						Txt: []string{record},
+					})
+				}
+			}
+			w.WriteMsg(m)
+		}),
+	}
+	go dnsServer.ActivateAndServe()
+	defer dnsServer.Shutdown()
+
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": func(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
+				b := Backend(conf)
+				b.acmeHTTPClient = &http.Client{
+					Transport: &http.Transport{
+						DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+							return net.Dial("tcp", httpServer.Listener.Addr().String())
+						},
+					},
+				}
+				if err := b.Setup(ctx, conf); err != nil {
+					return nil, err
+				}
+				return b, nil
+			},
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+	err = client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+		Config: api.MountConfigInput{
+			DefaultLeaseTTL: "16h",
+			MaxLeaseTTL:     "60h",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
+		"ttl":         "40h",
+		"common_name": "Root CA",
+		"key_type":    "ec",
+		"key_bits":    256,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Logical().Write("pki/roles/acme", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "any",
+		"ttl":              "10h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acme := newACMETestClient(t, client)
+	baseURL := client.Address() + "/v1/pki"
+	directoryURL := baseURL + "/acme/directory"
+
+	if status, _, _ := acme.do("GET", directoryURL, nil); status != http.StatusNotFound {
+		t.Fatalf("expected ACME to be disabled, got status %d", status)
+	}
+
+	_, err = client.Logical().Write("pki/config/acme", map[string]interface{}{
+		"enabled": true,
+		"role":    "acme",
+	})
+	if err == nil {
+		t.Fatal("expected an error enabling ACME without a base URL")
+	}
+	_, err = client.Logical().Write("pki/config/acme", map[string]interface{}{
+		"enabled":      true,
+		"role":         "acme",
+		"base_url":     baseURL,
+		"dns_resolver": pc.LocalAddr().String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, _, body := acme.do("GET", directoryURL, nil)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", status, body)
+	}
+	var directory map[string]interface{}
+	if err := json.Unmarshal(body, &directory); err != nil {
+		t.Fatal(err)
+	}
+	if directory["newAccount"] != baseURL+"/acme/new-account" || directory["newOrder"] != baseURL+"/acme/new-order" {
+		t.Fatalf("unexpected directory %#v", directory)
+	}
+
+	// Accounts
+	problem := map[string]interface{}{}
+	status, _, _ = acme.post(directory["newAccount"].(string), map[string]interface{}{
+		"onlyReturnExisting": true,
+	}, &problem)
+	if status != http.StatusBadRequest || problem["type"] != "urn:ietf:params:acme:error:accountDoesNotExist" {
+		t.Fatalf("expected no account, got %d %#v", status, problem)
+	}
+	status, headers, _ := acme.post(directory["newAccount"].(string), map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	}, nil)
+	if status != http.StatusCreated || headers.Get("Location") == "" {
+		t.Fatalf("unexpected new account response %d %v", status, headers)
+	}
+	accountURL := headers.Get("Location")
+	status, headers, _ = acme.post(directory["newAccount"].(string), map[string]interface{}{
+		"onlyReturnExisting": true,
+	}, nil)
+	if status != http.StatusOK || headers.Get("Location") != accountURL {
+		t.Fatalf("unexpected existing account response %d %v", status, headers)
+	}
+	acme.kid = accountURL
+
+	// Nonces can only be used once
+	acme.do("HEAD", baseURL+"/acme/new-nonce", nil)
+	nonce := acme.nonce
+	acme.post(acme.kid, nil, nil)
+	acme.nonce = nonce
+	problem = map[string]interface{}{}
+	if status, _, _ := acme.post(acme.kid, nil, &problem); status != http.StatusBadRequest || problem["type"] != "urn:ietf:params:acme:error:badNonce" {
+		t.Fatalf("expected a bad nonce, got %d %#v", status, problem)
+	}
+
+	// Identifiers are checked against the role
+	problem = map[string]interface{}{}
+	status, _, _ = acme.post(directory["newOrder"].(string), map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.org"}},
+	}, &problem)
+	if status != http.StatusBadRequest || problem["type"] != "urn:ietf:params:acme:error:rejectedIdentifier" {
+		t.Fatalf("expected a rejected identifier, got %d %#v", status, problem)
+	}
+
+	var order acmeTestOrder
+	status, headers, _ = acme.post(directory["newOrder"].(string), map[string]interface{}{
+		"identifiers": []map[string]string{
+			{"type": "dns", "value": "www.example.com"},
+			{"type": "dns", "value": "*.example.com"},
+		},
+	}, &order)
+	if status != http.StatusCreated || order.Status != "pending" || len(order.Authorizations) != 2 {
+		t.Fatalf("unexpected new order response %d %#v", status, order)
+	}
+	orderURL := headers.Get("Location")
+
+	// Other accounts cannot access the order
+	other := newACMETestClient(t, client)
+	_, headers, _ = other.post(directory["newAccount"].(string), map[string]interface{}{}, nil)
+	other.kid = headers.Get("Location")
+	if status, _, _ := other.post(orderURL, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("expected another account to be refused, got %d", status)
+	}
+
+	for _, authzURL := range order.Authorizations {
+		var authz acmeTestAuthorization
+		if status, _, _ := acme.post(authzURL, nil, &authz); status != http.StatusOK {
+			t.Fatalf("unexpected authorization response %d", status)
+		}
+
+		challengeType := "http-01"
+		if authz.Wildcard {
+			challengeType = "dns-01"
+			if len(authz.Challenges) != 1 {
+				t.Fatalf("expected only dns-01 for wildcards, got %#v", authz.Challenges)
+			}
+		}
+		for _, challenge := range authz.Challenges {
+			if challenge.Type != challengeType {
+				continue
+			}
+			keyAuthz := acme.keyAuthorization(challenge.Token)
+			lock.Lock()
+			if challengeType == "http-01" {
+				httpTokens[authz.Identifier.Value+"/.well-known/acme-challenge/"+challenge.Token] = keyAuthz
+			} else {
+				digest := sha256.Sum256([]byte(keyAuthz))
+				txtRecords["_acme-challenge."+authz.Identifier.Value+"."] = base64.RawURLEncoding.EncodeToString(digest[:])
+			}
+			lock.Unlock()
+
+			var result map[string]interface{}
+			if status, _, _ := acme.post(challenge.URL, map[string]interface{}{}, &result); status != http.StatusOK || result["status"] != "valid" {
+				t.Fatalf("unexpected %s challenge response %d %#v", challengeType, status, result)
+			}
+		}
+	}
+
+	if acme.post(orderURL, nil, &order); order.Status != "ready" {
+		t.Fatalf("expected the order to be ready, got %#v", order)
+	}
+
+	csrKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createCSR := func(names ...string) string {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: names[0]},
+			DNSNames: names,
+		}, csrKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(csr)
+	}
+
+	problem = map[string]interface{}{}
+	status, _, _ = acme.post(order.Finalize, map[string]interface{}{
+		"csr": createCSR("www.example.com", "other.example.com"),
+	}, &problem)
+	if status != http.StatusBadRequest || problem["type"] != "urn:ietf:params:acme:error:badCSR" {
+		t.Fatalf("expected a bad CSR, got %d %#v", status, problem)
+	}
+
+	// Names are compared case-insensitively
+	status, _, _ = acme.post(order.Finalize, map[string]interface{}{
+		"csr": createCSR("WWW.Example.com", "*.EXAMPLE.com"),
+	}, &order)
+	if status != http.StatusOK || order.Status != "valid" || order.Certificate == "" {
+		t.Fatalf("unexpected finalize response %d %#v", status, order)
+	}
+
+	// Requests must be signed
+	if status, _, _ := acme.do("POST", order.Certificate, []byte("{}")); status != http.StatusBadRequest {
+		t.Fatalf("expected unsigned requests to be rejected, got %d", status)
+	}
+
+	status, headers, body = acme.post(order.Certificate, nil, nil)
+	if status != http.StatusOK || headers.Get("Content-Type") != "application/pem-certificate-chain" {
+		t.Fatalf("unexpected certificate response %d %v", status, headers)
+	}
+	block, _ = pem.Decode(body)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignatureFrom(caCert); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cert.DNSNames, []string{"www.example.com", "*.example.com"}) && !reflect.DeepEqual(cert.DNSNames, []string{"*.example.com", "www.example.com"}) {
+		t.Fatalf("unexpected names %v", cert.DNSNames)
+	}
+	if cert.Subject.CommonName != "www.example.com" {
+		t.Fatalf("unexpected common name %q", cert.Subject.CommonName)
+	}
+	if cert.NotAfter.After(time.Now().Add(10 * time.Hour)) {
+		t.Fatalf("expected the TTL of the role, got %v", cert.NotAfter)
+	}
+
+	// A failed challenge invalidates the order
+	order = acmeTestOrder{}
+	acme.post(directory["newOrder"].(string), map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "fail.example.com"}},
+	}, &order)
+	var authz acmeTestAuthorization
+	acme.post(order.Authorizations[0], nil, &authz)
+	for _, challenge := range authz.Challenges {
+		if challenge.Type != "http-01" {
+			continue
+		}
+		var result map[string]interface{}
+		if acme.post(challenge.URL, map[string]interface{}{}, &result); result["status"] != "invalid" || result["error"] == nil {
+			t.Fatalf("expected the challenge to fail, got %#v", result)
+		}
+	}
+	if acme.post(order.Finalize[:len(order.Finalize)-len("/finalize")], nil, &order); order.Status != "invalid" {
+		t.Fatalf("expected the order to be invalid, got %#v", order)
+	}
+}
+
+func TestBackend_MultipleIssuers(t *testing.T) {
+	config := logical.TestBackendConfig()
+	storage := &logical.InmemStorage{}
+	config.StorageView = storage
+
+	b := Backend(config)
+	err := b.Setup(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	do := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != nil && resp.IsError() {
+			t.Fatalf("error response from %s: %#v", path, resp.Data)
+		}
+		return resp
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		t.Helper()
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("failed to decode %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	crl := func(path string) *pkix.CertificateList {
+		t.Helper()
+		resp := do(logical.ReadOperation, path, nil)
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+
+	// Mounts created before multiple issuers were supported hold their CA
+	// in a single bundle
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: "legacy.example.com",
+		},
+		SerialNumber:          big.NewInt(1234),
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	legacyDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyBundle := &certutil.CertBundle{
+		PrivateKeyType: certutil.RSAPrivateKey,
+		PrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		Certificate:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: legacyDER})),
+		SerialNumber:   certutil.GetHexFormatted(template.SerialNumber.Bytes(), ":"),
+	}
+	entry, err := logical.StorageEntryJSON("config/ca_bundle", legacyBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), &logical.StorageEntry{Key: "ca", Value: legacyDER}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Until it is migrated, the legacy CA is served as the default issuer
+	// without writing to storage, including to unauthenticated requests
+	resp := do(logical.ListOperation, "issuers", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 {
+		t.Fatalf("expected the legacy CA to be listed, got %#v", resp.Data)
+	}
+	legacyID := resp.Data["keys"].([]string)[0]
+	if !resp.Data["key_info"].(map[string]interface{})[legacyID].(map[string]interface{})["is_default"].(bool) {
+		t.Fatalf("expected the legacy CA to be the default issuer, got %#v", resp.Data)
+	}
+	resp = do(logical.ReadOperation, "ca/issuer/default/pem", nil)
+	if !parseCert(string(resp.Data[logical.HTTPRawBody].([]byte))).Equal(parseCert(legacyBundle.Certificate)) {
+		t.Fatal("expected the legacy CA to be served")
+	}
+	request(logical.UpdateOperation, "ocsp", nil)
+	for _, prefix := range []string{"issuers/", "keys/", "config/"} {
+		keys, err := storage.List(context.Background(), prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefix == "config/" && len(keys) == 1 && keys[0] == "ca_bundle" {
+			continue
+		}
+		if len(keys) != 0 {
+			t.Fatalf("expected no writes to %s before the migration, got %v", prefix, keys)
+		}
+	}
+
+	// The periodic function migrates the legacy CA to the issuer that was
+	// served
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := storage.List(context.Background(), "issuers/"); err != nil || len(ids) != 1 || ids[0] != legacyID {
+		t.Fatalf("expected the legacy CA to be migrated to %s, got %v, %v", legacyID, ids, err)
+	}
+	// The legacy bundle is kept for older versions
+	if kept, err := storage.Get(context.Background(), "config/ca_bundle"); err != nil || kept == nil || !bytes.Equal(kept.Value, entry.Value) {
+		t.Fatalf("expected the legacy bundle to be kept unchanged, got %#v, %v", kept, err)
+	}
+	resp = do(logical.ReadOperation, "issuer/default", nil)
+	if resp.Data["issuer_id"] != legacyID || !parseCert(resp.Data["certificate"].(string)).Equal(parseCert(legacyBundle.Certificate)) {
+		t.Fatalf("unexpected default issuer: %#v", resp.Data)
+	}
+
+	// Generating a root does not replace the default issuer, rotating adds
+	// a new one
+	resp = do(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "next.example.com",
+	})
+	if len(resp.Warnings) == 0 || resp.Data != nil {
+		t.Fatalf("expected a warning, got %#v", resp)
+	}
+	resp = do(logical.UpdateOperation, "root/rotate/internal", map[string]interface{}{
+		"common_name": "next.example.com",
+		"issuer_name": "next",
+		"key_name":    "next-key",
+		"ttl":         "24h",
+	})
+	nextID := resp.Data["issuer_id"].(string)
+	nextCert := parseCert(resp.Data["certificate"].(string))
+	if nextID == legacyID {
+		t.Fatal("expected a new issuer")
+	}
+	resp = do(logical.ReadOperation, "issuer/next", nil)
+	if resp.Data["issuer_id"] != nextID || resp.Data["is_default"].(bool) {
+		t.Fatalf("expected the new issuer not to be the default, got %#v", resp.Data)
+	}
+	resp = do(logical.ReadOperation, "key/next-key", nil)
+	if resp.Data["key_type"] != certutil.RSAPrivateKey {
+		t.Fatalf("unexpected key: %#v", resp.Data)
+	}
+
+	// Names are unique and cannot be "default"
+	for _, name := range []string{"next", "default", "in valid"} {
+		resp, err := request(logical.UpdateOperation, "issuer/"+legacyID, map[string]interface{}{
+			"issuer_name": name,
+		})
+		if err != nil || !resp.IsError() {
+			t.Fatalf("expected naming the issuer %q to fail, got %#v, %v", name, resp, err)
+		}
+	}
+	do(logical.UpdateOperation, "issuer/"+legacyID, map[string]interface{}{
+		"issuer_name": "legacy",
+	})
+
+	// Roles issue from the default issuer unless they select another one
+	for role, issuerRef := range map[string]string{"current": "default", "staged": "next"} {
+		do(logical.UpdateOperation, "roles/"+role, map[string]interface{}{
+			"allow_any_name": true,
+			"issuer_ref":     issuerRef,
+		})
+	}
+	issue := func(role string) *x509.Certificate {
+		t.Helper()
+		resp := do(logical.UpdateOperation, "issue/"+role, map[string]interface{}{
+			"common_name": "issuers.example.com",
+			"ttl":         "1h",
+		})
+		return parseCert(resp.Data["certificate"].(string))
+	}
+	currentCert := issue("current")
+	if err := currentCert.CheckSignatureFrom(parseCert(legacyBundle.Certificate)); err != nil {
+		t.Fatalf("expected the legacy issuer to sign: %v", err)
+	}
+	stagedCert := issue("staged")
+	if err := stagedCert.CheckSignatureFrom(nextCert); err != nil {
+		t.Fatalf("expected the new issuer to sign: %v", err)
+	}
+
+	// Each issuer has its own CRL, which only lists its certificates
+	do(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": certutil.GetHexFormatted(stagedCert.SerialNumber.Bytes(), ":"),
+	})
+	nextCRL := crl("crl/issuer/next")
+	if err := nextCert.CheckCRLSignature(nextCRL); err != nil {
+		t.Fatal(err)
+	}
+	if revoked := nextCRL.TBSCertList.RevokedCertificates; len(revoked) != 1 || revoked[0].SerialNumber.Cmp(stagedCert.SerialNumber) != 0 {
+		t.Fatalf("expected the revoked certificate in the CRL of its issuer, got %#v", revoked)
+	}
+	if revoked := crl("crl").TBSCertList.RevokedCertificates; len(revoked) != 0 {
+		t.Fatalf("expected no revoked certificates in the CRL of the default issuer, got %#v", revoked)
+	}
+
+	// The certificate and chain of each issuer are served
+	resp = do(logical.ReadOperation, "ca/issuer/"+nextID+"/pem", nil)
+	if !parseCert(string(resp.Data[logical.HTTPRawBody].([]byte))).Equal(nextCert) {
+		t.Fatal("unexpected certificate of the new issuer")
+	}
+	resp = do(logical.ReadOperation, "ca_chain/issuer/legacy", nil)
+	if len(resp.Data[logical.HTTPRawBody].([]byte)) != 0 {
+		t.Fatalf("expected an empty chain for a root, got %q", resp.Data[logical.HTTPRawBody])
+	}
+
+	// Changing the default issuer completes the rotation
+	do(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": "next",
+	})
+	resp = do(logical.ReadOperation, "ca", nil)
+	if !bytes.Equal(resp.Data[logical.HTTPRawBody].([]byte), nextCert.Raw) {
+		t.Fatal("expected the new issuer to be served as the CA")
+	}
+	if revoked := crl("crl").TBSCertList.RevokedCertificates; len(revoked) != 1 {
+		t.Fatalf("expected the CRL of the new issuer, got %#v", revoked)
+	}
+	if err := issue("current").CheckSignatureFrom(nextCert); err != nil {
+		t.Fatalf("expected the new default issuer to sign: %v", err)
+	}
+
+	// Keys in use cannot be deleted, issuers can
+	resp, err = request(logical.DeleteOperation, "key/next-key", nil)
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected deleting a key in use to fail, got %#v, %v", resp, err)
+	}
+	do(logical.DeleteOperation, "issuer/legacy", nil)
+	resp = do(logical.ListOperation, "issuers", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != nextID {
+		t.Fatalf("unexpected issuers: %#v", resp.Data)
+	}
+
+	// The legacy bundle is not migrated again once it was, so the deleted
+	// issuer does not come back after a restart
+	restart := func() {
+		t.Helper()
+		b = Backend(config)
+		if err := b.Setup(context.Background(), config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	restart()
+	resp = do(logical.ListOperation, "issuers", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != nextID {
+		t.Fatalf("expected the legacy bundle not to be migrated again, got %#v", resp.Data)
+	}
+	if resp = do(logical.ListOperation, "keys", nil); len(resp.Data["keys"].([]string)) != 2 {
+		t.Fatalf("expected keys to be kept, got %#v", resp.Data)
+	}
+
+	// Deleting the root removes all issuers and keys
+	do(logical.DeleteOperation, "root", nil)
+	for _, path := range []string{"issuers", "keys"} {
+		resp = do(logical.ListOperation, path, nil)
+		if keys, _ := resp.Data["keys"].([]string); len(keys) != 0 {
+			t.Fatalf("expected no %s, got %#v", path, resp.Data)
+		}
+	}
+	resp, err = request(logical.UpdateOperation, "issue/current", map[string]interface{}{
+		"common_name": "issuers.example.com",
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected issuing without issuers to fail")
+	}
+
+	// A legacy bundle written by an older version after a rollback is
+	// migrated again
+	template.SerialNumber = big.NewInt(5678)
+	rollbackDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyBundle.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rollbackDER}))
+	legacyBundle.SerialNumber = certutil.GetHexFormatted(template.SerialNumber.Bytes(), ":")
+	entry, err = logical.StorageEntryJSON("config/ca_bundle", legacyBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	b.InvalidateKey(context.Background(), "config/ca_bundle")
+	resp = do(logical.ReadOperation, "issuer/default", nil)
+	if !parseCert(resp.Data["certificate"].(string)).Equal(parseCert(legacyBundle.Certificate)) {
+		t.Fatalf("expected the rewritten legacy bundle to be served, got %#v", resp.Data)
+	}
+	issue("current")
+	if ids, err := storage.List(context.Background(), "issuers/"); err != nil || len(ids) != 1 {
+		t.Fatalf("expected the rewritten legacy bundle to be migrated, got %v, %v", ids, err)
+	}
+}
+
+func TestBackend_DeltaCRL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	storage := &logical.InmemStorage{}
+	config.StorageView = storage
+
+	b := Backend(config)
+	err := b.Setup(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != nil && resp.IsError() {
+			t.Fatalf("error response from %s: %#v", path, resp.Data)
+		}
+		return resp
+	}
+
+	resp := do(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "40h",
+	})
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"allow_any_name": true,
+	})
+	issue := func() string {
+		t.Helper()
+		resp := do(logical.UpdateOperation, "issue/test", map[string]interface{}{
+			"common_name": "leaf.example.com",
+		})
+		return resp.Data["serial_number"].(string)
+	}
+
+	// crl returns the CRL at the path with its CRL number and, for delta
+	// CRLs, the number of their base CRL
+	crl := func(path string) (*pkix.CertificateList, int64, int64) {
+		t.Helper()
+		resp := do(logical.ReadOperation, path, nil)
+		crl, err := x509.ParseDERCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := caCert.CheckCRLSignature(crl); err != nil {
+			t.Fatal(err)
+		}
+		var number, baseNumber *big.Int
+		for _, ext := range crl.TBSCertList.Extensions {
+			switch {
+			case ext.Id.Equal(oidExtensionCRLNumber):
+				_, err = asn1.Unmarshal(ext.Value, &number)
+			case ext.Id.Equal(oidExtensionDeltaCRLIndicator):
+				if !ext.Critical {
+					t.Fatal("expected the delta CRL indicator to be critical")
+				}
+				_, err = asn1.Unmarshal(ext.Value, &baseNumber)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if number == nil {
+			t.Fatalf("expected a CRL number in %s", path)
+		}
+		if baseNumber == nil {
+			return crl, number.Int64(), 0
+		}
+		return crl, number.Int64(), baseNumber.Int64()
+	}
+	revokedSerials := func(crl *pkix.CertificateList) []string {
+		serials := []string{}
+		for _, rc := range crl.TBSCertList.RevokedCertificates {
+			serials = append(serials, certutil.GetHexFormatted(rc.SerialNumber.Bytes(), ":"))
+		}
+		return serials
+	}
+
+	// Delta CRLs rely on the full CRL being rebuilt automatically
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/crl",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enable_delta": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error enabling delta CRLs without auto_rebuild, got %#v, %v", resp, err)
+	}
+	do(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry":       "24h",
+		"auto_rebuild": true,
+		"enable_delta": true,
+	})
+	resp = do(logical.ReadOperation, "config/crl", nil)
+	if resp.Data["auto_rebuild_grace_period"] != "12h" || resp.Data["enable_delta"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Writing the configuration rebuilt the CRLs, publishing a delta CRL
+	full, fullNumber, _ := crl("crl")
+	if len(full.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("expected an empty CRL, got %v", revokedSerials(full))
+	}
+	delta, deltaNumber, baseNumber := crl("crl/delta")
+	if baseNumber != fullNumber || deltaNumber <= fullNumber {
+		t.Fatalf("bad delta CRL numbers: full %d, delta %d, base %d", fullNumber, deltaNumber, baseNumber)
+	}
+	if len(delta.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("expected an empty delta CRL, got %v", revokedSerials(delta))
+	}
+
+	// Revocations only rebuild the delta CRL
+	serial := issue()
+	issue()
+	do(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	full, number, _ := crl("crl")
+	if number != fullNumber || len(full.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("expected the full CRL to be unchanged, got number %d and %v", number, revokedSerials(full))
+	}
+	delta, number, baseNumber = crl("crl/issuer/default/delta")
+	if number <= deltaNumber || baseNumber != fullNumber {
+		t.Fatalf("bad delta CRL numbers: delta %d, base %d", number, baseNumber)
+	}
+	if serials := revokedSerials(delta); !reflect.DeepEqual(serials, []string{serial}) {
+		t.Fatalf("expected %s in the delta CRL, got %v", serial, serials)
+	}
+	resp = do(logical.ReadOperation, "crl/delta/pem", nil)
+	if !strings.HasPrefix(string(resp.Data[logical.HTTPRawBody].([]byte)), "-----BEGIN X509 CRL-----") {
+		t.Fatalf("expected a PEM encoded delta CRL, got %q", resp.Data[logical.HTTPRawBody])
+	}
+
+	// The periodic function leaves CRLs alone until they are within the grace
+	// period of their next update
+	periodic := func() {
+		t.Helper()
+		if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	periodic()
+	if _, number, _ := crl("crl"); number != fullNumber {
+		t.Fatalf("expected the full CRL not to be rebuilt, got number %d", number)
+	}
+
+	issuerID := do(logical.ReadOperation, "config/issuers", nil).Data["default"].(string)
+	state, err := fetchCRLState(context.Background(), storage, issuerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.NextUpdate = time.Now().Add(time.Hour)
+	entry, err := logical.StorageEntryJSON(crlStatePrefix+issuerID, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	periodic()
+
+	full, number, _ = crl("crl")
+	if number <= fullNumber {
+		t.Fatalf("expected the full CRL to be rebuilt, got number %d", number)
+	}
+	if serials := revokedSerials(full); !reflect.DeepEqual(serials, []string{serial}) {
+		t.Fatalf("expected %s in the full CRL, got %v", serial, serials)
+	}
+	if full.TBSCertList.NextUpdate.Before(time.Now().Add(23 * time.Hour)) {
+		t.Fatalf("expected the rebuilt CRL to be valid for 24h, got next update %s", full.TBSCertList.NextUpdate)
+	}
+	delta, _, baseNumber = crl("crl/delta")
+	if baseNumber != number || len(delta.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("expected an empty delta CRL against %d, got base %d and %v", number, baseNumber, revokedSerials(delta))
+	}
+
+	// Disabling delta CRLs removes them
+	do(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry": "24h",
+	})
+	resp = do(logical.ReadOperation, "crl/delta", nil)
+	if resp.Data[logical.HTTPStatusCode] != 204 {
+		t.Fatalf("expected no delta CRL, got %#v", resp.Data)
+	}
+
+	// A configuration is saved with a warning when the CRLs cannot be rebuilt
+	issuer, err := fetchIssuer(context.Background(), storage, issuerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete(context.Background(), keyPrefix+issuer.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	resp = do(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry": "48h",
+	})
+	if resp == nil || len(resp.Warnings) != 1 {
+		t.Fatalf("expected a warning, got %#v", resp)
+	}
+	if resp = do(logical.ReadOperation, "config/crl", nil); resp.Data["expiry"] != "48h" {
+		t.Fatalf("expected the configuration to be saved, got %#v", resp.Data)
+	}
+}
+
+func TestBackend_Ed25519(t *testing.T) {
+	config := logical.TestBackendConfig()
+	storage := &logical.InmemStorage{}
+	config.StorageView = storage
+
+	b := Backend(config)
+	err := b.Setup(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	do := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != nil && resp.IsError() {
+			t.Fatalf("error response from %s: %#v", path, resp.Data)
+		}
+		return resp
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		t.Helper()
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("failed to decode %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	csr := func(key crypto.Signer) string {
+		t.Helper()
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: "csr.example.com"},
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE REQUEST",
+			Bytes: der,
+		})))
+	}
+
+	// Ed25519 root
+	resp := do(logical.UpdateOperation, "root/generate/exported", map[string]interface{}{
+		"common_name": "root.example.com",
+		"key_type":    "ed25519",
+		"ttl":         "40h",
+	})
+	if resp.Data["private_key_type"] != certutil.Ed25519PrivateKey {
+		t.Fatalf("bad: private_key_type: %#v", resp.Data["private_key_type"])
+	}
+	root := parseCert(resp.Data["certificate"].(string))
+	if root.SignatureAlgorithm != x509.PureEd25519 || root.PublicKeyAlgorithm != x509.Ed25519 {
+		t.Fatalf("expected an Ed25519 root, got %s with a %s key", root.SignatureAlgorithm, root.PublicKeyAlgorithm)
+	}
+	if err := root.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Issuance from an Ed25519 role
+	do(logical.UpdateOperation, "roles/ed25519", map[string]interface{}{
+		"allow_any_name": true,
+		"key_type":       "ed25519",
+	})
+	resp = do(logical.UpdateOperation, "issue/ed25519", map[string]interface{}{
+		"common_name":        "leaf.example.com",
+		"private_key_format": "pkcs8",
+	})
+	var certBundle certutil.CertBundle
+	if err := mapstructure.Decode(resp.Data, &certBundle); err != nil {
+		t.Fatal(err)
+	}
+	parsedBundle, err := certBundle.ToParsedCertBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedBundle.PrivateKeyType != certutil.Ed25519PrivateKey {
+		t.Fatalf("bad: private key type: %s", parsedBundle.PrivateKeyType)
+	}
+	if _, ok := parsedBundle.PrivateKey.(ed25519.PrivateKey); !ok {
+		t.Fatalf("expected an Ed25519 private key, got %T", parsedBundle.PrivateKey)
+	}
+	if err := parsedBundle.Certificate.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+	leafSerial := resp.Data["serial_number"].(string)
+
+	// Signing Ed25519 CSRs requires a role allowing them
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = do(logical.UpdateOperation, "sign/ed25519", map[string]interface{}{
+		"csr":         csr(edKey),
+		"common_name": "csr.example.com",
+	})
+	cert := parseCert(resp.Data["certificate"].(string))
+	if !edKey.Public().(ed25519.PublicKey).Equal(cert.PublicKey) {
+		t.Fatal("signed certificate does not hold the key of the CSR")
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = request(logical.UpdateOperation, "sign/ed25519", map[string]interface{}{
+		"csr":         csr(rsaKey),
+		"common_name": "csr.example.com",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error signing an RSA CSR with an Ed25519 role, got %#v, %v", resp, err)
+	}
+	do(logical.UpdateOperation, "roles/rsa", map[string]interface{}{
+		"allow_any_name": true,
+	})
+	resp, err = request(logical.UpdateOperation, "sign/rsa", map[string]interface{}{
+		"csr":         csr(edKey),
+		"common_name": "csr.example.com",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error signing an Ed25519 CSR with an RSA role, got %#v, %v", resp, err)
+	}
+
+	resp = do(logical.UpdateOperation, "sign-verbatim", map[string]interface{}{
+		"csr": csr(edKey),
+	})
+	cert = parseCert(resp.Data["certificate"].(string))
+	if cert.Subject.CommonName != "csr.example.com" || cert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatalf("bad: verbatim certificate: %s signed with %s", cert.Subject.CommonName, cert.SignatureAlgorithm)
+	}
+
+	// Ed25519 intermediate signed by the Ed25519 root
+	resp = do(logical.UpdateOperation, "intermediate/generate/internal", map[string]interface{}{
+		"common_name": "int.example.com",
+		"key_type":    "ed25519",
+	})
+	if resp.Data["private_key_type"] != nil {
+		t.Fatalf("expected no private key for an internal intermediate, got %#v", resp.Data)
+	}
+	resp = do(logical.UpdateOperation, "root/sign-intermediate", map[string]interface{}{
+		"csr":         resp.Data["csr"],
+		"common_name": "int.example.com",
+	})
+	intermediate := parseCert(resp.Data["certificate"].(string))
+	if !intermediate.IsCA || intermediate.PublicKeyAlgorithm != x509.Ed25519 {
+		t.Fatalf("expected an Ed25519 CA, got %s", intermediate.PublicKeyAlgorithm)
+	}
+	if err := intermediate.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// CRLs are signed with the Ed25519 key of the root
+	do(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": leafSerial,
+	})
+	resp = do(logical.ReadOperation, "crl", nil)
+	crl, err := x509.ParseDERCRL(resp.Data[logical.HTTPRawBody].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.CheckCRLSignature(crl); err != nil {
+		t.Fatal(err)
+	}
+	if len(crl.TBSCertList.RevokedCertificates) != 1 ||
+		certutil.GetHexFormatted(crl.TBSCertList.RevokedCertificates[0].SerialNumber.Bytes(), ":") != leafSerial {
+		t.Fatalf("expected %s in the CRL, got %#v", leafSerial, crl.TBSCertList.RevokedCertificates)
+	}
+}
+
+func TestBackend_NameConstraints(t *testing.T) {
+	newBackend := func() (*backend, logical.Storage) {
+		config := logical.TestBackendConfig()
+		storage := &logical.InmemStorage{}
+		config.StorageView = storage
+
+		b := Backend(config)
+		if err := b.Setup(context.Background(), config); err != nil {
+			t.Fatal(err)
+		}
+		return b, storage
+	}
+	rootBackend, rootStorage := newBackend()
+	intBackend, intStorage := newBackend()
+
+	request := func(b *backend, storage logical.Storage, op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	doRoot := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(rootBackend, rootStorage, op, path, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != nil && resp.IsError() {
+			t.Fatalf("error response from %s: %#v", path, resp.Data)
+		}
+		return resp
+	}
+	doInt := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(intBackend, intStorage, op, path, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != nil && resp.IsError() {
+			t.Fatalf("error response from %s: %#v", path, resp.Data)
+		}
+		return resp
+	}
+	expectError := func(b *backend, storage logical.Storage, path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := request(b, storage, logical.UpdateOperation, path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected an error from %s with %#v, got %#v", path, data, resp)
+		}
+	}
+	parseCert := func(pemCert string) *x509.Certificate {
+		t.Helper()
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			t.Fatalf("failed to decode %q", pemCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := doRoot(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name":     "root.example.com",
+		"ttl":             "40h",
+		"max_path_length": 2,
+	})
+	root := parseCert(resp.Data["certificate"].(string))
+	if root.MaxPathLen != 2 {
+		t.Fatalf("expected a max path length of 2 on the root, got %d", root.MaxPathLen)
+	}
+
+	resp = doInt(logical.UpdateOperation, "intermediate/generate/internal", map[string]interface{}{
+		"common_name": "int.example.com",
+	})
+	intCSR := resp.Data["csr"].(string)
+
+	// The root only allows a path length of 1 below it
+	expectError(rootBackend, rootStorage, "root/sign-intermediate", map[string]interface{}{
+		"csr":             intCSR,
+		"common_name":     "int.example.com",
+		"max_path_length": 5,
+	})
+	expectError(rootBackend, rootStorage, "root/sign-intermediate", map[string]interface{}{
+		"csr":                 intCSR,
+		"common_name":         "int.example.com",
+		"permitted_ip_ranges": "10.0.0.0",
+	})
+
+	resp = doRoot(logical.UpdateOperation, "root/sign-intermediate", map[string]interface{}{
+		"csr":                       intCSR,
+		"common_name":               "int.example.com",
+		"ttl":                       "20h",
+		"max_path_length":           0,
+		"permitted_dns_domains":     "example.com",
+		"excluded_dns_domains":      "bad.example.com",
+		"permitted_ip_ranges":       "10.0.0.0/8",
+		"excluded_ip_ranges":        "10.1.0.0/16",
+		"permitted_email_addresses": "example.com",
+		"excluded_email_addresses":  "admin@example.com",
+	})
+	intCertPEM := resp.Data["certificate"].(string)
+	intCert := parseCert(intCertPEM)
+	if intCert.MaxPathLen != 0 || !intCert.MaxPathLenZero {
+		t.Fatalf("expected a max path length of zero, got %d", intCert.MaxPathLen)
+	}
+	if !intCert.PermittedDNSDomainsCritical ||
+		!reflect.DeepEqual(intCert.PermittedDNSDomains, []string{"example.com"}) ||
+		!reflect.DeepEqual(intCert.ExcludedDNSDomains, []string{"bad.example.com"}) ||
+		len(intCert.PermittedIPRanges) != 1 || intCert.PermittedIPRanges[0].String() != "10.0.0.0/8" ||
+		len(intCert.ExcludedIPRanges) != 1 || intCert.ExcludedIPRanges[0].String() != "10.1.0.0/16" ||
+		!reflect.DeepEqual(intCert.PermittedEmailAddresses, []string{"example.com"}) ||
+		!reflect.DeepEqual(intCert.ExcludedEmailAddresses, []string{"admin@example.com"}) {
+		t.Fatalf("unexpected name constraints on intermediate: %#v", intCert)
+	}
+
+	doInt(logical.UpdateOperation, "intermediate/set-signed", map[string]interface{}{
+		"certificate": intCertPEM,
+	})
+	doInt(logical.UpdateOperation, "roles/any", map[string]interface{}{
+		"allow_any_name":    true,
+		"enforce_hostnames": false,
+		"ttl":               "1h",
+	})
+
+	// Names within the constraints are issued and verify against the root
+	resp = doInt(logical.UpdateOperation, "issue/any", map[string]interface{}{
+		"common_name": "good.example.com",
+		"alt_names":   "user@example.com",
+		"ip_sans":     "10.2.0.1",
+	})
+	leaf := parseCert(resp.Data["certificate"].(string))
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(intCert)
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names outside the constraints are refused
+	for _, data := range []map[string]interface{}{
+		{"common_name": "other.com"},
+		{"common_name": "bad.example.com"},
+		{"common_name": "host.bad.example.com"},
+		{"common_name": "good.example.com", "ip_sans": "192.168.0.1"},
+		{"common_name": "good.example.com", "ip_sans": "10.1.2.3"},
+		{"common_name": "good.example.com", "alt_names": "user@other.com"},
+		{"common_name": "good.example.com", "alt_names": "admin@example.com"},
+	} {
+		expectError(intBackend, intStorage, "issue/any", data)
+	}
+
+	// The intermediate has a max path length of zero and cannot sign CAs
+	subBackend, subStorage := newBackend()
+	resp, err := request(subBackend, subStorage, logical.UpdateOperation, "intermediate/generate/internal", map[string]interface{}{
+		"common_name": "sub.example.com",
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	expectError(intBackend, intStorage, "root/sign-intermediate", map[string]interface{}{
+		"csr":         resp.Data["csr"],
+		"common_name": "sub.example.com",
+	})
+}
+
+func TestBackend_CertSearch(t *testing.T) {
+	config := logical.TestBackendConfig()
+	storage := &logical.InmemStorage{}
+	config.StorageView = storage
+
+	b := Backend(config)
+	err := b.Setup(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != nil && resp.IsError() {
+			t.Fatalf("error response from %s: %#v", path, resp.Data)
+		}
+		return resp
+	}
+	search := func(data map[string]interface{}) []string {
+		t.Helper()
+		resp := do(logical.ReadOperation, "certs/search", data)
+		keys, _ := resp.Data["keys"].([]string)
+		sort.Strings(keys)
+		return keys
+	}
+
+	resp := do(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "40h",
+	})
+	rootSerial := resp.Data["serial_number"].(string)
+	rootIssuerID := resp.Data["issuer_id"].(string)
+
+	do(logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"allow_any_name": true,
+		"ttl":            "1h",
+	})
+	do(logical.UpdateOperation, "roles/db", map[string]interface{}{
+		"allow_any_name": true,
+		"ttl":            "30h",
+	})
+
+	issue := func(role string, data map[string]interface{}) string {
+		t.Helper()
+		resp := do(logical.UpdateOperation, "issue/"+role, data)
+		return resp.Data["serial_number"].(string)
+	}
+	webSerial := issue("web", map[string]interface{}{"common_name": "a.payments.internal"})
+	dbSerial := issue("db", map[string]interface{}{"common_name": "b.payments.internal"})
+	otherSerial := issue("web", map[string]interface{}{"common_name": "c.other.internal", "ip_sans": "10.0.0.1"})
+	do(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": dbSerial,
+	})
+
+	sorted := func(serials ...string) []string {
+		sort.Strings(serials)
+		return serials
+	}
+	testCases := []struct {
+		data     map[string]interface{}
+		expected []string
+	}{
+		{map[string]interface{}{}, sorted(rootSerial, webSerial, dbSerial, otherSerial)},
+		{map[string]interface{}{"common_name": "*.payments.internal"}, sorted(webSerial, dbSerial)},
+		{map[string]interface{}{"common_name": "*.PAYMENTS.internal", "expires_before": "24h"}, sorted(webSerial)},
+		{map[string]interface{}{"expires_after": "24h", "expires_before": time.Now().Add(35 * time.Hour).Format(time.RFC3339)}, sorted(dbSerial)},
+		{map[string]interface{}{"role": "db"}, sorted(dbSerial)},
+		{map[string]interface{}{"revocation_state": "revoked"}, sorted(dbSerial)},
+		{map[string]interface{}{"common_name": "*.payments.internal", "revocation_state": "unrevoked"}, sorted(webSerial)},
+		{map[string]interface{}{"san": "10.0.0.*"}, sorted(otherSerial)},
+		{map[string]interface{}{"issuer_ref": rootIssuerID, "role": "web"}, sorted(webSerial, otherSerial)},
+		{map[string]interface{}{"role": "nonexistent"}, nil},
+	}
+	checkSearches := func() {
+		t.Helper()
+		for _, tc := range testCases {
+			if keys := search(tc.data); !reflect.DeepEqual(keys, tc.expected) {
+				t.Fatalf("search with %#v: expected %v, got %v", tc.data, tc.expected, keys)
+			}
+		}
+	}
+
+	// Searches read every certificate until the periodic function marks the
+	// index complete, and then read the index listings
+	checkSearches()
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if indexed, err := b.certIndexComplete(context.Background(), storage); err != nil || !indexed {
+		t.Fatalf("expected the index to be complete, got %v, %v", indexed, err)
+	}
+	roleSerials, err := storage.List(context.Background(), certIndexRolePrefix+"db/")
+	if err != nil || !reflect.DeepEqual(roleSerials, []string{normalizeSerial(dbSerial)}) {
+		t.Fatalf("bad: role listing: %v, %v", roleSerials, err)
+	}
+	checkSearches()
+
+	resp = do(logical.ReadOperation, "certs/search", map[string]interface{}{
+		"common_name": "a.payments.internal",
+	})
+	info := resp.Data["key_info"].(map[string]interface{})[webSerial].(map[string]interface{})
+	if info["role"] != "web" || info["issuer_id"] != rootIssuerID || info["revoked"] != false {
+		t.Fatalf("bad: key_info: %#v", info)
+	}
+
+	// Pages of one certificate return all certificates exactly once
+	var paged []string
+	after := ""
+	for i := 0; ; i++ {
+		if i > 4 {
+			t.Fatalf("too many pages: %v", paged)
+		}
+		resp = do(logical.ReadOperation, "certs/search", map[string]interface{}{
+			"limit": 1,
+			"after": after,
+		})
+		paged = append(paged, resp.Data["keys"].([]string)...)
+		next, ok := resp.Data["next"]
+		if !ok {
+			break
+		}
+		after = next.(string)
+	}
+	if !reflect.DeepEqual(sorted(paged...), sorted(rootSerial, webSerial, dbSerial, otherSerial)) {
+		t.Fatalf("bad: paged results: %v", paged)
+	}
+
+	// Certificates stored before the index are found without writing to
+	// storage, and indexed by the periodic function
+	if err := deleteCertIndexEntry(context.Background(), storage, otherSerial); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete(context.Background(), certIndexStatePath); err != nil {
+		t.Fatal(err)
+	}
+	b.InvalidateKey(context.Background(), certIndexStatePath)
+	if keys := search(map[string]interface{}{"issuer_ref": "default", "san": "10.0.0.1"}); !reflect.DeepEqual(keys, []string{otherSerial}) {
+		t.Fatalf("expected %s to be found without its index entry, got %v", otherSerial, keys)
+	}
+	entry, err := storage.Get(context.Background(), certIndexPrefix+normalizeSerial(otherSerial))
+	if err != nil || entry != nil {
+		t.Fatalf("expected no index entry to be stored by a search, got %v, %v", entry, err)
+	}
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	entry, err = storage.Get(context.Background(), certIndexPrefix+normalizeSerial(otherSerial))
+	if err != nil || entry == nil {
+		t.Fatalf("expected the index entry to be stored, got %v, %v", entry, err)
+	}
+	if keys := search(map[string]interface{}{"issuer_ref": "default", "san": "10.0.0.1"}); !reflect.DeepEqual(keys, []string{otherSerial}) {
+		t.Fatalf("expected %s to be found after indexing, got %v", otherSerial, keys)
+	}
+
+	// Expiry report
+	resp = do(logical.ReadOperation, "certs/expiry-report", map[string]interface{}{
+		"expiring_within": "24h",
+	})
+	if resp.Data["valid"] != 3 || resp.Data["expired"] != 0 || resp.Data["revoked"] != 1 {
+		t.Fatalf("bad: report counts: %#v", resp.Data)
+	}
+	expiring := resp.Data["expiring"].([]map[string]interface{})
+	if len(expiring) != 2 {
+		t.Fatalf("expected two expiring certificates, got %#v", expiring)
+	}
+	for _, cert := range expiring {
+		if cert["serial_number"] != webSerial && cert["serial_number"] != otherSerial {
+			t.Fatalf("unexpected expiring certificate %#v", cert)
+		}
+	}
+
+	resp = do(logical.ReadOperation, "certs/expiry-report", map[string]interface{}{
+		"expiring_within": "24h",
+		"format":          "prometheus",
+	})
+	body := string(resp.Data[logical.HTTPRawBody].([]byte))
+	for _, expected := range []string{
+		`vault_pki_certificates{state="valid"} 3`,
+		`vault_pki_certificates{state="revoked"} 1`,
+		`vault_pki_certificates_expiring{window_seconds="86400"} 2`,
+		fmt.Sprintf(`vault_pki_certificate_expiry_timestamp_seconds{serial_number="%s",common_name="a.payments.internal",role="web",issuer_id="%s"}`, webSerial, rootIssuerID),
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected %q in the report, got:\n%s", expected, body)
+		}
+	}
+
+	// Reports are cached
+	issue("web", map[string]interface{}{"common_name": "d.other.internal"})
+	resp = do(logical.ReadOperation, "certs/expiry-report", map[string]interface{}{
+		"expiring_within": "24h",
+	})
+	if resp.Data["valid"] != 3 {
+		t.Fatalf("expected the cached report, got %#v", resp.Data)
+	}
+	b.expiryReports.Flush()
+	resp = do(logical.ReadOperation, "certs/expiry-report", map[string]interface{}{
+		"expiring_within": "24h",
+	})
+	if resp.Data["valid"] != 4 || len(resp.Data["expiring"].([]map[string]interface{})) != 3 {
+		t.Fatalf("bad: report: %#v", resp.Data)
+	}
+}
@@ -0,0 +1,267 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// certIndexPrefix holds the index entry of each stored certificate by
+	// serial number
+	certIndexPrefix = "cert-index/"
+
+	// The serial numbers of the stored certificates are also listed under
+	// the day they expire, their role and their issuer, so that searches on
+	// these attributes only read the matching certificates
+	certIndexExpiryPrefix = "cert-index-expiry/"
+	certIndexRolePrefix   = "cert-index-role/"
+	certIndexIssuerPrefix = "cert-index-issuer/"
+
+	// certIndexStatePath records that the certificates stored before the
+	// index existed were indexed
+	certIndexStatePath = "cert-index-state"
+	certIndexVersion   = 1
+)
+
+// certIndexEntry holds the searchable attributes of a certificate stored by
+// the mount, so that searches do not need to parse every certificate
+type certIndexEntry struct {
+	SerialNumber   string    `json:"serial_number"`
+	CommonName     string    `json:"common_name"`
+	DNSNames       []string  `json:"dns_names"`
+	EmailAddresses []string  `json:"email_addresses"`
+	IPAddresses    []string  `json:"ip_addresses"`
+	URIs           []string  `json:"uris"`
+	Role           string    `json:"role"`
+	IssuerID       string    `json:"issuer_id"`
+	IsCA           bool      `json:"is_ca"`
+	NotBefore      time.Time `json:"not_before"`
+	NotAfter       time.Time `json:"not_after"`
+}
+
+func newCertIndexEntry(cert *x509.Certificate, role, issuerID string) *certIndexEntry {
+	entry := &certIndexEntry{
+		SerialNumber:   certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Role:           role,
+		IssuerID:       issuerID,
+		IsCA:           cert.IsCA,
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+	}
+	for _, ip := range cert.IPAddresses {
+		entry.IPAddresses = append(entry.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		entry.URIs = append(entry.URIs, uri.String())
+	}
+	return entry
+}
+
+// subjectAltNames returns all of the alternative names of the certificate
+func (e *certIndexEntry) subjectAltNames() []string {
+	var sans []string
+	sans = append(sans, e.DNSNames...)
+	sans = append(sans, e.EmailAddresses...)
+	sans = append(sans, e.IPAddresses...)
+	sans = append(sans, e.URIs...)
+	return sans
+}
+
+// expiryBucket returns the day under which certificates expiring at t are
+// listed in the index
+func expiryBucket(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// listingKeys returns the keys under which the serial number of the
+// certificate is listed in the index
+func (e *certIndexEntry) listingKeys() []string {
+	serial := normalizeSerial(e.SerialNumber)
+	keys := []string{certIndexExpiryPrefix + expiryBucket(e.NotAfter) + "/" + serial}
+	if e.Role != "" {
+		keys = append(keys, certIndexRolePrefix+e.Role+"/"+serial)
+	}
+	if e.IssuerID != "" {
+		keys = append(keys, certIndexIssuerPrefix+e.IssuerID+"/"+serial)
+	}
+	return keys
+}
+
+func writeCertIndexEntry(ctx context.Context, s logical.Storage, entry *certIndexEntry) error {
+	storageEntry, err := logical.StorageEntryJSON(certIndexPrefix+normalizeSerial(entry.SerialNumber), entry)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, storageEntry); err != nil {
+		return err
+	}
+
+	for _, key := range entry.listingKeys() {
+		if err := s.Put(ctx, &logical.StorageEntry{Key: key}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCertIndexEntry removes a certificate from the index
+func deleteCertIndexEntry(ctx context.Context, s logical.Storage, serial string) error {
+	storageEntry, err := s.Get(ctx, certIndexPrefix+normalizeSerial(serial))
+	if err != nil {
+		return err
+	}
+	if storageEntry == nil {
+		return nil
+	}
+	var entry certIndexEntry
+	if err := storageEntry.DecodeJSON(&entry); err != nil {
+		return err
+	}
+
+	for _, key := range entry.listingKeys() {
+		if err := s.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return s.Delete(ctx, storageEntry.Key)
+}
+
+// storeCert stores an issued certificate by serial number so that it can be
+// fetched and revoked, along with its index entry
+func storeCert(ctx context.Context, s logical.Storage, cert *x509.Certificate, certBytes []byte, role, issuerID string) error {
+	serial := normalizeSerial(certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"))
+	if err := s.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + serial,
+		Value: certBytes,
+	}); err != nil {
+		return err
+	}
+
+	return writeCertIndexEntry(ctx, s, newCertIndexEntry(cert, role, issuerID))
+}
+
+// fetchCertIndexEntry returns the index entry of a stored certificate. The
+// entry of a certificate stored before the index existed is built from the
+// certificate itself, without storing it. It returns nil if the certificate
+// is not stored.
+func (b *backend) fetchCertIndexEntry(ctx context.Context, s logical.Storage, serial string) (*certIndexEntry, error) {
+	storageEntry, err := s.Get(ctx, certIndexPrefix+normalizeSerial(serial))
+	if err != nil {
+		return nil, err
+	}
+	if storageEntry != nil {
+		var entry certIndexEntry
+		if err := storageEntry.DecodeJSON(&entry); err != nil {
+			return nil, err
+		}
+		return &entry, nil
+	}
+
+	certEntry, err := s.Get(ctx, "certs/"+serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil || len(certEntry.Value) == 0 {
+		return nil, nil
+	}
+	cert, err := x509.ParseCertificate(certEntry.Value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse stored certificate with serial %q: %v", serial, err)
+	}
+
+	var issuerID string
+	issuer, err := b.findIssuerOf(ctx, s, cert)
+	if err != nil {
+		return nil, err
+	}
+	if issuer != nil {
+		issuerID = issuer.ID
+	}
+
+	return newCertIndexEntry(cert, "", issuerID), nil
+}
+
+type certIndexState struct {
+	Version int `json:"version"`
+}
+
+// certIndexComplete reports whether every stored certificate is indexed.
+// Until then, searches read all of the stored certificates.
+func (b *backend) certIndexComplete(ctx context.Context, s logical.Storage) (bool, error) {
+	if atomic.LoadUint32(b.certIndexed) == 1 {
+		return true, nil
+	}
+
+	storageEntry, err := s.Get(ctx, certIndexStatePath)
+	if err != nil {
+		return false, err
+	}
+	if storageEntry == nil {
+		return false, nil
+	}
+	var state certIndexState
+	if err := storageEntry.DecodeJSON(&state); err != nil {
+		return false, err
+	}
+	if state.Version < certIndexVersion {
+		return false, nil
+	}
+
+	atomic.StoreUint32(b.certIndexed, 1)
+	return true, nil
+}
+
+// indexLegacyCerts indexes the certificates stored before the index existed.
+// It runs once per mount, from the periodic function, and is skipped while a
+// tidy operation is deleting certificates.
+func (b *backend) indexLegacyCerts(ctx context.Context, s logical.Storage) error {
+	indexed, err := b.certIndexComplete(ctx, s)
+	if err != nil || indexed {
+		return err
+	}
+
+	if !atomic.CompareAndSwapUint32(b.tidyCASGuard, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreUint32(b.tidyCASGuard, 0)
+
+	serials, err := s.List(ctx, "certs/")
+	if err != nil {
+		return err
+	}
+	for _, serial := range serials {
+		entry, err := b.fetchCertIndexEntry(ctx, s, serial)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		if err := writeCertIndexEntry(ctx, s, entry); err != nil {
+			return err
+		}
+	}
+
+	storageEntry, err := logical.StorageEntryJSON(certIndexStatePath, &certIndexState{
+		Version: certIndexVersion,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, storageEntry); err != nil {
+		return err
+	}
+
+	atomic.StoreUint32(b.certIndexed, 1)
+	b.Logger().Info("indexed stored certificates", "count", len(serials))
+	return nil
+}
@@ -79,8 +79,13 @@ type creationParameters struct {
 	BasicConstraintsValidForNonCA bool
 
 	// Only used when signing a CA cert
-	UseCSRValues        bool
-	PermittedDNSDomains []string
+	UseCSRValues            bool
+	PermittedDNSDomains     []string
+	ExcludedDNSDomains      []string
+	PermittedIPRanges       []*net.IPNet
+	ExcludedIPRanges        []*net.IPNet
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
 
 	// URLs to encode into the certificate
 	URLs *urlEntries
@@ -91,7 +96,8 @@ type creationParameters struct {
 
 type caInfoBundle struct {
 	certutil.ParsedCertBundle
-	URLs *urlEntries
+	URLs     *urlEntries
+	IssuerID string
 }
 
 func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
@@ -166,6 +172,8 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 			return logical.ErrorResponse(fmt.Sprintf(
 				"unsupported bit length for EC key: %d", keyBits))
 		}
+	case "ed25519":
+		// Ed25519 keys have a fixed size, so the key bits are ignored
 	case "any":
 	default:
 		return logical.ErrorResponse(fmt.Sprintf(
@@ -175,22 +183,36 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 	return nil
 }
 
-// Fetches the CA info. Unlike other certificates, the CA info is stored
-// in the backend as a CertBundle, because we are storing its private key
-func fetchCAInfo(ctx context.Context, req *logical.Request) (*caInfoBundle, error) {
-	bundleEntry, err := req.Storage.Get(ctx, "config/ca_bundle")
+// Fetches the CA info of the referenced issuer, along with its private key
+func fetchCAInfo(ctx context.Context, b *backend, req *logical.Request, issuerRef string) (*caInfoBundle, error) {
+	issuerID, err := b.resolveIssuerRef(ctx, req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := fetchIssuer(ctx, req.Storage, issuerID)
 	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate: %v", err)}
 	}
-	if bundleEntry == nil {
-		return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+	if issuer == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unable to find issuer %q", issuerRef)}
 	}
 
-	var bundle certutil.CertBundle
-	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode local CA certificate/key: %v", err)}
+	key, err := fetchKey(ctx, req.Storage, issuer.KeyID)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA key: %v", err)}
+	}
+	if key == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("the key of issuer %s is not stored in this mount", issuer.ID)}
 	}
 
+	bundle := &certutil.CertBundle{
+		PrivateKeyType: key.PrivateKeyType,
+		PrivateKey:     key.PrivateKey,
+		Certificate:    issuer.Certificate,
+		CAChain:        issuer.CAChain,
+		SerialNumber:   issuer.SerialNumber,
+	}
 	parsedBundle, err := bundle.ToParsedCertBundle()
 	if err != nil {
 		return nil, errutil.InternalError{Err: err.Error()}
@@ -200,7 +222,10 @@ func fetchCAInfo(ctx context.Context, req *logical.Request) (*caInfoBundle, erro
 		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
 	}
 
-	caInfo := &caInfoBundle{*parsedBundle, nil}
+	caInfo := &caInfoBundle{
+		ParsedCertBundle: *parsedBundle,
+		IssuerID:         issuer.ID,
+	}
 
 	entries, err := getURLs(ctx, req)
 	if err != nil {
@@ -213,7 +238,7 @@ func fetchCAInfo(ctx context.Context, req *logical.Request) (*caInfoBundle, erro
 			OCSPServers:           []string{},
 		}
 	}
-	caInfo.URLs = entries
+	caInfo.URLs = expandIssuerURLs(entries, issuer.ID)
 
 	return caInfo, nil
 }
@@ -556,7 +581,9 @@ func generateCert(ctx context.Context,
 
 	if isCA {
 		data.params.IsCA = isCA
-		data.params.PermittedDNSDomains = data.apiData.Get("permitted_dns_domains").([]string)
+		if err := parseNameConstraints(data); err != nil {
+			return nil, err
+		}
 
 		if data.signingBundle == nil {
 			// Generating a self-signed root certificate
@@ -678,6 +705,15 @@ func signCert(b *backend,
 				pubKey.Params().BitSize)}
 		}
 
+	case "ed25519":
+		// Verify that the key matches the role type; Ed25519 keys have a
+		// fixed size
+		if csr.PublicKeyAlgorithm != x509.Ed25519 {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"role requires keys of type %s",
+				data.role.KeyType)}
+		}
+
 	case "any":
 		// We only care about running RSA < 2048 bit checks, so if not RSA
 		// break out
@@ -710,7 +746,9 @@ func signCert(b *backend,
 	data.params.UseCSRValues = useCSRValues
 
 	if isCA {
-		data.params.PermittedDNSDomains = data.apiData.Get("permitted_dns_domains").([]string)
+		if err := parseNameConstraints(data); err != nil {
+			return nil, err
+		}
 	}
 
 	parsedBundle, err := signCertificate(data)
@@ -1035,6 +1073,15 @@ func generateCreationBundle(b *backend, data *dataBundle) error {
 	// from the signing certificate
 	if data.role.MaxPathLength != nil {
 		data.params.MaxPathLength = *data.role.MaxPathLength
+
+		// A CA cannot grant a longer path than it was itself granted
+		caCert := data.signingBundle.Certificate
+		if caCert.MaxPathLen > 0 {
+			if data.params.MaxPathLength < 0 || data.params.MaxPathLength > caCert.MaxPathLen-1 {
+				return errutil.UserError{Err: fmt.Sprintf(
+					"max_path_length %d exceeds the maximum of %d allowed by the signing certificate", data.params.MaxPathLength, caCert.MaxPathLen-1)}
+			}
+		}
 	} else {
 		switch {
 		case data.signingBundle.Certificate.MaxPathLen < 0:
@@ -1189,10 +1236,7 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 	}
 
 	// This will only be filled in from the generation paths
-	if len(data.params.PermittedDNSDomains) > 0 {
-		certTemplate.PermittedDNSDomains = data.params.PermittedDNSDomains
-		certTemplate.PermittedDNSDomainsCritical = true
-	}
+	addNameConstraints(data, certTemplate)
 
 	addPolicyIdentifiers(data, certTemplate)
 
@@ -1211,11 +1255,17 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case certutil.ECPrivateKey:
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case certutil.Ed25519PrivateKey:
+			certTemplate.SignatureAlgorithm = x509.PureEd25519
 		}
 
 		caCert := data.signingBundle.Certificate
 		certTemplate.AuthorityKeyId = caCert.SubjectKeyId
 
+		if err := checkNameConstraints(data.signingBundle, certTemplate); err != nil {
+			return nil, err
+		}
+
 		certBytes, err = x509.CreateCertificate(rand.Reader, certTemplate, caCert, result.PrivateKey.Public(), data.signingBundle.PrivateKey)
 	} else {
 		// Creating a self-signed root
@@ -1231,6 +1281,8 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case "ec":
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case "ed25519":
+			certTemplate.SignatureAlgorithm = x509.PureEd25519
 		}
 
 		certTemplate.AuthorityKeyId = subjKeyID
@@ -1312,6 +1364,8 @@ func createCSR(data *dataBundle) (*certutil.ParsedCSRBundle, error) {
 		csrTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case "ec":
 		csrTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case "ed25519":
+		csrTemplate.SignatureAlgorithm = x509.PureEd25519
 	}
 
 	csr, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, result.PrivateKey)
@@ -1376,6 +1430,8 @@ func signCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 		certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case certutil.ECPrivateKey:
 		certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case certutil.Ed25519PrivateKey:
+		certTemplate.SignatureAlgorithm = x509.PureEd25519
 	}
 
 	if data.params.UseCSRValues {
@@ -1433,9 +1489,10 @@ func signCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 		certTemplate.IsCA = false
 	}
 
-	if len(data.params.PermittedDNSDomains) > 0 {
-		certTemplate.PermittedDNSDomains = data.params.PermittedDNSDomains
-		certTemplate.PermittedDNSDomainsCritical = true
+	addNameConstraints(data, certTemplate)
+
+	if err := checkNameConstraints(data.signingBundle, certTemplate); err != nil {
+		return nil, err
 	}
 
 	certBytes, err = x509.CreateCertificate(rand.Reader, certTemplate, caCert, data.csr.PublicKey, data.signingBundle.PrivateKey)
@@ -1495,6 +1552,9 @@ func convertRespToPKCS8(resp *logical.Response) error {
 		signer, err = x509.ParsePKCS1PrivateKey(keyData)
 	case certutil.ECPrivateKey:
 		signer, err = x509.ParseECPrivateKey(keyData)
+	case certutil.Ed25519PrivateKey:
+		// Ed25519 keys are always encoded as PKCS#8 already
+		return nil
 	default:
 		return fmt.Errorf("unknown private key type %q", privKeyType)
 	}
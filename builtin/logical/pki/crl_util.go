@@ -2,10 +2,17 @@ package pki
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/hashicorp/errwrap"
@@ -100,7 +107,18 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 
 	}
 
-	crlErr := buildCRL(ctx, b, req)
+	crlConfig, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching CRL config information: {{err}}", err)
+	}
+	var crlErr error
+	if crlConfig != nil && crlConfig.EnableDelta {
+		// The full CRL is rebuilt periodically, so the revocation only has to
+		// be published in the delta CRL
+		crlErr = buildDeltaCRL(ctx, b, req)
+	} else {
+		crlErr = buildCRL(ctx, b, req)
+	}
 	switch crlErr.(type) {
 	case errutil.UserError:
 		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
@@ -119,15 +137,86 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 	return resp, nil
 }
 
-// Builds a CRL by going through the list of revoked certificates and building
-// a new CRL with the stored revocation times and serial numbers.
+// crlState tracks the CRLs of an issuer
+type crlState struct {
+	// Number is the last CRL number used by either the full or the delta CRL
+	// of the issuer
+	Number int64 `json:"number"`
+
+	// BaseNumber is the CRL number of the current full CRL, which delta CRLs
+	// refer to
+	BaseNumber int64 `json:"base_number"`
+
+	// ThisUpdate and NextUpdate are the validity of the current full CRL
+	ThisUpdate time.Time `json:"this_update"`
+	NextUpdate time.Time `json:"next_update"`
+}
+
+func fetchCRLState(ctx context.Context, s logical.Storage, issuerID string) (*crlState, error) {
+	entry, err := s.Get(ctx, crlStatePrefix+issuerID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var state crlState
+	if err := entry.DecodeJSON(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Builds the full CRLs of the issuers of the mount, and their delta CRLs if
+// enabled
 func buildCRL(ctx context.Context, b *backend, req *logical.Request) error {
+	return buildCRLs(ctx, b, req, false)
+}
+
+// Builds the delta CRLs of the issuers of the mount, listing the certificates
+// revoked since their full CRL was built. Issuers without a full CRL get one.
+func buildDeltaCRL(ctx context.Context, b *backend, req *logical.Request) error {
+	return buildCRLs(ctx, b, req, true)
+}
+
+// Builds the CRLs of the issuers of the mount by going through the list of
+// revoked certificates and building new CRLs per issuer with the stored
+// revocation times and serial numbers of the certificates it issued.
+func buildCRLs(ctx context.Context, b *backend, req *logical.Request, deltaOnly bool) error {
+	b.crlLock.Lock()
+	defer b.crlLock.Unlock()
+
+	crlLifetime := b.crlLifetime
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
+	}
+	if crlInfo == nil {
+		crlInfo = &crlConfig{}
+	}
+	if crlInfo.Expiry != "" {
+		crlDur, err := time.ParseDuration(crlInfo.Expiry)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error parsing CRL duration of %s", crlInfo.Expiry)}
+		}
+		crlLifetime = crlDur
+	}
+
+	// Taken before listing the revoked certificates, so that delta CRLs
+	// built against this full CRL cannot miss a revocation
+	now := time.Now()
+
 	revokedSerials, err := req.Storage.List(ctx, "revoked/")
 	if err != nil {
 		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of revoked certs: %s", err)}
 	}
 
-	revokedCerts := []pkix.RevokedCertificate{}
+	type revoked struct {
+		cert  *x509.Certificate
+		entry pkix.RevokedCertificate
+	}
+	revokedCerts := []revoked{}
 	var revInfo revocationInfo
 	for _, serial := range revokedSerials {
 		revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
@@ -144,6 +233,7 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request) error {
 			return errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
 		}
 
+		revInfo = revocationInfo{}
 		err = revokedEntry.DecodeJSON(&revInfo)
 		if err != nil {
 			return errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
@@ -164,42 +254,211 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request) error {
 		} else {
 			newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
 		}
-		revokedCerts = append(revokedCerts, newRevCert)
+		revokedCerts = append(revokedCerts, revoked{
+			cert:  revokedCert,
+			entry: newRevCert,
+		})
 	}
 
-	signingBundle, caErr := fetchCAInfo(ctx, req)
-	switch caErr.(type) {
-	case errutil.UserError:
-		return errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
-	case errutil.InternalError:
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of issuers: %s", err)}
+	}
+	if len(issuerIDs) == 0 {
+		return errutil.UserError{Err: "could not fetch the CA certificate: backend must be configured with a CA certificate/key"}
+	}
+	config, err := fetchIssuerConfig(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching issuer configuration: %s", err)}
 	}
 
-	crlLifetime := b.crlLifetime
-	crlInfo, err := b.CRL(ctx, req.Storage)
+	for _, issuerID := range issuerIDs {
+		signingBundle, caErr := fetchCAInfo(ctx, b, req, issuerID)
+		switch caErr.(type) {
+		case errutil.UserError:
+			return errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
+		case errutil.InternalError:
+			return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+		}
+
+		state, err := fetchCRLState(ctx, req.Storage, issuerID)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL state of issuer %s: %s", issuerID, err)}
+		}
+		if state == nil {
+			state = &crlState{}
+		}
+
+		if !deltaOnly || state.BaseNumber == 0 {
+			issuerRevokedCerts := []pkix.RevokedCertificate{}
+			for _, revoked := range revokedCerts {
+				if issuedBy(revoked.cert, signingBundle.Certificate) {
+					issuerRevokedCerts = append(issuerRevokedCerts, revoked.entry)
+				}
+			}
+
+			state.Number++
+			crlBytes, err := createCRL(signingBundle, issuerRevokedCerts, now, now.Add(crlLifetime), state.Number, 0)
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+			}
+
+			keys := []string{crlPrefix + issuerID}
+			if issuerID == config.DefaultIssuerID {
+				keys = append(keys, "crl")
+			}
+			for _, key := range keys {
+				err = req.Storage.Put(ctx, &logical.StorageEntry{
+					Key:   key,
+					Value: crlBytes,
+				})
+				if err != nil {
+					return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+				}
+			}
+
+			state.BaseNumber = state.Number
+			state.ThisUpdate = now
+			state.NextUpdate = now.Add(crlLifetime)
+		}
+
+		if crlInfo.EnableDelta {
+			issuerRevokedCerts := []pkix.RevokedCertificate{}
+			for _, revoked := range revokedCerts {
+				if revoked.entry.RevocationTime.Before(state.ThisUpdate) {
+					continue
+				}
+				if issuedBy(revoked.cert, signingBundle.Certificate) {
+					issuerRevokedCerts = append(issuerRevokedCerts, revoked.entry)
+				}
+			}
+
+			state.Number++
+			crlBytes, err := createCRL(signingBundle, issuerRevokedCerts, now, state.NextUpdate, state.Number, state.BaseNumber)
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error creating new delta CRL: %s", err)}
+			}
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key:   deltaCRLPrefix + issuerID,
+				Value: crlBytes,
+			})
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error storing delta CRL: %s", err)}
+			}
+		} else if err := req.Storage.Delete(ctx, deltaCRLPrefix+issuerID); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error deleting delta CRL: %s", err)}
+		}
+
+		entry, err := logical.StorageEntryJSON(crlStatePrefix+issuerID, state)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error encoding CRL state: %s", err)}
+		}
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error storing CRL state: %s", err)}
+		}
+	}
+
+	return nil
+}
+
+var (
+	oidExtensionAuthorityKeyID    = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtensionCRLNumber         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// createCRL returns the DER encoding of a CRL signed by the CA of the bundle.
+// The CRL carries the given CRL number and, if baseNumber is not zero, is a
+// delta CRL against the full CRL with that number.
+func createCRL(signingBundle *caInfoBundle, revokedCerts []pkix.RevokedCertificate, thisUpdate, nextUpdate time.Time, number, baseNumber int64) ([]byte, error) {
+	var sigAlgo pkix.AlgorithmIdentifier
+	hash := crypto.SHA256
+	switch signingBundle.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm:  oidSHA256WithRSA,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		}
+	case *ecdsa.PublicKey:
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm: oidECDSAWithSHA256,
+		}
+	case ed25519.PublicKey:
+		// Ed25519 signs the message itself rather than a digest
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm: oidEd25519,
+		}
+		hash = crypto.Hash(0)
+	default:
+		return nil, errors.New("unsupported CRL signing key type")
+	}
+
+	extensions := []pkix.Extension{}
+	if len(signingBundle.Certificate.SubjectKeyId) > 0 {
+		value, err := asn1.Marshal(struct {
+			ID []byte `asn1:"optional,tag:0"`
+		}{signingBundle.Certificate.SubjectKeyId})
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionAuthorityKeyID, Value: value})
+	}
+	value, err := asn1.Marshal(big.NewInt(number))
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
+		return nil, err
 	}
-	if crlInfo != nil {
-		crlDur, err := time.ParseDuration(crlInfo.Expiry)
+	extensions = append(extensions, pkix.Extension{Id: oidExtensionCRLNumber, Value: value})
+	if baseNumber != 0 {
+		value, err := asn1.Marshal(big.NewInt(baseNumber))
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("error parsing CRL duration of %s", crlInfo.Expiry)}
+			return nil, err
 		}
-		crlLifetime = crlDur
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionDeltaCRLIndicator, Critical: true, Value: value})
+	}
+
+	// Revocation times must be encoded in UTC
+	entries := make([]pkix.RevokedCertificate, len(revokedCerts))
+	for i, rc := range revokedCerts {
+		entries[i] = rc
+		entries[i].RevocationTime = rc.RevocationTime.UTC()
 	}
 
-	crlBytes, err := signingBundle.Certificate.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, time.Now(), time.Now().Add(crlLifetime))
+	tbs := pkix.TBSCertificateList{
+		Version:             1,
+		Signature:           sigAlgo,
+		Issuer:              signingBundle.Certificate.Subject.ToRDNSequence(),
+		ThisUpdate:          thisUpdate.UTC(),
+		NextUpdate:          nextUpdate.UTC(),
+		RevokedCertificates: entries,
+		Extensions:          extensions,
+	}
+	tbsBytes, err := asn1.Marshal(tbs)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+		return nil, err
 	}
+	tbs.Raw = tbsBytes
 
-	err = req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   "crl",
-		Value: crlBytes,
-	})
+	signed := tbsBytes
+	if hash != 0 {
+		digest := hash.New()
+		digest.Write(tbsBytes)
+		signed = digest.Sum(nil)
+	}
+	signature, err := signingBundle.PrivateKey.Sign(rand.Reader, signed, hash)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+		return nil, err
 	}
 
-	return nil
+	return asn1.Marshal(pkix.CertificateList{
+		TBSCertList:        tbs,
+		SignatureAlgorithm: sigAlgo,
+		SignatureValue: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	})
 }
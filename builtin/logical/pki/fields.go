@@ -201,14 +201,46 @@ the private key!`,
 		Default: 2048,
 		Description: `The number of bits to use. You will almost
 certainly want to change this if you adjust
-the key_type.`,
+the key_type. Ignored for ed25519 keys.`,
 	}
 
 	fields["key_type"] = &framework.FieldSchema{
 		Type:    framework.TypeString,
 		Default: "rsa",
-		Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+		Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
+	}
+
+	fields["key_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name of the generated key, which can
+be used instead of its ID to refer to it.`,
+	}
+
+	return fields
+}
+
+// addIssuerNameField adds the field naming the issuer created by an
+// endpoint
+func addIssuerNameField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name of the new issuer, which can be
+used instead of its ID to refer to it.`,
+	}
+
+	return fields
+}
+
+// addIssuerRefField adds the field selecting the issuer that signs
+// certificates
+func addIssuerRefField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: defaultRef,
+		Description: `Reference to the issuer to sign with: its ID,
+its name, or "default" for the default issuer
+of the mount.`,
 	}
 
 	return fields
@@ -228,5 +260,30 @@ func addCAIssueFields(fields map[string]*framework.FieldSchema) map[string]*fram
 		Description: `Domains for which this certificate is allowed to sign or issue child certificates. If set, all DNS names (subject and alt) on child certs must be exact matches or subsets of the given domains (see https://tools.ietf.org/html/rfc5280#section-4.2.1.10).`,
 	}
 
+	fields["excluded_dns_domains"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `Domains for which this certificate is not allowed to sign or issue child certificates. If set, no DNS names (subject and alt) on child certs may be exact matches or subsets of the given domains (see https://tools.ietf.org/html/rfc5280#section-4.2.1.10).`,
+	}
+
+	fields["permitted_ip_ranges"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `IP ranges, in CIDR notation, for which this certificate is allowed to sign or issue child certificates. If set, all IP SANs on child certs must fall within one of the given ranges.`,
+	}
+
+	fields["excluded_ip_ranges"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `IP ranges, in CIDR notation, for which this certificate is not allowed to sign or issue child certificates. If set, no IP SANs on child certs may fall within the given ranges.`,
+	}
+
+	fields["permitted_email_addresses"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `Email addresses or domains for which this certificate is allowed to sign or issue child certificates. If set, all email SANs on child certs must match one of the given mailboxes or domains.`,
+	}
+
+	fields["excluded_email_addresses"] = &framework.FieldSchema{
+		Type:        framework.TypeCommaStringSlice,
+		Description: `Email addresses or domains for which this certificate is not allowed to sign or issue child certificates. If set, no email SANs on child certs may match the given mailboxes or domains.`,
+	}
+
 	return fields
 }
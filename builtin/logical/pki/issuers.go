@@ -0,0 +1,845 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// defaultRef refers to the default issuer of the mount
+	defaultRef = "default"
+
+	issuerPrefix = "issuers/"
+	keyPrefix    = "keys/"
+	crlPrefix    = "crls/"
+
+	// deltaCRLPrefix holds the delta CRLs of the issuers and crlStatePrefix
+	// the numbering and validity of their CRLs
+	deltaCRLPrefix = "delta-crls/"
+	crlStatePrefix = "crl-state/"
+
+	// legacyBundlePath holds the single CA certificate and key of mounts
+	// created before multiple issuers were supported
+	legacyBundlePath = "config/ca_bundle"
+
+	// legacyMigrationLogPath records which legacy bundle was migrated
+	legacyMigrationLogPath = "config/legacy_migration_log"
+)
+
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// issuerEntry is a CA certificate of the mount that certificates can be
+// issued from
+type issuerEntry struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	KeyID        string   `json:"key_id"`
+	Certificate  string   `json:"certificate"`
+	CAChain      []string `json:"ca_chain"`
+	SerialNumber string   `json:"serial_number"`
+}
+
+// keyEntry is a private key of the mount, which issuers reference
+type keyEntry struct {
+	ID             string                  `json:"id"`
+	Name           string                  `json:"name"`
+	PrivateKeyType certutil.PrivateKeyType `json:"private_key_type"`
+	PrivateKey     string                  `json:"private_key"`
+}
+
+// legacyMigrationLog records the migration of the legacy bundle, which is
+// left in place for older versions. The bundle is migrated again if it no
+// longer matches the hash, as when an older version replaced the CA.
+type legacyMigrationLog struct {
+	Hash     string    `json:"hash"`
+	Migrated time.Time `json:"migrated"`
+}
+
+// issuerConfig holds the mount-wide issuer settings
+type issuerConfig struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+func (i *issuerEntry) parseCertificate() (*x509.Certificate, error) {
+	parsed, err := (&certutil.CertBundle{Certificate: i.Certificate}).ToParsedCertBundle()
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Certificate == nil {
+		return nil, fmt.Errorf("issuer %s has no certificate", i.ID)
+	}
+	return parsed.Certificate, nil
+}
+
+func (k *keyEntry) signer() (crypto.Signer, error) {
+	parsed, err := (&certutil.CertBundle{
+		PrivateKeyType: k.PrivateKeyType,
+		PrivateKey:     k.PrivateKey,
+	}).ToParsedCertBundle()
+	if err != nil {
+		return nil, err
+	}
+	if parsed.PrivateKey == nil {
+		return nil, fmt.Errorf("key %s could not be parsed", k.ID)
+	}
+	return parsed.PrivateKey, nil
+}
+
+// validateName checks a name given to an issuer or a key. Names are optional
+// but cannot be mistaken for the default reference.
+func validateName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef {
+		return fmt.Errorf("%q is reserved and cannot be used as a name", defaultRef)
+	}
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid name %q: names may only contain letters, digits, dashes and underscores", name)
+	}
+	return nil
+}
+
+// legacyCA holds the issuer and key that an unmigrated legacy bundle is
+// migrated to. Their IDs are derived from the hash of the bundle, so that
+// certificates issued from it before the migration reference the same
+// issuer afterwards. An issuer or key that is stored already is reused.
+type legacyCA struct {
+	hash        string
+	issuer      *issuerEntry
+	key         *keyEntry
+	storeIssuer bool
+	storeKey    bool
+}
+
+// legacyID returns an ID in UUID format derived from the hash of a legacy
+// bundle
+func legacyID(hash, kind string) (string, error) {
+	sum := sha256.Sum256([]byte(hash + "/" + kind))
+	return uuid.FormatUUID(sum[:16])
+}
+
+// pendingLegacyCA returns the issuer and key of the legacy bundle of the
+// mount if it was not migrated yet, or nil. It does not write to storage.
+func (b *backend) pendingLegacyCA(ctx context.Context, s logical.Storage) (*legacyCA, error) {
+	entry, err := s.Get(ctx, legacyBundlePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	sum := sha256.Sum256(entry.Value)
+	legacy := &legacyCA{
+		hash: hex.EncodeToString(sum[:]),
+	}
+
+	log, err := fetchLegacyMigrationLog(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if log != nil && log.Hash == legacy.hash {
+		// This bundle was migrated already
+		return nil, nil
+	}
+
+	var bundle certutil.CertBundle
+	if err := entry.DecodeJSON(&bundle); err != nil {
+		return nil, fmt.Errorf("error decoding the legacy CA bundle: %s", err)
+	}
+	parsed, err := bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the legacy CA bundle: %s", err)
+	}
+	if parsed.PrivateKey == nil {
+		return legacy, nil
+	}
+	cb, err := parsed.ToCertBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	legacy.key, err = findKey(ctx, s, parsed.PrivateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if legacy.key == nil {
+		id, err := legacyID(legacy.hash, "key")
+		if err != nil {
+			return nil, err
+		}
+		legacy.key = &keyEntry{
+			ID:             id,
+			PrivateKeyType: cb.PrivateKeyType,
+			PrivateKey:     cb.PrivateKey,
+		}
+		legacy.storeKey = true
+	}
+
+	if parsed.Certificate == nil {
+		return legacy, nil
+	}
+	if !publicKeysEqual(parsed.PrivateKey.Public(), parsed.Certificate.PublicKey) {
+		return nil, errors.New("the private key of the legacy CA bundle does not match its certificate")
+	}
+	legacy.issuer, err = findIssuerByCert(ctx, s, parsed.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	if legacy.issuer == nil {
+		id, err := legacyID(legacy.hash, "issuer")
+		if err != nil {
+			return nil, err
+		}
+		legacy.issuer = &issuerEntry{
+			ID:           id,
+			KeyID:        legacy.key.ID,
+			Certificate:  cb.Certificate,
+			CAChain:      cb.CAChain,
+			SerialNumber: cb.SerialNumber,
+		}
+		legacy.storeIssuer = true
+	}
+
+	return legacy, nil
+}
+
+// migrateLegacyCA copies the CA certificate and key of mounts created before
+// multiple issuers were supported into an issuer and a key, which becomes the
+// default issuer. It is called from the periodic function and before
+// authenticated writes, and is safe to call repeatedly. Other requests see
+// the legacy CA through legacyCAView until then.
+//
+// The migration is one-way: the legacy bundle is left unchanged so that an
+// older version still finds its CA after a rollback, but issuers created or
+// changed since are not written back to it.
+func (b *backend) migrateLegacyCA(ctx context.Context, s logical.Storage) error {
+	if atomic.LoadUint32(b.issuersMigrated) == 1 {
+		return nil
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if atomic.LoadUint32(b.issuersMigrated) == 1 {
+		return nil
+	}
+
+	legacy, err := b.pendingLegacyCA(ctx, s)
+	if err != nil {
+		return err
+	}
+	if legacy != nil {
+		if legacy.storeKey {
+			if err := writeKey(ctx, s, legacy.key); err != nil {
+				return err
+			}
+		}
+		if legacy.storeIssuer {
+			if err := writeIssuer(ctx, s, legacy.issuer); err != nil {
+				return err
+			}
+
+			// The CRL was built for this issuer already
+			crl, err := s.Get(ctx, "crl")
+			if err != nil {
+				return err
+			}
+			if crl != nil {
+				crl.Key = crlPrefix + legacy.issuer.ID
+				if err := s.Put(ctx, crl); err != nil {
+					return err
+				}
+			}
+
+			config, err := fetchIssuerConfig(ctx, s)
+			if err != nil {
+				return err
+			}
+			if config.DefaultIssuerID == "" {
+				if err := setDefaultIssuer(ctx, s, legacy.issuer); err != nil {
+					return err
+				}
+			}
+		}
+
+		logEntry, err := logical.StorageEntryJSON(legacyMigrationLogPath, &legacyMigrationLog{
+			Hash:     legacy.hash,
+			Migrated: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.Put(ctx, logEntry); err != nil {
+			return err
+		}
+		b.Logger().Info("migrated the CA of the mount to an issuer")
+	}
+
+	atomic.StoreUint32(b.issuersMigrated, 1)
+	return nil
+}
+
+// legacyCAView returns a view of the storage in which the legacy CA of the
+// mount, if it was not migrated yet, appears as the issuer and key it will be
+// migrated to, without writing to storage
+func (b *backend) legacyCAView(ctx context.Context, s logical.Storage) (logical.Storage, error) {
+	if atomic.LoadUint32(b.issuersMigrated) == 1 {
+		return s, nil
+	}
+
+	legacy, err := b.pendingLegacyCA(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if legacy == nil {
+		atomic.StoreUint32(b.issuersMigrated, 1)
+		return s, nil
+	}
+	return &legacyCAStorage{
+		Storage: s,
+		legacy:  legacy,
+	}, nil
+}
+
+// legacyCAStorage serves the issuer and key of an unmigrated legacy bundle
+// as if they were stored
+type legacyCAStorage struct {
+	logical.Storage
+	legacy *legacyCA
+}
+
+func (l *legacyCAStorage) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	issuer := l.legacy.issuer
+	switch {
+	case l.legacy.storeKey && key == keyPrefix+l.legacy.key.ID:
+		return logical.StorageEntryJSON(key, l.legacy.key)
+	case l.legacy.storeIssuer && key == issuerPrefix+issuer.ID:
+		return logical.StorageEntryJSON(key, issuer)
+	case l.legacy.storeIssuer && key == crlPrefix+issuer.ID:
+		crl, err := l.Storage.Get(ctx, "crl")
+		if err != nil || crl == nil {
+			return crl, err
+		}
+		crl.Key = key
+		return crl, nil
+	case l.legacy.storeIssuer && key == "config/issuers":
+		config, err := fetchIssuerConfig(ctx, l.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if config.DefaultIssuerID == "" {
+			config.DefaultIssuerID = issuer.ID
+		}
+		return logical.StorageEntryJSON(key, config)
+	}
+	return l.Storage.Get(ctx, key)
+}
+
+func (l *legacyCAStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := l.Storage.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case l.legacy.storeKey && prefix == keyPrefix:
+		keys = append(keys, l.legacy.key.ID)
+	case l.legacy.storeIssuer && prefix == issuerPrefix:
+		keys = append(keys, l.legacy.issuer.ID)
+	}
+	return keys, nil
+}
+
+func fetchLegacyMigrationLog(ctx context.Context, s logical.Storage) (*legacyMigrationLog, error) {
+	entry, err := s.Get(ctx, legacyMigrationLogPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var log legacyMigrationLog
+	if err := entry.DecodeJSON(&log); err != nil {
+		return nil, fmt.Errorf("error decoding the legacy migration log: %s", err)
+	}
+	return &log, nil
+}
+
+func fetchIssuerConfig(ctx context.Context, s logical.Storage) (*issuerConfig, error) {
+	entry, err := s.Get(ctx, "config/issuers")
+	if err != nil {
+		return nil, err
+	}
+	config := &issuerConfig{}
+	if entry == nil {
+		return config, nil
+	}
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func writeIssuerConfig(ctx context.Context, s logical.Storage, config *issuerConfig) error {
+	entry, err := logical.StorageEntryJSON("config/issuers", config)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// setDefaultIssuer makes the issuer the default of the mount. The
+// certificate and CRL of the default issuer are also kept at the "ca" and
+// "crl" paths, where they were stored before multiple issuers were
+// supported.
+func setDefaultIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	config, err := fetchIssuerConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	config.DefaultIssuerID = issuer.ID
+	if err := writeIssuerConfig(ctx, s, config); err != nil {
+		return err
+	}
+
+	cert, err := issuer.parseCertificate()
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, &logical.StorageEntry{
+		Key:   "ca",
+		Value: cert.Raw,
+	}); err != nil {
+		return err
+	}
+
+	crl, err := s.Get(ctx, crlPrefix+issuer.ID)
+	if err != nil {
+		return err
+	}
+	if crl == nil {
+		return s.Delete(ctx, "crl")
+	}
+	crl.Key = "crl"
+	return s.Put(ctx, crl)
+}
+
+func listIssuers(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, issuerPrefix)
+}
+
+func fetchIssuer(ctx context.Context, s logical.Storage, id string) (*issuerEntry, error) {
+	entry, err := s.Get(ctx, issuerPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+func writeIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func listKeys(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, keyPrefix)
+}
+
+func fetchKey(ctx context.Context, s logical.Storage, id string) (*keyEntry, error) {
+	entry, err := s.Get(ctx, keyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	var key keyEntry
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func writeKey(ctx context.Context, s logical.Storage, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON(keyPrefix+key.ID, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// resolveIssuerRef returns the ID of the issuer that the reference, which is
+// an ID, a name or "default", refers to
+func (b *backend) resolveIssuerRef(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	if ref == "" || ref == defaultRef {
+		config, err := fetchIssuerConfig(ctx, s)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("unable to fetch the issuer configuration: %v", err)}
+		}
+		if config.DefaultIssuerID == "" {
+			return "", errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+		}
+		return config.DefaultIssuerID, nil
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to list issuers: %v", err)}
+	}
+	for _, id := range ids {
+		if id == ref {
+			return id, nil
+		}
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, s, id)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuer %s: %v", id, err)}
+		}
+		if issuer != nil && issuer.Name == ref {
+			return id, nil
+		}
+	}
+	return "", errutil.UserError{Err: fmt.Sprintf("unable to find issuer %q", ref)}
+}
+
+// resolveKeyRef returns the ID of the key that the reference, which is an ID
+// or a name, refers to
+func (b *backend) resolveKeyRef(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to list keys: %v", err)}
+	}
+	for _, id := range ids {
+		if id == ref {
+			return id, nil
+		}
+	}
+	for _, id := range ids {
+		key, err := fetchKey(ctx, s, id)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("unable to fetch key %s: %v", id, err)}
+		}
+		if key != nil && key.Name == ref {
+			return id, nil
+		}
+	}
+	return "", errutil.UserError{Err: fmt.Sprintf("unable to find key %q", ref)}
+}
+
+// checkIssuerName fails if the name is invalid or taken by another issuer
+func checkIssuerName(ctx context.Context, s logical.Storage, id, name string) error {
+	if err := validateName(name); err != nil {
+		return errutil.UserError{Err: err.Error()}
+	}
+	if name == "" {
+		return nil
+	}
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, other := range ids {
+		if other == id {
+			continue
+		}
+		issuer, err := fetchIssuer(ctx, s, other)
+		if err != nil {
+			return err
+		}
+		if issuer != nil && issuer.Name == name {
+			return errutil.UserError{Err: fmt.Sprintf("the name %q is already used by issuer %s", name, other)}
+		}
+	}
+	return nil
+}
+
+// checkKeyName fails if the name is invalid or taken by another key
+func checkKeyName(ctx context.Context, s logical.Storage, id, name string) error {
+	if err := validateName(name); err != nil {
+		return errutil.UserError{Err: err.Error()}
+	}
+	if name == "" {
+		return nil
+	}
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, other := range ids {
+		if other == id {
+			continue
+		}
+		key, err := fetchKey(ctx, s, other)
+		if err != nil {
+			return err
+		}
+		if key != nil && key.Name == name {
+			return errutil.UserError{Err: fmt.Sprintf("the name %q is already used by key %s", name, other)}
+		}
+	}
+	return nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	aBytes, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// findKey returns the stored key whose public key is the given one, if any
+func findKey(ctx context.Context, s logical.Storage, public crypto.PublicKey) (*keyEntry, error) {
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		key, err := fetchKey(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		signer, err := key.signer()
+		if err != nil {
+			return nil, err
+		}
+		if publicKeysEqual(signer.Public(), public) {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+// importKey stores the private key of the bundle, unless the same key is
+// stored already, in which case the existing key is returned. Callers must
+// hold issuersLock.
+func (b *backend) importKey(ctx context.Context, s logical.Storage, bundle *certutil.ParsedCertBundle, name string) (*keyEntry, error) {
+	existing, err := findKey(ctx, s, bundle.PrivateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	if err := checkKeyName(ctx, s, "", name); err != nil {
+		return nil, err
+	}
+
+	cb, err := bundle.ToCertBundle()
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	key := &keyEntry{
+		ID:             id,
+		Name:           name,
+		PrivateKeyType: cb.PrivateKeyType,
+		PrivateKey:     cb.PrivateKey,
+	}
+	if err := writeKey(ctx, s, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// findIssuerByCert returns the issuer with the given certificate, if any
+func findIssuerByCert(ctx context.Context, s logical.Storage, cert *x509.Certificate) (*issuerEntry, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		issuerCert, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(issuerCert.Raw, cert.Raw) {
+			return issuer, nil
+		}
+	}
+	return nil, nil
+}
+
+// importIssuer stores the certificate of the bundle as an issuer, along
+// with its private key if the bundle holds one. Otherwise the key must have
+// been stored before, for instance when generating an intermediate CSR. An
+// issuer with the same certificate is returned as is. The first issuer of
+// the mount becomes the default issuer. Callers must hold issuersLock.
+func (b *backend) importIssuer(ctx context.Context, s logical.Storage, bundle *certutil.ParsedCertBundle, issuerName, keyName string) (*issuerEntry, *keyEntry, error) {
+	var key *keyEntry
+	var err error
+	if bundle.PrivateKey != nil {
+		if !publicKeysEqual(bundle.PrivateKey.Public(), bundle.Certificate.PublicKey) {
+			return nil, nil, errutil.UserError{Err: "the private key does not match the public key of the certificate"}
+		}
+		key, err = b.importKey(ctx, s, bundle, keyName)
+	} else {
+		key, err = findKey(ctx, s, bundle.Certificate.PublicKey)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil {
+		return nil, nil, errutil.UserError{Err: "could not find an existing private key matching the certificate"}
+	}
+
+	existing, err := findIssuerByCert(ctx, s, bundle.Certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		return existing, key, nil
+	}
+
+	if err := checkIssuerName(ctx, s, "", issuerName); err != nil {
+		return nil, nil, err
+	}
+
+	cb, err := bundle.ToCertBundle()
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, nil, err
+	}
+	issuer := &issuerEntry{
+		ID:           id,
+		Name:         issuerName,
+		KeyID:        key.ID,
+		Certificate:  cb.Certificate,
+		CAChain:      cb.CAChain,
+		SerialNumber: cb.SerialNumber,
+	}
+	if err := writeIssuer(ctx, s, issuer); err != nil {
+		return nil, nil, err
+	}
+
+	config, err := fetchIssuerConfig(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.DefaultIssuerID == "" {
+		if err := setDefaultIssuer(ctx, s, issuer); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return issuer, key, nil
+}
+
+// nonDefaultIssuerResponse warns that a newly stored issuer is not the
+// default issuer of the mount, which has to be changed explicitly. It
+// returns nil if the issuer is the default.
+func nonDefaultIssuerResponse(ctx context.Context, s logical.Storage, issuer *issuerEntry) (*logical.Response, error) {
+	config, err := fetchIssuerConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID == issuer.ID {
+		return nil, nil
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id": issuer.ID,
+		},
+	}
+	resp.AddWarning(fmt.Sprintf("The issuer %s was stored but is not the default issuer of the mount. Update \"config/issuers\" to make it the default.", issuer.ID))
+	return resp, nil
+}
+
+// issuedBy reports whether the certificate was signed by the issuer
+func issuedBy(cert, issuer *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, issuer.RawSubject) {
+		return false
+	}
+	return cert.CheckSignatureFrom(issuer) == nil
+}
+
+// findIssuerOf returns the issuer of the mount that signed the certificate,
+// if any
+func (b *backend) findIssuerOf(ctx context.Context, s logical.Storage, cert *x509.Certificate) (*issuerEntry, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		issuerCert, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, err
+		}
+		if issuedBy(cert, issuerCert) {
+			return issuer, nil
+		}
+	}
+	return nil, nil
+}
+
+// expandIssuerURLs fills in the ID of the issuer in the configured URLs,
+// so that each issuer can point to its own CRL
+func expandIssuerURLs(entries *urlEntries, issuerID string) *urlEntries {
+	expand := func(urls []string) []string {
+		result := make([]string, 0, len(urls))
+		for _, url := range urls {
+			result = append(result, strings.Replace(url, issuerIDTemplate, issuerID, -1))
+		}
+		return result
+	}
+	return &urlEntries{
+		IssuingCertificates:   expand(entries.IssuingCertificates),
+		CRLDistributionPoints: expand(entries.CRLDistributionPoints),
+		OCSPServers:           expand(entries.OCSPServers),
+	}
+}
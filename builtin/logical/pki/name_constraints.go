@@ -0,0 +1,176 @@
+package pki
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/errutil"
+)
+
+// parseNameConstraints reads the name constraint parameters supplied to the
+// CA generation and signing endpoints into the creation parameters
+func parseNameConstraints(data *dataBundle) error {
+	data.params.PermittedDNSDomains = data.apiData.Get("permitted_dns_domains").([]string)
+	data.params.ExcludedDNSDomains = data.apiData.Get("excluded_dns_domains").([]string)
+	data.params.PermittedEmailAddresses = data.apiData.Get("permitted_email_addresses").([]string)
+	data.params.ExcludedEmailAddresses = data.apiData.Get("excluded_email_addresses").([]string)
+
+	var err error
+	data.params.PermittedIPRanges, err = parseIPRanges(data.apiData.Get("permitted_ip_ranges").([]string))
+	if err != nil {
+		return err
+	}
+	data.params.ExcludedIPRanges, err = parseIPRanges(data.apiData.Get("excluded_ip_ranges").([]string))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseIPRanges(ranges []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, r := range ranges {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("the value '%s' is not a valid IP range in CIDR notation", r)}
+		}
+		ret = append(ret, ipNet)
+	}
+	return ret, nil
+}
+
+// addNameConstraints encodes the name constraints from the creation
+// parameters into the template. The extension is marked critical, as
+// recommended by RFC 5280.
+func addNameConstraints(data *dataBundle, certTemplate *x509.Certificate) {
+	certTemplate.PermittedDNSDomains = data.params.PermittedDNSDomains
+	certTemplate.ExcludedDNSDomains = data.params.ExcludedDNSDomains
+	certTemplate.PermittedIPRanges = data.params.PermittedIPRanges
+	certTemplate.ExcludedIPRanges = data.params.ExcludedIPRanges
+	certTemplate.PermittedEmailAddresses = data.params.PermittedEmailAddresses
+	certTemplate.ExcludedEmailAddresses = data.params.ExcludedEmailAddresses
+
+	if len(certTemplate.PermittedDNSDomains) > 0 ||
+		len(certTemplate.ExcludedDNSDomains) > 0 ||
+		len(certTemplate.PermittedIPRanges) > 0 ||
+		len(certTemplate.ExcludedIPRanges) > 0 ||
+		len(certTemplate.PermittedEmailAddresses) > 0 ||
+		len(certTemplate.ExcludedEmailAddresses) > 0 {
+		certTemplate.PermittedDNSDomainsCritical = true
+	}
+}
+
+// checkNameConstraints verifies that the names in the template are allowed
+// by the name constraints of the signing certificate and of every CA above
+// it in the chain, so that Vault does not issue certificates that would fail
+// path validation
+func checkNameConstraints(signingBundle *caInfoBundle, certTemplate *x509.Certificate) error {
+	caCerts := []*x509.Certificate{signingBundle.Certificate}
+	for _, block := range signingBundle.CAChain {
+		if block.Certificate != nil {
+			caCerts = append(caCerts, block.Certificate)
+		}
+	}
+
+	for _, caCert := range caCerts {
+		for _, name := range certTemplate.DNSNames {
+			if !nameConstraintsAllow(caCert.PermittedDNSDomains, caCert.ExcludedDNSDomains, name, matchDNSConstraint) {
+				return errutil.UserError{Err: fmt.Sprintf(
+					"DNS name %s is not allowed by the name constraints of CA certificate %q", name, caCert.Subject.CommonName)}
+			}
+		}
+
+		for _, email := range certTemplate.EmailAddresses {
+			if !nameConstraintsAllow(caCert.PermittedEmailAddresses, caCert.ExcludedEmailAddresses, email, matchEmailConstraint) {
+				return errutil.UserError{Err: fmt.Sprintf(
+					"email address %s is not allowed by the name constraints of CA certificate %q", email, caCert.Subject.CommonName)}
+			}
+		}
+
+		for _, ip := range certTemplate.IPAddresses {
+			if !ipConstraintsAllow(caCert.PermittedIPRanges, caCert.ExcludedIPRanges, ip) {
+				return errutil.UserError{Err: fmt.Sprintf(
+					"IP address %s is not allowed by the name constraints of CA certificate %q", ip, caCert.Subject.CommonName)}
+			}
+		}
+	}
+
+	return nil
+}
+
+func nameConstraintsAllow(permitted, excluded []string, name string, match func(constraint, name string) bool) bool {
+	for _, constraint := range excluded {
+		if match(constraint, name) {
+			return false
+		}
+	}
+
+	if len(permitted) == 0 {
+		return true
+	}
+	for _, constraint := range permitted {
+		if match(constraint, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipConstraintsAllow(permitted, excluded []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range excluded {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(permitted) == 0 {
+		return true
+	}
+	for _, ipNet := range permitted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDNSConstraint follows RFC 5280 section 4.2.1.10: a constraint matches
+// the domain itself and any of its subdomains, while a constraint with a
+// leading period only matches subdomains
+func matchDNSConstraint(constraint, name string) bool {
+	constraint = strings.ToLower(constraint)
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if constraint == "" {
+		return true
+	}
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(name, constraint)
+	}
+	return name == constraint || strings.HasSuffix(name, "."+constraint)
+}
+
+// matchEmailConstraint follows RFC 5280 section 4.2.1.10: a constraint
+// containing an "@" matches that mailbox exactly; otherwise it is a host
+// that matches the domain part of the address, with a leading period
+// matching any subdomain of it
+func matchEmailConstraint(constraint, email string) bool {
+	if strings.Contains(constraint, "@") {
+		return strings.EqualFold(constraint, email)
+	}
+
+	idx := strings.LastIndex(email, "@")
+	if idx == -1 {
+		return false
+	}
+	host := strings.ToLower(email[idx+1:])
+	constraint = strings.ToLower(constraint)
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return host == constraint
+}
@@ -0,0 +1,791 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/net/idna"
+)
+
+const acmeCertificateContentType = "application/pem-certificate-chain"
+
+func pathACMEDirectory(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/directory$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathACMEDirectory,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewNonce(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-nonce$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathACMENewNonce,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-account$",
+		Fields:  acmeJWSFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathACMENewAccount,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/account/(?P<account_id>[^/]+)$",
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"account_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The ID of the account.",
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathACMEAccount,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-order$",
+		Fields:  acmeJWSFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathACMENewOrder,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/order/(?P<order_id>[^/]+)(?P<action>/finalize|/cert)?$",
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"order_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The ID of the order.",
+			},
+			"action": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Either "/finalize" to finalize the order, or "/cert" to fetch its certificate.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathACMEOrder,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAuthorization(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/authorization/(?P<authorization_id>[^/]+)$",
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"authorization_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The ID of the authorization.",
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathACMEAuthorization,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEChallenge(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/challenge/(?P<authorization_id>[^/]+)/(?P<challenge_type>[^/]+)$",
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"authorization_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The ID of the authorization.",
+			},
+			"challenge_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The type of the challenge.",
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathACMEChallenge,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func (b *backend) pathACMEDirectory(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, resp, err := b.acmeEnabledConfig(ctx, req)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	return b.acmeJSONResponse(config, http.StatusOK, map[string]interface{}{
+		"newNonce":   config.BaseURL + "/acme/new-nonce",
+		"newAccount": config.BaseURL + "/acme/new-account",
+		"newOrder":   config.BaseURL + "/acme/new-order",
+		"meta": map[string]interface{}{
+			"externalAccountRequired": false,
+		},
+	}, "")
+}
+
+func (b *backend) pathACMENewNonce(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, resp, err := b.acmeEnabledConfig(ctx, req)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	return b.acmeRawResponse(config, http.StatusNoContent, "", nil, "")
+}
+
+func (b *backend) pathACMENewAccount(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, resp, err := b.acmeVerify(ctx, req, data, true)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("failed to decode payload: %v", err)))
+	}
+
+	// Accounts are identified by the thumbprint of their key, so that
+	// clients can look up the account of a key
+	id, err := acmeThumbprint(r.key)
+	if err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	account, err := b.acmeAccount(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		return b.acmeJSONResponse(r.config, http.StatusOK, acmeAccountResponse(account), acmeAccountURL(r.config, account))
+	}
+	if payload.OnlyReturnExisting {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "accountDoesNotExist", "no account exists for the key"))
+	}
+	if problem := validateACMEContact(payload.Contact); problem != nil {
+		return b.acmeProblemResponse(r.config, problem)
+	}
+
+	account = &acmeAccount{
+		ID:        id,
+		Key:       r.key,
+		Status:    acmeStatusValid,
+		Contact:   payload.Contact,
+		CreatedAt: time.Now(),
+	}
+	if err := acmePut(ctx, req.Storage, "acme/accounts/"+id, account); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(r.config, http.StatusCreated, acmeAccountResponse(account), acmeAccountURL(r.config, account))
+}
+
+func (b *backend) pathACMEAccount(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, resp, err := b.acmeVerify(ctx, req, data, false)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+	if r.account.ID != data.Get("account_id").(string) {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusForbidden, "unauthorized", "the account does not belong to the key"))
+	}
+
+	var payload struct {
+		Contact []string `json:"contact"`
+		Status  string   `json:"status"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("failed to decode payload: %v", err)))
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	account := r.account
+	modified := false
+	if payload.Contact != nil {
+		if problem := validateACMEContact(payload.Contact); problem != nil {
+			return b.acmeProblemResponse(r.config, problem)
+		}
+		account.Contact = payload.Contact
+		modified = true
+	}
+	switch payload.Status {
+	case "":
+	case acmeStatusDeactivated:
+		account.Status = acmeStatusDeactivated
+		modified = true
+	default:
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("accounts cannot be set to %q", payload.Status)))
+	}
+	if modified {
+		if err := acmePut(ctx, req.Storage, "acme/accounts/"+account.ID, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeJSONResponse(r.config, http.StatusOK, acmeAccountResponse(account), "")
+}
+
+func (b *backend) pathACMENewOrder(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, resp, err := b.acmeVerify(ctx, req, data, false)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("failed to decode payload: %v", err)))
+	}
+	if len(payload.Identifiers) == 0 {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", "no identifiers given"))
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", "notBefore and notAfter are not supported; the validity is set by the role"))
+	}
+
+	role, err := b.getRole(ctx, req.Storage, r.config.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("ACME role %q not found", r.config.Role)
+	}
+
+	// Normalize the identifiers and check them against the role before
+	// anything is stored
+	var names []string
+	for _, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "unsupportedIdentifier", fmt.Sprintf("unsupported identifier type %q", identifier.Type)))
+		}
+		name, err := normalizeACMEName(identifier.Value)
+		if err != nil {
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", fmt.Sprintf("invalid DNS identifier %q", identifier.Value)))
+		}
+		if badName := validateNames(&dataBundle{req: req, role: role}, []string{name}); badName != "" {
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", fmt.Sprintf("identifier %s not allowed by the role", badName)))
+		}
+		names = append(names, name)
+	}
+	names = strutil.RemoveDuplicates(names, false)
+
+	order := &acmeOrder{
+		AccountID: r.account.ID,
+		Status:    acmeStatusPending,
+		Expires:   time.Now().Add(acmeOrderLifetime).UTC().Truncate(time.Second),
+	}
+	order.ID, err = acmeNewID()
+	if err != nil {
+		return nil, err
+	}
+
+	var authorizations []*acmeAuthorization
+	for _, name := range names {
+		order.Identifiers = append(order.Identifiers, acmeIdentifier{Type: "dns", Value: name})
+
+		authz := &acmeAuthorization{
+			AccountID: r.account.ID,
+			Status:    acmeStatusPending,
+			Expires:   order.Expires,
+			Identifier: acmeIdentifier{
+				Type:  "dns",
+				Value: strings.TrimPrefix(name, "*."),
+			},
+			Wildcard: strings.HasPrefix(name, "*."),
+		}
+		authz.ID, err = acmeNewID()
+		if err != nil {
+			return nil, err
+		}
+		for _, challengeType := range r.config.AllowedChallenges {
+			// Wildcards can only be validated through DNS
+			if authz.Wildcard && challengeType != acmeChallengeDNS01 {
+				continue
+			}
+			token, err := acmeRandomToken()
+			if err != nil {
+				return nil, err
+			}
+			authz.Challenges = append(authz.Challenges, &acmeChallenge{
+				Type:   challengeType,
+				Token:  token,
+				Status: acmeStatusPending,
+			})
+		}
+		if len(authz.Challenges) == 0 {
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", fmt.Sprintf("no allowed challenge can validate %s", name)))
+		}
+
+		authorizations = append(authorizations, authz)
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	for _, authz := range authorizations {
+		if err := acmePut(ctx, req.Storage, "acme/authorizations/"+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+	if err := acmePut(ctx, req.Storage, "acme/orders/"+order.ID, order); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(r.config, http.StatusCreated, acmeOrderResponse(r.config, order), acmeOrderURL(r.config, order))
+}
+
+func (b *backend) pathACMEOrder(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, resp, err := b.acmeVerify(ctx, req, data, false)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	order, err := b.acmeOrder(ctx, req.Storage, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusNotFound, "malformed", "order not found"))
+	}
+	if order.AccountID != r.account.ID {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusForbidden, "unauthorized", "the order belongs to another account"))
+	}
+
+	switch data.Get("action").(string) {
+	case "/finalize":
+		return b.acmeFinalize(ctx, req, r, order.ID)
+
+	case "/cert":
+		if order.Status != acmeStatusValid {
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusForbidden, "orderNotReady", fmt.Sprintf("the order is %s", order.Status)))
+		}
+		return b.acmeRawResponse(r.config, http.StatusOK, acmeCertificateContentType, []byte(order.Certificate), "")
+
+	default:
+		if err := b.acmeOrderStatus(ctx, req.Storage, order); err != nil {
+			return nil, err
+		}
+		return b.acmeJSONResponse(r.config, http.StatusOK, acmeOrderResponse(r.config, order), "")
+	}
+}
+
+// acmeFinalize issues the certificate of an order whose identifiers have
+// all been validated
+func (b *backend) acmeFinalize(ctx context.Context, req *logical.Request, r *acmeRequest, orderID string) (*logical.Response, error) {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("failed to decode payload: %v", err)))
+	}
+	csrDER, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload.CSR, "="))
+	if err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "badCSR", "the CSR is not base64url encoded"))
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "badCSR", fmt.Sprintf("failed to parse CSR: %v", err)))
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "badCSR", fmt.Sprintf("invalid CSR signature: %v", err)))
+	}
+
+	// Only one finalization of an order can issue a certificate
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	order, err := b.acmeOrder(ctx, req.Storage, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusNotFound, "malformed", "order not found"))
+	}
+	if err := b.acmeOrderStatus(ctx, req.Storage, order); err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusReady {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusForbidden, "orderNotReady", fmt.Sprintf("the order is %s", order.Status)))
+	}
+
+	// The certificate has exactly the names of the order, which were
+	// normalized when it was created
+	var names []string
+	for _, identifier := range order.Identifiers {
+		names = append(names, identifier.Value)
+	}
+	badNames := newACMEProblem(http.StatusBadRequest, "badCSR", "the names of the CSR must be the identifiers of the order")
+	var requested []string
+	for _, name := range csr.DNSNames {
+		name, err := normalizeACMEName(name)
+		if err != nil {
+			return b.acmeProblemResponse(r.config, badNames)
+		}
+		requested = append(requested, name)
+	}
+	var commonName string
+	if csr.Subject.CommonName != "" {
+		commonName, err = normalizeACMEName(csr.Subject.CommonName)
+		if err != nil {
+			return b.acmeProblemResponse(r.config, badNames)
+		}
+		requested = append(requested, commonName)
+	}
+	if !strutil.EquivalentSlices(strutil.RemoveDuplicates(requested, false), names) ||
+		len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return b.acmeProblemResponse(r.config, badNames)
+	}
+
+	role, err := b.getRole(ctx, req.Storage, r.config.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("ACME role %q not found", r.config.Role)
+	}
+	// The names were validated against the role with the order, and are
+	// checked again when signing
+	issueRole := *role
+	issueRole.UseCSRCommonName = false
+	issueRole.UseCSRSANs = false
+
+	if commonName == "" {
+		commonName = names[0]
+	}
+	altNames := strutil.StrListDelete(names, commonName)
+
+	signingBundle, err := fetchCAInfo(ctx, b, req, role.IssuerRef)
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching CA certificate: {{err}}", err)
+	}
+	input := &dataBundle{
+		req:  req,
+		role: &issueRole,
+		apiData: &framework.FieldData{
+			Raw: map[string]interface{}{
+				"csr":         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})),
+				"common_name": commonName,
+				"alt_names":   strings.Join(altNames, ","),
+			},
+			Schema: pathSign(b).Fields,
+		},
+		signingBundle: signingBundle,
+	}
+	parsedBundle, err := signCert(b, input, false, false)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "badCSR", err.Error()))
+		default:
+			return nil, err
+		}
+	}
+
+	cb, err := parsedBundle.ToCertBundle()
+	if err != nil {
+		return nil, errwrap.Wrapf("error converting raw cert bundle to cert bundle: {{err}}", err)
+	}
+	if !role.NoStore {
+		err = storeCert(ctx, req.Storage, parsedBundle.Certificate, parsedBundle.CertificateBytes, r.config.Role, signingBundle.IssuerID)
+		if err != nil {
+			return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
+		}
+	}
+
+	chain := []*certutil.CertBlock{{Bytes: parsedBundle.CertificateBytes}}
+	chain = append(chain, signingBundle.GetCAChain()...)
+	var certificate []byte
+	for _, block := range chain {
+		certificate = append(certificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: block.Bytes})...)
+	}
+
+	order.Status = acmeStatusValid
+	order.Certificate = string(certificate)
+	order.SerialNumber = cb.SerialNumber
+	if err := acmePut(ctx, req.Storage, "acme/orders/"+order.ID, order); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(r.config, http.StatusOK, acmeOrderResponse(r.config, order), acmeOrderURL(r.config, order))
+}
+
+func (b *backend) pathACMEAuthorization(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, resp, err := b.acmeVerify(ctx, req, data, false)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("failed to decode payload: %v", err)))
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	authz, err := b.acmeAuthorization(ctx, req.Storage, data.Get("authorization_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusNotFound, "malformed", "authorization not found"))
+	}
+	if authz.AccountID != r.account.ID {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusForbidden, "unauthorized", "the authorization belongs to another account"))
+	}
+
+	switch payload.Status {
+	case "":
+	case acmeStatusDeactivated:
+		if authz.Status != acmeStatusPending && authz.Status != acmeStatusValid {
+			return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("the authorization is %s", authz.Status)))
+		}
+		authz.Status = acmeStatusDeactivated
+		if err := acmePut(ctx, req.Storage, "acme/authorizations/"+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	default:
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("authorizations cannot be set to %q", payload.Status)))
+	}
+
+	return b.acmeJSONResponse(r.config, http.StatusOK, acmeAuthorizationResponse(r.config, authz), "")
+}
+
+func (b *backend) pathACMEChallenge(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	r, resp, err := b.acmeVerify(ctx, req, data, false)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	authzID := data.Get("authorization_id").(string)
+	challengeType := data.Get("challenge_type").(string)
+
+	authz, err := b.acmeAuthorization(ctx, req.Storage, authzID)
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusNotFound, "malformed", "authorization not found"))
+	}
+	if authz.AccountID != r.account.ID {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusForbidden, "unauthorized", "the authorization belongs to another account"))
+	}
+	challenge := authz.challenge(challengeType)
+	if challenge == nil {
+		return b.acmeProblemResponse(r.config, newACMEProblem(http.StatusNotFound, "malformed", "challenge not found"))
+	}
+
+	// An empty payload only fetches the challenge, as does responding to a
+	// challenge that was already processed
+	if len(r.payload) == 0 || authz.Status != acmeStatusPending || challenge.Status != acmeStatusPending {
+		return b.acmeJSONResponse(r.config, http.StatusOK, acmeChallengeResponse(r.config, authz, challenge), "")
+	}
+
+	// The validation is done without holding the lock, as it waits on the
+	// network
+	problem, err := b.acmeValidateChallenge(ctx, r.config, authz, challenge, r.account.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	authz, err = b.acmeAuthorization(ctx, req.Storage, authzID)
+	if err != nil {
+		return nil, err
+	}
+	challenge = authz.challenge(challengeType)
+	if authz.Status == acmeStatusPending && challenge.Status == acmeStatusPending {
+		if problem == nil {
+			challenge.Status = acmeStatusValid
+			challenge.Validated = time.Now().UTC().Truncate(time.Second)
+			authz.Status = acmeStatusValid
+		} else {
+			challenge.Status = acmeStatusInvalid
+			challenge.Error = problem
+			authz.Status = acmeStatusInvalid
+		}
+		if err := acmePut(ctx, req.Storage, "acme/authorizations/"+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeJSONResponse(r.config, http.StatusOK, acmeChallengeResponse(r.config, authz, challenge), "")
+}
+
+func (a *acmeAuthorization) challenge(challengeType string) *acmeChallenge {
+	for _, challenge := range a.Challenges {
+		if challenge.Type == challengeType {
+			return challenge
+		}
+	}
+	return nil
+}
+
+// validateACMEContact checks that the contacts of an account are email
+// addresses, which are only stored for informational purposes
+func validateACMEContact(contact []string) *acmeProblem {
+	for _, c := range contact {
+		if !strings.HasPrefix(c, "mailto:") {
+			return newACMEProblem(http.StatusBadRequest, "unsupportedContact", fmt.Sprintf("unsupported contact %q", c))
+		}
+	}
+	return nil
+}
+
+func acmeAccountURL(config *acmeConfig, account *acmeAccount) string {
+	return config.BaseURL + "/acme/account/" + account.ID
+}
+
+func acmeOrderURL(config *acmeConfig, order *acmeOrder) string {
+	return config.BaseURL + "/acme/order/" + order.ID
+}
+
+func acmeAccountResponse(account *acmeAccount) map[string]interface{} {
+	return map[string]interface{}{
+		"status":  account.Status,
+		"contact": account.Contact,
+	}
+}
+
+func acmeOrderResponse(config *acmeConfig, order *acmeOrder) map[string]interface{} {
+	var authorizations []string
+	for _, id := range order.AuthorizationIDs {
+		authorizations = append(authorizations, config.BaseURL+"/acme/authorization/"+id)
+	}
+	ret := map[string]interface{}{
+		"status":         order.Status,
+		"expires":        order.Expires.Format(time.RFC3339),
+		"identifiers":    order.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       acmeOrderURL(config, order) + "/finalize",
+	}
+	if order.Status == acmeStatusValid {
+		ret["certificate"] = acmeOrderURL(config, order) + "/cert"
+	}
+	return ret
+}
+
+func acmeAuthorizationResponse(config *acmeConfig, authz *acmeAuthorization) map[string]interface{} {
+	var challenges []map[string]interface{}
+	for _, challenge := range authz.Challenges {
+		challenges = append(challenges, acmeChallengeResponse(config, authz, challenge))
+	}
+	ret := map[string]interface{}{
+		"status":     authz.Status,
+		"expires":    authz.Expires.Format(time.RFC3339),
+		"identifier": authz.Identifier,
+		"challenges": challenges,
+	}
+	if authz.Wildcard {
+		ret["wildcard"] = true
+	}
+	return ret
+}
+
+func acmeChallengeResponse(config *acmeConfig, authz *acmeAuthorization, challenge *acmeChallenge) map[string]interface{} {
+	ret := map[string]interface{}{
+		"type":   challenge.Type,
+		"url":    fmt.Sprintf("%s/acme/challenge/%s/%s", config.BaseURL, authz.ID, challenge.Type),
+		"status": challenge.Status,
+		"token":  challenge.Token,
+	}
+	if !challenge.Validated.IsZero() {
+		ret["validated"] = challenge.Validated.Format(time.RFC3339)
+	}
+	if challenge.Error != nil {
+		ret["error"] = challenge.Error
+	}
+	return ret
+}
+
+const pathACMEHelpSyn = `
+ACME server of the mount.
+`
+
+const pathACMEHelpDesc = `
+These endpoints implement an ACME server (RFC 8555), so that certificates
+can be ordered with standard ACME clients. Clients start at the
+"acme/directory" endpoint; all other requests are signed with the key of the
+client's account as a JWS. Identifiers are validated with http-01 or dns-01
+challenges, after which the certificate is issued with the role configured at
+"config/acme".
+`
+
+// normalizeACMEName returns the DNS name lowercased and in its ASCII form, as
+// identifiers are stored with orders and compared with the names of CSRs
+func normalizeACMEName(value string) (string, error) {
+	name, err := idna.New(
+		idna.StrictDomainName(true),
+		idna.VerifyDNSLength(true),
+	).ToASCII(strings.ToLower(value))
+	if err != nil {
+		return "", err
+	}
+	if !hostnameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid DNS name %q", value)
+	}
+	return name, nil
+}
@@ -0,0 +1,587 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/ryanuber/go-glob"
+)
+
+const (
+	revocationStateAny       = "any"
+	revocationStateRevoked   = "revoked"
+	revocationStateUnrevoked = "unrevoked"
+
+	defaultSearchLimit = 100
+
+	expiryReportCacheTTL = time.Minute
+)
+
+func pathSearchCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/search",
+		Fields: map[string]*framework.FieldSchema{
+			"common_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates whose common name
+matches this pattern, which may contain globs such as
+"*.example.com"`,
+			},
+
+			"san": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates with a DNS, email,
+IP or URI subject alternative name that matches this
+pattern, which may contain globs`,
+			},
+
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Only return certificates issued through this role`,
+			},
+
+			"issuer_ref": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates signed by this issuer:
+its ID, its name, or "default"`,
+			},
+
+			"revocation_state": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: revocationStateAny,
+				Description: `Only return certificates that are "revoked" or
+"unrevoked"; "any" returns both. Defaults to "any".`,
+			},
+
+			"expires_after": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates that expire after this
+time: an RFC 3339 timestamp, or a duration from now
+such as "-24h"`,
+			},
+
+			"expires_before": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates that expire before this
+time: an RFC 3339 timestamp, or a duration from now
+such as "720h"`,
+			},
+
+			"after": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates with a serial number
+after this one, to fetch the next page of results`,
+			},
+
+			"limit": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     defaultSearchLimit,
+				Description: `The maximum number of certificates to return. Defaults to 100.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathSearchCertsRead,
+			logical.UpdateOperation: b.pathSearchCertsRead,
+		},
+
+		HelpSynopsis:    pathSearchCertsHelpSyn,
+		HelpDescription: pathSearchCertsHelpDesc,
+	}
+}
+
+func pathCertExpiryReport(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/expiry-report",
+		Fields: map[string]*framework.FieldSchema{
+			"expiring_within": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Certificates that expire within this duration
+are reported as expiring. Defaults to 30 days.`,
+				Default: 2592000, // 720h, but TypeDurationSecond currently requires defaults to be int
+			},
+
+			"format": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "json",
+				Description: `The format of the report: "json", or
+"prometheus" for the Prometheus text exposition
+format. Defaults to "json".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCertExpiryReportRead,
+		},
+
+		HelpSynopsis:    pathCertExpiryReportHelpSyn,
+		HelpDescription: pathCertExpiryReportHelpDesc,
+	}
+}
+
+// parseSearchTime parses an RFC 3339 timestamp or a duration relative to now
+func parseSearchTime(now time.Time, in string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, in); err == nil {
+		return t, nil
+	}
+	d, err := parseutil.ParseDurationSecond(in)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 timestamp nor a duration", in)
+	}
+	return now.Add(d), nil
+}
+
+// listRevokedSerials returns the set of revoked serial numbers
+func listRevokedSerials(ctx context.Context, s logical.Storage) (map[string]bool, error) {
+	revokedSerials, err := s.List(ctx, "revoked/")
+	if err != nil {
+		return nil, err
+	}
+	revoked := make(map[string]bool, len(revokedSerials))
+	for _, serial := range revokedSerials {
+		revoked[normalizeSerial(serial)] = true
+	}
+	return revoked, nil
+}
+
+// listExpiringSerials returns the serial numbers listed in the index under
+// the days between after and before; either may be zero to leave the range
+// open
+func listExpiringSerials(ctx context.Context, s logical.Storage, after, before time.Time) ([]string, error) {
+	days, err := s.List(ctx, certIndexExpiryPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var serials []string
+	for _, day := range days {
+		bucket := strings.TrimSuffix(day, "/")
+		if (!after.IsZero() && bucket < expiryBucket(after)) || (!before.IsZero() && bucket > expiryBucket(before)) {
+			continue
+		}
+		daySerials, err := s.List(ctx, certIndexExpiryPrefix+day)
+		if err != nil {
+			return nil, err
+		}
+		serials = append(serials, daySerials...)
+	}
+	return serials, nil
+}
+
+// certSearch holds the filters of a search that narrow down the certificates
+// read from the index
+type certSearch struct {
+	revokedOnly   bool
+	role          string
+	issuerID      string
+	expiresAfter  time.Time
+	expiresBefore time.Time
+}
+
+// searchSerials returns the serial numbers of the stored certificates that
+// may match the search, read from the narrowest listing of the index that
+// applies. Until every certificate is indexed, it returns all of them.
+func (b *backend) searchSerials(ctx context.Context, s logical.Storage, search *certSearch, revoked map[string]bool) ([]string, error) {
+	if search.revokedOnly {
+		serials := make([]string, 0, len(revoked))
+		for serial := range revoked {
+			serials = append(serials, serial)
+		}
+		return serials, nil
+	}
+
+	indexed, err := b.certIndexComplete(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !indexed:
+		return s.List(ctx, "certs/")
+	case search.role != "":
+		return s.List(ctx, certIndexRolePrefix+search.role+"/")
+	case search.issuerID != "":
+		return s.List(ctx, certIndexIssuerPrefix+search.issuerID+"/")
+	case !search.expiresAfter.IsZero() || !search.expiresBefore.IsZero():
+		return listExpiringSerials(ctx, s, search.expiresAfter, search.expiresBefore)
+	default:
+		return s.List(ctx, certIndexPrefix)
+	}
+}
+
+// walkCertIndex calls fn with the index entry of each of the given
+// certificates, in order of serial number, starting after the given serial
+// number. It stops when fn returns false.
+func (b *backend) walkCertIndex(ctx context.Context, s logical.Storage, serials []string, revoked map[string]bool, after string, fn func(entry *certIndexEntry, revoked bool) bool) error {
+	sort.Slice(serials, func(i, j int) bool {
+		return normalizeSerial(serials[i]) < normalizeSerial(serials[j])
+	})
+
+	after = normalizeSerial(after)
+	for _, serial := range serials {
+		if after != "" && normalizeSerial(serial) <= after {
+			continue
+		}
+		entry, err := b.fetchCertIndexEntry(ctx, s, serial)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		if !fn(entry, revoked[normalizeSerial(serial)]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func certIndexEntryInfo(entry *certIndexEntry, revoked bool) map[string]interface{} {
+	return map[string]interface{}{
+		"common_name": entry.CommonName,
+		"alt_names":   entry.subjectAltNames(),
+		"role":        entry.Role,
+		"issuer_id":   entry.IssuerID,
+		"is_ca":       entry.IsCA,
+		"not_before":  entry.NotBefore.Format(time.RFC3339),
+		"not_after":   entry.NotAfter.Format(time.RFC3339),
+		"revoked":     revoked,
+	}
+}
+
+func (b *backend) pathSearchCertsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	now := time.Now()
+
+	commonName := strings.ToLower(data.Get("common_name").(string))
+	san := strings.ToLower(data.Get("san").(string))
+	role := data.Get("role").(string)
+	if strings.Contains(role, "/") || strings.Contains(role, "..") {
+		return logical.ErrorResponse(fmt.Sprintf("invalid role name %q", role)), nil
+	}
+
+	revocationState := data.Get("revocation_state").(string)
+	switch revocationState {
+	case revocationStateAny, revocationStateRevoked, revocationStateUnrevoked:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid revocation_state %q; must be %q, %q or %q", revocationState, revocationStateAny, revocationStateRevoked, revocationStateUnrevoked)), nil
+	}
+
+	var expiresAfter, expiresBefore time.Time
+	if raw := data.Get("expires_after").(string); raw != "" {
+		t, err := parseSearchTime(now, raw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid expires_after: %v", err)), nil
+		}
+		expiresAfter = t
+	}
+	if raw := data.Get("expires_before").(string); raw != "" {
+		t, err := parseSearchTime(now, raw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid expires_before: %v", err)), nil
+		}
+		expiresBefore = t
+	}
+
+	var issuerID string
+	if issuerRef := data.Get("issuer_ref").(string); issuerRef != "" {
+		id, err := b.resolveIssuerRef(ctx, req.Storage, issuerRef)
+		switch err.(type) {
+		case nil:
+			issuerID = id
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	limit := data.Get("limit").(int)
+	if limit <= 0 {
+		return logical.ErrorResponse("limit must be greater than zero"), nil
+	}
+
+	matches := func(entry *certIndexEntry, revoked bool) bool {
+		switch {
+		case revocationState == revocationStateRevoked && !revoked,
+			revocationState == revocationStateUnrevoked && revoked:
+			return false
+		case role != "" && entry.Role != role:
+			return false
+		case issuerID != "" && entry.IssuerID != issuerID:
+			return false
+		case !expiresAfter.IsZero() && !entry.NotAfter.After(expiresAfter):
+			return false
+		case !expiresBefore.IsZero() && !entry.NotAfter.Before(expiresBefore):
+			return false
+		case commonName != "" && !glob.Glob(commonName, strings.ToLower(entry.CommonName)):
+			return false
+		}
+
+		if san == "" {
+			return true
+		}
+		for _, name := range entry.subjectAltNames() {
+			if glob.Glob(san, strings.ToLower(name)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	revokedSerials, err := listRevokedSerials(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	serials, err := b.searchSerials(ctx, req.Storage, &certSearch{
+		revokedOnly:   revocationState == revocationStateRevoked,
+		role:          role,
+		issuerID:      issuerID,
+		expiresAfter:  expiresAfter,
+		expiresBefore: expiresBefore,
+	}, revokedSerials)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	keyInfo := map[string]interface{}{}
+	more := false
+	err = b.walkCertIndex(ctx, req.Storage, serials, revokedSerials, data.Get("after").(string), func(entry *certIndexEntry, revoked bool) bool {
+		if !matches(entry, revoked) {
+			return true
+		}
+		if len(keys) == limit {
+			more = true
+			return false
+		}
+		keys = append(keys, entry.SerialNumber)
+		keyInfo[entry.SerialNumber] = certIndexEntryInfo(entry, revoked)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := logical.ListResponseWithInfo(keys, keyInfo)
+	if more {
+		resp.Data["next"] = keys[len(keys)-1]
+	}
+	return resp, nil
+}
+
+func (b *backend) pathCertExpiryReportRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	expiringWithin := data.Get("expiring_within").(int)
+	if expiringWithin < 1 {
+		return logical.ErrorResponse("expiring_within must be greater than zero"), nil
+	}
+	format := data.Get("format").(string)
+	if format != "json" && format != "prometheus" {
+		return logical.ErrorResponse(`the "format" parameter must be "json" or "prometheus"`), nil
+	}
+
+	report, err := b.expiryReport(ctx, req.Storage, expiringWithin)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "prometheus" {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPContentType: "text/plain; version=0.0.4",
+				logical.HTTPRawBody:     []byte(prometheusExpiryReport(report, expiringWithin)),
+				logical.HTTPStatusCode:  http.StatusOK,
+			},
+		}, nil
+	}
+
+	now := time.Now()
+	expiringInfo := make([]map[string]interface{}, 0, len(report.expiring))
+	for _, entry := range report.expiring {
+		info := certIndexEntryInfo(entry, false)
+		info["serial_number"] = entry.SerialNumber
+		info["expires_in"] = int64(entry.NotAfter.Sub(now).Seconds())
+		expiringInfo = append(expiringInfo, info)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"valid":           report.valid,
+			"expired":         report.expired,
+			"revoked":         report.revoked,
+			"expiring":        expiringInfo,
+			"expiring_within": expiringWithin,
+		},
+	}, nil
+}
+
+// expiryReport counts the stored certificates by state and holds the valid
+// certificates that expire within the reporting window, soonest first
+type expiryReport struct {
+	valid    int
+	expired  int
+	revoked  int
+	expiring []*certIndexEntry
+}
+
+// expiryReport returns the expiry report for the window, which is cached for
+// expiryReportCacheTTL
+func (b *backend) expiryReport(ctx context.Context, s logical.Storage, expiringWithin int) (*expiryReport, error) {
+	cacheKey := strconv.Itoa(expiringWithin)
+	if cached, ok := b.expiryReports.Get(cacheKey); ok {
+		return cached.(*expiryReport), nil
+	}
+
+	now := time.Now()
+	report, err := b.buildExpiryReport(ctx, s, now, now.Add(time.Duration(expiringWithin)*time.Second))
+	if err != nil {
+		return nil, err
+	}
+	b.expiryReports.SetDefault(cacheKey, report)
+	return report, nil
+}
+
+// buildExpiryReport builds the expiry report from the index. Only the
+// certificates listed under the days from now to the end of the window are
+// read; the others are counted from the listings alone.
+func (b *backend) buildExpiryReport(ctx context.Context, s logical.Storage, now, horizon time.Time) (*expiryReport, error) {
+	revokedSerials, err := listRevokedSerials(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &expiryReport{}
+	add := func(entry *certIndexEntry, revoked bool) bool {
+		switch {
+		case revoked:
+			report.revoked++
+		case !entry.NotAfter.After(now):
+			report.expired++
+		default:
+			report.valid++
+			if entry.NotAfter.Before(horizon) {
+				report.expiring = append(report.expiring, entry)
+			}
+		}
+		return true
+	}
+
+	indexed, err := b.certIndexComplete(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if indexed {
+		days, err := s.List(ctx, certIndexExpiryPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, day := range days {
+			bucket := strings.TrimSuffix(day, "/")
+			serials, err := s.List(ctx, certIndexExpiryPrefix+day)
+			if err != nil {
+				return nil, err
+			}
+			for _, serial := range serials {
+				switch {
+				case revokedSerials[serial]:
+					report.revoked++
+				case bucket < expiryBucket(now):
+					report.expired++
+				case bucket > expiryBucket(horizon):
+					report.valid++
+				default:
+					entry, err := b.fetchCertIndexEntry(ctx, s, serial)
+					if err != nil {
+						return nil, err
+					}
+					if entry != nil {
+						add(entry, false)
+					}
+				}
+			}
+		}
+	} else {
+		serials, err := s.List(ctx, "certs/")
+		if err != nil {
+			return nil, err
+		}
+		if err := b.walkCertIndex(ctx, s, serials, revokedSerials, "", add); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(report.expiring, func(i, j int) bool {
+		return report.expiring[i].NotAfter.Before(report.expiring[j].NotAfter)
+	})
+	return report, nil
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func prometheusExpiryReport(report *expiryReport, expiringWithin int) string {
+	var sb strings.Builder
+
+	sb.WriteString("# HELP vault_pki_certificates Number of certificates stored by the mount.\n")
+	sb.WriteString("# TYPE vault_pki_certificates gauge\n")
+	fmt.Fprintf(&sb, "vault_pki_certificates{state=\"valid\"} %d\n", report.valid)
+	fmt.Fprintf(&sb, "vault_pki_certificates{state=\"expired\"} %d\n", report.expired)
+	fmt.Fprintf(&sb, "vault_pki_certificates{state=\"revoked\"} %d\n", report.revoked)
+
+	sb.WriteString("# HELP vault_pki_certificates_expiring Number of valid certificates that expire within the reporting window.\n")
+	sb.WriteString("# TYPE vault_pki_certificates_expiring gauge\n")
+	fmt.Fprintf(&sb, "vault_pki_certificates_expiring{window_seconds=\"%d\"} %d\n", expiringWithin, len(report.expiring))
+
+	sb.WriteString("# HELP vault_pki_certificate_expiry_timestamp_seconds Expiry time of each valid certificate that expires within the reporting window.\n")
+	sb.WriteString("# TYPE vault_pki_certificate_expiry_timestamp_seconds gauge\n")
+	for _, entry := range report.expiring {
+		fmt.Fprintf(&sb, "vault_pki_certificate_expiry_timestamp_seconds{serial_number=\"%s\",common_name=\"%s\",role=\"%s\",issuer_id=\"%s\"} %d\n",
+			prometheusLabelEscaper.Replace(entry.SerialNumber),
+			prometheusLabelEscaper.Replace(entry.CommonName),
+			prometheusLabelEscaper.Replace(entry.Role),
+			prometheusLabelEscaper.Replace(entry.IssuerID),
+			entry.NotAfter.Unix())
+	}
+
+	return sb.String()
+}
+
+const pathSearchCertsHelpSyn = `
+Search the certificates stored by the backend.
+`
+
+const pathSearchCertsHelpDesc = `
+This endpoint returns the serial numbers of the stored certificates that
+match all of the given filters, along with their names, role, issuer,
+validity and revocation state. Certificates issued by roles with "no_store"
+set are not stored and cannot be found.
+
+Results are ordered by serial number and limited to 'limit' entries. When
+more certificates match, the response contains 'next'; pass it as 'after'
+to fetch the next page.
+
+Searches with 'role', 'issuer_ref', 'expires_after' or 'expires_before', or
+for revoked certificates, only read the certificates listed in the index
+under those values. Other searches read the stored certificates one by one
+until enough matches are found, so their cost grows with the number of
+stored certificates.
+`
+
+const pathCertExpiryReportHelpSyn = `
+Report on the expiry of the certificates stored by the backend.
+`
+
+const pathCertExpiryReportHelpDesc = `
+This endpoint counts the stored certificates that are valid, expired or
+revoked, and lists the valid certificates that expire within
+'expiring_within', soonest first. With 'format' set to "prometheus", the
+report is returned in the Prometheus text exposition format so that it can
+be scraped for alerting. Reports are cached for a minute.
+`
@@ -0,0 +1,207 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Challenge types that can be used to validate ACME identifiers
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeChallengeDNS01  = "dns-01"
+)
+
+var acmeChallengeTypes = []string{acmeChallengeHTTP01, acmeChallengeDNS01}
+
+// acmeConfig holds the configuration of the ACME server
+type acmeConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+
+	// Role is the role that certificates are issued with
+	Role string `json:"role" mapstructure:"role" structs:"role"`
+
+	// BaseURL is the URL of the mount as reached by ACME clients, which
+	// prefixes the URLs of the ACME resources
+	BaseURL string `json:"base_url" mapstructure:"base_url" structs:"base_url"`
+
+	AllowedChallenges []string `json:"allowed_challenges" mapstructure:"allowed_challenges" structs:"allowed_challenges"`
+
+	// DNSResolver is the address of the DNS server that dns-01 challenges
+	// are validated against. If empty, the resolver of the system is used.
+	DNSResolver string `json:"dns_resolver" mapstructure:"dns_resolver" structs:"dns_resolver"`
+}
+
+func pathConfigACME(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/acme",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set, the ACME server of this mount is enabled`,
+			},
+
+			"role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role that certificates ordered through ACME are
+issued with. The identifiers of orders must be allowed
+by the role. Required to enable ACME.`,
+			},
+
+			"base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The URL of this mount as reached by ACME clients,
+such as "https://vault.example.com:8200/v1/pki". Required
+to enable ACME.`,
+			},
+
+			"allowed_challenges": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of the challenge types that
+identifiers can be validated with; defaults to
+"http-01,dns-01"`,
+				Default: strings.Join(acmeChallengeTypes, ","),
+			},
+
+			"dns_resolver": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The address, as host:port, of the DNS server that
+dns-01 challenges are validated against. If empty, the
+resolver of the system is used.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathACMEConfigRead,
+			logical.UpdateOperation: b.pathACMEConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigACMEHelpSyn,
+		HelpDescription: pathConfigACMEHelpDesc,
+	}
+}
+
+func (b *backend) ACME(ctx context.Context, s logical.Storage) (*acmeConfig, error) {
+	entry, err := s.Get(ctx, "config/acme")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result acmeConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathACMEConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACME(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":            config.Enabled,
+			"role":               config.Role,
+			"base_url":           config.BaseURL,
+			"allowed_challenges": config.AllowedChallenges,
+			"dns_resolver":       config.DNSResolver,
+		},
+	}, nil
+}
+
+func (b *backend) pathACMEConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACME(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &acmeConfig{
+			AllowedChallenges: acmeChallengeTypes,
+		}
+	}
+
+	if enabled, ok := d.GetOk("enabled"); ok {
+		config.Enabled = enabled.(bool)
+	}
+	if role, ok := d.GetOk("role"); ok {
+		config.Role = role.(string)
+	}
+	if baseURL, ok := d.GetOk("base_url"); ok {
+		config.BaseURL = strings.TrimSuffix(baseURL.(string), "/")
+		if config.BaseURL != "" && !govalidator.IsURL(config.BaseURL) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid base_url %q", config.BaseURL)), nil
+		}
+	}
+	if challenges, ok := d.GetOk("allowed_challenges"); ok {
+		config.AllowedChallenges = strutil.RemoveDuplicates(challenges.([]string), true)
+		for _, challenge := range config.AllowedChallenges {
+			if !strutil.StrListContains(acmeChallengeTypes, challenge) {
+				return logical.ErrorResponse(fmt.Sprintf("unsupported challenge type %q", challenge)), nil
+			}
+		}
+		if len(config.AllowedChallenges) == 0 {
+			return logical.ErrorResponse("at least one challenge type must be allowed"), nil
+		}
+	}
+	if resolver, ok := d.GetOk("dns_resolver"); ok {
+		config.DNSResolver = resolver.(string)
+		if config.DNSResolver != "" {
+			if _, _, err := net.SplitHostPort(config.DNSResolver); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid dns_resolver: %s", err)), nil
+			}
+		}
+	}
+
+	if config.Enabled {
+		if config.BaseURL == "" {
+			return logical.ErrorResponse("base_url is required to enable ACME"), nil
+		}
+		if config.Role == "" {
+			return logical.ErrorResponse("role is required to enable ACME"), nil
+		}
+		role, err := b.getRole(ctx, req.Storage, config.Role)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", config.Role)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/acme", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigACMEHelpSyn = `
+Configure the ACME server.
+`
+
+const pathConfigACMEHelpDesc = `
+This endpoint allows configuration of the ACME server (RFC 8555) of this
+mount, whose directory is served at "acme/directory". Certificates ordered
+through ACME are issued with the configured role once their identifiers are
+validated with one of the allowed challenge types.
+`
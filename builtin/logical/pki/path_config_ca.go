@@ -19,6 +19,18 @@ func pathConfigCA(b *backend) *framework.Path {
 				Description: `PEM-format, concatenated unencrypted
 secret key and certificate.`,
 			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Optional name of the new issuer, which can be
+used instead of its ID to refer to it.`,
+			},
+
+			"key_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Optional name of the imported key, which can
+be used instead of its ID to refer to it.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -60,32 +72,25 @@ func (b *backend) pathCAWrite(ctx context.Context, req *logical.Request, data *f
 		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
-	cb, err := parsedBundle.ToCertBundle()
-	if err != nil {
-		return nil, errwrap.Wrapf("error converting raw values into cert bundle: {{err}}", err)
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	// Store it as a new issuer, plus a fresh CRL
+	issuer, _, err := b.importIssuer(ctx, req.Storage, parsedBundle, data.Get("issuer_name").(string), data.Get("key_name").(string))
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, errwrap.Wrapf("error storing the CA: {{err}}", err)
 	}
 
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
-
-	// For ease of later use, also store just the certificate at a known
-	// location, plus a fresh CRL
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
+	err = buildCRL(ctx, b, req)
 	if err != nil {
 		return nil, err
 	}
 
-	err = buildCRL(ctx, b, req)
-
-	return nil, err
+	return nonDefaultIssuerResponse(ctx, req.Storage, issuer)
 }
 
 const pathConfigCAHelpSyn = `
@@ -93,9 +98,10 @@ Set the CA certificate and private key used for generated credentials.
 `
 
 const pathConfigCAHelpDesc = `
-This sets the CA information used for credentials generated by this
-by this mount. This must be a PEM-format, concatenated unencrypted
-secret key and certificate.
+This adds the CA information used for credentials generated by this
+by this mount as a new issuer. This must be a PEM-format, concatenated
+unencrypted secret key and certificate. The first issuer of the mount
+becomes its default issuer.
 
 For security reasons, the secret key cannot be retrieved later.
 `
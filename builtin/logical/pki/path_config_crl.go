@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
 // CRLConfig holds basic CRL configuration information
 type crlConfig struct {
-	Expiry string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	Expiry                 string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	AutoRebuild            bool   `json:"auto_rebuild" mapstructure:"auto_rebuild" structs:"auto_rebuild"`
+	AutoRebuildGracePeriod string `json:"auto_rebuild_grace_period" mapstructure:"auto_rebuild_grace_period" structs:"auto_rebuild_grace_period"`
+	EnableDelta            bool   `json:"enable_delta" mapstructure:"enable_delta" structs:"enable_delta"`
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -24,6 +28,25 @@ func pathConfigCRL(b *backend) *framework.Path {
 valid; defaults to 72 hours`,
 				Default: "72h",
 			},
+
+			"auto_rebuild": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the CRL is rebuilt automatically before
+it expires`,
+			},
+
+			"auto_rebuild_grace_period": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long before its expiry the CRL is rebuilt when
+auto_rebuild is set; defaults to 12 hours`,
+				Default: "12h",
+			},
+
+			"enable_delta": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, delta CRLs are published at "crl/delta".
+Revocations then only rebuild the delta CRL. Requires auto_rebuild.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -64,7 +87,10 @@ func (b *backend) pathCRLRead(ctx context.Context, req *logical.Request, data *f
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"expiry": config.Expiry,
+			"expiry":                    config.Expiry,
+			"auto_rebuild":              config.AutoRebuild,
+			"auto_rebuild_grace_period": config.AutoRebuildGracePeriod,
+			"enable_delta":              config.EnableDelta,
 		},
 	}, nil
 }
@@ -72,13 +98,28 @@ func (b *backend) pathCRLRead(ctx context.Context, req *logical.Request, data *f
 func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	expiry := d.Get("expiry").(string)
 
-	_, err := time.ParseDuration(expiry)
+	expiryDur, err := time.ParseDuration(expiry)
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf("Given expiry could not be decoded: %s", err)), nil
 	}
 
+	gracePeriod := d.Get("auto_rebuild_grace_period").(string)
+	gracePeriodDur, err := time.ParseDuration(gracePeriod)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given auto_rebuild_grace_period could not be decoded: %s", err)), nil
+	}
+
 	config := &crlConfig{
-		Expiry: expiry,
+		Expiry:                 expiry,
+		AutoRebuild:            d.Get("auto_rebuild").(bool),
+		AutoRebuildGracePeriod: gracePeriod,
+		EnableDelta:            d.Get("enable_delta").(bool),
+	}
+	if config.AutoRebuild && gracePeriodDur >= expiryDur {
+		return logical.ErrorResponse("auto_rebuild_grace_period must be shorter than expiry"), nil
+	}
+	if config.EnableDelta && !config.AutoRebuild {
+		return logical.ErrorResponse("enable_delta requires auto_rebuild, as revocations no longer rebuild the full CRL"), nil
 	}
 
 	entry, err := logical.StorageEntryJSON("config/crl", config)
@@ -90,13 +131,42 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		return nil, err
 	}
 
+	// Rebuild the CRLs so that the new configuration applies right away; a
+	// mount without a CA has none to rebuild
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(issuerIDs) == 0 {
+		return nil, nil
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	crlErr := buildCRL(ctx, b, req)
+	switch crlErr.(type) {
+	case errutil.UserError:
+		resp := &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("The configuration was saved, but the CRLs could not be rebuilt: %s", crlErr))
+		return resp, nil
+	case errutil.InternalError:
+		return nil, crlErr
+	}
+
 	return nil, nil
 }
 
 const pathConfigCRLHelpSyn = `
-Configure the CRL expiration.
+Configure the CRL expiration and rebuilding.
 `
 
 const pathConfigCRLHelpDesc = `
 This endpoint allows configuration of the CRL lifetime.
+
+If auto_rebuild is set, the CRL is rebuilt automatically once it is within
+auto_rebuild_grace_period of its expiry. With enable_delta, delta CRLs listing
+the certificates revoked since the full CRL was built are published at
+"crl/delta" and "crl/issuer/<issuer_ref>/delta". Revocations then only rebuild
+the delta CRLs, so that the full CRL is rebuilt less often on large mounts.
 `
@@ -0,0 +1,211 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// ocspConfig holds the configuration of the OCSP responder
+type ocspConfig struct {
+	Disable    bool   `json:"disable" mapstructure:"disable" structs:"disable"`
+	NextUpdate string `json:"next_update" mapstructure:"next_update" structs:"next_update"`
+
+	// ResponderBundle is the delegated responder certificate and key. If
+	// empty, responses are signed by the CA.
+	ResponderBundle *certutil.CertBundle `json:"responder_bundle,omitempty" mapstructure:"responder_bundle" structs:"responder_bundle"`
+}
+
+const defaultOCSPNextUpdate = "12h"
+
+func pathConfigOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ocsp",
+		Fields: map[string]*framework.FieldSchema{
+			"disable": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set, the OCSP responder answers all requests as unauthorized`,
+			},
+
+			"next_update": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The amount of time OCSP responses are valid for, after
+which clients should fetch a new one; defaults to 12 hours`,
+				Default: defaultOCSPNextUpdate,
+			},
+
+			"pem_bundle": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format, concatenated unencrypted secret key and
+certificate of a delegated OCSP responder. The certificate
+must be issued by an issuer of the mount for OCSP
+signing. If empty, responses are signed by the CA.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathOCSPConfigRead,
+			logical.UpdateOperation: b.pathOCSPConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigOCSPHelpSyn,
+		HelpDescription: pathConfigOCSPHelpDesc,
+	}
+}
+
+func (b *backend) OCSP(ctx context.Context, s logical.Storage) (*ocspConfig, error) {
+	entry, err := s.Get(ctx, "config/ocsp")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result ocspConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathOCSPConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.OCSP(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"disable":     config.Disable,
+			"next_update": config.NextUpdate,
+		},
+	}
+	if config.ResponderBundle != nil {
+		resp.Data["responder_certificate"] = config.ResponderBundle.Certificate
+	}
+	return resp, nil
+}
+
+func (b *backend) pathOCSPConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.OCSP(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &ocspConfig{
+			NextUpdate: defaultOCSPNextUpdate,
+		}
+	}
+
+	if disable, ok := d.GetOk("disable"); ok {
+		config.Disable = disable.(bool)
+	}
+	if nextUpdate, ok := d.GetOk("next_update"); ok {
+		config.NextUpdate = nextUpdate.(string)
+	}
+	if _, err := time.ParseDuration(config.NextUpdate); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given next_update could not be decoded: %s", err)), nil
+	}
+
+	if pemBundleRaw, ok := d.GetOk("pem_bundle"); ok {
+		pemBundle := pemBundleRaw.(string)
+		if pemBundle == "" {
+			config.ResponderBundle = nil
+		} else {
+			bundle, resp, err := b.parseOCSPResponderBundle(ctx, req, pemBundle)
+			if resp != nil || err != nil {
+				return resp, err
+			}
+			config.ResponderBundle = bundle
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/ocsp", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseOCSPResponderBundle validates the bundle of a delegated responder
+func (b *backend) parseOCSPResponderBundle(ctx context.Context, req *logical.Request, pemBundle string) (*certutil.CertBundle, *logical.Response, error) {
+	parsedBundle, err := certutil.ParsePEMBundle(pemBundle)
+	if err != nil {
+		switch err.(type) {
+		case errutil.InternalError:
+			return nil, nil, err
+		default:
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if parsedBundle.PrivateKey == nil ||
+		parsedBundle.PrivateKeyType == certutil.UnknownPrivateKey {
+		return nil, logical.ErrorResponse("private key not found in the PEM bundle"), nil
+	}
+	if parsedBundle.Certificate == nil {
+		return nil, logical.ErrorResponse("no certificate found in the PEM bundle"), nil
+	}
+
+	cert := parsedBundle.Certificate
+	var ocspSigning bool
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			ocspSigning = true
+		}
+	}
+	if !ocspSigning {
+		return nil, logical.ErrorResponse("the given certificate is not marked for OCSP signing"), nil
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, logical.ErrorResponse("the given certificate has expired"), nil
+	}
+
+	issuer, err := b.findIssuerOf(ctx, req.Storage, cert)
+	if err != nil {
+		return nil, nil, err
+	}
+	if issuer == nil {
+		return nil, logical.ErrorResponse("the given certificate was not issued by an issuer of this mount"), nil
+	}
+
+	// Only the responder itself is kept; it is the only certificate
+	// included in responses
+	parsedBundle.CAChain = nil
+	cb, err := parsedBundle.ToCertBundle()
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("error converting raw values into cert bundle: {{err}}", err)
+	}
+	return cb, nil, nil
+}
+
+const pathConfigOCSPHelpSyn = `
+Configure the OCSP responder.
+`
+
+const pathConfigOCSPHelpDesc = `
+This endpoint allows configuration of the OCSP responder of this mount,
+which answers requests at the "ocsp" endpoint. Responses are valid for the
+configured next update time, and are signed by the CA or, if a PEM bundle is
+given, by a delegated responder certificate issued by the CA for OCSP
+signing. The responder is only used for requests about certificates of the
+issuer that issued it. For security reasons, the secret key of the responder cannot be
+retrieved later.
+`
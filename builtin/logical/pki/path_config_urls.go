@@ -3,6 +3,7 @@ package pki
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/fatih/structs"
@@ -10,6 +11,10 @@ import (
 	"github.com/hashicorp/vault/logical/framework"
 )
 
+// issuerIDTemplate is replaced by the ID of the issuing CA in the configured
+// URLs
+const issuerIDTemplate = "{{issuer_id}}"
+
 func pathConfigURLs(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config/urls",
@@ -17,19 +22,22 @@ func pathConfigURLs(b *backend) *framework.Path {
 			"issuing_certificates": &framework.FieldSchema{
 				Type: framework.TypeCommaStringSlice,
 				Description: `Comma-separated list of URLs to be used
-for the issuing certificate attribute`,
+for the issuing certificate attribute. "{{issuer_id}}"
+is replaced by the ID of the issuer.`,
 			},
 
 			"crl_distribution_points": &framework.FieldSchema{
 				Type: framework.TypeCommaStringSlice,
 				Description: `Comma-separated list of URLs to be used
-for the CRL distribution points attribute`,
+for the CRL distribution points attribute.
+"{{issuer_id}}" is replaced by the ID of the issuer.`,
 			},
 
 			"ocsp_servers": &framework.FieldSchema{
 				Type: framework.TypeCommaStringSlice,
 				Description: `Comma-separated list of URLs to be used
-for the OCSP servers attribute`,
+for the OCSP servers attribute. "{{issuer_id}}"
+is replaced by the ID of the issuer.`,
 			},
 		},
 
@@ -45,7 +53,9 @@ for the OCSP servers attribute`,
 
 func validateURLs(urls []string) string {
 	for _, curr := range urls {
-		if !govalidator.IsURL(curr) {
+		// The template is not valid in URLs by itself
+		expanded := strings.Replace(curr, issuerIDTemplate, "issuer", -1)
+		if !govalidator.IsURL(expanded) {
 			return curr
 		}
 	}
@@ -39,10 +39,70 @@ func pathFetchCAChain(b *backend) *framework.Path {
 	}
 }
 
-// Returns the CRL in raw format
+// Returns the CRL or the delta CRL in raw format
 func pathFetchCRL(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: `crl(/pem)?`,
+		Pattern: `crl(/delta)?(/pem)?`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchRead,
+		},
+
+		HelpSynopsis:    pathFetchHelpSyn,
+		HelpDescription: pathFetchHelpDesc,
+	}
+}
+
+// Returns the certificate of an issuer in raw format
+func pathFetchIssuerCA(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `ca/issuer/` + framework.GenericNameRegex("issuer_ref") + `(/pem)?`,
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the issuer: its ID, its name, or "default"`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchRead,
+		},
+
+		HelpSynopsis:    pathFetchHelpSyn,
+		HelpDescription: pathFetchHelpDesc,
+	}
+}
+
+// Returns the CA chain of an issuer
+func pathFetchIssuerCAChain(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `ca_chain/issuer/` + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the issuer: its ID, its name, or "default"`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchRead,
+		},
+
+		HelpSynopsis:    pathFetchHelpSyn,
+		HelpDescription: pathFetchHelpDesc,
+	}
+}
+
+// Returns the CRL or the delta CRL of an issuer in raw format
+func pathFetchIssuerCRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `crl/issuer/` + framework.GenericNameRegex("issuer_ref") + `(/delta)?(/pem)?`,
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the issuer: its ID, its name, or "default"`,
+			},
+		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathFetchRead,
@@ -122,6 +182,13 @@ func (b *backend) pathFetchRead(ctx context.Context, req *logical.Request, data
 		Data: map[string]interface{}{},
 	}
 
+	// The per-issuer paths select the issuer; the others use the default
+	issuerRef := defaultRef
+	issuerRefRaw, hasIssuerRef := data.GetOk("issuer_ref")
+	if hasIssuerRef {
+		issuerRef = issuerRefRaw.(string)
+	}
+
 	// Some of these need to return raw and some non-raw;
 	// this is basically handled by setting contentType or not.
 	// Errors don't cause an immediate exit, because the raw
@@ -145,9 +212,38 @@ func (b *backend) pathFetchRead(ctx context.Context, req *logical.Request, data
 		if req.Path == "crl/pem" {
 			pemType = "X509 CRL"
 		}
+	case req.Path == "crl/delta" || req.Path == "crl/delta/pem":
+		// Delta CRLs are only stored per issuer, so read the one of the
+		// default issuer
+		hasIssuerRef = true
+		serial = "delta_crl"
+		contentType = "application/pkix-crl"
+		if req.Path == "crl/delta/pem" {
+			pemType = "X509 CRL"
+		}
 	case req.Path == "cert/crl":
 		serial = "crl"
 		pemType = "X509 CRL"
+	case strings.HasPrefix(req.Path, "ca/issuer/"):
+		serial = "ca"
+		contentType = "application/pkix-cert"
+		if req.Path == "ca/issuer/"+issuerRef+"/pem" {
+			pemType = "CERTIFICATE"
+		}
+	case strings.HasPrefix(req.Path, "ca_chain/issuer/"):
+		serial = "ca_chain"
+		contentType = "application/pkix-cert"
+	case strings.HasPrefix(req.Path, "crl/issuer/"):
+		serial = "crl"
+		path := "crl/issuer/" + issuerRef
+		if strings.HasPrefix(req.Path, path+"/delta") {
+			serial = "delta_crl"
+			path += "/delta"
+		}
+		contentType = "application/pkix-crl"
+		if req.Path == path+"/pem" {
+			pemType = "X509 CRL"
+		}
 	default:
 		serial = data.Get("serial").(string)
 		pemType = "CERTIFICATE"
@@ -158,7 +254,7 @@ func (b *backend) pathFetchRead(ctx context.Context, req *logical.Request, data
 	}
 
 	if serial == "ca_chain" {
-		caInfo, err := fetchCAInfo(ctx, req)
+		caInfo, err := fetchCAInfo(ctx, b, req, issuerRef)
 		switch err.(type) {
 		case errutil.UserError:
 			response = logical.ErrorResponse(err.Error())
@@ -181,6 +277,26 @@ func (b *backend) pathFetchRead(ctx context.Context, req *logical.Request, data
 		goto reply
 	}
 
+	if hasIssuerRef {
+		certificate, funcErr = b.fetchIssuerBytes(ctx, req, issuerRef, serial)
+		switch funcErr.(type) {
+		case errutil.UserError:
+			response = logical.ErrorResponse(funcErr.Error())
+			goto reply
+		case errutil.InternalError:
+			retErr = funcErr
+			goto reply
+		}
+		if len(pemType) != 0 && len(certificate) != 0 {
+			block := pem.Block{
+				Type:  pemType,
+				Bytes: certificate,
+			}
+			certificate = []byte(strings.TrimSpace(string(pem.EncodeToMemory(&block))))
+		}
+		goto reply
+	}
+
 	certEntry, funcErr = fetchCertBySerial(ctx, req, req.Path, serial)
 	if funcErr != nil {
 		switch funcErr.(type) {
@@ -263,6 +379,43 @@ reply:
 	return
 }
 
+// fetchIssuerBytes returns the DER encoded certificate ("ca"), CRL ("crl") or
+// delta CRL ("delta_crl") of the referenced issuer
+func (b *backend) fetchIssuerBytes(ctx context.Context, req *logical.Request, issuerRef, kind string) ([]byte, error) {
+	issuerID, err := b.resolveIssuerRef(ctx, req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "crl" || kind == "delta_crl" {
+		prefix := crlPrefix
+		if kind == "delta_crl" {
+			prefix = deltaCRLPrefix
+		}
+		entry, err := req.Storage.Get(ctx, prefix+issuerID)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CRL of issuer %s: %s", issuerID, err)}
+		}
+		if entry == nil {
+			return nil, nil
+		}
+		return entry.Value, nil
+	}
+
+	issuer, err := fetchIssuer(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching issuer %s: %s", issuerID, err)}
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+	cert, err := issuer.parseCertificate()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	return cert.Raw, nil
+}
+
 const pathFetchHelpSyn = `
 Fetch a CA, CRL, CA Chain, or non-revoked certificate.
 `
@@ -273,4 +426,8 @@ This allows certificates to be fetched. If using the fetch/ prefix any non-revok
 Using "ca" or "crl" as the value fetches the appropriate information in DER encoding. Add "/pem" to either to get PEM encoding.
 
 Using "ca_chain" as the value fetches the certificate authority trust chain in PEM encoding.
+
+Using "crl/delta" fetches the delta CRL, if delta CRLs are enabled in "config/crl". Add "/pem" to get PEM encoding.
+
+These return the default issuer of the mount. Append "/issuer/<issuer_ref>" to "ca", "ca_chain" or "crl" to fetch them for another issuer, as in "crl/issuer/<issuer_ref>/pem" or "crl/issuer/<issuer_ref>/delta".
 `
@@ -45,7 +45,7 @@ func pathSetSignedIntermediate(b *backend) *framework.Path {
 			"certificate": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `PEM-format certificate. This must be a CA
-certificate with a public key matching the
+certificate with a public key matching a
 previously-generated key from the generation
 endpoint.`,
 			},
@@ -59,6 +59,8 @@ endpoint.`,
 		HelpDescription: pathSetSignedIntermediateHelpDesc,
 	}
 
+	ret.Fields = addIssuerNameField(ret.Fields)
+
 	return ret
 }
 
@@ -70,6 +72,11 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		return errorResp, nil
 	}
 
+	keyName := data.Get("key_name").(string)
+	if err := validateName(keyName); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	var resp *logical.Response
 	input := &dataBundle{
 		role:    role,
@@ -126,18 +133,24 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		}
 	}
 
-	cb := &certutil.CertBundle{}
-	cb.PrivateKey = csrb.PrivateKey
-	cb.PrivateKeyType = csrb.PrivateKeyType
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	// Store the key until the signed certificate is set, leaving the
+	// existing issuers in place
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	key, err := b.importKey(ctx, req.Storage, &certutil.ParsedCertBundle{
+		PrivateKeyType:  parsedBundle.PrivateKeyType,
+		PrivateKeyBytes: parsedBundle.PrivateKeyBytes,
+		PrivateKey:      parsedBundle.PrivateKey,
+	}, keyName)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
 		return nil, err
 	}
+	resp.Data["key_id"] = key.ID
 
 	return resp, nil
 }
@@ -163,24 +176,23 @@ func (b *backend) pathSetSignedIntermediate(ctx context.Context, req *logical.Re
 		return logical.ErrorResponse("supplied certificate could not be successfully parsed"), nil
 	}
 
-	cb := &certutil.CertBundle{}
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
-		return nil, err
-	}
-	if entry == nil {
-		return logical.ErrorResponse("could not find any existing entry with a private key"), nil
-	}
+	issuerName := data.Get("issuer_name").(string)
 
-	err = entry.DecodeJSON(cb)
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	key, err := findKey(ctx, req.Storage, inputBundle.Certificate.PublicKey)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(cb.PrivateKey) == 0 || cb.PrivateKeyType == "" {
-		return logical.ErrorResponse("could not find an existing private key"), nil
+	if key == nil {
+		return logical.ErrorResponse("could not find an existing private key matching the certificate"), nil
 	}
 
+	cb := &certutil.CertBundle{
+		PrivateKeyType: key.PrivateKeyType,
+		PrivateKey:     key.PrivateKey,
+	}
 	parsedCB, err := cb.ToParsedCertBundle()
 	if err != nil {
 		return nil, err
@@ -206,35 +218,27 @@ func (b *backend) pathSetSignedIntermediate(ctx context.Context, req *logical.Re
 		return nil, errwrap.Wrapf("error converting raw values into cert bundle: {{err}}", err)
 	}
 
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	issuer, _, err := b.importIssuer(ctx, req.Storage, inputBundle, issuerName, "")
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
 		return nil, err
 	}
 
-	entry.Key = "certs/" + normalizeSerial(cb.SerialNumber)
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
+	err = storeCert(ctx, req.Storage, inputBundle.Certificate, inputBundle.CertificateBytes, "", issuer.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
+	// Build a fresh CRL
+	err = buildCRL(ctx, b, req)
 	if err != nil {
 		return nil, err
 	}
 
-	// Build a fresh CRL
-	err = buildCRL(ctx, b, req)
-
-	return nil, err
+	return nonDefaultIssuerResponse(ctx, req.Storage, issuer)
 }
 
 const pathGenerateIntermediateHelpSyn = `
@@ -100,6 +100,11 @@ this value to an empty list.`,
 		Description: `A comma-separated string or list of extended key usage oids.`,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+	ret.Fields["issuer_ref"].Description += `
+Defaults to the issuer of the role, if one is
+given.`
+
 	return ret
 }
 
@@ -169,6 +174,7 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 		KeyUsage:             data.Get("key_usage").([]string),
 		ExtKeyUsage:          data.Get("ext_key_usage").([]string),
 		ExtKeyUsageOIDs:      data.Get("ext_key_usage_oids").([]string),
+		IssuerRef:            data.Get("issuer_ref").(string),
 	}
 
 	*entry.GenerateLease = false
@@ -184,6 +190,9 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 			*entry.GenerateLease = *role.GenerateLease
 		}
 		entry.NoStore = role.NoStore
+		if _, ok := data.GetOk("issuer_ref"); !ok {
+			entry.IssuerRef = role.IssuerRef
+		}
 	}
 
 	if entry.MaxTTL > 0 && entry.TTL > entry.MaxTTL {
@@ -201,7 +210,7 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, b, req, role.IssuerRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -315,10 +324,7 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	}
 
 	if !role.NoStore {
-		err = req.Storage.Put(ctx, &logical.StorageEntry{
-			Key:   "certs/" + normalizeSerial(cb.SerialNumber),
-			Value: parsedBundle.CertificateBytes,
-		})
+		err = storeCert(ctx, req.Storage, parsedBundle.Certificate, parsedBundle.CertificateBytes, data.Get("role").(string), signingBundle.IssuerID)
 		if err != nil {
 			return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 		}
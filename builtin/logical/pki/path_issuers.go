@@ -0,0 +1,472 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathIssuerList,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func pathIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the issuer: its ID, its name, or "default"`,
+			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name of the issuer, which can be used instead of its
+ID to refer to it. Set to an empty string to remove the name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuersHelpSyn,
+		HelpDescription: pathIssuersHelpDesc,
+	}
+}
+
+func pathListKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathKeyList,
+		},
+
+		HelpSynopsis:    pathListKeysHelpSyn,
+		HelpDescription: pathListKeysHelpDesc,
+	}
+}
+
+func pathKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key/" + framework.GenericNameRegex("key_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to the key: its ID or its name`,
+			},
+
+			"key_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name of the key, which can be used instead of its ID
+to refer to it. Set to an empty string to remove the name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeyRead,
+			logical.UpdateOperation: b.pathKeyWrite,
+			logical.DeleteOperation: b.pathKeyDelete,
+		},
+
+		HelpSynopsis:    pathKeysHelpSyn,
+		HelpDescription: pathKeysHelpDesc,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			"default": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reference to the issuer, by ID or name, that becomes
+the default issuer of the mount`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerConfigRead,
+			logical.UpdateOperation: b.pathIssuerConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+// errorResponse turns user errors into error responses
+func errorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+}
+
+func (b *backend) pathIssuerList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	config, err := fetchIssuerConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		issuer, err := fetchIssuer(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"key_id":      issuer.KeyID,
+			"is_default":  id == config.DefaultIssuerID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveIssuerRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return errorResponse(err)
+	}
+	issuer, err := fetchIssuer(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	return b.issuerResponse(ctx, req.Storage, issuer)
+}
+
+func (b *backend) issuerResponse(ctx context.Context, s logical.Storage, issuer *issuerEntry) (*logical.Response, error) {
+	config, err := fetchIssuerConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	caChain := issuer.CAChain
+	if caChain == nil {
+		caChain = []string{}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":     issuer.ID,
+			"issuer_name":   issuer.Name,
+			"key_id":        issuer.KeyID,
+			"certificate":   issuer.Certificate,
+			"ca_chain":      caChain,
+			"serial_number": issuer.SerialNumber,
+			"is_default":    issuer.ID == config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveIssuerRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := fetchIssuer(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find issuer %q", id)), nil
+	}
+
+	if name, ok := data.GetOk("issuer_name"); ok {
+		if err := checkIssuerName(ctx, req.Storage, issuer.ID, name.(string)); err != nil {
+			return errorResponse(err)
+		}
+		issuer.Name = name.(string)
+	}
+
+	if err := writeIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	return b.issuerResponse(ctx, req.Storage, issuer)
+}
+
+func (b *backend) pathIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveIssuerRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		// Deleting is idempotent
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, issuerPrefix+id); err != nil {
+		return nil, err
+	}
+	for _, prefix := range []string{crlPrefix, deltaCRLPrefix, crlStatePrefix} {
+		if err := req.Storage.Delete(ctx, prefix+id); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := fetchIssuerConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID != id {
+		return nil, nil
+	}
+
+	config.DefaultIssuerID = ""
+	if err := writeIssuerConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+	for _, path := range []string{"ca", "crl"} {
+		if err := req.Storage.Delete(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &logical.Response{}
+	resp.AddWarning("The default issuer was deleted. Certificates cannot be issued from the default issuer until another one is set in \"config/issuers\".")
+	return resp, nil
+}
+
+func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		key, err := fetchKey(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"key_name": key.Name,
+			"key_type": key.PrivateKeyType,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveKeyRef(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return errorResponse(err)
+	}
+	key, err := fetchKey(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	return keyResponse(key), nil
+}
+
+func keyResponse(key *keyEntry) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":   key.ID,
+			"key_name": key.Name,
+			"key_type": key.PrivateKeyType,
+		},
+	}
+}
+
+func (b *backend) pathKeyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveKeyRef(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	key, err := fetchKey(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find key %q", id)), nil
+	}
+
+	if name, ok := data.GetOk("key_name"); ok {
+		if err := checkKeyName(ctx, req.Storage, key.ID, name.(string)); err != nil {
+			return errorResponse(err)
+		}
+		key.Name = name.(string)
+	}
+
+	if err := writeKey(ctx, req.Storage, key); err != nil {
+		return nil, err
+	}
+
+	return keyResponse(key), nil
+}
+
+func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := b.resolveKeyRef(ctx, req.Storage, data.Get("key_ref").(string))
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		// Deleting is idempotent
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuerID := range ids {
+		issuer, err := fetchIssuer(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil && issuer.KeyID == id {
+			return logical.ErrorResponse(fmt.Sprintf("the key is used by issuer %s, which must be deleted first", issuerID)), nil
+		}
+	}
+
+	return nil, req.Storage.Delete(ctx, keyPrefix+id)
+}
+
+func (b *backend) pathIssuerConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := fetchIssuerConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ref := data.Get("default").(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse("the ID or name of an issuer must be given as the default"), nil
+	}
+	id, err := b.resolveIssuerRef(ctx, req.Storage, ref)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := fetchIssuer(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find issuer %q", ref)), nil
+	}
+
+	if err := setDefaultIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": issuer.ID,
+		},
+	}, nil
+}
+
+const pathListIssuersHelpSyn = `
+List the issuers of this mount.
+`
+
+const pathListIssuersHelpDesc = `
+This lists the IDs of the CA certificates of this mount, along with their
+names, keys and whether they are the default issuer.
+`
+
+const pathIssuersHelpSyn = `
+Manage an issuer of this mount.
+`
+
+const pathIssuersHelpDesc = `
+This allows reading, renaming and deleting an issuer, which is a CA
+certificate of this mount along with a reference to its key. Issuers can be
+referenced by ID, by name, or as "default" for the default issuer of the
+mount. Deleting an issuer keeps its key.
+`
+
+const pathListKeysHelpSyn = `
+List the keys of this mount.
+`
+
+const pathListKeysHelpDesc = `
+This lists the IDs of the private keys of this mount, along with their names
+and types.
+`
+
+const pathKeysHelpSyn = `
+Manage a key of this mount.
+`
+
+const pathKeysHelpDesc = `
+This allows reading, renaming and deleting a private key of this mount. The
+key itself cannot be read. Keys used by an issuer cannot be deleted.
+`
+
+const pathConfigIssuersHelpSyn = `
+Configure the default issuer of this mount.
+`
+
+const pathConfigIssuersHelpDesc = `
+This sets the issuer used when no other is selected, which signs
+certificates of roles that do not reference an issuer, and whose certificate
+and CRL are served at the "ca" and "crl" endpoints. Changing the default
+issuer completes a rotation once the new issuer has been distributed.
+`
@@ -0,0 +1,265 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/ocsputil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	ocspResponseContentType = "application/ocsp-response"
+
+	// maxOCSPRequestSize bounds requests, which only hold a few hashes and
+	// a serial number
+	maxOCSPRequestSize = 4096
+)
+
+// Handles OCSP requests sent by POST
+func pathOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOCSPPost,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+// Handles OCSP requests sent by GET, which carry the request in the path
+func pathOCSPGet(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp/(?P<req>.+)",
+		Fields: map[string]*framework.FieldSchema{
+			"req": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The base64 encoded, DER encoded OCSP request.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathOCSPGet,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) pathOCSPGet(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	encoded := data.Get("req").(string)
+
+	// Clients are supposed to URL encode the request, but not all of them
+	// do, and the path may or may not have been decoded already
+	if unescaped, err := url.PathUnescape(encoded); err == nil {
+		encoded = unescaped
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		der, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+	if err != nil || len(der) > maxOCSPRequestSize {
+		return ocspErrorResponse(ocsputil.Malformed), nil
+	}
+
+	return b.ocspResponse(ctx, req, der), nil
+}
+
+func (b *backend) pathOCSPPost(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok || len(der) == 0 || len(der) > maxOCSPRequestSize {
+		return ocspErrorResponse(ocsputil.Malformed), nil
+	}
+
+	return b.ocspResponse(ctx, req, der), nil
+}
+
+// ocspResponse answers the DER encoded request from the certificates of
+// this mount. Errors are reported to the client as OCSP error responses.
+func (b *backend) ocspResponse(ctx context.Context, req *logical.Request, der []byte) *logical.Response {
+	ocspReq, err := ocsputil.ParseRequest(der)
+	if err != nil {
+		return ocspErrorResponse(ocsputil.Malformed)
+	}
+
+	config, err := b.OCSP(ctx, req.Storage)
+	if err != nil {
+		b.Logger().Error("failed to read the OCSP configuration", "error", err)
+		return ocspErrorResponse(ocsputil.InternalError)
+	}
+	if config == nil {
+		config = &ocspConfig{
+			NextUpdate: defaultOCSPNextUpdate,
+		}
+	}
+	if config.Disable {
+		return ocspErrorResponse(ocsputil.Unauthorized)
+	}
+
+	// Only certificates issued by one of this mount's issuers can be
+	// answered for
+	caInfo, err := b.ocspIssuer(ctx, req, ocspReq)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return ocspErrorResponse(ocsputil.Unauthorized)
+	default:
+		b.Logger().Error("failed to fetch the CA for OCSP", "error", err)
+		return ocspErrorResponse(ocsputil.InternalError)
+	}
+	if caInfo == nil {
+		return ocspErrorResponse(ocsputil.Unauthorized)
+	}
+
+	template, err := b.ocspStatus(ctx, req, ocspReq)
+	if err != nil {
+		b.Logger().Error("failed to look up the certificate status for OCSP", "error", err)
+		return ocspErrorResponse(ocsputil.InternalError)
+	}
+
+	nextUpdate, err := time.ParseDuration(config.NextUpdate)
+	if err != nil {
+		b.Logger().Error("failed to parse the OCSP next update", "next_update", config.NextUpdate, "error", err)
+		return ocspErrorResponse(ocsputil.InternalError)
+	}
+	now := time.Now()
+	template.ThisUpdate = now
+	template.NextUpdate = now.Add(nextUpdate)
+	template.ProducedAt = now
+	template.IssuerHash = ocspReq.HashAlgorithm
+
+	responder, signer := b.ocspSigner(config, caInfo)
+	response, err := ocsputil.CreateResponse(caInfo.Certificate, responder, *template, signer)
+	if err != nil {
+		b.Logger().Error("failed to create OCSP response", "error", err)
+		return ocspErrorResponse(ocsputil.InternalError)
+	}
+
+	return ocspRawResponse(response)
+}
+
+// ocspIssuer returns the issuer of this mount that the request is for, if
+// any
+func (b *backend) ocspIssuer(ctx context.Context, req *logical.Request, ocspReq *ocsputil.Request) (*caInfoBundle, error) {
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuer(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		cert, err := issuer.parseCertificate()
+		if err != nil {
+			return nil, err
+		}
+		if ocspReq.MatchesIssuer(cert) {
+			return fetchCAInfo(ctx, b, req, issuerID)
+		}
+	}
+	return nil, nil
+}
+
+// ocspStatus returns the status of the requested certificate from the
+// revoked and issued certificates of this mount
+func (b *backend) ocspStatus(ctx context.Context, req *logical.Request, ocspReq *ocsputil.Request) (*ocsputil.Response, error) {
+	serial := certutil.GetHexFormatted(ocspReq.SerialNumber.Bytes(), ":")
+	template := &ocsputil.Response{
+		SerialNumber: ocspReq.SerialNumber,
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	revokedEntry, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedEntry != nil {
+		var revInfo revocationInfo
+		if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+			return nil, fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+		}
+		template.Status = ocsputil.Revoked
+		template.RevokedAt = revInfo.RevocationTimeUTC
+		if template.RevokedAt.IsZero() {
+			template.RevokedAt = time.Unix(revInfo.RevocationTime, 0)
+		}
+		return template, nil
+	}
+
+	certEntry, err := fetchCertBySerial(ctx, req, "certs/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry != nil {
+		template.Status = ocsputil.Good
+	} else {
+		template.Status = ocsputil.Unknown
+	}
+	return template, nil
+}
+
+// ocspSigner returns the certificate and key that sign responses: the
+// delegated responder if one is configured and was issued by the issuer
+// being queried, otherwise the issuer itself
+func (b *backend) ocspSigner(config *ocspConfig, caInfo *caInfoBundle) (*x509.Certificate, crypto.Signer) {
+	if config.ResponderBundle != nil {
+		parsed, err := config.ResponderBundle.ToParsedCertBundle()
+		switch {
+		case err != nil:
+			b.Logger().Warn("failed to parse the OCSP responder, signing with the CA", "error", err)
+		case parsed.Certificate.CheckSignatureFrom(caInfo.Certificate) != nil:
+			b.Logger().Debug("OCSP responder was not issued by the queried CA, signing with the CA")
+		case time.Now().After(parsed.Certificate.NotAfter):
+			b.Logger().Warn("OCSP responder has expired, signing with the CA")
+		default:
+			return parsed.Certificate, parsed.PrivateKey
+		}
+	}
+	return caInfo.Certificate, caInfo.PrivateKey
+}
+
+func ocspErrorResponse(status ocsputil.ResponseStatus) *logical.Response {
+	return ocspRawResponse(ocsputil.CreateErrorResponse(status))
+}
+
+func ocspRawResponse(der []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: ocspResponseContentType,
+			logical.HTTPRawBody:     der,
+			logical.HTTPStatusCode:  200,
+		},
+	}
+}
+
+const pathOCSPHelpSyn = `
+Query the revocation status of certificates using OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This endpoint is an OCSP responder (RFC 6960) for the certificates issued by
+the issuers of this mount. Requests are sent as DER in the body of a POST request with the
+"application/ocsp-request" content type, or base64 encoded in the path of a
+GET request. The status is taken from the revoked and issued certificates of
+this mount; certificates that are unknown, for instance because they were
+tidied, are reported as unknown.
+`
@@ -32,6 +32,27 @@ func pathGenerateRoot(b *backend) *framework.Path {
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameField(ret.Fields)
+
+	return ret
+}
+
+func pathRotateRoot(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "root/rotate/" + framework.GenericNameRegex("exported"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCAGenerateRoot,
+		},
+
+		HelpSynopsis:    pathRotateRootHelpSyn,
+		HelpDescription: pathRotateRootHelpDesc,
+	}
+
+	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
+	ret.Fields = addCAKeyGenerationFields(ret.Fields)
+	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameField(ret.Fields)
 
 	return ret
 }
@@ -65,6 +86,7 @@ func pathSignIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerRefField(ret.Fields)
 
 	ret.Fields["csr"] = &framework.FieldSchema{
 		Type:        framework.TypeString,
@@ -108,23 +130,53 @@ func pathSignSelfIssued(b *backend) *framework.Path {
 		HelpDescription: pathSignSelfIssuedHelpDesc,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+
 	return ret
 }
 
+// pathCADeleteRoot deletes all issuers and keys of the mount
 func (b *backend) pathCADeleteRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return nil, req.Storage.Delete(ctx, "config/ca_bundle")
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	for _, prefix := range []string{issuerPrefix, keyPrefix, crlPrefix, deltaCRLPrefix, crlStatePrefix} {
+		ids, err := req.Storage.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := req.Storage.Delete(ctx, prefix+id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, path := range []string{"config/issuers", "ca", "crl", legacyBundlePath} {
+		if err := req.Storage.Delete(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
 }
 
+// pathCAGenerateRoot generates a root certificate and key. Unless rotating,
+// the mount must not have a default issuer yet.
 func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	var err error
 
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	config, err := fetchIssuerConfig(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	if entry != nil {
+	rotate := strings.HasPrefix(req.Path, "root/rotate/")
+	if !rotate && config.DefaultIssuerID != "" {
 		resp := &logical.Response{}
-		resp.AddWarning(fmt.Sprintf("Refusing to generate a root certificate over an existing root certificate. If you really want to destroy the original root certificate, please issue a delete against %sroot.", req.MountPoint))
+		resp.AddWarning(fmt.Sprintf("Refusing to generate a root certificate over an existing root certificate. If you really want to destroy the original root certificate, please issue a delete against %sroot. To add a new root certificate alongside it, use %sroot/rotate.", req.MountPoint, req.MountPoint))
 		return resp, nil
 	}
 
@@ -133,6 +185,21 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		return errorResp, nil
 	}
 
+	issuerName := data.Get("issuer_name").(string)
+	keyName := data.Get("key_name").(string)
+	for _, check := range []error{
+		checkIssuerName(ctx, req.Storage, "", issuerName),
+		checkKeyName(ctx, req.Storage, "", keyName),
+	} {
+		switch check.(type) {
+		case nil:
+		case errutil.UserError:
+			return logical.ErrorResponse(check.Error()), nil
+		default:
+			return nil, check
+		}
+	}
+
 	maxPathLengthIface, ok := data.GetOk("max_path_length")
 	if ok {
 		maxPathLength := maxPathLengthIface.(int)
@@ -202,35 +269,21 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		}
 	}
 
-	// Store it as the CA bundle
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
+	// Store it as a new issuer
+	issuer, key, err := b.importIssuer(ctx, req.Storage, parsedBundle, issuerName, keyName)
 	if err != nil {
 		return nil, err
 	}
+	resp.Data["issuer_id"] = issuer.ID
+	resp.Data["key_id"] = key.ID
 
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
-	err = req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   "certs/" + normalizeSerial(cb.SerialNumber),
-		Value: parsedBundle.CertificateBytes,
-	})
+	err = storeCert(ctx, req.Storage, parsedBundle.Certificate, parsedBundle.CertificateBytes, "", issuer.ID)
 	if err != nil {
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build a fresh CRL
 	err = buildCRL(ctx, b, req)
 	if err != nil {
@@ -278,7 +331,7 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, b, req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -365,10 +418,7 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 		}
 	}
 
-	err = req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   "certs/" + normalizeSerial(cb.SerialNumber),
-		Value: parsedBundle.CertificateBytes,
-	})
+	err = storeCert(ctx, req.Storage, parsedBundle.Certificate, parsedBundle.CertificateBytes, "", signingBundle.IssuerID)
 	if err != nil {
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
@@ -405,7 +455,7 @@ func (b *backend) pathCASignSelfIssued(ctx context.Context, req *logical.Request
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, b, req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -456,8 +506,19 @@ const pathGenerateRootHelpDesc = `
 See the API documentation for more information.
 `
 
+const pathRotateRootHelpSyn = `
+Generate a new root CA certificate and private key alongside the existing ones.
+`
+
+const pathRotateRootHelpDesc = `
+This generates a new root CA certificate and private key as a new issuer of
+this mount. Unless the mount has no default issuer, the existing default issuer
+is kept, so that the new root can be distributed before making it the default
+through the "config/issuers" endpoint.
+`
+
 const pathDeleteRootHelpSyn = `
-Deletes the root CA key to allow a new one to be generated.
+Deletes all CA certificates and keys to allow a new one to be generated.
 `
 
 const pathDeleteRootHelpDesc = `
@@ -123,6 +123,9 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 							return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from storage: {{err}}", serial), err)
 						}
+						if err := deleteCertIndexEntry(ctx, req.Storage, serial); err != nil {
+							return errwrap.Wrapf(fmt.Sprintf("error deleting index entry of serial %q from storage: {{err}}", serial), err)
+						}
 					}
 				}
 			}
@@ -15,6 +15,11 @@ type backend struct {
 	view      logical.Storage
 	salt      *salt.Salt
 	saltMutex sync.RWMutex
+
+	// caLock serializes changes to the CA keys and the revocation list.
+	// Signing holds it for reading, so that it does not read the CA key in
+	// the middle of a change.
+	caLock sync.RWMutex
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -38,6 +43,8 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"public_keys",
+				"krl",
 			},
 
 			LocalStorage: []string{
@@ -47,6 +54,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			SealWrapStorage: []string{
 				caPrivateKey,
 				caPrivateKeyStoragePath,
+				caKeyringStoragePrefix,
 				"keys/",
 			},
 		},
@@ -60,8 +68,15 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathLookup(&b),
 			pathVerify(&b),
 			pathConfigCA(&b),
+			pathConfigCARotate(&b),
+			pathConfigCAPromote(&b),
+			pathListCAKeys(&b),
+			pathCAKeys(&b),
 			pathSign(&b),
+			pathRevoke(&b),
 			pathFetchPublicKey(&b),
+			pathFetchPublicKeys(&b),
+			pathFetchKRL(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -779,6 +779,162 @@ func TestBackend_DisallowUserProvidedKeyIDs(t *testing.T) {
 	logicaltest.Test(t, testCase)
 }
 
+func TestBackend_IdentityTemplating(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	sysView := logical.TestSystemView()
+	sysView.EntityVal = &logical.Entity{
+		ID:   "entity-id",
+		Name: "alice-entity",
+		Metadata: map[string]string{
+			"unix_user": "alice",
+		},
+		Aliases: []*logical.Alias{
+			&logical.Alias{
+				MountAccessor: "auth_userpass_1234",
+				MountType:     "userpass",
+				Name:          "alice-login",
+			},
+		},
+	}
+	config.System = sysView
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			EntityID:  "entity-id",
+			Data:      data,
+		})
+	}
+
+	sign := func(data map[string]interface{}) (*ssh.Certificate, *logical.Response) {
+		data["public_key"] = publicKey2
+		resp, err := request("sign/templated", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.IsError() {
+			return nil, resp
+		}
+		signedKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signedKey.(*ssh.Certificate), nil
+	}
+
+	if resp, err := request("config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	}); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	resp, err := request("roles/templated", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "{{identity.entity.metadata.unix_user}},{{identity.entity.aliases.auth_userpass_1234.name}},{{identity.entity.metadata.missing}},shared",
+		"allowed_users_template":  true,
+		"default_user":            "{{identity.entity.metadata.unix_user}}",
+		"default_user_template":   true,
+		"default_extensions": map[string]interface{}{
+			"login@example.com": "{{identity.entity.name}}",
+		},
+		"default_extensions_template": true,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	cert, errResp := sign(map[string]interface{}{})
+	if errResp != nil {
+		t.Fatalf("bad: %v", errResp)
+	}
+	if !reflect.DeepEqual(cert.ValidPrincipals, []string{"alice"}) {
+		t.Fatalf("bad: valid principals: %v", cert.ValidPrincipals)
+	}
+	if !reflect.DeepEqual(cert.Extensions, map[string]string{"login@example.com": "alice-entity"}) {
+		t.Fatalf("bad: extensions: %v", cert.Extensions)
+	}
+
+	cert, errResp = sign(map[string]interface{}{
+		"valid_principals": "alice-login,shared",
+	})
+	if errResp != nil {
+		t.Fatalf("bad: %v", errResp)
+	}
+	if !reflect.DeepEqual(cert.ValidPrincipals, []string{"alice-login", "shared"}) {
+		t.Fatalf("bad: valid principals: %v", cert.ValidPrincipals)
+	}
+
+	// Principals of other entities are not allowed, nor are unrendered
+	// templates
+	for _, principal := range []string{"bob", "{{identity.entity.metadata.missing}}"} {
+		if _, errResp = sign(map[string]interface{}{"valid_principals": principal}); errResp == nil {
+			t.Fatalf("expected principal %q to be rejected", principal)
+		}
+	}
+
+	// Rendered values are single principals and never the wildcard
+	resp, err = request("roles/alias", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "{{identity.entity.aliases.auth_userpass_1234.name}}",
+		"allowed_users_template":  true,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	for _, aliasName := range []string{"*", "a,root"} {
+		sysView.EntityVal.Aliases[0].Name = aliasName
+		for _, principal := range []string{"root", "a", "*", "a,root"} {
+			if _, errResp = sign(map[string]interface{}{"valid_principals": principal}); errResp == nil {
+				t.Fatalf("expected principal %q to be rejected for alias name %q", principal, aliasName)
+			}
+		}
+		if _, errResp = sign(map[string]interface{}{"valid_principals": "alice,shared"}); errResp != nil {
+			t.Fatalf("bad: alias name %q: %v", aliasName, errResp)
+		}
+		resp, err = request("sign/alias", map[string]interface{}{
+			"public_key":       publicKey2,
+			"valid_principals": "root",
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected principal root to be rejected for alias name %q, got err: %v, resp: %v", aliasName, err, resp)
+		}
+	}
+	sysView.EntityVal.Metadata["unix_user"] = "alice,root"
+	if _, errResp = sign(map[string]interface{}{}); errResp == nil {
+		t.Fatalf("expected an error rendering a default user containing a comma")
+	}
+
+	// Without an entity, the default user cannot be rendered
+	sysView.EntityVal = nil
+	if _, errResp = sign(map[string]interface{}{}); errResp == nil {
+		t.Fatalf("expected an error signing without an entity")
+	}
+	if _, errResp = sign(map[string]interface{}{"valid_principals": "shared", "extensions": map[string]interface{}{"permit-pty": ""}}); errResp != nil {
+		t.Fatalf("bad: %v", errResp)
+	}
+
+	resp, err = request("roles/invalid", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "{{identity.entity.unknown}}",
+		"allowed_users_template":  true,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response for an unknown placeholder, got err: %v, resp: %v", err, resp)
+	}
+}
+
 func configCaStep() logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.UpdateOperation,
@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+// The OpenSSH key revocation list format is described in PROTOCOL.krl in the
+// OpenSSH sources
+const (
+	krlMagic         = 0x5353484b524c0a00
+	krlFormatVersion = 1
+
+	krlSectionCertificates   = 1
+	krlSectionCertSerialList = 0x20
+	krlSectionCertKeyID      = 0x23
+
+	krlStateStoragePath = "krl/state"
+)
+
+// krlState holds the version of the KRL, which increases whenever its
+// contents change
+type krlState struct {
+	Version uint64 `json:"version" structs:"version" mapstructure:"version"`
+}
+
+func fetchKRLState(ctx context.Context, s logical.Storage) (*krlState, error) {
+	entry, err := s.Get(ctx, krlStateStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state krlState
+	if entry != nil {
+		if err := entry.DecodeJSON(&state); err != nil {
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+// bumpKRLVersion increases the version of the KRL; the caller must hold the
+// CA lock
+func bumpKRLVersion(ctx context.Context, s logical.Storage) error {
+	state, err := fetchKRLState(ctx, s)
+	if err != nil {
+		return errwrap.Wrapf("failed to read KRL state: {{err}}", err)
+	}
+	state.Version++
+
+	entry, err := logical.StorageEntryJSON(krlStateStoragePath, state)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// buildKRL returns a KRL that revokes the revoked certificates for each of
+// the trusted CA keys of the mount
+func buildKRL(ctx context.Context, s logical.Storage) ([]byte, error) {
+	state, err := fetchKRLState(ctx, s)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read KRL state: {{err}}", err)
+	}
+
+	revocations, err := listRevocations(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	var serials []uint64
+	var keyIDs []string
+	for _, revocation := range revocations {
+		if revocation.SerialNumber != "" {
+			serial, err := strconv.ParseUint(revocation.SerialNumber, 16, 64)
+			if err != nil {
+				return nil, errwrap.Wrapf("failed to parse revoked serial number: {{err}}", err)
+			}
+			serials = append(serials, serial)
+		}
+		if revocation.KeyID != "" {
+			keyIDs = append(keyIDs, revocation.KeyID)
+		}
+	}
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+	sort.Strings(keyIDs)
+
+	krl := ssh.Marshal(struct {
+		Magic         uint64
+		FormatVersion uint32
+		KRLVersion    uint64
+		GeneratedDate uint64
+		Flags         uint64
+		Reserved      string
+		Comment       string
+	}{
+		Magic:         krlMagic,
+		FormatVersion: krlFormatVersion,
+		KRLVersion:    state.Version,
+		GeneratedDate: uint64(time.Now().Unix()),
+	})
+
+	if len(serials) == 0 && len(keyIDs) == 0 {
+		return krl, nil
+	}
+
+	var certSections []byte
+	if len(serials) > 0 {
+		var data []byte
+		for _, serial := range serials {
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], serial)
+			data = append(data, b[:]...)
+		}
+		certSections = append(certSections, marshalKRLSection(krlSectionCertSerialList, data)...)
+	}
+	if len(keyIDs) > 0 {
+		var data []byte
+		for _, keyID := range keyIDs {
+			data = append(data, ssh.Marshal(struct{ KeyID string }{keyID})...)
+		}
+		certSections = append(certSections, marshalKRLSection(krlSectionCertKeyID, data)...)
+	}
+
+	// Vault does not record which key signed a certificate, so the revoked
+	// certificates are listed for each CA key that hosts trust
+	caKeys, err := trustedCAPublicKeys(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, caKey := range caKeys {
+		publicKey, err := parsePublicSSHKey(caKey)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse CA public key: {{err}}", err)
+		}
+
+		data := ssh.Marshal(struct {
+			CAKey    []byte
+			Reserved string
+		}{
+			CAKey: publicKey.Marshal(),
+		})
+		data = append(data, certSections...)
+		krl = append(krl, marshalKRLSection(krlSectionCertificates, data)...)
+	}
+
+	return krl, nil
+}
+
+func marshalKRLSection(sectionType byte, data []byte) []byte {
+	return ssh.Marshal(struct {
+		Type uint8
+		Data []byte
+	}{
+		Type: sectionType,
+		Data: data,
+	})
+}
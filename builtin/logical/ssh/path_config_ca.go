@@ -2,16 +2,22 @@ package ssh
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"fmt"
 
 	"github.com/hashicorp/errwrap"
 	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -31,21 +37,7 @@ type keyStorageEntry struct {
 func pathConfigCA(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config/ca",
-		Fields: map[string]*framework.FieldSchema{
-			"private_key": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Private half of the SSH key that will be used to sign certificates.`,
-			},
-			"public_key": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: `Public half of the SSH key that will be used to sign certificates.`,
-			},
-			"generate_signing_key": &framework.FieldSchema{
-				Type:        framework.TypeBool,
-				Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
-				Default:     true,
-			},
-		},
+		Fields:  caKeyPairFields(),
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathConfigCAUpdate,
@@ -63,6 +55,36 @@ Read operations will return the public key, if already stored/generated.`,
 	}
 }
 
+// caKeyPairFields returns the fields for configuring a CA key pair
+func caKeyPairFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"private_key": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `Private half of the SSH key that will be used to sign certificates.`,
+		},
+		"public_key": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `Public half of the SSH key that will be used to sign certificates.`,
+		},
+		"generate_signing_key": &framework.FieldSchema{
+			Type:        framework.TypeBool,
+			Description: `Generate SSH key pair internally rather than use the private_key and public_key fields.`,
+			Default:     true,
+		},
+		"key_type": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `Type of the key pair to generate: "rsa", "ecdsa" or "ed25519".`,
+			Default:     "rsa",
+		},
+		"key_bits": &framework.FieldSchema{
+			Type: framework.TypeInt,
+			Description: `Size of the key pair to generate. For "rsa" keys, 2048, 3072 or 4096
+bits, defaulting to 4096; for "ecdsa" keys, 256, 384 or 521 bits, defaulting
+to 256. Ignored for "ed25519" keys.`,
+		},
+	}
+}
+
 func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
 	if err != nil {
@@ -83,6 +105,18 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 }
 
 func (b *backend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.caLock.Lock()
+	defer b.caLock.Unlock()
+
+	keyIDs, err := req.Storage.List(ctx, caKeyringStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, keyID := range keyIDs {
+		if err := req.Storage.Delete(ctx, caKeyringStoragePrefix+keyID); err != nil {
+			return nil, err
+		}
+	}
 	if err := req.Storage.Delete(ctx, caPrivateKeyStoragePath); err != nil {
 		return nil, err
 	}
@@ -145,7 +179,94 @@ func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keySt
 }
 
 func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	var err error
+	publicKey, privateKey, generateSigningKey, err := caKeyPairFromRequest(data)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+
+	b.caLock.Lock()
+	defer b.caLock.Unlock()
+
+	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+	}
+
+	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read CA private key: {{err}}", err)
+	}
+
+	if (publicKeyEntry != nil && publicKeyEntry.Key != "") || (privateKeyEntry != nil && privateKeyEntry.Key != "") {
+		return nil, fmt.Errorf("keys are already configured; delete them before reconfiguring")
+	}
+
+	if err := storeCAKeyPair(ctx, req.Storage, publicKey, privateKey); err != nil {
+		return nil, err
+	}
+
+	if generateSigningKey {
+		response := &logical.Response{
+			Data: map[string]interface{}{
+				"public_key": publicKey,
+			},
+		}
+
+		return response, nil
+	}
+
+	return nil, nil
+}
+
+// storeCAKeyPair stores the key pair that the mount signs certificates with
+func storeCAKeyPair(ctx context.Context, s logical.Storage, publicKey, privateKey string) error {
+	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
+		Key: publicKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Save the public key
+	err = s.Put(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
+		Key: privateKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Save the private key
+	err = s.Put(ctx, entry)
+	if err != nil {
+		var mErr *multierror.Error
+
+		mErr = multierror.Append(mErr, errwrap.Wrapf("failed to store CA private key: {{err}}", err))
+
+		// If storing private key fails, the corresponding public key should be
+		// removed
+		if delErr := s.Delete(ctx, caPublicKeyStoragePath); delErr != nil {
+			mErr = multierror.Append(mErr, errwrap.Wrapf("failed to cleanup CA public key: {{err}}", delErr))
+			return mErr
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// caKeyPairFromRequest returns the CA key pair given in the request, or
+// generates one of the requested type, reporting whether it was generated
+func caKeyPairFromRequest(data *framework.FieldData) (string, string, bool, error) {
 	publicKey := data.Get("public_key").(string)
 	privateKey := data.Get("private_key").(string)
 
@@ -156,7 +277,7 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	// explicitly set true
 	case ok && generateSigningKeyRaw.(bool):
 		if publicKey != "" || privateKey != "" {
-			return logical.ErrorResponse("public_key and private_key must not be set when generate_signing_key is set to true"), nil
+			return "", "", false, errutil.UserError{Err: "public_key and private_key must not be set when generate_signing_key is set to true"}
 		}
 
 		generateSigningKey = true
@@ -164,21 +285,21 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	// explicitly set to false, or not set and we have both a public and private key
 	case ok, publicKey != "" && privateKey != "":
 		if publicKey == "" {
-			return logical.ErrorResponse("missing public_key"), nil
+			return "", "", false, errutil.UserError{Err: "missing public_key"}
 		}
 
 		if privateKey == "" {
-			return logical.ErrorResponse("missing private_key"), nil
+			return "", "", false, errutil.UserError{Err: "missing private_key"}
 		}
 
 		_, err := ssh.ParsePrivateKey([]byte(privateKey))
 		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
+			return "", "", false, errutil.UserError{Err: fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)}
 		}
 
 		_, err = parsePublicSSHKey(publicKey)
 		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
+			return "", "", false, errutil.UserError{Err: fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)}
 		}
 
 	// not set and no public/private key provided so generate
@@ -187,100 +308,148 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 
 	// not set, but one or the other supplied
 	default:
-		return logical.ErrorResponse("only one of public_key and private_key set; both must be set to use, or both must be blank to auto-generate"), nil
+		return "", "", false, errutil.UserError{Err: "only one of public_key and private_key set; both must be set to use, or both must be blank to auto-generate"}
 	}
 
 	if generateSigningKey {
-		publicKey, privateKey, err = generateSSHKeyPair()
+		var err error
+		publicKey, privateKey, err = generateSSHKeyPair(data.Get("key_type").(string), data.Get("key_bits").(int))
 		if err != nil {
-			return nil, err
+			return "", "", false, err
 		}
 	}
 
 	if publicKey == "" || privateKey == "" {
-		return nil, fmt.Errorf("failed to generate or parse the keys")
-	}
-
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
-	if err != nil {
-		return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+		return "", "", false, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
-	if err != nil {
-		return nil, errwrap.Wrapf("failed to read CA private key: {{err}}", err)
-	}
-
-	if (publicKeyEntry != nil && publicKeyEntry.Key != "") || (privateKeyEntry != nil && privateKeyEntry.Key != "") {
-		return nil, fmt.Errorf("keys are already configured; delete them before reconfiguring")
-	}
-
-	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
-		Key: publicKey,
-	})
-	if err != nil {
-		return nil, err
-	}
+	return publicKey, privateKey, generateSigningKey, nil
+}
 
-	// Save the public key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
+func generateSSHKeyPair(keyType string, keyBits int) (string, string, error) {
+	var signer crypto.Signer
+	var privateBlock *pem.Block
+	switch keyType {
+	case "rsa":
+		switch keyBits {
+		case 0:
+			keyBits = 4096
+		case 2048, 3072, 4096:
+		default:
+			return "", "", errutil.UserError{Err: fmt.Sprintf("unsupported key_bits %d for an rsa key; must be 2048, 3072 or 4096", keyBits)}
+		}
 
-	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
-		Key: privateKey,
-	})
-	if err != nil {
-		return nil, err
-	}
+		privateSeed, err := rsa.GenerateKey(rand.Reader, keyBits)
+		if err != nil {
+			return "", "", err
+		}
+		signer = privateSeed
+		privateBlock = &pem.Block{
+			Type:    "RSA PRIVATE KEY",
+			Headers: nil,
+			Bytes:   x509.MarshalPKCS1PrivateKey(privateSeed),
+		}
 
-	// Save the private key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		var mErr *multierror.Error
+	case "ecdsa":
+		var curve elliptic.Curve
+		switch keyBits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return "", "", errutil.UserError{Err: fmt.Sprintf("unsupported key_bits %d for an ecdsa key; must be 256, 384 or 521", keyBits)}
+		}
 
-		mErr = multierror.Append(mErr, errwrap.Wrapf("failed to store CA private key: {{err}}", err))
+		privateSeed, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		der, err := x509.MarshalECPrivateKey(privateSeed)
+		if err != nil {
+			return "", "", err
+		}
+		signer = privateSeed
+		privateBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
 
-		// If storing private key fails, the corresponding public key should be
-		// removed
-		if delErr := req.Storage.Delete(ctx, caPublicKeyStoragePath); delErr != nil {
-			mErr = multierror.Append(mErr, errwrap.Wrapf("failed to cleanup CA public key: {{err}}", delErr))
-			return nil, mErr
+	case "ed25519":
+		_, privateSeed, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		der, err := marshalOpenSSHEd25519PrivateKey(privateSeed)
+		if err != nil {
+			return "", "", err
+		}
+		signer = privateSeed
+		privateBlock = &pem.Block{
+			Type:  "OPENSSH PRIVATE KEY",
+			Bytes: der,
 		}
 
-		return nil, err
+	default:
+		return "", "", errutil.UserError{Err: fmt.Sprintf("unsupported key_type %q; must be \"rsa\", \"ecdsa\" or \"ed25519\"", keyType)}
 	}
 
-	if generateSigningKey {
-		response := &logical.Response{
-			Data: map[string]interface{}{
-				"public_key": publicKey,
-			},
-		}
-
-		return response, nil
+	public, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		return "", "", err
 	}
 
-	return nil, nil
+	return string(ssh.MarshalAuthorizedKey(public)), string(pem.EncodeToMemory(privateBlock)), nil
 }
 
-func generateSSHKeyPair() (string, string, error) {
-	privateSeed, err := rsa.GenerateKey(rand.Reader, 4096)
+// marshalOpenSSHEd25519PrivateKey encodes an Ed25519 private key in the
+// unencrypted OpenSSH private key format, which is the only format that
+// ssh.ParsePrivateKey accepts for Ed25519 keys
+func marshalOpenSSHEd25519PrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	publicKey, err := ssh.NewPublicKey(key.Public())
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	privateBlock := &pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   x509.MarshalPKCS1PrivateKey(privateSeed),
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
 	}
-
-	public, err := ssh.NewPublicKey(&privateSeed.PublicKey)
-	if err != nil {
-		return "", "", err
+	checkInt := binary.BigEndian.Uint32(check[:])
+
+	privKeyBlock := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{
+		Check1:  checkInt,
+		Check2:  checkInt,
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     []byte(key.Public().(ed25519.PublicKey)),
+		Priv:    []byte(key),
+	})
+	// The block is padded to the cipher block size, 8 for "none"
+	for i := byte(1); len(privKeyBlock)%8 != 0; i++ {
+		privKeyBlock = append(privKeyBlock, i)
 	}
 
-	return string(ssh.MarshalAuthorizedKey(public)), string(pem.EncodeToMemory(privateBlock)), nil
+	return append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       publicKey.Marshal(),
+		PrivKeyBlock: privKeyBlock,
+	})...), nil
 }
@@ -0,0 +1,366 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	caKeyringStoragePrefix = "config/ca_keys/"
+
+	caKeyStatusStaged  = "staged"
+	caKeyStatusRetired = "retired"
+)
+
+// caKeyringEntry is a CA key of the mount other than the one that signs
+// certificates: a staged key, which is trusted ahead of being promoted to
+// sign certificates, or a retired key, which stays trusted until the
+// certificates it signed have expired
+type caKeyringEntry struct {
+	ID           string    `json:"id" structs:"id" mapstructure:"id"`
+	Status       string    `json:"status" structs:"status" mapstructure:"status"`
+	PublicKey    string    `json:"public_key" structs:"public_key" mapstructure:"public_key"`
+	PrivateKey   string    `json:"private_key,omitempty" structs:"private_key" mapstructure:"private_key"`
+	CreationTime time.Time `json:"creation_time" structs:"creation_time" mapstructure:"creation_time"`
+}
+
+func pathConfigCARotate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/rotate",
+		Fields:  caKeyPairFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigCARotateUpdate,
+		},
+
+		HelpSynopsis: `Stage a new SSH CA key.`,
+		HelpDescription: `This generates or imports a new CA key pair, which is staged: it is
+published with the trusted CA keys but does not sign certificates until it
+is promoted through "config/ca/promote". This gives hosts time to trust the
+new key before certificates signed by it are presented.`,
+	}
+}
+
+func pathConfigCAPromote(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/promote",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigCAPromoteUpdate,
+		},
+
+		HelpSynopsis: `Promote the staged SSH CA key.`,
+		HelpDescription: `This makes the staged CA key the one that signs certificates. The
+previously active key is retired: it stays in the trusted CA keys until it
+is deleted, so that certificates it signed remain valid until they expire.`,
+	}
+}
+
+func pathListCAKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathCAKeysList,
+		},
+
+		HelpSynopsis:    `List the staged and retired SSH CA keys.`,
+		HelpDescription: `This lists the IDs of the staged and retired CA keys of the mount.`,
+	}
+}
+
+func pathCAKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca/keys/" + framework.GenericNameRegex("key_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the CA key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCAKeysRead,
+			logical.DeleteOperation: b.pathCAKeysDelete,
+		},
+
+		HelpSynopsis: `Read or delete a staged or retired SSH CA key.`,
+		HelpDescription: `This reads the public half of a staged or retired CA key, or deletes it so
+that it is no longer trusted.`,
+	}
+}
+
+func fetchCAKeyringEntry(ctx context.Context, s logical.Storage, id string) (*caKeyringEntry, error) {
+	entry, err := s.Get(ctx, caKeyringStoragePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var keyEntry caKeyringEntry
+	if err := entry.DecodeJSON(&keyEntry); err != nil {
+		return nil, err
+	}
+	return &keyEntry, nil
+}
+
+func writeCAKeyringEntry(ctx context.Context, s logical.Storage, keyEntry *caKeyringEntry) error {
+	entry, err := logical.StorageEntryJSON(caKeyringStoragePrefix+keyEntry.ID, keyEntry)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// listCAKeyringEntries returns the staged and retired CA keys, the staged
+// key first and the most recently retired keys next
+func listCAKeyringEntries(ctx context.Context, s logical.Storage) ([]*caKeyringEntry, error) {
+	ids, err := s.List(ctx, caKeyringStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*caKeyringEntry
+	for _, id := range ids {
+		keyEntry, err := fetchCAKeyringEntry(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if keyEntry != nil {
+			entries = append(entries, keyEntry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Status != entries[j].Status {
+			return entries[i].Status == caKeyStatusStaged
+		}
+		return entries[i].CreationTime.After(entries[j].CreationTime)
+	})
+	return entries, nil
+}
+
+// trustedCAPublicKeys returns the public keys that hosts should trust: the
+// active key, then the staged key, then the retired keys
+func trustedCAPublicKeys(ctx context.Context, s logical.Storage) ([]string, error) {
+	var keys []string
+
+	publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if publicKeyEntry != nil && publicKeyEntry.Key != "" {
+		keys = append(keys, strings.TrimSpace(publicKeyEntry.Key))
+	}
+
+	entries, err := listCAKeyringEntries(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, keyEntry := range entries {
+		keys = append(keys, strings.TrimSpace(keyEntry.PublicKey))
+	}
+
+	return keys, nil
+}
+
+func (b *backend) pathConfigCARotateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.caLock.Lock()
+	defer b.caLock.Unlock()
+
+	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		return logical.ErrorResponse(`keys haven't been configured yet; use "config/ca" to configure the first CA key`), nil
+	}
+
+	entries, err := listCAKeyringEntries(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, keyEntry := range entries {
+		if keyEntry.Status == caKeyStatusStaged {
+			return logical.ErrorResponse(fmt.Sprintf("key %s is already staged; promote or delete it before staging another key", keyEntry.ID)), nil
+		}
+	}
+
+	publicKey, privateKey, _, err := caKeyPairFromRequest(data)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	keyEntry := &caKeyringEntry{
+		ID:           id,
+		Status:       caKeyStatusStaged,
+		PublicKey:    publicKey,
+		PrivateKey:   privateKey,
+		CreationTime: time.Now().UTC(),
+	}
+	if err := writeCAKeyringEntry(ctx, req.Storage, keyEntry); err != nil {
+		return nil, errwrap.Wrapf("failed to store staged CA key: {{err}}", err)
+	}
+
+	// The KRL has a section for each trusted CA key
+	if err := bumpKRLVersion(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":     keyEntry.ID,
+			"public_key": keyEntry.PublicKey,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigCAPromoteUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.caLock.Lock()
+	defer b.caLock.Unlock()
+
+	entries, err := listCAKeyringEntries(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	var staged *caKeyringEntry
+	for _, keyEntry := range entries {
+		if keyEntry.Status == caKeyStatusStaged {
+			staged = keyEntry
+			break
+		}
+	}
+	if staged == nil {
+		return logical.ErrorResponse(`no key is staged; use "config/ca/rotate" to stage one`), nil
+	}
+
+	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+	}
+
+	// Keep the active public key as a retired key, so that the certificates
+	// it signed are still trusted; its private key is no longer needed
+	var retired *caKeyringEntry
+	if publicKeyEntry != nil && publicKeyEntry.Key != "" {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		retired = &caKeyringEntry{
+			ID:           id,
+			Status:       caKeyStatusRetired,
+			PublicKey:    publicKeyEntry.Key,
+			CreationTime: time.Now().UTC(),
+		}
+		if err := writeCAKeyringEntry(ctx, req.Storage, retired); err != nil {
+			return nil, errwrap.Wrapf("failed to store retired CA key: {{err}}", err)
+		}
+	}
+
+	if err := storeCAKeyPair(ctx, req.Storage, staged.PublicKey, staged.PrivateKey); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete(ctx, caKeyringStoragePrefix+staged.ID); err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": staged.PublicKey,
+		},
+	}
+	if retired != nil {
+		resp.Data["retired_key_id"] = retired.ID
+	}
+	return resp, nil
+}
+
+func (b *backend) pathCAKeysList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := listCAKeyringEntries(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	keyInfo := make(map[string]interface{}, len(entries))
+	for _, keyEntry := range entries {
+		info, err := caKeyringEntryInfo(keyEntry)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyEntry.ID)
+		keyInfo[keyEntry.ID] = info
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathCAKeysRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyEntry, err := fetchCAKeyringEntry(ctx, req.Storage, data.Get("key_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if keyEntry == nil {
+		return nil, nil
+	}
+
+	info, err := caKeyringEntryInfo(keyEntry)
+	if err != nil {
+		return nil, err
+	}
+	info["public_key"] = keyEntry.PublicKey
+
+	return &logical.Response{
+		Data: info,
+	}, nil
+}
+
+func (b *backend) pathCAKeysDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.caLock.Lock()
+	defer b.caLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, caKeyringStoragePrefix+data.Get("key_id").(string)); err != nil {
+		return nil, err
+	}
+
+	if err := bumpKRLVersion(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func caKeyringEntryInfo(keyEntry *caKeyringEntry) (map[string]interface{}, error) {
+	publicKey, err := parsePublicSSHKey(keyEntry.PublicKey)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to parse public key of CA key %s: {{err}}", keyEntry.ID), err)
+	}
+
+	return map[string]interface{}{
+		"key_id":        keyEntry.ID,
+		"status":        keyEntry.Status,
+		"key_type":      publicKey.Type(),
+		"fingerprint":   ssh.FingerprintSHA256(publicKey),
+		"creation_time": keyEntry.CreationTime.Format(time.RFC3339),
+	}, nil
+}
@@ -1,10 +1,15 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestSSH_ConfigCAStorageUpgrade(t *testing.T) {
@@ -162,3 +167,329 @@ func TestSSH_ConfigCAUpdateDelete(t *testing.T) {
 		t.Fatalf("bad: err: %v, resp:%v", err, resp)
 	}
 }
+
+func testCARequest(t *testing.T, b logical.Backend, s logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: %s %s: err: %v, resp: %v", op, path, err, resp)
+	}
+	return resp
+}
+
+func testCASignUserKey(t *testing.T, b logical.Backend, s logical.Storage) *ssh.Certificate {
+	t.Helper()
+	resp := testCARequest(t, b, s, logical.UpdateOperation, "sign/testcarole", map[string]interface{}{
+		"public_key": publicKey2,
+		"key_id":     "test-key-id",
+	})
+	signedKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signedKey.(*ssh.Certificate)
+}
+
+func TestSSH_ConfigCAKeyTypes(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	testCARequest(t, b, config.StorageView, logical.UpdateOperation, "roles/testcarole", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allow_user_key_ids":      true,
+	})
+
+	cases := []struct {
+		keyType  string
+		keyBits  int
+		expected string
+	}{
+		{"rsa", 0, ssh.KeyAlgoRSA},
+		{"rsa", 2048, ssh.KeyAlgoRSA},
+		{"ecdsa", 0, ssh.KeyAlgoECDSA256},
+		{"ecdsa", 384, ssh.KeyAlgoECDSA384},
+		{"ecdsa", 521, ssh.KeyAlgoECDSA521},
+		{"ed25519", 0, ssh.KeyAlgoED25519},
+	}
+	for _, tc := range cases {
+		data := map[string]interface{}{
+			"key_type": tc.keyType,
+		}
+		if tc.keyBits != 0 {
+			data["key_bits"] = tc.keyBits
+		}
+		resp := testCARequest(t, b, config.StorageView, logical.UpdateOperation, "config/ca", data)
+		caPublicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["public_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if caPublicKey.Type() != tc.expected {
+			t.Fatalf("bad: key_type %q, key_bits %d: expected a %q key, got %q", tc.keyType, tc.keyBits, tc.expected, caPublicKey.Type())
+		}
+
+		cert := testCASignUserKey(t, b, config.StorageView)
+		if !bytes.Equal(cert.SignatureKey.Marshal(), caPublicKey.Marshal()) {
+			t.Fatalf("bad: key_type %q: certificate not signed by the CA key", tc.keyType)
+		}
+		checker := ssh.CertChecker{}
+		if err := checker.CheckCert("test-key-id", cert); err != nil {
+			t.Fatalf("bad: key_type %q: %v", tc.keyType, err)
+		}
+
+		testCARequest(t, b, config.StorageView, logical.DeleteOperation, "config/ca", nil)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"key_type": "dsa"},
+		{"key_type": "rsa", "key_bits": 1024},
+		{"key_type": "ecdsa", "key_bits": 2048},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/ca",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error response for %v, got err: %v, resp: %v", data, err, resp)
+		}
+	}
+}
+
+func TestSSH_ConfigCARotation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+	s := config.StorageView
+
+	fetchPublicKeys := func() []string {
+		resp := testCARequest(t, b, s, logical.ReadOperation, "public_keys", nil)
+		return strings.Split(strings.TrimSpace(string(resp.Data[logical.HTTPRawBody].([]byte))), "\n")
+	}
+
+	testCARequest(t, b, s, logical.UpdateOperation, "roles/testcarole", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allow_user_key_ids":      true,
+	})
+	testCARequest(t, b, s, logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+
+	// Stage an Ed25519 key; certificates are still signed by the old key
+	resp := testCARequest(t, b, s, logical.UpdateOperation, "config/ca/rotate", map[string]interface{}{
+		"key_type": "ed25519",
+	})
+	stagedPublicKey := resp.Data["public_key"].(string)
+	stagedID := resp.Data["key_id"].(string)
+
+	publicKeys := fetchPublicKeys()
+	if len(publicKeys) != 2 || publicKeys[0] != strings.TrimSpace(publicKey) || publicKeys[1] != strings.TrimSpace(stagedPublicKey) {
+		t.Fatalf("bad: public keys while staged: %v", publicKeys)
+	}
+
+	cert := testCASignUserKey(t, b, s)
+	if cert.SignatureKey.Type() != ssh.KeyAlgoRSA {
+		t.Fatalf("bad: expected the active key to sign before promotion, got a %q key", cert.SignatureKey.Type())
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/ca/rotate",
+		Storage:   s,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error staging a second key, got err: %v, resp: %v", err, resp)
+	}
+
+	resp = testCARequest(t, b, s, logical.ReadOperation, "config/ca/keys/"+stagedID, nil)
+	if resp.Data["status"] != caKeyStatusStaged || resp.Data["key_type"] != ssh.KeyAlgoED25519 {
+		t.Fatalf("bad: staged key: %v", resp.Data)
+	}
+	if _, ok := resp.Data["private_key"]; ok {
+		t.Fatalf("bad: private key returned")
+	}
+
+	// Promote the staged key; the old key is retired but still trusted
+	resp = testCARequest(t, b, s, logical.UpdateOperation, "config/ca/promote", nil)
+	retiredID := resp.Data["retired_key_id"].(string)
+
+	resp = testCARequest(t, b, s, logical.ReadOperation, "config/ca", nil)
+	if resp.Data["public_key"] != stagedPublicKey {
+		t.Fatalf("bad: expected the promoted key to be active, got %v", resp.Data["public_key"])
+	}
+
+	cert = testCASignUserKey(t, b, s)
+	if cert.SignatureKey.Type() != ssh.KeyAlgoED25519 {
+		t.Fatalf("bad: expected the promoted key to sign, got a %q key", cert.SignatureKey.Type())
+	}
+
+	publicKeys = fetchPublicKeys()
+	if len(publicKeys) != 2 || publicKeys[0] != strings.TrimSpace(stagedPublicKey) || publicKeys[1] != strings.TrimSpace(publicKey) {
+		t.Fatalf("bad: public keys after promotion: %v", publicKeys)
+	}
+
+	resp = testCARequest(t, b, s, logical.ListOperation, "config/ca/keys/", nil)
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != retiredID {
+		t.Fatalf("bad: keys after promotion: %v", keys)
+	}
+	if info := resp.Data["key_info"].(map[string]interface{})[retiredID].(map[string]interface{}); info["status"] != caKeyStatusRetired {
+		t.Fatalf("bad: retired key info: %v", info)
+	}
+
+	// Deleting the retired key stops it from being trusted
+	testCARequest(t, b, s, logical.DeleteOperation, "config/ca/keys/"+retiredID, nil)
+	publicKeys = fetchPublicKeys()
+	if len(publicKeys) != 1 || publicKeys[0] != strings.TrimSpace(stagedPublicKey) {
+		t.Fatalf("bad: public keys after deletion: %v", publicKeys)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/ca/promote",
+		Storage:   s,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error promoting without a staged key, got err: %v, resp: %v", err, resp)
+	}
+}
+
+func TestSSH_KRL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+	s := config.StorageView
+
+	testCARequest(t, b, s, logical.UpdateOperation, "roles/testcarole", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allow_user_key_ids":      true,
+	})
+	resp := testCARequest(t, b, s, logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "ecdsa",
+	})
+	caPublicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["public_key"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchKRL := func() (uint64, []byte) {
+		resp := testCARequest(t, b, s, logical.ReadOperation, "krl", nil)
+		if resp.Data[logical.HTTPContentType] != "application/octet-stream" {
+			t.Fatalf("bad: content type: %v", resp.Data[logical.HTTPContentType])
+		}
+		krl := resp.Data[logical.HTTPRawBody].([]byte)
+		var header struct {
+			Magic         uint64
+			FormatVersion uint32
+			KRLVersion    uint64
+			GeneratedDate uint64
+			Flags         uint64
+			Reserved      string
+			Comment       string
+			Rest          []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(krl, &header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Magic != krlMagic || header.FormatVersion != krlFormatVersion {
+			t.Fatalf("bad: KRL header: %#v", header)
+		}
+		return header.KRLVersion, header.Rest
+	}
+
+	version, sections := fetchKRL()
+	if version != 0 || len(sections) != 0 {
+		t.Fatalf("bad: empty KRL: version %d, sections %x", version, sections)
+	}
+
+	cert := testCASignUserKey(t, b, s)
+	testCARequest(t, b, s, logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": strconv.FormatUint(cert.Serial, 16),
+	})
+	testCARequest(t, b, s, logical.UpdateOperation, "revoke", map[string]interface{}{
+		"key_id": "revoked-key-id",
+	})
+
+	version, sections = fetchKRL()
+	if version != 2 {
+		t.Fatalf("bad: expected KRL version 2, got %d", version)
+	}
+
+	var section struct {
+		Type uint8
+		Data []byte
+		Rest []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(sections, &section); err != nil {
+		t.Fatal(err)
+	}
+	if section.Type != krlSectionCertificates || len(section.Rest) != 0 {
+		t.Fatalf("bad: expected a single certificates section, got type %d", section.Type)
+	}
+
+	var certSection struct {
+		CAKey    []byte
+		Reserved string
+		Rest     []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(section.Data, &certSection); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(certSection.CAKey, caPublicKey.Marshal()) {
+		t.Fatalf("bad: KRL section is not for the CA key")
+	}
+
+	if err := ssh.Unmarshal(certSection.Rest, &section); err != nil {
+		t.Fatal(err)
+	}
+	if section.Type != krlSectionCertSerialList || len(section.Data) != 8 || binary.BigEndian.Uint64(section.Data) != cert.Serial {
+		t.Fatalf("bad: serial list section: type %d, data %x", section.Type, section.Data)
+	}
+
+	if err := ssh.Unmarshal(section.Rest, &section); err != nil {
+		t.Fatal(err)
+	}
+	var keyID struct {
+		KeyID string
+	}
+	if section.Type != krlSectionCertKeyID || ssh.Unmarshal(section.Data, &keyID) != nil || keyID.KeyID != "revoked-key-id" {
+		t.Fatalf("bad: key ID section: type %d, data %x", section.Type, section.Data)
+	}
+	if len(section.Rest) != 0 {
+		t.Fatalf("bad: unexpected trailing subsections")
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"serial_number": "not-a-serial",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got err: %v, resp: %v", err, resp)
+	}
+}
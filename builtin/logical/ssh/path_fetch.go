@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"context"
+	"strings"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -39,3 +40,69 @@ func (b *backend) pathFetchPublicKey(ctx context.Context, req *logical.Request,
 
 	return response, nil
 }
+
+func pathFetchPublicKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `public_keys`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchPublicKeys,
+		},
+
+		HelpSynopsis: `Retrieve all of the trusted CA public keys.`,
+		HelpDescription: `This returns the active, staged and retired CA public keys, one per line,
+in a form that can be used as the TrustedUserCAKeys file of hosts so that
+they trust certificates signed by any of them during a CA rotation.`,
+	}
+}
+
+func (b *backend) pathFetchPublicKeys(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	publicKeys, err := trustedCAPublicKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(publicKeys) == 0 {
+		return nil, nil
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(strings.Join(publicKeys, "\n") + "\n"),
+			logical.HTTPStatusCode:  200,
+		},
+	}
+
+	return response, nil
+}
+
+func pathFetchKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `krl`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKRL,
+		},
+
+		HelpSynopsis: `Retrieve the key revocation list.`,
+		HelpDescription: `This returns the revoked certificates in the binary OpenSSH KRL format,
+which hosts can use as their RevokedKeys file.`,
+	}
+}
+
+func (b *backend) pathFetchKRL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	krl, err := buildKRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     krl,
+			logical.HTTPStatusCode:  200,
+		},
+	}
+
+	return response, nil
+}
@@ -0,0 +1,131 @@
+package ssh
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	revokedSerialStoragePrefix = "revoked/serial/"
+	revokedKeyIDStoragePrefix  = "revoked/key_id/"
+)
+
+// revocationEntry records the revocation of the certificates with a serial
+// number or a key ID
+type revocationEntry struct {
+	SerialNumber   string    `json:"serial_number,omitempty" structs:"serial_number" mapstructure:"serial_number"`
+	KeyID          string    `json:"key_id,omitempty" structs:"key_id" mapstructure:"key_id"`
+	RevocationTime time.Time `json:"revocation_time" structs:"revocation_time" mapstructure:"revocation_time"`
+}
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, in hexadecimal as returned when signing.`,
+			},
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Key ID of the certificates to revoke. All certificates with this key ID are revoked.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeWrite,
+		},
+
+		HelpSynopsis: `Revoke SSH certificates.`,
+		HelpDescription: `This revokes certificates signed by the mount by serial number, by key ID,
+or both. Revoked certificates are published in the KRL served at "krl",
+which hosts can use as their RevokedKeys file.`,
+	}
+}
+
+func listRevocations(ctx context.Context, s logical.Storage) ([]*revocationEntry, error) {
+	var revocations []*revocationEntry
+	for _, prefix := range []string{revokedSerialStoragePrefix, revokedKeyIDStoragePrefix} {
+		keys, err := s.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			entry, err := s.Get(ctx, prefix+key)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				continue
+			}
+			var revocation revocationEntry
+			if err := entry.DecodeJSON(&revocation); err != nil {
+				return nil, err
+			}
+			revocations = append(revocations, &revocation)
+		}
+	}
+	return revocations, nil
+}
+
+func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serialNumber := data.Get("serial_number").(string)
+	keyID := data.Get("key_id").(string)
+	if serialNumber == "" && keyID == "" {
+		return logical.ErrorResponse("one of serial_number or key_id is required"), nil
+	}
+
+	var entries []*logical.StorageEntry
+	now := time.Now().UTC()
+	if serialNumber != "" {
+		serial, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(serialNumber), "0x"), 16, 64)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid serial_number %q; must be a hexadecimal number", serialNumber)), nil
+		}
+		serialNumber = strconv.FormatUint(serial, 16)
+
+		entry, err := logical.StorageEntryJSON(revokedSerialStoragePrefix+serialNumber, &revocationEntry{
+			SerialNumber:   serialNumber,
+			RevocationTime: now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if keyID != "" {
+		entry, err := logical.StorageEntryJSON(revokedKeyIDStoragePrefix+base64.RawURLEncoding.EncodeToString([]byte(keyID)), &revocationEntry{
+			KeyID:          keyID,
+			RevocationTime: now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	b.caLock.Lock()
+	defer b.caLock.Unlock()
+
+	for _, entry := range entries {
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := bumpKRLVersion(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"revocation_time": now.Unix(),
+		},
+	}, nil
+}
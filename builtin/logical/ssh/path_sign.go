@@ -112,6 +112,11 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 
 	// Note that these various functions always return "user errors" so we pass
 	// them as 4xx values
+	role, allowedUsers, err := b.renderRoleTemplates(data, req, role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	keyId, err := b.calculateKeyId(data, req, role, userPublicKey)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
@@ -124,12 +129,12 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 
 	var parsedPrincipals []string
 	if certificateType == ssh.HostCert {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, "", role.AllowedDomains, validateValidPrincipalForHosts(role))
+		parsedPrincipals, err = b.calculateValidPrincipals(data, "", strutil.ParseStringSlice(role.AllowedDomains, ","), validateValidPrincipalForHosts(role))
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
 	} else {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, role.DefaultUser, role.AllowedUsers, strutil.StrListContains)
+		parsedPrincipals, err = b.calculateValidPrincipals(data, role.DefaultUser, allowedUsers, strutil.StrListContains)
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
@@ -150,6 +155,9 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
+	b.caLock.RLock()
+	defer b.caLock.RUnlock()
+
 	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
 	if err != nil {
 		return nil, errwrap.Wrapf("failed to read CA private key: {{err}}", err)
@@ -195,7 +203,58 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 	return response, nil
 }
 
-func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPrincipal, principalsAllowedByRole string, validatePrincipal func([]string, string) bool) ([]string, error) {
+// renderRoleTemplates returns a copy of the role in which the templated
+// fields are rendered with the identity entity of the request, along with
+// the users allowed by the role. The allowed users are returned as a list so
+// that rendered values are never split again or taken as the "*" wildcard.
+func (b *backend) renderRoleTemplates(data *framework.FieldData, req *logical.Request, role *sshRole) (*sshRole, []string, error) {
+	allowedUsers := strutil.ParseStringSlice(role.AllowedUsers, ",")
+	if !role.AllowedUsersTemplate && !role.DefaultUserTemplate && !role.DefaultExtensionsTemplate {
+		return role, allowedUsers, nil
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("failed to look up the entity of the request: {{err}}", err)
+	}
+
+	rendered := *role
+
+	if role.AllowedUsersTemplate {
+		// Entries that do not apply to the entity are left out, so that a
+		// single role can serve entities with different sets of metadata
+		var renderedUsers []string
+		for _, user := range allowedUsers {
+			if user, err := renderIdentityPrincipal(user, entity); err == nil {
+				renderedUsers = append(renderedUsers, user)
+			}
+		}
+		allowedUsers = renderedUsers
+	}
+
+	// The default user is not needed when principals are requested, and an
+	// unrendered one must not result in a certificate valid for any principal
+	if _, ok := data.GetOk("valid_principals"); role.DefaultUserTemplate && !ok {
+		rendered.DefaultUser, err = renderIdentityPrincipal(role.DefaultUser, entity)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf("failed to render default_user: {{err}}", err)
+		}
+	}
+
+	if role.DefaultExtensionsTemplate && len(data.Get("extensions").(map[string]interface{})) == 0 {
+		rendered.DefaultExtensions = make(map[string]string, len(role.DefaultExtensions))
+		for extension, value := range role.DefaultExtensions {
+			rendered.DefaultExtensions[extension], err = renderIdentityTemplate(value, entity)
+			if err != nil {
+				return nil, nil, errwrap.Wrapf(fmt.Sprintf("failed to render default extension %q: {{err}}", extension), err)
+			}
+		}
+	}
+
+	return &rendered, allowedUsers, nil
+}
+
+func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPrincipal string, principalsAllowedByRole []string, validatePrincipal func([]string, string) bool) ([]string, error) {
 	validPrincipals := ""
 	validPrincipalsRaw, ok := data.GetOk("valid_principals")
 	if ok {
@@ -205,7 +264,7 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPri
 	}
 
 	parsedPrincipals := strutil.RemoveDuplicates(strutil.ParseStringSlice(validPrincipals, ","), false)
-	allowedPrincipals := strutil.RemoveDuplicates(strutil.ParseStringSlice(principalsAllowedByRole, ","), false)
+	allowedPrincipals := strutil.RemoveDuplicates(principalsAllowedByRole, false)
 	switch {
 	case len(parsedPrincipals) == 0:
 		// There is nothing to process
@@ -216,7 +275,7 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, defaultPri
 		return nil, fmt.Errorf("role is not configured to allow any principles")
 	default:
 		// Role was explicitly configured to allow any principal.
-		if principalsAllowedByRole == "*" {
+		if len(principalsAllowedByRole) == 1 && principalsAllowedByRole[0] == "*" {
 			return parsedPrincipals, nil
 		}
 
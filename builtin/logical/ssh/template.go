@@ -0,0 +1,120 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	identityEntityPrefix   = "identity.entity."
+	identityMetadataPrefix = "metadata."
+	identityAliasesPrefix  = "aliases."
+)
+
+// identityTemplateRegex matches the identity placeholders of role templates,
+// such as {{identity.entity.metadata.unix_user}}
+var identityTemplateRegex = regexp.MustCompile(`{{\s*(identity\.[^{}\s]*)\s*}}`)
+
+// validateIdentityTemplate checks that all of the identity placeholders in
+// the template are known
+func validateIdentityTemplate(tpl string) error {
+	for _, m := range identityTemplateRegex.FindAllStringSubmatch(tpl, -1) {
+		if _, err := identityTemplateLookup(m[1], &logical.Entity{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderIdentityTemplate replaces the identity placeholders in the template
+// with the values of the entity. It fails if the request has no entity or
+// the entity has no value for a placeholder.
+func renderIdentityTemplate(tpl string, entity *logical.Entity) (string, error) {
+	var renderErr error
+	rendered := identityTemplateRegex.ReplaceAllStringFunc(tpl, func(s string) string {
+		name := identityTemplateRegex.FindStringSubmatch(s)[1]
+		if entity == nil {
+			renderErr = fmt.Errorf("placeholder %q requires a token with an identity entity", name)
+			return ""
+		}
+		value, err := identityTemplateLookup(name, entity)
+		if err != nil {
+			renderErr = err
+			return ""
+		}
+		if value == "" {
+			renderErr = fmt.Errorf("entity has no value for placeholder %q", name)
+			return ""
+		}
+		return value
+	})
+	if renderErr != nil {
+		return "", renderErr
+	}
+	return rendered, nil
+}
+
+// renderIdentityPrincipal renders a template for a principal. Rendered values
+// that contain "," or are "*" are rejected, so that entity values cannot
+// expand into several principals or into the wildcard; entries without
+// placeholders are returned as they are.
+func renderIdentityPrincipal(tpl string, entity *logical.Entity) (string, error) {
+	if !identityTemplateRegex.MatchString(tpl) {
+		return tpl, nil
+	}
+	rendered, err := renderIdentityTemplate(tpl, entity)
+	if err != nil {
+		return "", err
+	}
+	if rendered == "*" || strings.Contains(rendered, ",") {
+		return "", fmt.Errorf("rendered principal %q is not allowed", rendered)
+	}
+	return rendered, nil
+}
+
+// identityTemplateLookup returns the value of an identity placeholder for
+// the entity. Aliases are selected by the accessor of their auth mount.
+func identityTemplateLookup(name string, entity *logical.Entity) (string, error) {
+	field := strings.TrimPrefix(name, identityEntityPrefix)
+	if field == name {
+		return "", fmt.Errorf("unknown template placeholder %q", name)
+	}
+
+	switch {
+	case field == "id":
+		return entity.ID, nil
+	case field == "name":
+		return entity.Name, nil
+	case strings.HasPrefix(field, identityMetadataPrefix) && len(field) > len(identityMetadataPrefix):
+		return entity.Metadata[strings.TrimPrefix(field, identityMetadataPrefix)], nil
+	case strings.HasPrefix(field, identityAliasesPrefix):
+		parts := strings.SplitN(strings.TrimPrefix(field, identityAliasesPrefix), ".", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			break
+		}
+		var alias *logical.Alias
+		for _, a := range entity.Aliases {
+			if a.MountAccessor == parts[0] {
+				alias = a
+				break
+			}
+		}
+		switch {
+		case len(parts) == 2 && parts[1] == "name":
+			if alias == nil {
+				return "", nil
+			}
+			return alias.Name, nil
+		case len(parts) == 3 && parts[1]+"." == identityMetadataPrefix && parts[2] != "":
+			if alias == nil {
+				return "", nil
+			}
+			return alias.Metadata[parts[2]], nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown template placeholder %q", name)
+}
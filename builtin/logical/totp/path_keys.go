@@ -1,17 +1,14 @@
 package totp
 
 import (
-	"bytes"
 	"context"
 	"encoding/base32"
-	"encoding/base64"
 	"fmt"
-	"image/png"
 	"net/url"
 	"strconv"
 	"strings"
 
-	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/totputil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	otplib "github.com/pquerna/otp"
@@ -264,26 +261,14 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 	}
 
 	// Translate digits and algorithm to a format the totp library understands
-	var keyDigits otplib.Digits
-	switch digits {
-	case 6:
-		keyDigits = otplib.DigitsSix
-	case 8:
-		keyDigits = otplib.DigitsEight
-	default:
-		return logical.ErrorResponse("the digits value can only be 6 or 8"), nil
+	keyDigits, err := totputil.ParseDigits(digits)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	var keyAlgorithm otplib.Algorithm
-	switch algorithm {
-	case "SHA1":
-		keyAlgorithm = otplib.AlgorithmSHA1
-	case "SHA256":
-		keyAlgorithm = otplib.AlgorithmSHA256
-	case "SHA512":
-		keyAlgorithm = otplib.AlgorithmSHA512
-	default:
-		return logical.ErrorResponse("the algorithm value is not valid"), nil
+	keyAlgorithm, err := totputil.ParseAlgorithm(algorithm)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
 	// Enforce input value requirements
@@ -354,14 +339,10 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 					},
 				}
 			} else {
-				barcode, err := keyObject.Image(qrSize, qrSize)
+				b64Barcode, err := totputil.Barcode(keyObject, qrSize)
 				if err != nil {
-					return nil, errwrap.Wrapf("failed to generate QR code image: {{err}}", err)
+					return nil, err
 				}
-
-				var buff bytes.Buffer
-				png.Encode(&buff, barcode)
-				b64Barcode := base64.StdEncoding.EncodeToString(buff.Bytes())
 				response = &logical.Response{
 					Data: map[string]interface{}{
 						"url":     urlString,
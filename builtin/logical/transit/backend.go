@@ -3,6 +3,7 @@ package transit
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
@@ -24,6 +25,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			SealWrapStorage: []string{
 				"archive/",
 				"policy/",
+				wrappingKeyStoragePrefix,
 			},
 		},
 
@@ -33,6 +35,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 			b.pathConfig(),
 			b.pathRotate(),
 			b.pathRewrap(),
+			b.pathImport(),
+			b.pathImportVersion(),
 			b.pathKeys(),
 			b.pathListKeys(),
 			b.pathExportKeys(),
@@ -46,6 +50,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			b.pathVerify(),
 			b.pathBackup(),
 			b.pathRestore(),
+			b.pathWrappingKey(),
 		},
 
 		Secrets:     []*framework.Secret{},
@@ -61,6 +66,10 @@ func Backend(conf *logical.BackendConfig) *backend {
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// wrappingKey is the cached key that imported keys are wrapped for
+	wrappingKey     *keysutil.Policy
+	wrappingKeyLock sync.RWMutex
 }
 
 func (b *backend) invalidate(_ context.Context, key string) {
@@ -71,5 +80,9 @@ func (b *backend) invalidate(_ context.Context, key string) {
 	case strings.HasPrefix(key, "policy/"):
 		name := strings.TrimPrefix(key, "policy/")
 		b.lm.InvalidatePolicy(name)
+	case key == wrappingKeyStoragePrefix+"policy/"+wrappingKeyName:
+		b.wrappingKeyLock.Lock()
+		b.wrappingKey = nil
+		b.wrappingKeyLock.Unlock()
 	}
 }
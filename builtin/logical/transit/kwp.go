@@ -0,0 +1,103 @@
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// kwpIVPrefix is the first half of the alternative initial value of AES key
+// wrap with padding, as defined in RFC 5649; the second half is the length of
+// the wrapped key
+var kwpIVPrefix = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// wrapKWP wraps the key with the key encryption key using AES key wrap with
+// padding (RFC 5649)
+func wrapKWP(kek, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 || uint64(len(key)) > 0xffffffff {
+		return nil, fmt.Errorf("invalid key length %d", len(key))
+	}
+
+	semiblocks := (len(key) + 7) / 8
+	out := make([]byte, 8+semiblocks*8)
+	copy(out, kwpIVPrefix)
+	binary.BigEndian.PutUint32(out[4:8], uint32(len(key)))
+	copy(out[8:], key)
+
+	// A single padded semiblock is encrypted together with the initial value
+	if semiblocks == 1 {
+		block.Encrypt(out, out)
+		return out, nil
+	}
+
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= semiblocks; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[i*8:i*8+8])
+			block.Encrypt(b[:], b[:])
+
+			t := uint64(semiblocks*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[i*8:i*8+8], b[8:])
+		}
+	}
+
+	return out, nil
+}
+
+// unwrapKWP unwraps a key wrapped with the key encryption key using AES key
+// wrap with padding (RFC 5649)
+func unwrapKWP(kek, wrapped []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("invalid wrapped key length %d", len(wrapped))
+	}
+
+	semiblocks := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	if semiblocks == 1 {
+		block.Decrypt(out, out)
+	} else {
+		var b [16]byte
+		for j := 5; j >= 0; j-- {
+			for i := semiblocks; i >= 1; i-- {
+				t := uint64(semiblocks*j + i)
+				binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(out[:8])^t)
+				copy(b[8:], out[i*8:i*8+8])
+				block.Decrypt(b[:], b[:])
+
+				copy(out[:8], b[:8])
+				copy(out[i*8:i*8+8], b[8:])
+			}
+		}
+	}
+
+	// Check the initial value, the length and the padding
+	errIntegrity := errors.New("failed to unwrap key: integrity check failed")
+	if subtle.ConstantTimeCompare(out[:4], kwpIVPrefix) != 1 {
+		return nil, errIntegrity
+	}
+	length := int(binary.BigEndian.Uint32(out[4:8]))
+	if length <= 8*(semiblocks-1) || length > 8*semiblocks {
+		return nil, errIntegrity
+	}
+	for _, p := range out[8+length:] {
+		if p != 0 {
+			return nil, errIntegrity
+		}
+	}
+
+	return out[8 : 8+length], nil
+}
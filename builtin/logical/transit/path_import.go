@@ -0,0 +1,271 @@
+package transit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathImport() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "aes256-gcm96",
+				Description: `
+The type of key being imported. Currently, "aes256-gcm96" (symmetric),
+"chacha20-poly1305" (symmetric), "ecdsa-p256" (asymmetric), 'ed25519'
+(asymmetric), 'rsa-2048' (asymmetric), 'rsa-4096' (asymmetric) are supported.
+Defaults to "aes256-gcm96".
+`,
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The base64-encoded ciphertext of the key material,
+made of an ephemeral AES-256 key encrypted with
+the wrapping key using RSA-OAEP, followed by the
+key material wrapped with the ephemeral key using
+AES key wrap with padding (RFC 5649).`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "SHA256",
+				Description: `The hash function used for RSA-OAEP. Supported
+values are "SHA1", "SHA224", "SHA256", "SHA384"
+and "SHA512". Defaults to "SHA256".`,
+			},
+
+			"derived": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables key derivation mode. This
+allows for per-transaction unique
+keys for encryption operations.`,
+			},
+
+			"exportable": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables keys to be exportable.
+This allows for all the valid keys
+in the key ring to be exported.`,
+			},
+
+			"allow_plaintext_backup": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables taking a backup of the named
+key in plaintext format. Once set,
+this cannot be disabled.`,
+			},
+
+			"allow_rotation": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Allows the imported key to be rotated
+within Vault, generating new versions
+of the key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *backend) pathImportVersion() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import_version",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The base64-encoded ciphertext of the key material,
+wrapped in the same way as for the "import"
+endpoint.`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "SHA256",
+				Description: `The hash function used for RSA-OAEP. Supported
+values are "SHA1", "SHA224", "SHA256", "SHA384"
+and "SHA512". Defaults to "SHA256".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportVersionWrite,
+		},
+
+		HelpSynopsis:    pathImportVersionHelpSyn,
+		HelpDescription: pathImportVersionHelpDesc,
+	}
+}
+
+func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	keyType, err := parseKeyType(d.Get("type").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	err = b.lm.ImportPolicy(ctx, keysutil.PolicyRequest{
+		Storage:                  req.Storage,
+		Name:                     name,
+		KeyType:                  keyType,
+		Derived:                  d.Get("derived").(bool),
+		Exportable:               d.Get("exportable").(bool),
+		AllowPlaintextBackup:     d.Get("allow_plaintext_backup").(bool),
+		AllowImportedKeyRotation: d.Get("allow_rotation").(bool),
+	}, key)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathImportVersionWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	if !p.Imported {
+		return logical.ErrorResponse(fmt.Sprintf("key %q was not imported; new versions can only be imported into imported keys", name)), logical.ErrInvalidRequest
+	}
+
+	if err := p.Import(ctx, req.Storage, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+// unwrapImportedKey returns the key material of an import request, which is
+// wrapped with an ephemeral AES key that is itself encrypted with the
+// wrapping key
+func (b *backend) unwrapImportedKey(ctx context.Context, storage logical.Storage, d *framework.FieldData) ([]byte, error) {
+	ciphertextB64 := d.Get("ciphertext").(string)
+	if ciphertextB64 == "" {
+		return nil, errutil.UserError{Err: "'ciphertext' must be supplied"}
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode ciphertext"}
+	}
+
+	var hashFunc hash.Hash
+	switch d.Get("hash_function").(string) {
+	case "SHA1":
+		hashFunc = sha1.New()
+	case "SHA224":
+		hashFunc = sha256.New224()
+	case "SHA256":
+		hashFunc = sha256.New()
+	case "SHA384":
+		hashFunc = sha512.New384()
+	case "SHA512":
+		hashFunc = sha512.New()
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported hash function %q", d.Get("hash_function").(string))}
+	}
+
+	wrappingKey, err := b.getWrappingKey(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keySize := wrappingKey.Size()
+	if len(ciphertext) < keySize+16 {
+		return nil, errutil.UserError{Err: "ciphertext is too short to contain a wrapped ephemeral key and key material"}
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(hashFunc, rand.Reader, wrappingKey, ciphertext[:keySize], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to decrypt the ephemeral key with the wrapping key: %v", err)}
+	}
+	if len(ephemeralKey) != 32 {
+		return nil, errutil.UserError{Err: fmt.Sprintf("ephemeral key must be a 256-bit AES key, got %d bits", len(ephemeralKey)*8)}
+	}
+
+	key, err := unwrapKWP(ephemeralKey, ciphertext[keySize:])
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+
+	return key, nil
+}
+
+func importErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+const pathImportHelpSyn = `Import a key into a new named key`
+
+const pathImportHelpDesc = `
+This path is used to create a named key from key material generated outside
+of Vault. The key material must be wrapped for the key returned by the
+"wrapping_key" endpoint. Symmetric keys are imported as raw bytes, and
+asymmetric keys as PKCS #8 DER-encoded private keys.
+`
+
+const pathImportVersionHelpSyn = `Import a new version of an imported key`
+
+const pathImportVersionHelpDesc = `
+This path is used to import key material as the latest version of a named
+key that was created through the "import" endpoint. The key material is
+wrapped in the same way as for that endpoint.
+`
@@ -0,0 +1,287 @@
+package transit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"hash"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_KWP(t *testing.T) {
+	// Test vectors from RFC 5649
+	kek, _ := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	vectors := []struct {
+		key     string
+		wrapped string
+	}{
+		{"c37b7e6492584340bed12207808941155068f738", "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{"466f7250617369", "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	}
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		expected, _ := hex.DecodeString(v.wrapped)
+
+		wrapped, err := wrapKWP(kek, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, expected) {
+			t.Fatalf("bad: wrapping %s: expected %s, got %x", v.key, v.wrapped, wrapped)
+		}
+
+		unwrapped, err := unwrapKWP(kek, expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Fatalf("bad: unwrapping %s: expected %s, got %x", v.wrapped, v.key, unwrapped)
+		}
+
+		expected[len(expected)-1] ^= 1
+		if _, err := unwrapKWP(kek, expected); err == nil {
+			t.Fatalf("expected an error unwrapping a modified key")
+		}
+	}
+
+	kek = make([]byte, 32)
+	for _, length := range []int{1, 8, 9, 16, 32, 33, 1217} {
+		key := make([]byte, length)
+		rand.Read(key)
+		wrapped, err := wrapKWP(kek, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unwrapped, err := unwrapKWP(kek, wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Fatalf("bad: round trip of a %d byte key", length)
+		}
+	}
+}
+
+// wrapKeyForImport wraps the key material for the wrapping key of the
+// backend, the way key material is prepared for import
+func wrapKeyForImport(t *testing.T, b *backend, storage logical.Storage, key []byte, hashFunc hash.Hash) string {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	if block == nil {
+		t.Fatalf("failed to decode wrapping key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub := pub.(*rsa.PublicKey)
+	if rsaPub.N.BitLen() != 4096 {
+		t.Fatalf("bad: wrapping key size: %d", rsaPub.N.BitLen())
+	}
+
+	ephemeralKey := make([]byte, 32)
+	if _, err := rand.Read(ephemeralKey); err != nil {
+		t.Fatal(err)
+	}
+	encryptedEphemeralKey, err := rsa.EncryptOAEP(hashFunc, rand.Reader, rsaPub, ephemeralKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappedKey, err := wrapKWP(ephemeralKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append(encryptedEphemeralKey, wrappedKey...))
+}
+
+func TestTransit_Import(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: %s %s: err: %v, resp: %v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	pemPublicKey := func(pub interface{}) string {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+
+	type importedKey struct {
+		material  []byte
+		publicKey string
+	}
+	newKey := func(keyType string) importedKey {
+		var priv interface{}
+		var publicKey string
+		switch keyType {
+		case "aes256-gcm96", "chacha20-poly1305":
+			key := make([]byte, 32)
+			rand.Read(key)
+			return importedKey{material: key}
+		case "ecdsa-p256":
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv, publicKey = key, pemPublicKey(key.Public())
+		case "ed25519":
+			pub, key, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv, publicKey = key, base64.StdEncoding.EncodeToString(pub)
+		case "rsa-2048", "rsa-4096":
+			bits := 2048
+			if keyType == "rsa-4096" {
+				bits = 4096
+			}
+			key, err := rsa.GenerateKey(rand.Reader, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv, publicKey = key, pemPublicKey(key.Public())
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return importedKey{material: der, publicKey: publicKey}
+	}
+
+	for _, keyType := range []string{"aes256-gcm96", "chacha20-poly1305", "ecdsa-p256", "ed25519", "rsa-2048", "rsa-4096"} {
+		name := "imported-" + keyType
+		v1, v2 := newKey(keyType), newKey(keyType)
+
+		mustRequest(logical.UpdateOperation, "keys/"+name+"/import", map[string]interface{}{
+			"type":       keyType,
+			"ciphertext": wrapKeyForImport(t, b, storage, v1.material, sha256.New()),
+			"exportable": true,
+		})
+		mustRequest(logical.UpdateOperation, "keys/"+name+"/import_version", map[string]interface{}{
+			"ciphertext":    wrapKeyForImport(t, b, storage, v2.material, sha1.New()),
+			"hash_function": "SHA1",
+		})
+
+		resp := mustRequest(logical.ReadOperation, "keys/"+name, nil)
+		if resp.Data["imported_key"] != true || resp.Data["imported_key_allow_rotation"] != false || resp.Data["latest_version"] != 2 {
+			t.Fatalf("bad: %s: key: %v", keyType, resp.Data)
+		}
+
+		switch keyType {
+		case "aes256-gcm96", "chacha20-poly1305":
+			resp = mustRequest(logical.ReadOperation, "export/encryption-key/"+name, nil)
+			keys := resp.Data["keys"].(map[string]string)
+			if keys["1"] != base64.StdEncoding.EncodeToString(v1.material) || keys["2"] != base64.StdEncoding.EncodeToString(v2.material) {
+				t.Fatalf("bad: %s: exported keys do not match the imported keys", keyType)
+			}
+
+			plaintext := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+			resp = mustRequest(logical.UpdateOperation, "encrypt/"+name, map[string]interface{}{"plaintext": plaintext})
+			resp = mustRequest(logical.UpdateOperation, "decrypt/"+name, map[string]interface{}{"ciphertext": resp.Data["ciphertext"]})
+			if resp.Data["plaintext"] != plaintext {
+				t.Fatalf("bad: %s: decrypted %v", keyType, resp.Data["plaintext"])
+			}
+
+		default:
+			keys := resp.Data["keys"].(map[string]map[string]interface{})
+			if keys["1"]["public_key"] != v1.publicKey || keys["2"]["public_key"] != v2.publicKey {
+				t.Fatalf("bad: %s: public keys do not match the imported keys: %v", keyType, keys)
+			}
+
+			input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+			resp = mustRequest(logical.UpdateOperation, "sign/"+name, map[string]interface{}{"input": input})
+			resp = mustRequest(logical.UpdateOperation, "verify/"+name, map[string]interface{}{"input": input, "signature": resp.Data["signature"]})
+			if resp.Data["valid"] != true {
+				t.Fatalf("bad: %s: signature not valid", keyType)
+			}
+		}
+
+		// Imported keys cannot be rotated unless allowed
+		if resp, _ := request(logical.UpdateOperation, "keys/"+name+"/rotate", nil); resp == nil || !resp.IsError() {
+			t.Fatalf("bad: %s: expected rotation of an imported key to fail, got %v", keyType, resp)
+		}
+	}
+
+	// Key material of another type is rejected
+	resp, _ := request(logical.UpdateOperation, "keys/mismatched/import", map[string]interface{}{
+		"type":       "rsa-2048",
+		"ciphertext": wrapKeyForImport(t, b, storage, newKey("ecdsa-p256").material, sha256.New()),
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error importing a key of the wrong type, got %v", resp)
+	}
+
+	// The hash function must match the one used for wrapping
+	resp, _ = request(logical.UpdateOperation, "keys/mismatched/import", map[string]interface{}{
+		"ciphertext": wrapKeyForImport(t, b, storage, newKey("aes256-gcm96").material, sha1.New()),
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error importing with the wrong hash function, got %v", resp)
+	}
+
+	// Existing keys cannot be imported over
+	resp, _ = request(logical.UpdateOperation, "keys/imported-aes256-gcm96/import", map[string]interface{}{
+		"ciphertext": wrapKeyForImport(t, b, storage, newKey("aes256-gcm96").material, sha256.New()),
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error importing over an existing key, got %v", resp)
+	}
+
+	// Versions can only be imported into imported keys
+	mustRequest(logical.UpdateOperation, "keys/generated", nil)
+	resp, _ = request(logical.UpdateOperation, "keys/generated/import_version", map[string]interface{}{
+		"ciphertext": wrapKeyForImport(t, b, storage, newKey("aes256-gcm96").material, sha256.New()),
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error importing a version into a generated key, got %v", resp)
+	}
+
+	// Rotation can be allowed for imported keys
+	mustRequest(logical.UpdateOperation, "keys/rotatable/import", map[string]interface{}{
+		"ciphertext":     wrapKeyForImport(t, b, storage, newKey("aes256-gcm96").material, sha256.New()),
+		"allow_rotation": true,
+	})
+	mustRequest(logical.UpdateOperation, "keys/rotatable/rotate", nil)
+	resp = mustRequest(logical.ReadOperation, "keys/rotatable", nil)
+	if resp.Data["latest_version"] != 2 || resp.Data["imported_key_allow_rotation"] != true {
+		t.Fatalf("bad: rotated imported key: %v", resp.Data)
+	}
+}
@@ -138,21 +138,10 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		Exportable:           exportable,
 		AllowPlaintextBackup: allowPlaintextBackup,
 	}
-	switch keyType {
-	case "aes256-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES256_GCM96
-	case "chacha20-poly1305":
-		polReq.KeyType = keysutil.KeyType_ChaCha20_Poly1305
-	case "ecdsa-p256":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P256
-	case "ed25519":
-		polReq.KeyType = keysutil.KeyType_ED25519
-	case "rsa-2048":
-		polReq.KeyType = keysutil.KeyType_RSA2048
-	case "rsa-4096":
-		polReq.KeyType = keysutil.KeyType_RSA4096
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	var err error
+	polReq.KeyType, err = parseKeyType(keyType)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
 	p, upserted, err := b.lm.GetPolicy(ctx, polReq)
@@ -174,6 +163,26 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 	return nil, nil
 }
 
+// parseKeyType returns the key type with the given name
+func parseKeyType(keyType string) (keysutil.KeyType, error) {
+	switch keyType {
+	case "aes256-gcm96":
+		return keysutil.KeyType_AES256_GCM96, nil
+	case "chacha20-poly1305":
+		return keysutil.KeyType_ChaCha20_Poly1305, nil
+	case "ecdsa-p256":
+		return keysutil.KeyType_ECDSA_P256, nil
+	case "ed25519":
+		return keysutil.KeyType_ED25519, nil
+	case "rsa-2048":
+		return keysutil.KeyType_RSA2048, nil
+	case "rsa-4096":
+		return keysutil.KeyType_RSA4096, nil
+	default:
+		return 0, fmt.Errorf("unknown key type %v", keyType)
+	}
+}
+
 // Built-in helper type for returning asymmetric keys
 type asymKey struct {
 	Name         string    `json:"name" structs:"name" mapstructure:"name"`
@@ -215,9 +224,14 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
 			"supports_derivation":    p.Type.DerivationSupported(),
+			"imported_key":           p.Imported,
 		},
 	}
 
+	if p.Imported {
+		resp.Data["imported_key_allow_rotation"] = p.AllowImportedKeyRotation
+	}
+
 	if p.BackupInfo != nil {
 		resp.Data["backup_info"] = map[string]interface{}{
 			"time":    p.BackupInfo.Time,
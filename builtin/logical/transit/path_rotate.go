@@ -3,6 +3,7 @@ package transit
 import (
 	"context"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -49,6 +50,9 @@ func (b *backend) pathRotateWrite(ctx context.Context, req *logical.Request, d *
 	err = p.Rotate(ctx, req.Storage)
 
 	p.Unlock()
+	if _, ok := err.(errutil.UserError); ok {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
 	return nil, err
 }
 
@@ -0,0 +1,105 @@
+package transit
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	wrappingKeyName          = "wrapping-key"
+	wrappingKeyStoragePrefix = "import/"
+)
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathWrappingKeyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	wrappingKey, err := b.getWrappingKey(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(wrappingKey.Public())
+	if err != nil {
+		return nil, errwrap.Wrapf("error marshaling wrapping key: {{err}}", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+	if len(pemBytes) == 0 {
+		return nil, fmt.Errorf("failed to PEM-encode wrapping key")
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pemBytes),
+		},
+	}, nil
+}
+
+// getWrappingKey returns the RSA-4096 key that key material to be imported
+// is wrapped for, generating it the first time it is needed
+func (b *backend) getWrappingKey(ctx context.Context, storage logical.Storage) (*rsa.PrivateKey, error) {
+	b.wrappingKeyLock.RLock()
+	p := b.wrappingKey
+	b.wrappingKeyLock.RUnlock()
+
+	if p == nil {
+		b.wrappingKeyLock.Lock()
+		defer b.wrappingKeyLock.Unlock()
+
+		p = b.wrappingKey
+		if p == nil {
+			var err error
+			p, err = keysutil.LoadPolicy(ctx, storage, wrappingKeyStoragePrefix+"policy/"+wrappingKeyName)
+			if err != nil {
+				return nil, errwrap.Wrapf("failed to load wrapping key: {{err}}", err)
+			}
+		}
+		if p == nil {
+			p = keysutil.NewPolicy(keysutil.PolicyConfig{
+				Name:          wrappingKeyName,
+				Type:          keysutil.KeyType_RSA4096,
+				StoragePrefix: wrappingKeyStoragePrefix,
+			})
+			if err := p.Rotate(ctx, storage); err != nil {
+				return nil, errwrap.Wrapf("failed to generate wrapping key: {{err}}", err)
+			}
+		}
+		b.wrappingKey = p
+	}
+
+	entry, ok := p.Keys[strconv.Itoa(p.LatestVersion)]
+	if !ok || entry.RSAKey == nil {
+		return nil, fmt.Errorf("wrapping key not found")
+	}
+	return entry.RSAKey, nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key to wrap keys for import`
+
+const pathWrappingKeyHelpDesc = `
+This path returns the PEM-encoded public part of an RSA-4096 key, generated
+by Vault, that is used to wrap key material imported through the
+"keys/<name>/import" and "keys/<name>/import_version" endpoints.
+`
@@ -274,7 +274,9 @@ func (c *BaseCommand) flagSet(bit FlagSetBit) *FlagSets {
 				Default:    nil,
 				EnvVar:     api.EnvVaultMFA,
 				Completion: complete.PredictAnything,
-				Usage:      "Supply MFA credentials as part of X-Vault-MFA header.",
+				Usage: "Supply MFA credentials as part of X-Vault-MFA header. " +
+					"Passcodes for MFA methods required by a login are supplied as " +
+					"\"name:passcode\".",
 			})
 		}
 
@@ -109,6 +109,7 @@ func (b *BaseCommand) PredictVaultAvailableAuths() complete.Predictor {
 		"okta",
 		"plugin",
 		"radius",
+		"saml",
 		"userpass",
 	)
 }
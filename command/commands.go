@@ -48,6 +48,7 @@ import (
 	credLdap "github.com/hashicorp/vault/builtin/credential/ldap"
 	credOkta "github.com/hashicorp/vault/builtin/credential/okta"
 	credRadius "github.com/hashicorp/vault/builtin/credential/radius"
+	credSAML "github.com/hashicorp/vault/builtin/credential/saml"
 	credToken "github.com/hashicorp/vault/builtin/credential/token"
 	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
 
@@ -112,6 +113,7 @@ var (
 		"okta":       credOkta.Factory,
 		"plugin":     plugin.Factory,
 		"radius":     credRadius.Factory,
+		"saml":       credSAML.Factory,
 		"userpass":   credUserpass.Factory,
 	}
 
@@ -214,6 +216,7 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) {
 		"radius": &credUserpass.CLIHandler{
 			DefaultMount: "radius",
 		},
+		"saml":  &credSAML.CLIHandler{},
 		"token": &credToken.CLIHandler{},
 		"userpass": &credUserpass.CLIHandler{
 			DefaultMount: "userpass",
@@ -290,11 +293,32 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) {
 				BaseCommand: getBaseCommand(),
 			}, nil
 		},
+		"debug": func() (cli.Command, error) {
+			return &DebugCommand{
+				BaseCommand: getBaseCommand(),
+				ShutdownCh:  MakeShutdownCh(),
+			}, nil
+		},
 		"delete": func() (cli.Command, error) {
 			return &DeleteCommand{
 				BaseCommand: getBaseCommand(),
 			}, nil
 		},
+		"identity": func() (cli.Command, error) {
+			return &IdentityCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
+		"identity export": func() (cli.Command, error) {
+			return &IdentityExportCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
+		"identity import": func() (cli.Command, error) {
+			return &IdentityImportCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
 		"lease": func() (cli.Command, error) {
 			return &LeaseCommand{
 				BaseCommand: getBaseCommand(),
@@ -321,6 +345,12 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) {
 				Handlers:    loginHandlers,
 			}, nil
 		},
+		"monitor": func() (cli.Command, error) {
+			return &MonitorCommand{
+				BaseCommand: getBaseCommand(),
+				ShutdownCh:  MakeShutdownCh(),
+			}, nil
+		},
 		"operator": func() (cli.Command, error) {
 			return &OperatorCommand{
 				BaseCommand: getBaseCommand(),
@@ -0,0 +1,649 @@
+package command
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/version"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+const (
+	// debugIndexVersion is the version of the index.json layout written into
+	// each bundle, bumped whenever its structure changes.
+	debugIndexVersion = 1
+
+	debugMinInterval = 5 * time.Second
+
+	// debugTraceSeconds is the length of the execution trace taken at each
+	// interval; traces are large, so it is kept short.
+	debugTraceSeconds = 1
+
+	// debugLogLevel is the level the server log is captured at
+	debugLogLevel = "debug"
+)
+
+var _ cli.Command = (*DebugCommand)(nil)
+var _ cli.CommandAutocomplete = (*DebugCommand)(nil)
+
+// debugTargets are the data sources the debug command can capture
+var debugTargets = []string{
+	"health",
+	"leader",
+	"log",
+	"metrics",
+	"pprof",
+	"seal-status",
+}
+
+type DebugCommand struct {
+	*BaseCommand
+
+	flagDuration        time.Duration
+	flagInterval        time.Duration
+	flagMetricsInterval time.Duration
+	flagOutput          string
+	flagTargets         []string
+	flagCompress        bool
+
+	// ShutdownCh stops the capture early; whatever was collected so far is
+	// still written out.
+	ShutdownCh chan struct{}
+
+	// skipTimingChecks bypasses the minimum interval checks, for testing
+	skipTimingChecks bool
+}
+
+// debugIndex is written as index.json at the root of the bundle
+type debugIndex struct {
+	Version         int                    `json:"version"`
+	VaultAddress    string                 `json:"vault_address"`
+	ClientVersion   string                 `json:"client_version"`
+	Timestamp       time.Time              `json:"timestamp"`
+	Duration        string                 `json:"duration"`
+	Interval        string                 `json:"interval"`
+	MetricsInterval string                 `json:"metrics_interval"`
+	Targets         []string               `json:"targets"`
+	Errors          []*debugCaptureError   `json:"errors"`
+	Output          map[string]interface{} `json:"output"`
+}
+
+// debugCaptureError records a failed capture so that a partial bundle still
+// explains what is missing from it
+type debugCaptureError struct {
+	Target    string    `json:"target"`
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error"`
+}
+
+// debugSample is a single timestamped response of a polled endpoint
+type debugSample struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Response  interface{} `json:"response"`
+}
+
+func (c *DebugCommand) Synopsis() string {
+	return "Capture diagnostic information from a Vault server"
+}
+
+func (c *DebugCommand) Help() string {
+	helpText := `
+Usage: vault debug [options]
+
+  Probes a Vault server over a period of time and writes the results into a
+  compressed archive suitable for attaching to an incident ticket. At each
+  interval the command polls the server status endpoints and collects
+  goroutine, heap and CPU profiles along with an execution trace. Telemetry
+  metrics are polled on their own interval, and the server log is streamed at
+  the debug level for the whole duration.
+
+  Profiling requires a token with sudo capability on "sys/pprof/*", and the
+  log requires sudo capability on "sys/monitor". Profiles and metrics are
+  served by the active node, while the log is that of the node the command
+  connects to.
+
+  Capture the default set of targets for two minutes:
+
+      $ vault debug
+
+  Capture only profiles every 10 seconds for one minute:
+
+      $ vault debug -target=pprof -interval=10s -duration=1m
+
+  Targets that fail to be captured are recorded in the bundle's index.json
+  rather than aborting the capture.
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.DurationVar(&DurationVar{
+		Name:       "duration",
+		Target:     &c.flagDuration,
+		Default:    2 * time.Minute,
+		Completion: complete.PredictAnything,
+		Usage:      "Duration to run the command.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "interval",
+		Target:     &c.flagInterval,
+		Default:    30 * time.Second,
+		Completion: complete.PredictAnything,
+		Usage: "The polling interval at which to collect profiling data and " +
+			"server state. This is also the length of each CPU profile.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "metrics-interval",
+		Target:     &c.flagMetricsInterval,
+		Default:    10 * time.Second,
+		Completion: complete.PredictAnything,
+		Usage:      "The polling interval at which to collect metrics data.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "output",
+		Target:     &c.flagOutput,
+		Completion: complete.PredictAnything,
+		Usage: "Specifies the output path for the debug package. Defaults to " +
+			"vault-debug-<timestamp> in the current directory, with a .tar.gz " +
+			"extension when compressed.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:       "target",
+		Target:     &c.flagTargets,
+		Completion: complete.PredictSet(debugTargets...),
+		Usage: "Target to capture, defaulting to all targets. This can be " +
+			"specified multiple times to capture several targets. Available " +
+			"targets are: " + strings.Join(debugTargets, ", ") + ".",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "compress",
+		Target:  &c.flagCompress,
+		Default: true,
+		Usage:   "Compress the captured data into a .tar.gz archive.",
+	})
+
+	return set
+}
+
+func (c *DebugCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *DebugCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *DebugCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	targets, err := c.parseTargets()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := c.validateTimings(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	// CPU profiles block for a whole interval, so leave room on top of it
+	client.SetClientTimeout(c.flagInterval + 30*time.Second)
+
+	dir, archive := c.outputPaths()
+	if _, err := os.Stat(dir); err == nil {
+		c.UI.Error(fmt.Sprintf("Output directory already exists: %s", dir))
+		return 1
+	}
+	if archive != "" {
+		if _, err := os.Stat(archive); err == nil {
+			c.UI.Error(fmt.Sprintf("Output file already exists: %s", archive))
+			return 1
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		c.UI.Error(fmt.Sprintf("Error creating output directory: %s", err))
+		return 2
+	}
+
+	c.UI.Output(fmt.Sprintf("Capturing %s from %s for %s, writing to %s",
+		strings.Join(targets, ", "), client.Address(), c.flagDuration, dir))
+
+	index := &debugIndex{
+		Version:         debugIndexVersion,
+		VaultAddress:    client.Address(),
+		ClientVersion:   version.GetVersion().VersionNumber(),
+		Timestamp:       time.Now().UTC(),
+		Duration:        c.flagDuration.String(),
+		Interval:        c.flagInterval.String(),
+		MetricsInterval: c.flagMetricsInterval.String(),
+		Targets:         targets,
+		Errors:          []*debugCaptureError{},
+		Output:          map[string]interface{}{},
+	}
+
+	capture := &debugCapture{
+		client: client,
+		dir:    dir,
+		index:  index,
+	}
+	c.capture(capture, targets)
+
+	if err := writeDebugJSON(filepath.Join(dir, "index.json"), index); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing index: %s", err))
+		return 2
+	}
+
+	output := dir
+	if archive != "" {
+		if err := debugArchive(dir, archive); err != nil {
+			c.UI.Error(fmt.Sprintf("Error creating archive: %s", err))
+			return 2
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			c.UI.Warn(fmt.Sprintf("Error removing output directory: %s", err))
+		}
+		output = archive
+	}
+
+	if len(index.Errors) > 0 {
+		c.UI.Warn(fmt.Sprintf("%d capture(s) failed; see index.json in the bundle for details", len(index.Errors)))
+	}
+	c.UI.Output(fmt.Sprintf("Success! Debug data written to: %s", output))
+	return 0
+}
+
+func (c *DebugCommand) parseTargets() ([]string, error) {
+	if len(c.flagTargets) == 0 {
+		return debugTargets, nil
+	}
+
+	targets := strutil.RemoveDuplicates(c.flagTargets, true)
+	for _, t := range targets {
+		if !strutil.StrListContains(debugTargets, t) {
+			return nil, fmt.Errorf("Unknown target %q, must be one of: %s", t, strings.Join(debugTargets, ", "))
+		}
+	}
+	return targets, nil
+}
+
+func (c *DebugCommand) validateTimings() error {
+	if c.skipTimingChecks {
+		return nil
+	}
+
+	if c.flagInterval < debugMinInterval {
+		return fmt.Errorf("Interval must be at least %s", debugMinInterval)
+	}
+	if c.flagMetricsInterval < debugMinInterval {
+		return fmt.Errorf("Metrics interval must be at least %s", debugMinInterval)
+	}
+	if c.flagDuration < c.flagInterval {
+		return fmt.Errorf("Duration (%s) must be at least the interval (%s)", c.flagDuration, c.flagInterval)
+	}
+	if c.flagDuration < c.flagMetricsInterval {
+		return fmt.Errorf("Duration (%s) must be at least the metrics interval (%s)", c.flagDuration, c.flagMetricsInterval)
+	}
+	return nil
+}
+
+// outputPaths returns the directory the data is captured into and, if
+// compression is enabled, the archive it is packaged as afterwards
+func (c *DebugCommand) outputPaths() (string, string) {
+	output := c.flagOutput
+	if output == "" {
+		output = fmt.Sprintf("vault-debug-%s", time.Now().UTC().Format("2006-01-02T15-04-05Z"))
+	}
+
+	if !c.flagCompress {
+		return output, ""
+	}
+
+	archive := output
+	if !strings.HasSuffix(archive, ".tar.gz") {
+		archive += ".tar.gz"
+	}
+	return strings.TrimSuffix(archive, ".tar.gz"), archive
+}
+
+// capture polls every target until the duration elapses or the command is
+// interrupted
+func (c *DebugCommand) capture(capture *debugCapture, targets []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.flagDuration)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-c.ShutdownCh:
+			c.UI.Warn("Interrupt received, writing out captured data...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	poll := func(interval time.Duration, fn func(context.Context, time.Time)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				fn(ctx, time.Now().UTC())
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for _, target := range targets {
+		switch target {
+		case "health":
+			poll(c.flagInterval, capture.pollJSON("health", "/v1/sys/health"))
+		case "leader":
+			poll(c.flagInterval, capture.pollJSON("leader", "/v1/sys/leader"))
+		case "seal-status":
+			poll(c.flagInterval, capture.pollJSON("seal-status", "/v1/sys/seal-status"))
+		case "metrics":
+			poll(c.flagMetricsInterval, capture.pollJSON("metrics", "/v1/sys/metrics"))
+		case "pprof":
+			poll(c.flagInterval, func(ctx context.Context, now time.Time) {
+				capture.pprof(ctx, now, c.flagInterval)
+			})
+		case "log":
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				capture.log(ctx)
+			}()
+		}
+	}
+
+	wg.Wait()
+	capture.flush()
+}
+
+// debugCapture collects the samples of a single run of the debug command
+type debugCapture struct {
+	client *api.Client
+	dir    string
+
+	l       sync.Mutex
+	index   *debugIndex
+	samples map[string][]*debugSample
+}
+
+func (d *debugCapture) addError(target string, err error) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	d.index.Errors = append(d.index.Errors, &debugCaptureError{
+		Target:    target,
+		Timestamp: time.Now().UTC(),
+		Error:     err.Error(),
+	})
+}
+
+// pollJSON returns a poller that appends the JSON response of path to the
+// samples of target
+func (d *debugCapture) pollJSON(target, path string) func(context.Context, time.Time) {
+	return func(ctx context.Context, now time.Time) {
+		r := d.client.NewRequest("GET", path)
+		if target == "health" {
+			// Capture the status of standby and sealed nodes rather than
+			// treating them as errors
+			r.Params.Add("uninitcode", "299")
+			r.Params.Add("sealedcode", "299")
+			r.Params.Add("standbycode", "299")
+			r.Params.Add("drsecondarycode", "299")
+		}
+
+		resp, err := d.client.RawRequestWithContext(ctx, r)
+		if resp != nil {
+			defer resp.Body.Close()
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				d.addError(target, err)
+			}
+			return
+		}
+
+		var data interface{}
+		if err := resp.DecodeJSON(&data); err != nil {
+			d.addError(target, err)
+			return
+		}
+
+		d.l.Lock()
+		defer d.l.Unlock()
+		if d.samples == nil {
+			d.samples = make(map[string][]*debugSample)
+		}
+		d.samples[target] = append(d.samples[target], &debugSample{
+			Timestamp: now,
+			Response:  data,
+		})
+	}
+}
+
+// pprof captures one set of profiles into a directory named after the
+// current time
+func (d *debugCapture) pprof(ctx context.Context, now time.Time, interval time.Duration) {
+	dirName := now.Format("2006-01-02T15-04-05Z")
+	if err := os.MkdirAll(filepath.Join(d.dir, dirName), 0700); err != nil {
+		d.addError("pprof", err)
+		return
+	}
+
+	profiles := []struct {
+		file   string
+		path   string
+		params map[string]string
+	}{
+		{"goroutine.prof", "/v1/sys/pprof/goroutine", nil},
+		{"goroutines.txt", "/v1/sys/pprof/goroutine", map[string]string{"debug": "2"}},
+		{"heap.prof", "/v1/sys/pprof/heap", nil},
+		{"profile.prof", "/v1/sys/pprof/profile", map[string]string{"seconds": strconv.Itoa(int(interval.Seconds()))}},
+		{"trace.out", "/v1/sys/pprof/trace", map[string]string{"seconds": strconv.Itoa(debugTraceSeconds)}},
+	}
+
+	// The CPU profile and trace block on the server, so everything is
+	// requested concurrently to keep to the interval
+	var wg sync.WaitGroup
+	for _, p := range profiles {
+		wg.Add(1)
+		go func(file, path string, params map[string]string) {
+			defer wg.Done()
+
+			r := d.client.NewRequest("GET", path)
+			for k, v := range params {
+				r.Params.Set(k, v)
+			}
+
+			resp, err := d.client.RawRequestWithContext(ctx, r)
+			if resp != nil {
+				defer resp.Body.Close()
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					d.addError("pprof", errwrap.Wrapf(fmt.Sprintf("error capturing %s: {{err}}", file), err))
+				}
+				return
+			}
+
+			out, err := os.OpenFile(filepath.Join(d.dir, dirName, file), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				d.addError("pprof", err)
+				return
+			}
+			defer out.Close()
+
+			if _, err := io.Copy(out, resp.Body); err != nil {
+				d.addError("pprof", err)
+			}
+		}(p.file, p.path, p.params)
+	}
+	wg.Wait()
+
+	d.l.Lock()
+	defer d.l.Unlock()
+	dirs, _ := d.index.Output["pprof"].([]string)
+	d.index.Output["pprof"] = append(dirs, dirName)
+}
+
+// log streams the server log into vault.log until ctx is done
+func (d *debugCapture) log(ctx context.Context) {
+	logCh, err := d.client.Sys().Monitor(ctx, debugLogLevel)
+	if err != nil {
+		d.addError("log", err)
+		return
+	}
+
+	out, err := os.OpenFile(filepath.Join(d.dir, "vault.log"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		d.addError("log", err)
+		return
+	}
+	defer out.Close()
+
+	d.l.Lock()
+	d.index.Output["log"] = "vault.log"
+	d.l.Unlock()
+
+	for line := range logCh {
+		if _, err := io.WriteString(out, line+"\n"); err != nil {
+			d.addError("log", err)
+			return
+		}
+	}
+}
+
+// flush writes the polled samples out, one file per target
+func (d *debugCapture) flush() {
+	d.l.Lock()
+	samples := d.samples
+	d.l.Unlock()
+
+	for target, s := range samples {
+		file := target + ".json"
+		if err := writeDebugJSON(filepath.Join(d.dir, file), s); err != nil {
+			d.addError(target, err)
+			continue
+		}
+
+		d.l.Lock()
+		d.index.Output[target] = file
+		d.l.Unlock()
+	}
+}
+
+func writeDebugJSON(path string, data interface{}) error {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// debugArchive packages the contents of dir into a gzipped tarball at path,
+// rooted at the base name of dir
+func debugArchive(dir, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	base := filepath.Dir(dir)
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,184 @@
+package command
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func testDebugCommand(tb testing.TB) (*cli.MockUi, *DebugCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &DebugCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+		ShutdownCh:       make(chan struct{}),
+		skipTimingChecks: true,
+	}
+}
+
+func TestDebugCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			name string
+			args []string
+			out  string
+			code int
+		}{
+			{
+				"too_many_args",
+				[]string{"foo"},
+				"Too many arguments",
+				1,
+			},
+			{
+				"unknown_target",
+				[]string{"-target", "bogus"},
+				"Unknown target",
+				1,
+			},
+		}
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				ui, cmd := testDebugCommand(t)
+
+				code := cmd.Run(tc.args)
+				if code != tc.code {
+					t.Errorf("expected %d to be %d", code, tc.code)
+				}
+
+				combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+				if !strings.Contains(combined, tc.out) {
+					t.Errorf("expected %q to contain %q", combined, tc.out)
+				}
+			})
+		}
+	})
+
+	t.Run("timings", func(t *testing.T) {
+		t.Parallel()
+
+		ui, cmd := testDebugCommand(t)
+		cmd.skipTimingChecks = false
+
+		code := cmd.Run([]string{"-interval", "1s"})
+		if exp := 1; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Interval must be at least"
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		dir, err := ioutil.TempDir("", "vault-debug")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		ui, cmd := testDebugCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{
+			"-duration", "2s",
+			"-interval", "1s",
+			"-metrics-interval", "1s",
+			"-output", filepath.Join(dir, "bundle"),
+		})
+		if exp := 0; code != exp {
+			t.Fatalf("expected %d to be %d: %s", code, exp, ui.ErrorWriter.String())
+		}
+
+		f, err := os.Open(filepath.Join(dir, "bundle.tar.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := map[string]bool{}
+		var index debugIndex
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[header.Name] = true
+
+			if header.Name == "bundle/index.json" {
+				if err := json.NewDecoder(tr).Decode(&index); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		for _, expected := range []string{
+			"bundle/index.json",
+			"bundle/health.json",
+			"bundle/leader.json",
+			"bundle/seal-status.json",
+		} {
+			if !files[expected] {
+				t.Errorf("expected %s in archive, got %v", expected, files)
+			}
+		}
+
+		if index.Version != debugIndexVersion {
+			t.Errorf("bad index version: %d", index.Version)
+		}
+		if index.Output["pprof"] == nil {
+			t.Errorf("expected pprof output in index: %#v", index.Output)
+		}
+
+		// The test server does not have an in-memory metrics sink
+		var metricsErr bool
+		for _, e := range index.Errors {
+			if e.Target == "metrics" {
+				metricsErr = true
+			}
+		}
+		if !metricsErr {
+			t.Errorf("expected metrics capture error in index: %#v", index.Errors)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "bundle")); !os.IsNotExist(err) {
+			t.Errorf("expected output directory to be removed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+var _ cli.Command = (*IdentityCommand)(nil)
+
+type IdentityCommand struct {
+	*BaseCommand
+}
+
+func (c *IdentityCommand) Synopsis() string {
+	return "Interact with Vault's identity store"
+}
+
+func (c *IdentityCommand) Help() string {
+	helpText := `
+Usage: vault identity <subcommand> [options] [args]
+
+  This command has subcommands for moving entities, aliases and groups
+  between identity stores. Here are some simple examples, and more detailed
+  examples are available in the subcommands or the documentation.
+
+  Export all entities and groups to a file:
+
+      $ vault identity export -output=identity.json
+
+  Import them into another cluster, mapping the accessor of an auth mount:
+
+      $ vault identity import \
+          -mount-accessor-map=auth_github_1a2b3c4d=auth_github_5e6f7a8b \
+          identity.json
+
+  Please see the individual subcommand help for detailed usage information.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *IdentityCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,119 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*IdentityExportCommand)(nil)
+var _ cli.CommandAutocomplete = (*IdentityExportCommand)(nil)
+
+type IdentityExportCommand struct {
+	*BaseCommand
+
+	flagOutput string
+}
+
+func (c *IdentityExportCommand) Synopsis() string {
+	return "Exports entities and groups from the identity store"
+}
+
+func (c *IdentityExportCommand) Help() string {
+	helpText := `
+Usage: vault identity export [options]
+
+  Exports all entities, entity aliases, groups and group aliases from the
+  identity store as versioned JSON. The output can be loaded into this or
+  another cluster with "vault identity import". This requires a sudo
+  capable token.
+
+  Print the export to stdout:
+
+      $ vault identity export
+
+  Write the export to a file:
+
+      $ vault identity export -output=identity.json
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *IdentityExportCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "output",
+		Target:     &c.flagOutput,
+		Completion: complete.PredictFiles("*.json"),
+		Usage:      "Path to a file to write the export to. By default it is written to stdout.",
+	})
+
+	return set
+}
+
+func (c *IdentityExportCommand) AutocompleteArgs() complete.Predictor {
+	return nil
+}
+
+func (c *IdentityExportCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *IdentityExportCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	secret, err := client.Logical().Read("identity/export")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error exporting identity store: %s", err))
+		return 2
+	}
+	if secret == nil || secret.Data == nil {
+		c.UI.Error("No data returned from the identity store export")
+		return 2
+	}
+
+	b, err := json.MarshalIndent(secret.Data, "", "  ")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error encoding export: %s", err))
+		return 2
+	}
+
+	if c.flagOutput == "" {
+		c.UI.Output(string(b))
+		return 0
+	}
+
+	if err := ioutil.WriteFile(c.flagOutput, append(b, '\n'), 0600); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing export: %s", err))
+		return 2
+	}
+
+	c.UI.Output(fmt.Sprintf("Success! Exported identity store to: %s", c.flagOutput))
+	return 0
+}
@@ -0,0 +1,123 @@
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func testIdentityExportCommand(tb testing.TB) (*cli.MockUi, *IdentityExportCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &IdentityExportCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestIdentityExportCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("too_many_args", func(t *testing.T) {
+		t.Parallel()
+
+		ui, cmd := testIdentityExportCommand(t)
+
+		code := cmd.Run([]string{"foo"})
+		if exp := 1; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Too many arguments"
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		if _, err := client.Logical().Write("identity/entity", map[string]interface{}{
+			"name": "my-entity",
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		dir, err := ioutil.TempDir("", "vault-identity-export")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "identity.json")
+
+		ui, cmd := testIdentityExportCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{
+			"-output", path,
+		})
+		if exp := 0; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Success! Exported identity store to: " + path
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var export struct {
+			Version  int                      `json:"version"`
+			Entities []map[string]interface{} `json:"entities"`
+		}
+		if err := json.Unmarshal(b, &export); err != nil {
+			t.Fatal(err)
+		}
+		if export.Version != 1 || len(export.Entities) != 1 || export.Entities[0]["name"] != "my-entity" {
+			t.Errorf("bad export: %s", b)
+		}
+	})
+
+	t.Run("communication_failure", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServerBad(t)
+		defer closer()
+
+		ui, cmd := testIdentityExportCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{})
+		if exp := 2; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Error exporting identity store: "
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testIdentityExportCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
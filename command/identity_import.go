@@ -0,0 +1,154 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*IdentityImportCommand)(nil)
+var _ cli.CommandAutocomplete = (*IdentityImportCommand)(nil)
+
+type IdentityImportCommand struct {
+	*BaseCommand
+
+	flagConflict         string
+	flagMountAccessorMap map[string]string
+
+	testStdin io.Reader // for tests
+}
+
+func (c *IdentityImportCommand) Synopsis() string {
+	return "Imports entities and groups into the identity store"
+}
+
+func (c *IdentityImportCommand) Help() string {
+	helpText := `
+Usage: vault identity import [options] PATH
+
+  Imports entities, entity aliases, groups and group aliases that were
+  written by "vault identity export". If PATH is "-", the export is read
+  from stdin. This requires a sudo capable token.
+
+  Entities and groups whose IDs already exist are skipped unless -conflict
+  is set to "overwrite". Items whose names or aliases belong to a different
+  entity or group are always skipped and reported in the output.
+
+  Restore a deleted entity from an earlier export:
+
+      $ vault identity import identity.json
+
+  Import into another cluster, mapping the accessor of the exported GitHub
+  mount to the accessor of the local one:
+
+      $ vault identity import \
+          -mount-accessor-map=auth_github_1a2b3c4d=auth_github_5e6f7a8b \
+          identity.json
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *IdentityImportCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "conflict",
+		Target:     &c.flagConflict,
+		Default:    "skip",
+		Completion: complete.PredictSet("skip", "overwrite"),
+		Usage: "How to handle entities and groups whose IDs already exist. " +
+			"Valid values are \"skip\" and \"overwrite\".",
+	})
+
+	f.StringMapVar(&StringMapVar{
+		Name:       "mount-accessor-map",
+		Target:     &c.flagMountAccessorMap,
+		Completion: complete.PredictAnything,
+		Usage: "Mapping of an exported mount accessor to a local mount " +
+			"accessor, provided as old=new. This can be specified multiple times.",
+	})
+
+	return set
+}
+
+func (c *IdentityImportCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*.json")
+}
+
+func (c *IdentityImportCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *IdentityImportCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	switch {
+	case len(args) < 1:
+		c.UI.Error(fmt.Sprintf("Not enough arguments (expected 1, got %d)", len(args)))
+		return 1
+	case len(args) > 1:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	// Get the export contents, either from stdin or a file
+	path := strings.TrimSpace(args[0])
+	var reader io.Reader
+	if path == "-" {
+		reader = os.Stdin
+		if c.testStdin != nil {
+			reader = c.testStdin
+		}
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error opening export file: %s", err))
+			return 2
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing export: %s", err))
+		return 2
+	}
+	data["conflict"] = c.flagConflict
+	if len(c.flagMountAccessorMap) > 0 {
+		data["mount_accessor_map"] = c.flagMountAccessorMap
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	secret, err := client.Logical().Write("identity/import", data)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error importing identity store: %s", err))
+		return 2
+	}
+	if secret == nil {
+		c.UI.Error("No data returned from the identity store import")
+		return 2
+	}
+
+	return OutputSecret(c.UI, secret)
+}
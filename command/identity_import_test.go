@@ -0,0 +1,136 @@
+package command
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func testIdentityImportCommand(tb testing.TB) (*cli.MockUi, *IdentityImportCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &IdentityImportCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestIdentityImportCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		args []string
+		out  string
+		code int
+	}{
+		{
+			"not_enough_args",
+			[]string{},
+			"Not enough arguments",
+			1,
+		},
+		{
+			"too_many_args",
+			[]string{"foo", "bar"},
+			"Too many arguments",
+			1,
+		},
+		{
+			"bad_file",
+			[]string{"/not/a/real/path.json"},
+			"Error opening export file",
+			2,
+		},
+	}
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				ui, cmd := testIdentityImportCommand(t)
+
+				code := cmd.Run(tc.args)
+				if code != tc.code {
+					t.Errorf("expected %d to be %d", code, tc.code)
+				}
+
+				combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+				if !strings.Contains(combined, tc.out) {
+					t.Errorf("expected %q to contain %q", combined, tc.out)
+				}
+			})
+		}
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		secret, err := client.Logical().Write("identity/entity", map[string]interface{}{
+			"name": "my-entity",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entityID := secret.Data["id"].(string)
+
+		exportUI, exportCmd := testIdentityExportCommand(t)
+		exportCmd.client = client
+		if code := exportCmd.Run([]string{}); code != 0 {
+			t.Fatalf("expected 0 to be %d: %s", code, exportUI.ErrorWriter.String())
+		}
+
+		if _, err := client.Logical().Delete("identity/entity/id/" + entityID); err != nil {
+			t.Fatal(err)
+		}
+
+		stdinR, stdinW := io.Pipe()
+		go func() {
+			stdinW.Write(exportUI.OutputWriter.Bytes())
+			stdinW.Close()
+		}()
+
+		ui, cmd := testIdentityImportCommand(t)
+		cmd.client = client
+		cmd.testStdin = stdinR
+
+		code := cmd.Run([]string{
+			"-conflict", "overwrite", "-",
+		})
+		if exp := 0; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, entityID) {
+			t.Errorf("expected %q to contain %q", combined, entityID)
+		}
+
+		secret, err = client.Logical().Read("identity/entity/id/" + entityID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret == nil || secret.Data["name"] != "my-entity" {
+			t.Errorf("expected the entity to be restored: %#v", secret)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testIdentityImportCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/password"
 	"github.com/posener/complete"
 )
 
@@ -68,6 +69,11 @@ Usage: vault login [options] [AUTH K=V...]
 
       $ vault login -method=github -path=github-prod
 
+  If the login requires MFA, Vault will prompt for the passcodes of the
+  required methods, or they can be supplied with -mfa:
+
+      $ vault login -method=userpass -mfa=my_totp:123456 username=my-username
+
   If the authentication is requested with response wrapping (via -wrap-ttl),
   the returned token is automatically unwrapped unless:
 
@@ -269,6 +275,15 @@ func (c *LoginCommand) Run(args []string) int {
 		return 2
 	}
 
+	// Complete the login if it requires MFA
+	if secret != nil && secret.Auth != nil && secret.Auth.MFARequirement != nil {
+		secret, err = c.validateMFA(client, secret.Auth.MFARequirement)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error validating MFA: %s", err))
+			return 2
+		}
+	}
+
 	// Unset any previous token wrapping functionality. If the original request
 	// was for a wrapped token, we don't want future requests to be wrapped.
 	client.SetWrappingLookupFunc(func(string, string) string { return "" })
@@ -368,6 +383,48 @@ func (c *LoginCommand) Run(args []string) int {
 	return OutputSecret(c.UI, secret)
 }
 
+// validateMFA collects the passcodes of the MFA methods a login requires,
+// either from the -mfa flag or by prompting for them, and completes the
+// login through sys/mfa/validate.
+func (c *LoginCommand) validateMFA(client *api.Client, requirement *api.MFARequirement) (*api.Secret, error) {
+	// Passcodes given with -mfa are in the form name:passcode
+	passcodes := make(map[string]string, len(c.flagMFA))
+	for _, creds := range c.flagMFA {
+		idx := strings.Index(creds, ":")
+		if idx == -1 {
+			passcodes[creds] = ""
+			continue
+		}
+		passcodes[creds[:idx]] = creds[idx+1:]
+	}
+
+	payload := make(map[string][]string, len(requirement.MFAMethods))
+	for _, method := range requirement.MFAMethods {
+		payload[method.Name] = []string{}
+
+		if passcode, ok := passcodes[method.Name]; ok {
+			if passcode != "" {
+				payload[method.Name] = []string{passcode}
+			}
+			continue
+		}
+		if !method.UsesPasscode {
+			c.UI.Info(fmt.Sprintf("Approve the %s MFA request %q to continue...", method.Type, method.Name))
+			continue
+		}
+
+		fmt.Fprintf(os.Stdout, "Passcode for MFA method %q (will be hidden): ", method.Name)
+		passcode, err := password.Read(os.Stdin)
+		fmt.Fprintf(os.Stdout, "\n")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passcode, provide it with -mfa instead: %s", err)
+		}
+		payload[method.Name] = []string{strings.TrimSpace(passcode)}
+	}
+
+	return client.Sys().MFAValidate(requirement.MFARequestID, payload)
+}
+
 // extractToken extracts the token from the given secret, automatically
 // unwrapping responses and handling error conditions if unwrap is true. The
 // result also returns whether it was a wrapped response that was not unwrapped.
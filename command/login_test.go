@@ -3,8 +3,11 @@ package command
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mitchellh/cli"
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
 
 	"github.com/hashicorp/vault/api"
 	credToken "github.com/hashicorp/vault/builtin/credential/token"
@@ -247,6 +250,81 @@ func TestLoginCommand_Run(t *testing.T) {
 		}
 	})
 
+	t.Run("mfa", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		if err := client.Sys().EnableAuth("userpass", "userpass", ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Logical().Write("auth/userpass/users/test", map[string]interface{}{
+			"password": "test",
+			"policies": "default",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		secret, err := client.Logical().Write("auth/userpass/login/test", map[string]interface{}{
+			"password": "test",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret, err = client.Auth().Token().Lookup(secret.Auth.ClientToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entityID := secret.Data["entity_id"].(string)
+
+		if _, err := client.Logical().Write("identity/mfa/method/totp/my_totp", map[string]interface{}{
+			"issuer":     "Vault",
+			"entity_ids": entityID,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		secret, err = client.Logical().Write("identity/mfa/method/totp/my_totp/admin-generate", map[string]interface{}{
+			"entity_id": entityID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := otplib.NewKeyFromURL(secret.Data["url"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := totplib.GenerateCode(key.Secret(), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ui, cmd := testLoginCommand(t)
+		cmd.client = client
+
+		tokenHelper, err := cmd.TokenHelper()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exitCode := cmd.Run([]string{
+			"-method", "userpass",
+			"-mfa", "my_totp:" + code,
+			"username=test",
+			"password=test",
+		})
+		if exp := 0; exitCode != exp {
+			t.Errorf("expected %d to be %d: %s", exitCode, exp, ui.ErrorWriter.String())
+		}
+
+		storedToken, err := tokenHelper.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l, exp := len(storedToken), 36; l != exp {
+			t.Errorf("expected token to be %d characters, was %d: %q", exp, l, storedToken)
+		}
+	})
+
 	t.Run("wrap_auto_unwrap", func(t *testing.T) {
 		t.Parallel()
 
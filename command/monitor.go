@@ -0,0 +1,126 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*MonitorCommand)(nil)
+var _ cli.CommandAutocomplete = (*MonitorCommand)(nil)
+
+type MonitorCommand struct {
+	*BaseCommand
+
+	flagLogLevel string
+
+	// ShutdownCh stops the stream
+	ShutdownCh chan struct{}
+}
+
+func (c *MonitorCommand) Synopsis() string {
+	return "Stream log messages from a Vault server"
+}
+
+func (c *MonitorCommand) Help() string {
+	helpText := `
+Usage: vault monitor [options]
+
+  Streams the log of a Vault server until interrupted. The log is read from
+  the node the command connects to, which may be a standby, and can be
+  streamed at a more verbose level than the server writes to its own output.
+
+  This requires a token with sudo capability on "sys/monitor".
+
+  Stream the log at the info level:
+
+      $ vault monitor
+
+  Stream debug messages from a specific node:
+
+      $ vault monitor -log-level=debug -address=https://vault-2:8200
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *MonitorCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "log-level",
+		Target:     &c.flagLogLevel,
+		Default:    "info",
+		Completion: complete.PredictSet("trace", "debug", "info", "warn", "error"),
+		Usage: "Log level of the messages to stream. Supported values (in " +
+			"order of detail) are \"trace\", \"debug\", \"info\", \"warn\" and " +
+			"\"error\".",
+	})
+
+	return set
+}
+
+func (c *MonitorCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *MonitorCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *MonitorCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	c.flagLogLevel = strings.ToLower(strings.TrimSpace(c.flagLogLevel))
+	switch c.flagLogLevel {
+	case "trace", "debug", "info", "warn", "error":
+	default:
+		c.UI.Error(fmt.Sprintf("Unknown log level: %s", c.flagLogLevel))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logCh, err := client.Sys().Monitor(ctx, c.flagLogLevel)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error starting monitor: %s", err))
+		return 2
+	}
+
+	for {
+		select {
+		case line, ok := <-logCh:
+			if !ok {
+				c.UI.Error("Monitor stream closed by the server")
+				return 2
+			}
+			c.UI.Output(line)
+		case <-c.ShutdownCh:
+			return 0
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package command
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/vault"
+	"github.com/mitchellh/cli"
+)
+
+func testMonitorCommand(tb testing.TB) (*cli.MockUi, *MonitorCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &MonitorCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+		ShutdownCh: make(chan struct{}),
+	}
+}
+
+func TestMonitorCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			name string
+			args []string
+			out  string
+			code int
+		}{
+			{
+				"too_many_args",
+				[]string{"foo"},
+				"Too many arguments",
+				1,
+			},
+			{
+				"unknown_level",
+				[]string{"-log-level", "bogus"},
+				"Unknown log level",
+				1,
+			},
+		}
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				ui, cmd := testMonitorCommand(t)
+
+				code := cmd.Run(tc.args)
+				if code != tc.code {
+					t.Errorf("expected %d to be %d", code, tc.code)
+				}
+
+				combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+				if !strings.Contains(combined, tc.out) {
+					t.Errorf("expected %q to contain %q", combined, tc.out)
+				}
+			})
+		}
+	})
+
+	t.Run("not_available", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		ui, cmd := testMonitorCommand(t)
+		cmd.client = client
+
+		code := cmd.Run(nil)
+		if exp := 2; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "not available"
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		monitor := logging.NewMonitorWriter(ioutil.Discard, log.Info)
+		logger := logging.NewVaultLoggerWithWriter(monitor, log.Info)
+		monitor.SetLogger(logger)
+
+		client, _, closer := testVaultServerCoreConfig(t, &vault.CoreConfig{
+			DisableMlock:       true,
+			DisableCache:       true,
+			Logger:             logger,
+			LogMonitor:         monitor,
+			CredentialBackends: defaultVaultCredentialBackends,
+			AuditBackends:      defaultVaultAuditBackends,
+			LogicalBackends:    defaultVaultLogicalBackends,
+		})
+		defer closer()
+
+		ui, cmd := testMonitorCommand(t)
+		cmd.client = client
+
+		codeCh := make(chan int)
+		go func() {
+			codeCh <- cmd.Run([]string{"-log-level", "debug"})
+		}()
+
+		// The logger is below the requested level until the monitor is
+		// attached, so keep logging until the message comes through
+		expected := "monitor test message"
+		deadline := time.Now().Add(10 * time.Second)
+		for !strings.Contains(ui.OutputWriter.String(), expected) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q, got %q", expected, ui.OutputWriter.String()+ui.ErrorWriter.String())
+			}
+			logger.Debug(expected)
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		close(cmd.ShutdownCh)
+		if code := <-codeCh; code != 0 {
+			t.Errorf("expected %d to be 0: %s", code, ui.ErrorWriter.String())
+		}
+	})
+}
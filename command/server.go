@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sort"
 	"strconv"
@@ -21,6 +23,7 @@ import (
 	"time"
 
 	"github.com/mitchellh/cli"
+	"github.com/mitchellh/copystructure"
 	testing "github.com/mitchellh/go-testing-interface"
 	"github.com/posener/complete"
 
@@ -63,9 +66,22 @@ type ServerCommand struct {
 
 	WaitGroup *sync.WaitGroup
 
-	logWriter io.Writer
-	logGate   *gatedwriter.Writer
-	logger    log.Logger
+	logWriter  io.Writer
+	logGate    *gatedwriter.Writer
+	logMonitor *logging.MonitorWriter
+	logger     log.Logger
+
+	// logLevelFromFlag is set when the log level was given by flag or
+	// environment variable, which takes precedence over the configuration
+	logLevelFromFlag bool
+
+	// config holds the settings currently in effect, and is compared with
+	// the configuration files when they are reloaded
+	config *server.Config
+
+	inmemMetrics *metrics.InmemSink
+	circonusSink *circonus.CirconusSink
+	metricsSinks []metrics.MetricSink
 
 	cleanupGuard sync.Once
 
@@ -306,21 +322,19 @@ func (c *ServerCommand) Run(args []string) int {
 	if c.flagCombineLogs {
 		c.logWriter = os.Stdout
 	}
-	var level log.Level
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == "log-level" {
+			c.logLevelFromFlag = true
+		}
+	})
+	if os.Getenv("VAULT_LOG_LEVEL") != "" {
+		c.logLevelFromFlag = true
+	}
+
 	c.flagLogLevel = strings.ToLower(strings.TrimSpace(c.flagLogLevel))
-	switch c.flagLogLevel {
-	case "trace":
-		level = log.Trace
-	case "debug":
-		level = log.Debug
-	case "notice", "info", "":
-		level = log.Info
-	case "warn", "warning":
-		level = log.Warn
-	case "err", "error":
-		level = log.Error
-	default:
-		c.UI.Error(fmt.Sprintf("Unknown log level: %s", c.flagLogLevel))
+	level, err := parseLogLevel(c.flagLogLevel)
+	if err != nil {
+		c.UI.Error(err.Error())
 		return 1
 	}
 
@@ -331,7 +345,11 @@ func (c *ServerCommand) Run(args []string) int {
 			Level:  log.Trace,
 		})
 	} else {
-		c.logger = logging.NewVaultLoggerWithWriter(c.logWriter, level)
+		// Route the log through a monitor writer so that it can be streamed
+		// from sys/monitor, possibly at a more verbose level
+		c.logMonitor = logging.NewMonitorWriter(c.logWriter, level)
+		c.logger = logging.NewVaultLoggerWithWriter(c.logMonitor, level)
+		c.logMonitor.SetLogger(c.logger)
 	}
 
 	grpclog.SetLogger(&grpclogFaker{
@@ -359,25 +377,10 @@ func (c *ServerCommand) Run(args []string) int {
 	}
 
 	// Load the configuration
-	var config *server.Config
-	if c.flagDev {
-		config = server.DevConfig(c.flagDevHA, c.flagDevTransactional)
-		if c.flagDevListenAddr != "" {
-			config.Listeners[0].Config["address"] = c.flagDevListenAddr
-		}
-	}
-	for _, path := range c.flagConfigs {
-		current, err := server.LoadConfig(path, c.logger)
-		if err != nil {
-			c.UI.Error(fmt.Sprintf("Error loading configuration from %s: %s", path, err))
-			return 1
-		}
-
-		if config == nil {
-			config = current
-		} else {
-			config = config.Merge(current)
-		}
+	config, err := c.loadConfig()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
 	}
 
 	// Ensure at least one config was found.
@@ -400,6 +403,25 @@ func (c *ServerCommand) Run(args []string) int {
 		vault.DefaultMaxRequestDuration = config.DefaultMaxRequestDuration
 	}
 
+	// Keep a pristine copy of the configuration to compare against on
+	// reload, since listener setup modifies the listener settings
+	appliedConfig, err := copystructure.Copy(config)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error copying configuration: %s", err))
+		return 1
+	}
+	c.config = appliedConfig.(*server.Config)
+
+	if config.LogLevel != "" && !c.logLevelFromFlag && c.logMonitor != nil {
+		level, err := parseLogLevel(config.LogLevel)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		c.logMonitor.SetLevel(level)
+		c.flagLogLevel = config.LogLevel
+	}
+
 	// If mlockall(2) isn't supported, show a warning. We disable this in dev
 	// because it is quite scary to see when first using Vault. We also disable
 	// this if the user has explicitly disabled mlock in configuration.
@@ -412,10 +434,12 @@ func (c *ServerCommand) Run(args []string) int {
 				"in a Docker container, provide the IPC_LOCK cap to the container."))
 	}
 
-	if err := c.setupTelemetry(config); err != nil {
+	inmemMetrics, err := c.setupTelemetry(config)
+	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error initializing telemetry: %s", err))
 		return 1
 	}
+	c.inmemMetrics = inmemMetrics
 
 	// Initialize the backend
 	factory, exists := c.PhysicalBackends[config.Storage.Type]
@@ -469,6 +493,8 @@ func (c *ServerCommand) Run(args []string) int {
 		PluginDirectory:    config.PluginDirectory,
 		EnableUI:           config.EnableUI,
 		EnableRaw:          config.EnableRawEndpoint,
+		MetricSink:         inmemMetrics,
+		LogMonitor:         c.logMonitor,
 	}
 	if c.flagDev {
 		coreConfig.DevToken = c.flagDevRootTokenID
@@ -1038,7 +1064,7 @@ CLUSTER_SYNTHESIS_COMPLETE:
 
 		case <-c.SighupCh:
 			c.UI.Output("==> Vault reload triggered")
-			if err := c.Reload(c.reloadFuncsLock, c.reloadFuncs, c.flagConfigs); err != nil {
+			if err := c.Reload(c.reloadFuncsLock, c.reloadFuncs, c.flagConfigs, core); err != nil {
 				c.UI.Error(fmt.Sprintf("Error(s) were encountered during reload: %s", err))
 			}
 		}
@@ -1360,7 +1386,7 @@ func (c *ServerCommand) enableThreeNodeDevCluster(base *vault.CoreConfig, info m
 		case <-c.SighupCh:
 			c.UI.Output("==> Vault reload triggered")
 			for _, core := range testCluster.Cores {
-				if err := c.Reload(core.ReloadFuncsLock, core.ReloadFuncs, nil); err != nil {
+				if err := c.Reload(core.ReloadFuncsLock, core.ReloadFuncs, nil, nil); err != nil {
 					c.UI.Error(fmt.Sprintf("Error(s) were encountered during reload: %s", err))
 				}
 			}
@@ -1486,8 +1512,9 @@ func (c *ServerCommand) detectRedirect(detect physical.RedirectDetect,
 	return url.String(), nil
 }
 
-// setupTelemetry is used to setup the telemetry sub-systems
-func (c *ServerCommand) setupTelemetry(config *server.Config) error {
+// setupTelemetry is used to setup the telemetry sub-systems and returns the
+// in-memory sink so that it can be served by the sys/metrics endpoint
+func (c *ServerCommand) setupTelemetry(config *server.Config) (*metrics.InmemSink, error) {
 	/* Setup telemetry
 	Aggregate on 10 second intervals for 1 minute. Expose the
 	metrics over stderr when there is a SIGUSR1 received.
@@ -1495,6 +1522,17 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 	inm := metrics.NewInmemSink(10*time.Second, time.Minute)
 	metrics.DefaultInmemSignal(inm)
 
+	if err := c.configureTelemetry(config, inm, false); err != nil {
+		return nil, err
+	}
+	return inm, nil
+}
+
+// configureTelemetry creates the metrics sinks of the configuration and
+// installs them globally, alongside the in-memory sink. When reloading, the
+// sinks that were replaced are shut down. The Circonus sink cannot be shut
+// down, so it is only created at startup and kept across reloads.
+func (c *ServerCommand) configureTelemetry(config *server.Config, inm *metrics.InmemSink, reloading bool) error {
 	var telConfig *server.Telemetry
 	if config.Telemetry == nil {
 		telConfig = &server.Telemetry{}
@@ -1505,6 +1543,9 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 	metricsConf := metrics.DefaultConfig("vault")
 	metricsConf.EnableHostname = !telConfig.DisableHostname
 
+	// Sinks that need to be shut down when they are replaced
+	var sinks []metrics.MetricSink
+
 	// Configure the statsite sink
 	var fanout metrics.FanoutSink
 	if telConfig.StatsiteAddr != "" {
@@ -1513,19 +1554,22 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 			return err
 		}
 		fanout = append(fanout, sink)
+		sinks = append(sinks, sink)
 	}
 
 	// Configure the statsd sink
 	if telConfig.StatsdAddr != "" {
 		sink, err := metrics.NewStatsdSink(telConfig.StatsdAddr)
 		if err != nil {
+			shutdownMetricsSinks(sinks)
 			return err
 		}
 		fanout = append(fanout, sink)
+		sinks = append(sinks, sink)
 	}
 
 	// Configure the Circonus sink
-	if telConfig.CirconusAPIToken != "" || telConfig.CirconusCheckSubmissionURL != "" {
+	if !reloading && (telConfig.CirconusAPIToken != "" || telConfig.CirconusCheckSubmissionURL != "") {
 		cfg := &circonus.Config{}
 		cfg.Interval = telConfig.CirconusSubmissionInterval
 		cfg.CheckManager.API.TokenKey = telConfig.CirconusAPIToken
@@ -1558,7 +1602,10 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 			return err
 		}
 		sink.Start()
-		fanout = append(fanout, sink)
+		c.circonusSink = sink
+	}
+	if c.circonusSink != nil {
+		fanout = append(fanout, c.circonusSink)
 	}
 
 	if telConfig.DogStatsDAddr != "" {
@@ -1570,10 +1617,12 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 
 		sink, err := datadog.NewDogStatsdSink(telConfig.DogStatsDAddr, metricsConf.HostName)
 		if err != nil {
+			shutdownMetricsSinks(sinks)
 			return errwrap.Wrapf("failed to start DogStatsD sink: {{err}}", err)
 		}
 		sink.SetTags(tags)
 		fanout = append(fanout, sink)
+		sinks = append(sinks, sink)
 	}
 
 	// Initialize the global sink
@@ -1584,15 +1633,34 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 		metricsConf.EnableHostname = false
 		metrics.NewGlobal(metricsConf, inm)
 	}
+
+	shutdownMetricsSinks(c.metricsSinks)
+	c.metricsSinks = sinks
+
 	return nil
 }
 
-func (c *ServerCommand) Reload(lock *sync.RWMutex, reloadFuncs *map[string][]reload.ReloadFunc, configPath []string) error {
+// shutdownMetricsSinks stops the background workers of the given sinks
+func shutdownMetricsSinks(sinks []metrics.MetricSink) {
+	for _, sink := range sinks {
+		if s, ok := sink.(interface{ Shutdown() }); ok {
+			s.Shutdown()
+		}
+	}
+}
+
+func (c *ServerCommand) Reload(lock *sync.RWMutex, reloadFuncs *map[string][]reload.ReloadFunc, configPath []string, core *vault.Core) error {
 	lock.RLock()
 	defer lock.RUnlock()
 
 	var reloadErrors *multierror.Error
 
+	if len(configPath) > 0 && core != nil {
+		if err := c.reloadConfig(core); err != nil {
+			reloadErrors = multierror.Append(reloadErrors, errwrap.Wrapf("error encountered reloading configuration: {{err}}", err))
+		}
+	}
+
 	for k, relFuncs := range *reloadFuncs {
 		switch {
 		case strings.HasPrefix(k, "listener|"):
@@ -1625,6 +1693,124 @@ func (c *ServerCommand) Reload(lock *sync.RWMutex, reloadFuncs *map[string][]rel
 	return reloadErrors.ErrorOrNil()
 }
 
+// loadConfig loads and merges the configuration files, on top of the dev mode
+// configuration when running in dev mode. It returns nil if no configuration
+// was found.
+func (c *ServerCommand) loadConfig() (*server.Config, error) {
+	var config *server.Config
+	if c.flagDev {
+		config = server.DevConfig(c.flagDevHA, c.flagDevTransactional)
+		if c.flagDevListenAddr != "" {
+			config.Listeners[0].Config["address"] = c.flagDevListenAddr
+		}
+	}
+	for _, path := range c.flagConfigs {
+		current, err := server.LoadConfig(path, c.logger)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading configuration from %s: %s", path, err)
+		}
+
+		if config == nil {
+			config = current
+		} else {
+			config = config.Merge(current)
+		}
+	}
+	return config, nil
+}
+
+// reloadConfig loads the configuration files again and applies the settings
+// that can be changed while the server is running: the log level, telemetry,
+// lease TTLs, the UI and the plugin directory. Other changed settings are
+// reported as requiring a restart.
+func (c *ServerCommand) reloadConfig(core *vault.Core) error {
+	config, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return fmt.Errorf("no configuration files found")
+	}
+
+	// c.config tracks the settings in effect, so it is only updated with the
+	// settings that were applied successfully
+	applied := c.config
+	var reloadErrors *multierror.Error
+
+	if changed := applied.RestartRequiredChanges(config); len(changed) > 0 {
+		c.UI.Warn(wrapAtLength(fmt.Sprintf(
+			"The following settings have changed but only take effect when "+
+				"Vault is restarted: %s", strings.Join(changed, ", "))))
+		c.logger.Warn("configuration changes require a restart", "settings", changed)
+	}
+
+	if config.LogLevel != applied.LogLevel && !c.logLevelFromFlag && c.logMonitor != nil {
+		level, err := parseLogLevel(config.LogLevel)
+		if err != nil {
+			reloadErrors = multierror.Append(reloadErrors, err)
+		} else {
+			c.logMonitor.SetLevel(level)
+			applied.LogLevel = config.LogLevel
+			c.logger.Info("log level changed", "level", config.LogLevel)
+		}
+	}
+
+	if !reflect.DeepEqual(config.Telemetry, applied.Telemetry) {
+		if err := c.configureTelemetry(config, c.inmemMetrics, true); err != nil {
+			reloadErrors = multierror.Append(reloadErrors, errwrap.Wrapf("error reloading telemetry: {{err}}", err))
+		} else {
+			applied.Telemetry = config.Telemetry
+			c.logger.Info("telemetry reloaded")
+		}
+	}
+
+	if config.DefaultLeaseTTL != applied.DefaultLeaseTTL || config.MaxLeaseTTL != applied.MaxLeaseTTL {
+		if err := core.SetLeaseTTLs(config.DefaultLeaseTTL, config.MaxLeaseTTL); err != nil {
+			reloadErrors = multierror.Append(reloadErrors, errwrap.Wrapf("error reloading lease TTLs: {{err}}", err))
+		} else {
+			applied.DefaultLeaseTTL = config.DefaultLeaseTTL
+			applied.MaxLeaseTTL = config.MaxLeaseTTL
+			c.logger.Info("lease TTLs changed", "default_lease_ttl", config.DefaultLeaseTTL, "max_lease_ttl", config.MaxLeaseTTL)
+		}
+	}
+
+	// The VAULT_UI environment variable overrides the configuration
+	if config.EnableUI != applied.EnableUI && os.Getenv("VAULT_UI") == "" {
+		core.SetUIEnabled(config.EnableUI)
+		applied.EnableUI = config.EnableUI
+		c.logger.Info("UI setting changed", "enabled", config.EnableUI)
+	}
+
+	if config.PluginDirectory != applied.PluginDirectory {
+		if err := core.SetPluginDirectory(config.PluginDirectory); err != nil {
+			reloadErrors = multierror.Append(reloadErrors, errwrap.Wrapf("error reloading plugin directory: {{err}}", err))
+		} else {
+			applied.PluginDirectory = config.PluginDirectory
+			c.logger.Info("plugin directory changed", "plugin_directory", config.PluginDirectory)
+		}
+	}
+
+	return reloadErrors.ErrorOrNil()
+}
+
+// parseLogLevel returns the log level of the given name
+func parseLogLevel(name string) (log.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "trace":
+		return log.Trace, nil
+	case "debug":
+		return log.Debug, nil
+	case "notice", "info", "":
+		return log.Info, nil
+	case "warn", "warning":
+		return log.Warn, nil
+	case "err", "error":
+		return log.Error, nil
+	default:
+		return log.NoLevel, fmt.Errorf("Unknown log level: %s", name)
+	}
+}
+
 // storePidFile is used to write out our PID to a file if necessary
 func (c *ServerCommand) storePidFile(pidPath string) error {
 	// Quit fast if no pidfile
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -40,6 +41,8 @@ type Config struct {
 
 	Telemetry *Telemetry `hcl:"telemetry"`
 
+	LogLevel string `hcl:"log_level"`
+
 	MaxLeaseTTL        time.Duration `hcl:"-"`
 	MaxLeaseTTLRaw     interface{}   `hcl:"max_lease_ttl"`
 	DefaultLeaseTTL    time.Duration `hcl:"-"`
@@ -226,6 +229,22 @@ type Telemetry struct {
 	DogStatsDTags []string `hcl:"dogstatsd_tags"`
 }
 
+// circonusSettings returns a copy of t with only the Circonus settings, which
+// cannot be changed without a restart
+func (t *Telemetry) circonusSettings() Telemetry {
+	if t == nil {
+		return Telemetry{}
+	}
+
+	circonus := *t
+	circonus.StatsiteAddr = ""
+	circonus.StatsdAddr = ""
+	circonus.DisableHostname = false
+	circonus.DogStatsDAddr = ""
+	circonus.DogStatsDTags = nil
+	return circonus
+}
+
 func (s *Telemetry) GoString() string {
 	return fmt.Sprintf("*%#v", *s)
 }
@@ -316,6 +335,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.EnableRawEndpoint = c2.EnableRawEndpoint
 	}
 
+	result.LogLevel = c.LogLevel
+	if c2.LogLevel != "" {
+		result.LogLevel = c2.LogLevel
+	}
+
 	result.PluginDirectory = c.PluginDirectory
 	if c2.PluginDirectory != "" {
 		result.PluginDirectory = c2.PluginDirectory
@@ -334,6 +358,39 @@ func (c *Config) Merge(c2 *Config) *Config {
 	return result
 }
 
+// RestartRequiredChanges returns the names of the settings that differ between
+// c and c2 and only take effect when the server is restarted. The remaining
+// settings are applied when the configuration is reloaded.
+func (c *Config) RestartRequiredChanges(c2 *Config) []string {
+	var changed []string
+	check := func(name string, v1, v2 interface{}) {
+		if !reflect.DeepEqual(v1, v2) {
+			changed = append(changed, name)
+		}
+	}
+
+	check("listener", c.Listeners, c2.Listeners)
+	check("storage", c.Storage, c2.Storage)
+	check("ha_storage", c.HAStorage, c2.HAStorage)
+	check("seal", c.Seal, c2.Seal)
+	check("cache_size", c.CacheSize, c2.CacheSize)
+	check("disable_cache", c.DisableCache, c2.DisableCache)
+	check("disable_mlock", c.DisableMlock, c2.DisableMlock)
+	check("disable_printable_check", c.DisablePrintableCheck, c2.DisablePrintableCheck)
+	check("default_max_request_time", c.DefaultMaxRequestDuration, c2.DefaultMaxRequestDuration)
+	check("cluster_name", c.ClusterName, c2.ClusterName)
+	check("cluster_cipher_suites", c.ClusterCipherSuites, c2.ClusterCipherSuites)
+	check("pid_file", c.PidFile, c2.PidFile)
+	check("raw_storage_endpoint", c.EnableRawEndpoint, c2.EnableRawEndpoint)
+	check("api_addr", c.APIAddr, c2.APIAddr)
+	check("cluster_addr", c.ClusterAddr, c2.ClusterAddr)
+	check("disable_clustering", c.DisableClustering, c2.DisableClustering)
+	check("disable_sealwrap", c.DisableSealWrap, c2.DisableSealWrap)
+	check("telemetry.circonus_*", c.Telemetry.circonusSettings(), c2.Telemetry.circonusSettings())
+
+	return changed
+}
+
 // LoadConfig loads the configuration at the given path, regardless if
 // its a file or directory.
 func LoadConfig(path string, logger log.Logger) (*Config, error) {
@@ -383,3 +383,65 @@ listener "tcp" {
 	}
 
 }
+
+func TestConfig_RestartRequiredChanges(t *testing.T) {
+	base := `
+storage "file" {
+	path = "/tmp/vault"
+}
+listener "tcp" {
+	address = "127.0.0.1:8200"
+}
+log_level = "info"
+default_lease_ttl = "1h"
+ui = false
+telemetry {
+	statsd_address = "127.0.0.1:8125"
+	circonus_api_token = "foo"
+}
+`
+	cases := map[string]struct {
+		config   string
+		expected []string
+	}{
+		"unchanged": {
+			base,
+			nil,
+		},
+		"reloadable": {
+			strings.NewReplacer(
+				`"info"`, `"debug"`,
+				`"1h"`, `"2h"`,
+				"ui = false", "ui = true",
+				"8125", "8126",
+			).Replace(base),
+			nil,
+		},
+		"listener": {
+			strings.Replace(base, "8200", "8300", 1),
+			[]string{"listener"},
+		},
+		"storage and circonus": {
+			strings.NewReplacer(
+				"/tmp/vault", "/tmp/other",
+				`"foo"`, `"bar"`,
+			).Replace(base),
+			[]string{"storage", "telemetry.circonus_*"},
+		},
+	}
+
+	logger := logging.NewVaultLogger(log.Debug)
+	c1, err := ParseConfig(base, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range cases {
+		c2, err := ParseConfig(tc.config, logger)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if changed := c1.RestartRequiredChanges(c2); !reflect.DeepEqual(changed, tc.expected) {
+			t.Fatalf("%s: expected %v, got %v", name, tc.expected, changed)
+		}
+	}
+}
@@ -24,7 +24,8 @@ type ListenerFactory func(map[string]interface{}, io.Writer, cli.Ui) (net.Listen
 
 // BuiltinListeners is the list of built-in listener types.
 var BuiltinListeners = map[string]ListenerFactory{
-	"tcp": tcpListenerFactory,
+	"tcp":  tcpListenerFactory,
+	"unix": unixListenerFactory,
 }
 
 // NewListener creates a new listener of the given type with the given
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/reload"
+	"github.com/mitchellh/cli"
+)
+
+// unixListenerUnsupported are the listener options that only make sense for
+// TCP connections
+var unixListenerUnsupported = []string{
+	"cluster_address",
+	"proxy_protocol_behavior",
+	"x_forwarded_for_authorized_addrs",
+	"x_forwarded_for_hop_skips",
+	"x_forwarded_for_reject_not_authorized",
+	"x_forwarded_for_reject_not_present",
+}
+
+func unixListenerFactory(config map[string]interface{}, _ io.Writer, ui cli.Ui) (net.Listener, map[string]string, reload.ReloadFunc, error) {
+	var addr string
+	addrRaw, ok := config["address"]
+	if !ok {
+		addr = "/run/vault.sock"
+	} else {
+		addr = addrRaw.(string)
+	}
+
+	for _, k := range unixListenerUnsupported {
+		if _, ok := config[k]; ok {
+			return nil, nil, nil, fmt.Errorf("%q is not supported by unix listeners", k)
+		}
+	}
+
+	mode, uid, gid, err := unixSocketOptions(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Remove a socket left behind by a previous run, but never any other kind
+	// of file
+	if fi, err := os.Lstat(addr); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, nil, nil, fmt.Errorf("%q exists and is not a unix socket", addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return nil, nil, nil, errwrap.Wrapf("error removing stale unix socket: {{err}}", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(addr, mode); err != nil {
+			ln.Close()
+			return nil, nil, nil, errwrap.Wrapf("error setting unix socket mode: {{err}}", err)
+		}
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(addr, uid, gid); err != nil {
+			ln.Close()
+			return nil, nil, nil, errwrap.Wrapf("error setting unix socket owner: {{err}}", err)
+		}
+	}
+
+	props := map[string]string{"addr": addr}
+	if mode != 0 {
+		props["socket_mode"] = fmt.Sprintf("%04o", mode)
+	}
+	if v, ok := config["socket_user"]; ok {
+		props["socket_user"] = fmt.Sprintf("%v", v)
+	}
+	if v, ok := config["socket_group"]; ok {
+		props["socket_group"] = fmt.Sprintf("%v", v)
+	}
+
+	// Local clients do not need TLS, so it is opt-in on unix listeners
+	if _, ok := config["tls_disable"]; !ok {
+		if _, ok := config["tls_cert_file"]; !ok {
+			config["tls_disable"] = true
+		}
+	}
+
+	return listenerWrapTLS(ln, props, config, ui)
+}
+
+// unixSocketOptions parses the file mode and ownership of the socket. A zero
+// mode leaves the mode set by the umask, and an ID of -1 leaves the owner or
+// group unchanged.
+func unixSocketOptions(config map[string]interface{}) (os.FileMode, int, int, error) {
+	var mode os.FileMode
+	if v, ok := config["socket_mode"]; ok {
+		modeStr, ok := v.(string)
+		if !ok {
+			return 0, 0, 0, fmt.Errorf("\"socket_mode\" must be a string of octal digits, e.g. \"0770\"")
+		}
+		m, err := strconv.ParseUint(modeStr, 8, 32)
+		if err != nil || m > 0777 {
+			return 0, 0, 0, fmt.Errorf("invalid \"socket_mode\" %q, must be an octal file mode, e.g. \"0770\"", modeStr)
+		}
+		mode = os.FileMode(m)
+	}
+
+	uid := -1
+	if v, ok := config["socket_user"]; ok {
+		id, err := parseutil.ParseInt(v)
+		if err != nil {
+			u, lookupErr := user.Lookup(fmt.Sprintf("%v", v))
+			if lookupErr != nil {
+				return 0, 0, 0, errwrap.Wrapf("invalid \"socket_user\": {{err}}", lookupErr)
+			}
+			id, err = strconv.ParseInt(u.Uid, 10, 64)
+			if err != nil {
+				return 0, 0, 0, errwrap.Wrapf("invalid \"socket_user\": {{err}}", err)
+			}
+		}
+		uid = int(id)
+	}
+
+	gid := -1
+	if v, ok := config["socket_group"]; ok {
+		id, err := parseutil.ParseInt(v)
+		if err != nil {
+			g, lookupErr := user.LookupGroup(fmt.Sprintf("%v", v))
+			if lookupErr != nil {
+				return 0, 0, 0, errwrap.Wrapf("invalid \"socket_group\": {{err}}", lookupErr)
+			}
+			id, err = strconv.ParseInt(g.Gid, 10, 64)
+			if err != nil {
+				return 0, 0, 0, errwrap.Wrapf("invalid \"socket_group\": {{err}}", err)
+			}
+		}
+		gid = int(id)
+	}
+
+	return mode, uid, gid, nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestUnixListener(t *testing.T) {
+	td, err := ioutil.TempDir("", "vault-test-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	socket := filepath.Join(td, "vault.sock")
+
+	// A stale socket from a previous run is replaced
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, props, _, err := unixListenerFactory(map[string]interface{}{
+		"address":      socket,
+		"socket_mode":  "0640",
+		"socket_user":  strconv.Itoa(os.Getuid()),
+		"socket_group": strconv.Itoa(os.Getgid()),
+	}, nil, cli.NewMockUi())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+
+	if props["tls"] != "disabled" {
+		t.Fatalf("expected TLS to be disabled by default, got %q", props["tls"])
+	}
+
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0640 {
+		t.Fatalf("bad socket mode: %o", perm)
+	}
+
+	connFn := func(lnReal net.Listener) (net.Conn, error) {
+		return net.Dial("unix", socket)
+	}
+
+	testListenerImpl(t, ln, connFn, "")
+}
+
+func TestUnixListener_errors(t *testing.T) {
+	td, err := ioutil.TempDir("", "vault-test-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	regular := filepath.Join(td, "regular")
+	if err := ioutil.WriteFile(regular, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{
+			"not_a_socket",
+			map[string]interface{}{"address": regular},
+			"is not a unix socket",
+		},
+		{
+			"bad_mode",
+			map[string]interface{}{"address": filepath.Join(td, "a.sock"), "socket_mode": "0999"},
+			"invalid \"socket_mode\"",
+		},
+		{
+			"unsupported_option",
+			map[string]interface{}{"address": filepath.Join(td, "b.sock"), "x_forwarded_for_authorized_addrs": "127.0.0.1"},
+			"not supported by unix listeners",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ln, _, _, err := unixListenerFactory(tc.config, nil, cli.NewMockUi())
+			if err == nil {
+				ln.Close()
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected %q to contain %q", err.Error(), tc.err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(regular); err != nil {
+		t.Fatalf("regular file should not be removed: %v", err)
+	}
+}
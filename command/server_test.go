@@ -207,6 +207,88 @@ func TestServer_ReloadListener(t *testing.T) {
 	wg.Wait()
 }
 
+func TestServer_ReloadConfig(t *testing.T) {
+	t.Parallel()
+
+	td, err := ioutil.TempDir("", "vault-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	port := testRandomPort(t)
+	writeConfig := func(extra string) {
+		hcl := fmt.Sprintf(`
+backend "file" {
+  path = "%s/data"
+}
+disable_mlock = true
+listener "tcp" {
+  address     = "127.0.0.1:%d"
+  tls_disable = "true"
+}
+%s
+`, td, port, extra)
+		if err := ioutil.WriteFile(td+"/config.hcl", []byte(hcl), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("")
+
+	ui, cmd := testServerCommand(t)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if code := cmd.Run([]string{"-config", td + "/config.hcl"}); code != 0 {
+			output := ui.ErrorWriter.String() + ui.OutputWriter.String()
+			t.Errorf("got a non-zero exit status: %s", output)
+		}
+	}()
+
+	select {
+	case <-cmd.startedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	writeConfig(fmt.Sprintf(`
+log_level         = "debug"
+default_lease_ttl = "1h"
+max_lease_ttl     = "2h"
+cache_size        = 1000
+plugin_directory  = "%s"
+`, td))
+
+	cmd.SighupCh <- struct{}{}
+	select {
+	case <-cmd.reloadedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	if !cmd.logger.IsDebug() {
+		t.Fatal("expected the log level to be reloaded")
+	}
+	if cmd.config.DefaultLeaseTTL != time.Hour || cmd.config.MaxLeaseTTL != 2*time.Hour {
+		t.Fatalf("expected the lease TTLs to be reloaded, got %s and %s", cmd.config.DefaultLeaseTTL, cmd.config.MaxLeaseTTL)
+	}
+	if cmd.config.PluginDirectory != td {
+		t.Fatalf("expected the plugin directory to be reloaded, got %q", cmd.config.PluginDirectory)
+	}
+	if cmd.config.CacheSize != 0 {
+		t.Fatalf("expected cache_size not to be reloaded, got %d", cmd.config.CacheSize)
+	}
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, "cache_size") {
+		t.Fatalf("expected a restart warning for cache_size, got %q", output)
+	}
+
+	cmd.ShutdownCh <- struct{}{}
+
+	wg.Wait()
+}
+
 func TestServer(t *testing.T) {
 	t.Parallel()
 
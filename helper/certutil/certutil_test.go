@@ -3,6 +3,7 @@ package certutil
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -37,6 +38,8 @@ func TestCertBundleConversion(t *testing.T) {
 		refreshECCertBundleWithChain(),
 		refreshEC8CertBundle(),
 		refreshEC8CertBundleWithChain(),
+		refreshEd25519CertBundle(),
+		refreshEd25519CertBundleWithChain(),
 	}
 
 	for i, cbut := range cbuts {
@@ -104,6 +107,8 @@ func TestCertBundleParsing(t *testing.T) {
 		refreshECCertBundleWithChain(),
 		refreshEC8CertBundle(),
 		refreshEC8CertBundleWithChain(),
+		refreshEd25519CertBundle(),
+		refreshEd25519CertBundleWithChain(),
 	}
 
 	for i, cbut := range cbuts {
@@ -182,6 +187,10 @@ func compareCertBundleToParsedCertBundle(cbut *CertBundle, pcbut *ParsedCertBund
 		if pcbut.PrivateKeyType != ECPrivateKey {
 			return fmt.Errorf("parsed bundle has wrong pkcs8 private key type: %v, should be 'ec' (%v)", pcbut.PrivateKeyType, ECPrivateKey)
 		}
+	case privEd25519KeyPem:
+		if pcbut.PrivateKeyType != Ed25519PrivateKey {
+			return fmt.Errorf("parsed bundle has wrong private key type: %v, should be 'ed25519' (%v)", pcbut.PrivateKeyType, Ed25519PrivateKey)
+		}
 	default:
 		return fmt.Errorf("parsed bundle has unknown private key type")
 	}
@@ -224,6 +233,10 @@ func compareCertBundleToParsedCertBundle(cbut *CertBundle, pcbut *ParsedCertBund
 		if cb.PrivateKey != privECKeyPem && cb.PrivateKey != privEC8KeyPem {
 			return fmt.Errorf("bundle private key does not match")
 		}
+	case Ed25519PrivateKey:
+		if cb.PrivateKey != privEd25519KeyPem {
+			return fmt.Errorf("bundle private key does not match")
+		}
 	default:
 		return fmt.Errorf("certBundle has unknown private key type")
 	}
@@ -248,6 +261,7 @@ func TestCSRBundleConversion(t *testing.T) {
 	csrbuts := []*CSRBundle{
 		refreshRSACSRBundle(),
 		refreshECCSRBundle(),
+		refreshEd25519CSRBundle(),
 	}
 
 	for _, csrbut := range csrbuts {
@@ -297,6 +311,10 @@ func compareCSRBundleToParsedCSRBundle(csrbut *CSRBundle, pcsrbut *ParsedCSRBund
 		if pcsrbut.PrivateKeyType != ECPrivateKey {
 			return fmt.Errorf("parsed bundle has wrong private key type")
 		}
+	case privEd25519KeyPem:
+		if pcsrbut.PrivateKeyType != Ed25519PrivateKey {
+			return fmt.Errorf("parsed bundle has wrong private key type")
+		}
 	default:
 		return fmt.Errorf("parsed bundle has unknown private key type")
 	}
@@ -328,6 +346,13 @@ func compareCSRBundleToParsedCSRBundle(csrbut *CSRBundle, pcsrbut *ParsedCSRBund
 		if csrb.PrivateKey != privECKeyPem {
 			return fmt.Errorf("bundle ec private key does not match")
 		}
+	case "ed25519":
+		if pcsrbut.PrivateKeyType != Ed25519PrivateKey {
+			return fmt.Errorf("bundle has wrong private key type")
+		}
+		if csrb.PrivateKey != privEd25519KeyPem {
+			return fmt.Errorf("bundle ed25519 private key does not match")
+		}
 	default:
 		return fmt.Errorf("bundle has unknown private key type")
 	}
@@ -486,6 +511,30 @@ func refreshEC8CertBundleWithChain() *CertBundle {
 	return ret
 }
 
+func refreshEd25519CertBundle() *CertBundle {
+	initTest.Do(setCerts)
+	return &CertBundle{
+		Certificate: certEd25519Pem,
+		PrivateKey:  privEd25519KeyPem,
+		CAChain:     []string{issuingCaChainPem[0]},
+	}
+}
+
+func refreshEd25519CertBundleWithChain() *CertBundle {
+	initTest.Do(setCerts)
+	ret := refreshEd25519CertBundle()
+	ret.CAChain = issuingCaChainPem
+	return ret
+}
+
+func refreshEd25519CSRBundle() *CSRBundle {
+	initTest.Do(setCerts)
+	return &CSRBundle{
+		CSR:        csrEd25519Pem,
+		PrivateKey: privEd25519KeyPem,
+	}
+}
+
 func setCerts() {
 	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -692,6 +741,66 @@ func setCerts() {
 		privRSA8KeyPem = strings.TrimSpace(string(pem.EncodeToMemory(keyPEMBlock)))
 	}
 
+	// Ed25519 generation
+	{
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		subjKeyID, err := GetSubjKeyID(key)
+		if err != nil {
+			panic(err)
+		}
+		certTemplate := &x509.Certificate{
+			Subject: pkix.Name{
+				CommonName: "localhost",
+			},
+			SubjectKeyId: subjKeyID,
+			DNSNames:     []string{"localhost"},
+			ExtKeyUsage: []x509.ExtKeyUsage{
+				x509.ExtKeyUsageServerAuth,
+				x509.ExtKeyUsageClientAuth,
+			},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			SerialNumber: big.NewInt(mathrand.Int63()),
+			NotBefore:    time.Now().Add(-30 * time.Second),
+			NotAfter:     time.Now().Add(262980 * time.Hour),
+		}
+		csrTemplate := &x509.CertificateRequest{
+			Subject: pkix.Name{
+				CommonName: "localhost",
+			},
+			DNSNames: []string{"localhost"},
+		}
+		csrBytes, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, key)
+		if err != nil {
+			panic(err)
+		}
+		csrPEMBlock := &pem.Block{
+			Type:  "CERTIFICATE REQUEST",
+			Bytes: csrBytes,
+		}
+		csrEd25519Pem = strings.TrimSpace(string(pem.EncodeToMemory(csrPEMBlock)))
+		certBytes, err := x509.CreateCertificate(rand.Reader, certTemplate, intCert, pub, intKey)
+		if err != nil {
+			panic(err)
+		}
+		certPEMBlock := &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: certBytes,
+		}
+		certEd25519Pem = strings.TrimSpace(string(pem.EncodeToMemory(certPEMBlock)))
+		marshaledKey, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			panic(err)
+		}
+		keyPEMBlock := &pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: marshaledKey,
+		}
+		privEd25519KeyPem = strings.TrimSpace(string(pem.EncodeToMemory(keyPEMBlock)))
+	}
+
 	issuingCaChainPem = []string{intCertPEM, caCertPEM}
 }
 
@@ -705,5 +814,8 @@ var (
 	csrECPem          string
 	privEC8KeyPem     string
 	certECPem         string
+	privEd25519KeyPem string
+	csrEd25519Pem     string
+	certEd25519Pem    string
 	issuingCaChainPem []string
 )
@@ -346,6 +346,14 @@ type Policy struct {
 	// AllowPlaintextBackup allows taking backup of the policy in plaintext
 	AllowPlaintextBackup bool `json:"allow_plaintext_backup"`
 
+	// Imported indicates whether the key material of the policy was imported
+	// rather than generated
+	Imported bool `json:"imported"`
+
+	// AllowImportedKeyRotation allows generating new versions of an imported
+	// policy through rotation
+	AllowImportedKeyRotation bool `json:"allow_imported_key_rotation"`
+
 	// VersionTemplate is used to prefix the ciphertext with information about
 	// the key version. It must inclide {{version}} and a delimiter between the
 	// version prefix and the ciphertext.
@@ -1257,6 +1265,10 @@ func (p *Policy) VerifySignature(context, input []byte, sig, hashAlgorithm strin
 }
 
 func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr error) {
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return errutil.UserError{Err: fmt.Sprintf("key %q was imported and does not allow rotation", p.Name)}
+	}
+
 	priorLatestVersion := p.LatestVersion
 	priorMinDecryptionVersion := p.MinDecryptionVersion
 	var priorKeys keyEntryMap
@@ -1310,22 +1322,9 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr er
 		if err != nil {
 			return err
 		}
-		entry.EC_D = privKey.D
-		entry.EC_X = privKey.X
-		entry.EC_Y = privKey.Y
-		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
-		if err != nil {
-			return errwrap.Wrapf("error marshaling public key: {{err}}", err)
-		}
-		pemBlock := &pem.Block{
-			Type:  "PUBLIC KEY",
-			Bytes: derBytes,
-		}
-		pemBytes := pem.EncodeToMemory(pemBlock)
-		if pemBytes == nil || len(pemBytes) == 0 {
-			return fmt.Errorf("error PEM-encoding public key")
+		if err := entry.setECDSAKey(privKey); err != nil {
+			return err
 		}
-		entry.FormattedPublicKey = string(pemBytes)
 
 	case KeyType_ED25519:
 		pub, pri, err := ed25519.GenerateKey(rand.Reader)
@@ -1365,6 +1364,137 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr er
 	return p.Persist(ctx, storage)
 }
 
+// Import adds the given key material as the latest version of the policy.
+// Keys of the symmetric types are the raw key bytes and keys of the
+// asymmetric types are PKCS #8 DER-encoded private keys.
+func (p *Policy) Import(ctx context.Context, storage logical.Storage, key []byte) (retErr error) {
+	now := time.Now()
+	entry := KeyEntry{
+		CreationTime:           now,
+		DeprecatedCreationTime: now.Unix(),
+	}
+
+	hmacKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return err
+	}
+	entry.HMACKey = hmacKey
+
+	if err := entry.parseImportedKey(p.Type, key); err != nil {
+		return err
+	}
+
+	priorLatestVersion := p.LatestVersion
+	priorMinDecryptionVersion := p.MinDecryptionVersion
+	var priorKeys keyEntryMap
+
+	if p.Keys != nil {
+		priorKeys = keyEntryMap{}
+		for k, v := range p.Keys {
+			priorKeys[k] = v
+		}
+	}
+
+	defer func() {
+		if retErr != nil {
+			p.LatestVersion = priorLatestVersion
+			p.MinDecryptionVersion = priorMinDecryptionVersion
+			p.Keys = priorKeys
+		}
+	}()
+
+	if p.Keys == nil {
+		p.Keys = keyEntryMap{}
+	}
+
+	p.LatestVersion += 1
+	p.Keys[strconv.Itoa(p.LatestVersion)] = entry
+
+	if p.MinDecryptionVersion == 0 {
+		p.MinDecryptionVersion = 1
+	}
+
+	return p.Persist(ctx, storage)
+}
+
+// parseImportedKey sets the key of the entry from imported key material of
+// the given type
+func (ke *KeyEntry) parseImportedKey(keyType KeyType, key []byte) error {
+	switch keyType {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if len(key) != 32 {
+			return errutil.UserError{Err: fmt.Sprintf("invalid key size of %d bytes for key type %v; must be 32 bytes", len(key), keyType)}
+		}
+		ke.Key = key
+		return nil
+	}
+
+	parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("failed to parse key as a PKCS #8 private key: %v", err)}
+	}
+
+	switch keyType {
+	case KeyType_ECDSA_P256:
+		privKey, ok := parsedKey.(*ecdsa.PrivateKey)
+		if !ok || privKey.Curve != elliptic.P256() {
+			return errutil.UserError{Err: fmt.Sprintf("key is not a private key of type %v", keyType)}
+		}
+		return ke.setECDSAKey(privKey)
+
+	case KeyType_ED25519:
+		// Only Ed25519 private keys have a seed. The parsed key may be of a
+		// different Ed25519 type than the one used here, so the key is
+		// rebuilt from the seed.
+		privKey, ok := parsedKey.(interface {
+			Seed() []byte
+		})
+		if !ok {
+			return errutil.UserError{Err: fmt.Sprintf("key is not a private key of type %v", keyType)}
+		}
+		ke.Key = ed25519.NewKeyFromSeed(privKey.Seed())
+		ke.FormattedPublicKey = base64.StdEncoding.EncodeToString(ed25519.PrivateKey(ke.Key).Public().(ed25519.PublicKey))
+		return nil
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		bitSize := 2048
+		if keyType == KeyType_RSA4096 {
+			bitSize = 4096
+		}
+
+		privKey, ok := parsedKey.(*rsa.PrivateKey)
+		if !ok || privKey.N.BitLen() != bitSize {
+			return errutil.UserError{Err: fmt.Sprintf("key is not a private key of type %v", keyType)}
+		}
+		ke.RSAKey = privKey
+		return nil
+
+	default:
+		return errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", keyType)}
+	}
+}
+
+// setECDSAKey sets the key of the entry and its PEM-encoded public key
+func (ke *KeyEntry) setECDSAKey(privKey *ecdsa.PrivateKey) error {
+	ke.EC_D = privKey.D
+	ke.EC_X = privKey.X
+	ke.EC_Y = privKey.Y
+	derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+	if err != nil {
+		return errwrap.Wrapf("error marshaling public key: {{err}}", err)
+	}
+	pemBlock := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}
+	pemBytes := pem.EncodeToMemory(pemBlock)
+	if pemBytes == nil || len(pemBytes) == 0 {
+		return fmt.Errorf("error PEM-encoding public key")
+	}
+	ke.FormattedPublicKey = string(pemBytes)
+	return nil
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	now := time.Now()
 	p.Keys = keyEntryMap{
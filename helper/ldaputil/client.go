@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/go-ldap/ldap"
 	"github.com/hashicorp/errwrap"
@@ -23,6 +24,8 @@ type Client struct {
 	LDAP   LDAP
 }
 
+// DialLDAP connects to the first reachable server among the comma-separated
+// URLs of the configuration, in order.
 func (c *Client) DialLDAP(cfg *ConfigEntry) (Connection, error) {
 	var retErr *multierror.Error
 	var conn Connection
@@ -58,6 +61,11 @@ func (c *Client) DialLDAP(cfg *ConfigEntry) (Connection, error) {
 					break
 				}
 				err = conn.StartTLS(tlsConfig)
+				if err != nil {
+					// Don't leak the connection that was dialed
+					conn.Close()
+					conn = nil
+				}
 			}
 		case "ldaps":
 			if port == "" {
@@ -75,10 +83,13 @@ func (c *Client) DialLDAP(cfg *ConfigEntry) (Connection, error) {
 		if err == nil {
 			if retErr != nil {
 				if c.Logger.IsDebug() {
-					c.Logger.Debug("errors connecting to some hosts: %s", retErr.Error())
+					c.Logger.Debug("errors connecting to some hosts", "error", retErr.Error(), "url", uut)
 				}
 			}
 			retErr = nil
+			if cfg.RequestTimeout > 0 {
+				conn.SetTimeout(time.Duration(cfg.RequestTimeout) * time.Second)
+			}
 			break
 		}
 		retErr = multierror.Append(retErr, errwrap.Wrapf(fmt.Sprintf("error connecting to host %q: {{err}}", uut), err))
@@ -87,6 +98,15 @@ func (c *Client) DialLDAP(cfg *ConfigEntry) (Connection, error) {
 	return conn, retErr.ErrorOrNil()
 }
 
+// search runs the search request, using the paged results control if a page
+// size is configured
+func (c *Client) search(cfg *ConfigEntry, conn Connection, searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if cfg.PageSize > 0 {
+		return conn.SearchWithPaging(searchRequest, uint32(cfg.PageSize))
+	}
+	return conn.Search(searchRequest)
+}
+
 /*
  * Discover and return the bind string for the user attempting to authenticate.
  * This is handled in one of several ways:
@@ -116,7 +136,7 @@ func (c *Client) GetUserBindDN(cfg *ConfigEntry, conn Connection, username strin
 		if c.Logger.IsDebug() {
 			c.Logger.Debug("discovering user", "userdn", cfg.UserDN, "filter", filter)
 		}
-		result, err := conn.Search(&ldap.SearchRequest{
+		result, err := c.search(cfg, conn, &ldap.SearchRequest{
 			BaseDN:    cfg.UserDN,
 			Scope:     2, // subtree
 			Filter:    filter,
@@ -151,7 +171,7 @@ func (c *Client) GetUserDN(cfg *ConfigEntry, conn Connection, bindDN string) (st
 		if c.Logger.IsDebug() {
 			c.Logger.Debug("searching upn", "userdn", cfg.UserDN, "filter", filter)
 		}
-		result, err := conn.Search(&ldap.SearchRequest{
+		result, err := c.search(cfg, conn, &ldap.SearchRequest{
 			BaseDN:    cfg.UserDN,
 			Scope:     2, // subtree
 			Filter:    filter,
@@ -185,6 +205,10 @@ func (c *Client) GetUserDN(cfg *ConfigEntry, conn Connection, bindDN string) (st
  *   cfg.GroupDN     = "OU=Groups,DC=myorg,DC=com"
  *   cfg.GroupAttr   = "cn"
  *
+ * If cfg.NestedGroups is set, the query is run again for every group found, with the DN of the
+ * group as UserDN and its name as Username, until no new groups are found. Each group is only
+ * queried once, so membership cycles are harmless.
+ *
  * NOTE - If cfg.GroupFilter is empty, no query is performed and an empty result slice is returned.
  *
  */
@@ -215,52 +239,88 @@ func (c *Client) GetLdapGroups(cfg *ConfigEntry, conn Connection, userDN string,
 		return nil, errwrap.Wrapf("LDAP search failed due to template compilation error: {{err}}", err)
 	}
 
-	// Build context to pass to template - we will be exposing UserDn and Username.
-	context := struct {
-		UserDN   string
-		Username string
-	}{
-		ldap.EscapeFilter(userDN),
-		ldap.EscapeFilter(username),
+	type groupQuery struct {
+		DN   string
+		Name string
 	}
 
-	var renderedQuery bytes.Buffer
-	t.Execute(&renderedQuery, context)
-
-	if c.Logger.IsDebug() {
-		c.Logger.Debug("searching", "groupdn", cfg.GroupDN, "rendered_query", renderedQuery.String())
+	// The DNs that were queried or are pending, used to stop on cycles
+	queried := map[string]bool{
+		strings.ToLower(userDN): true,
 	}
+	pending := []groupQuery{{DN: userDN, Name: username}}
+
+	for len(pending) > 0 {
+		query := pending[0]
+		pending = pending[1:]
+
+		// Build context to pass to template - we will be exposing UserDn and Username.
+		context := struct {
+			UserDN   string
+			Username string
+		}{
+			ldap.EscapeFilter(query.DN),
+			ldap.EscapeFilter(query.Name),
+		}
 
-	result, err := conn.Search(&ldap.SearchRequest{
-		BaseDN: cfg.GroupDN,
-		Scope:  2, // subtree
-		Filter: renderedQuery.String(),
-		Attributes: []string{
-			cfg.GroupAttr,
-		},
-		SizeLimit: math.MaxInt32,
-	})
-	if err != nil {
-		return nil, errwrap.Wrapf("LDAP search failed: {{err}}", err)
-	}
+		var renderedQuery bytes.Buffer
+		t.Execute(&renderedQuery, context)
 
-	for _, e := range result.Entries {
-		dn, err := ldap.ParseDN(e.DN)
-		if err != nil || len(dn.RDNs) == 0 {
-			continue
+		if c.Logger.IsDebug() {
+			c.Logger.Debug("searching", "groupdn", cfg.GroupDN, "rendered_query", renderedQuery.String())
 		}
 
-		// Enumerate attributes of each result, parse out CN and add as group
-		values := e.GetAttributeValues(cfg.GroupAttr)
-		if len(values) > 0 {
-			for _, val := range values {
-				groupCN := getCN(val)
+		result, err := c.search(cfg, conn, &ldap.SearchRequest{
+			BaseDN: cfg.GroupDN,
+			Scope:  2, // subtree
+			Filter: renderedQuery.String(),
+			Attributes: []string{
+				cfg.GroupAttr,
+			},
+			SizeLimit: math.MaxInt32,
+		})
+		if err != nil {
+			return nil, errwrap.Wrapf("LDAP search failed: {{err}}", err)
+		}
+
+		for _, e := range result.Entries {
+			dn, err := ldap.ParseDN(e.DN)
+			if err != nil || len(dn.RDNs) == 0 {
+				continue
+			}
+
+			// Enumerate attributes of each result, parse out CN and add as group
+			var groupDNs []string
+			values := e.GetAttributeValues(cfg.GroupAttr)
+			if len(values) > 0 {
+				for _, val := range values {
+					groupCN := getCN(val)
+					ldapMap[groupCN] = true
+					if isDN(val) {
+						groupDNs = append(groupDNs, val)
+					}
+				}
+			} else {
+				// If groupattr didn't resolve, use self (enumerating group objects)
+				groupCN := getCN(e.DN)
 				ldapMap[groupCN] = true
 			}
-		} else {
-			// If groupattr didn't resolve, use self (enumerating group objects)
-			groupCN := getCN(e.DN)
-			ldapMap[groupCN] = true
+
+			if !cfg.NestedGroups {
+				continue
+			}
+			// The values name the groups if they are DNs, such as with
+			// memberOf; otherwise the entry is the group
+			if len(groupDNs) == 0 {
+				groupDNs = []string{e.DN}
+			}
+			for _, groupDN := range groupDNs {
+				if queried[strings.ToLower(groupDN)] {
+					continue
+				}
+				queried[strings.ToLower(groupDN)] = true
+				pending = append(pending, groupQuery{DN: groupDN, Name: getCN(groupDN)})
+			}
 		}
 	}
 
@@ -307,6 +367,13 @@ func EscapeLDAPValue(input string) string {
 	return input
 }
 
+// isDN returns whether the value is a distinguished name rather than a plain
+// name
+func isDN(value string) bool {
+	parsedDN, err := ldap.ParseDN(value)
+	return err == nil && len(parsedDN.RDNs) > 0
+}
+
 /*
  * Parses a distinguished name and returns the CN portion.
  * Given a non-conforming string (such as an already-extracted CN),
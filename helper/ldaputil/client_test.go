@@ -1,7 +1,18 @@
 package ldaputil
 
 import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/go-hclog"
 )
 
 func TestLDAPEscape(t *testing.T) {
@@ -44,3 +55,170 @@ func TestGetTLSConfigs(t *testing.T) {
 		t.Fatal("expected TLS min and max version of 771 which corresponds with TLS 1.2 since TLS 1.1 and 1.0 have known vulnerabilities")
 	}
 }
+
+// testConn is a Connection to a directory in which each member DN maps to
+// the DNs of the groups it is a member of
+type testConn struct {
+	memberOf  map[string][]string
+	searches  []string
+	pageSizes []uint32
+	timeout   time.Duration
+	tlsErr    error
+	closed    bool
+}
+
+func (c *testConn) Bind(username, password string) error           { return nil }
+func (c *testConn) Close()                                         { c.closed = true }
+func (c *testConn) Modify(modifyRequest *ldap.ModifyRequest) error { return nil }
+func (c *testConn) SetTimeout(timeout time.Duration)               { c.timeout = timeout }
+func (c *testConn) StartTLS(config *tls.Config) error              { return c.tlsErr }
+func (c *testConn) UnauthenticatedBind(username string) error      { return nil }
+func (c *testConn) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	c.pageSizes = append(c.pageSizes, pagingSize)
+	return c.Search(searchRequest)
+}
+
+func (c *testConn) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	// Supports the filter (member={{.UserDN}})
+	member := strings.TrimSuffix(strings.TrimPrefix(searchRequest.Filter, "(member="), ")")
+	c.searches = append(c.searches, member)
+	result := &ldap.SearchResult{}
+	for _, group := range c.memberOf[member] {
+		result.Entries = append(result.Entries, ldap.NewEntry(group, map[string][]string{
+			"cn": []string{getCN(group)},
+		}))
+	}
+	return result, nil
+}
+
+type testLDAP struct {
+	conns map[string]*testConn
+}
+
+func (l *testLDAP) Dial(network, addr string) (Connection, error) {
+	conn, ok := l.conns[addr]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return conn, nil
+}
+
+func (l *testLDAP) DialTLS(network, addr string, config *tls.Config) (Connection, error) {
+	return l.Dial(network, addr)
+}
+
+func TestGetLdapGroups_Nested(t *testing.T) {
+	client := &Client{
+		Logger: hclog.NewNullLogger(),
+	}
+	conn := &testConn{
+		memberOf: map[string][]string{
+			"uid=alice,ou=users,dc=example,dc=com": {"CN=dev,OU=groups,DC=example,DC=com"},
+			"CN=dev,OU=groups,DC=example,DC=com":   {"CN=eng,OU=groups,DC=example,DC=com"},
+			// A cycle back to dev
+			"CN=eng,OU=groups,DC=example,DC=com": {"CN=dev,OU=groups,DC=example,DC=com", "CN=all,OU=groups,DC=example,DC=com"},
+		},
+	}
+	cfg := &ConfigEntry{
+		GroupDN:     "ou=groups,dc=example,dc=com",
+		GroupFilter: "(member={{.UserDN}})",
+		GroupAttr:   "cn",
+	}
+
+	groups, err := client.GetLdapGroups(cfg, conn, "uid=alice,ou=users,dc=example,dc=com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(groups, []string{"dev"}) {
+		t.Fatalf("bad: groups: %v", groups)
+	}
+
+	cfg.NestedGroups = true
+	cfg.PageSize = 100
+	conn.searches = nil
+	groups, err = client.GetLdapGroups(cfg, conn, "uid=alice,ou=users,dc=example,dc=com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(groups)
+	if !reflect.DeepEqual(groups, []string{"all", "dev", "eng"}) {
+		t.Fatalf("bad: groups: %v", groups)
+	}
+	// Every group is queried once
+	if len(conn.searches) != 4 {
+		t.Fatalf("bad: searches: %v", conn.searches)
+	}
+	if len(conn.pageSizes) != 4 || conn.pageSizes[0] != 100 {
+		t.Fatalf("bad: page sizes: %v", conn.pageSizes)
+	}
+}
+
+func TestDialLDAP_Failover(t *testing.T) {
+	badTLS := &testConn{tlsErr: errors.New("handshake failure")}
+	good := &testConn{}
+	client := &Client{
+		Logger: hclog.NewNullLogger(),
+		LDAP: &testLDAP{
+			conns: map[string]*testConn{
+				"ldap2.example.com:389": badTLS,
+				"ldap3.example.com:389": good,
+			},
+		},
+	}
+	cfg := testConfig()
+	cfg.Url = "ldap://ldap1.example.com,ldap://ldap2.example.com,ldap://ldap3.example.com"
+	cfg.StartTLS = true
+	cfg.RequestTimeout = 30
+
+	conn, err := client.DialLDAP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn != good || good.timeout != 30*time.Second {
+		t.Fatalf("expected the third server with a timeout: %#v", conn)
+	}
+	if !badTLS.closed {
+		t.Fatal("expected the connection that failed StartTLS to be closed")
+	}
+
+	cfg.Url = "ldap://ldap1.example.com,ldap://ldap2.example.com"
+	if _, err := client.DialLDAP(cfg); err == nil {
+		t.Fatal("expected an error when no server is reachable")
+	}
+}
+
+func TestDialLDAP_ClosedPort(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ioutil.ReadAll(conn)
+	}()
+
+	client := &Client{
+		Logger: hclog.NewNullLogger(),
+		LDAP:   NewLDAP(),
+	}
+	cfg := testConfig()
+	cfg.Url = "ldap://" + closedAddr + ",ldap://" + ln.Addr().String()
+
+	conn, err := client.DialLDAP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
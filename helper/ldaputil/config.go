@@ -21,7 +21,7 @@ func ConfigFields() map[string]*framework.FieldSchema {
 		"url": {
 			Type:        framework.TypeString,
 			Default:     "ldap://127.0.0.1",
-			Description: "LDAP URL to connect to (default: ldap://127.0.0.1). Multiple URLs can be specified by concatenating them with commas; they will be tried in-order.",
+			Description: "LDAP URL to connect to (default: ldap://127.0.0.1). Multiple URLs can be specified by concatenating them with commas; they will be tried in-order, failing over to the next URL when a server cannot be reached.",
 		},
 
 		"userdn": {
@@ -62,6 +62,24 @@ Examples: "cn" or "memberOf", etc.
 Default: cn`,
 		},
 
+		"nested_groups": {
+			Type: framework.TypeBool,
+			Description: `If true, groups are resolved transitively: <groupfilter> is
+run again for every group found, with the group's DN as UserDN and its
+name as Username, until no new groups are found.`,
+		},
+
+		"page_size": {
+			Type:        framework.TypeInt,
+			Description: "If greater than zero, searches use the paged results control with pages of this size, so that large directories don't hit server size limits.",
+		},
+
+		"request_timeout": {
+			Type:        framework.TypeDurationSecond,
+			Default:     90,
+			Description: "Timeout, in seconds, for each request to the LDAP server. A value of 0 disables the timeout. Defaults to 90 seconds.",
+		},
+
 		"upndomain": {
 			Type:        framework.TypeString,
 			Description: "Enables userPrincipalDomain login with [username]@UPNDomain (optional)",
@@ -155,6 +173,20 @@ func NewConfigEntry(d *framework.FieldData) (*ConfigEntry, error) {
 	if groupattr != "" {
 		cfg.GroupAttr = groupattr
 	}
+	nestedGroups := d.Get("nested_groups").(bool)
+	if nestedGroups {
+		cfg.NestedGroups = nestedGroups
+	}
+	pageSize := d.Get("page_size").(int)
+	if pageSize < 0 {
+		return nil, fmt.Errorf("'page_size' cannot be negative")
+	}
+	cfg.PageSize = pageSize
+	requestTimeout := d.Get("request_timeout").(int)
+	if requestTimeout < 0 {
+		return nil, fmt.Errorf("'request_timeout' cannot be negative")
+	}
+	cfg.RequestTimeout = requestTimeout
 	upndomain := d.Get("upndomain").(string)
 	if upndomain != "" {
 		cfg.UPNDomain = upndomain
@@ -252,6 +284,10 @@ type ConfigEntry struct {
 	TLSMinVersion string `json:"tls_min_version"`
 	TLSMaxVersion string `json:"tls_max_version"`
 
+	NestedGroups   bool `json:"nested_groups"`
+	PageSize       int  `json:"page_size"`
+	RequestTimeout int  `json:"request_timeout"`
+
 	// This json tag deviates from snake case because there was a past issue
 	// where the tag was being ignored, causing it to be jsonified as "CaseSensitiveNames".
 	// To continue reading in users' previously stored values,
@@ -282,6 +318,9 @@ func (c *ConfigEntry) PasswordlessMap() map[string]interface{} {
 		"discoverdn":      c.DiscoverDN,
 		"tls_min_version": c.TLSMinVersion,
 		"tls_max_version": c.TLSMaxVersion,
+		"nested_groups":   c.NestedGroups,
+		"page_size":       c.PageSize,
+		"request_timeout": c.RequestTimeout,
 	}
 	if c.CaseSensitiveNames != nil {
 		m["case_sensitive_names"] = *c.CaseSensitiveNames
@@ -2,6 +2,7 @@ package ldaputil
 
 import (
 	"crypto/tls"
+	"time"
 
 	"github.com/go-ldap/ldap"
 )
@@ -13,6 +14,8 @@ type Connection interface {
 	Close()
 	Modify(modifyRequest *ldap.ModifyRequest) error
 	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	SetTimeout(timeout time.Duration)
 	StartTLS(config *tls.Config) error
 	UnauthenticatedBind(username string) error
 }
@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/hashicorp/go-hclog"
+)
+
+// monitorBufferSize is the number of log entries buffered for each monitor
+// before further entries are dropped
+const monitorBufferSize = 512
+
+var (
+	textLevelBrackets = map[string]log.Level{
+		"[TRACE]": log.Trace,
+		"[DEBUG]": log.Debug,
+		"[INFO ]": log.Info,
+		"[WARN ]": log.Warn,
+		"[ERROR]": log.Error,
+	}
+
+	jsonLevelRe = regexp.MustCompile(`"@level":"(trace|debug|info|warn|error)"`)
+)
+
+// MonitorWriter sits between a logger and its output. Entries at or above the
+// configured level are passed through to the output, and every entry is also
+// streamed to the registered monitors whose level it meets.
+//
+// If a logger is attached, its level is lowered while a monitor requests more
+// verbose entries than the output does, so that monitoring at debug level
+// works on a server logging at info.
+type MonitorWriter struct {
+	out io.Writer
+
+	l         sync.Mutex
+	level     log.Level
+	logger    log.Logger
+	monitors  map[*Monitor]struct{}
+	lastLevel log.Level
+}
+
+// Monitor receives the log entries of a MonitorWriter at or above its level
+type Monitor struct {
+	level   log.Level
+	ch      chan []byte
+	dropped uint64
+}
+
+// NewMonitorWriter returns a MonitorWriter that writes entries at or above
+// level to out
+func NewMonitorWriter(out io.Writer, level log.Level) *MonitorWriter {
+	return &MonitorWriter{
+		out:       out,
+		level:     level,
+		monitors:  make(map[*Monitor]struct{}),
+		lastLevel: level,
+	}
+}
+
+// SetLogger attaches the logger writing to w so that its level can be
+// adjusted for monitors
+func (w *MonitorWriter) SetLogger(logger log.Logger) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.logger = logger
+	w.updateLoggerLevel()
+}
+
+// SetLevel changes the level of entries passed through to the output
+func (w *MonitorWriter) SetLevel(level log.Level) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.level = level
+	w.updateLoggerLevel()
+}
+
+// Monitor registers and returns a new monitor receiving entries at or above
+// level. It must be released with StopMonitor.
+func (w *MonitorWriter) Monitor(level log.Level) *Monitor {
+	m := &Monitor{
+		level: level,
+		ch:    make(chan []byte, monitorBufferSize),
+	}
+
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.monitors[m] = struct{}{}
+	w.updateLoggerLevel()
+
+	return m
+}
+
+// StopMonitor deregisters m and closes its channel
+func (w *MonitorWriter) StopMonitor(m *Monitor) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if _, ok := w.monitors[m]; !ok {
+		return
+	}
+	delete(w.monitors, m)
+	close(m.ch)
+	w.updateLoggerLevel()
+}
+
+// Write implements io.Writer. Monitors that cannot keep up have entries
+// dropped rather than blocking the logger.
+func (w *MonitorWriter) Write(p []byte) (int, error) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	// Large entries may be written in several chunks, in which case only the
+	// first one carries the level
+	level, ok := parseLevel(p)
+	if !ok {
+		level = w.lastLevel
+	}
+	w.lastLevel = level
+
+	for m := range w.monitors {
+		if level < m.level {
+			continue
+		}
+		entry := make([]byte, len(p))
+		copy(entry, p)
+		select {
+		case m.ch <- entry:
+		default:
+			atomic.AddUint64(&m.dropped, 1)
+		}
+	}
+
+	if level < w.level {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// updateLoggerLevel sets the attached logger to the most verbose level that
+// is needed by the output or a monitor. The lock must be held.
+func (w *MonitorWriter) updateLoggerLevel() {
+	if w.logger == nil {
+		return
+	}
+
+	level := w.level
+	for m := range w.monitors {
+		if m.level < level {
+			level = m.level
+		}
+	}
+	w.logger.SetLevel(level)
+}
+
+// Logs returns the channel log entries are delivered on. It is closed when
+// the monitor is stopped.
+func (m *Monitor) Logs() <-chan []byte {
+	return m.ch
+}
+
+// Dropped returns the number of entries dropped since it was last called
+func (m *Monitor) Dropped() uint64 {
+	return atomic.SwapUint64(&m.dropped, 0)
+}
+
+// parseLevel extracts the level of an entry in either the standard or the
+// JSON log format
+func parseLevel(p []byte) (log.Level, bool) {
+	// Standard format: "<timestamp> [LEVEL] message"
+	if i := bytes.IndexByte(p, ' '); i >= 0 && len(p) >= i+8 {
+		if level, ok := textLevelBrackets[string(p[i+1:i+8])]; ok {
+			return level, true
+		}
+	}
+
+	if m := jsonLevelRe.FindSubmatch(p); m != nil {
+		return log.LevelFromString(string(m[1])), true
+	}
+
+	return log.NoLevel, false
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+)
+
+func TestMonitorWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewMonitorWriter(&out, log.Info)
+	logger := NewVaultLoggerWithWriter(w, log.Info)
+	w.SetLogger(logger)
+
+	logger.Debug("before monitor")
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %q", out.String())
+	}
+
+	m := w.Monitor(log.Debug)
+	if !logger.IsDebug() {
+		t.Fatal("expected logger level to be lowered for the monitor")
+	}
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+
+	if strings.Contains(out.String(), "debug message") {
+		t.Fatalf("debug message should not reach the output: %q", out.String())
+	}
+	if !strings.Contains(out.String(), "info message") {
+		t.Fatalf("info message should reach the output: %q", out.String())
+	}
+
+	for _, expected := range []string{"debug message", "info message"} {
+		entry := string(<-m.Logs())
+		if !strings.Contains(entry, expected) {
+			t.Fatalf("expected %q in %q", expected, entry)
+		}
+	}
+
+	w.StopMonitor(m)
+	if _, ok := <-m.Logs(); ok {
+		t.Fatal("expected monitor channel to be closed")
+	}
+	if logger.IsDebug() {
+		t.Fatal("expected logger level to be restored")
+	}
+}
+
+func TestMonitorWriter_Dropped(t *testing.T) {
+	w := NewMonitorWriter(&bytes.Buffer{}, log.Info)
+	logger := NewVaultLoggerWithWriter(w, log.Info)
+	w.SetLogger(logger)
+
+	m := w.Monitor(log.Info)
+	defer w.StopMonitor(m)
+
+	for i := 0; i < monitorBufferSize+10; i++ {
+		logger.Info("message")
+	}
+
+	if dropped := m.Dropped(); dropped != 10 {
+		t.Fatalf("expected 10 dropped entries, got %d", dropped)
+	}
+	if dropped := m.Dropped(); dropped != 0 {
+		t.Fatalf("expected dropped count to reset, got %d", dropped)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]log.Level{
+		"2018-07-01T00:00:00.000Z [DEBUG] core: message":                 log.Debug,
+		"2018-07-01T00:00:00.000Z [WARN ] core: message":                 log.Warn,
+		`{"@level":"error","@message":"message","@module":"core"}`:       log.Error,
+		`{"@level":"trace","@message":"message","@timestamp":"2018-07"}`: log.Trace,
+	}
+	for entry, expected := range cases {
+		level, ok := parseLevel([]byte(entry))
+		if !ok || level != expected {
+			t.Errorf("%q: expected %v, got %v (%v)", entry, expected, level, ok)
+		}
+	}
+
+	if _, ok := parseLevel([]byte("continuation of a long entry")); ok {
+		t.Error("expected no level for continuation")
+	}
+}
@@ -59,6 +59,26 @@ func DuoHandler(ctx context.Context, req *logical.Request, d *framework.FieldDat
 	return duoHandler(duoConfig, duoAuthClient, request)
 }
 
+// Authenticate runs the Duo preauth and auth flow for the given user. An
+// empty method defaults to "auto", and a passcode, if given, is verified
+// instead of sending a push. A nil error means Duo allowed the login.
+func Authenticate(duoConfig *DuoConfig, duoAuthClient AuthClient, username, method, passcode, ipAddr string) error {
+	resp, err := duoHandler(duoConfig, duoAuthClient, &duoAuthRequest{
+		successResp: &logical.Response{},
+		username:    username,
+		method:      method,
+		passcode:    passcode,
+		ipAddr:      ipAddr,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return resp.Error()
+	}
+	return nil
+}
+
 type duoAuthRequest struct {
 	successResp *logical.Response
 	username    string
@@ -121,3 +121,21 @@ func TestDuoHandlerReject(t *testing.T) {
 		t.Fatalf("Testing Duo authentication gave incorrect response (expected deny, got: %v)", error)
 	}
 }
+
+func TestDuoAuthenticate(t *testing.T) {
+	duoConfig := &DuoConfig{
+		UsernameFormat: "%s",
+	}
+	if err := Authenticate(duoConfig, getDuoAuthClient(&MockClientData{}), "user", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	AuthData := &authapi.AuthResult{}
+	jsonutil.DecodeJSON([]byte(`{"Stat": "OK", "Response": {"Result": "deny", "Status_Msg": "Invalid auth"}}`), AuthData)
+	err := Authenticate(duoConfig, getDuoAuthClient(&MockClientData{
+		AuthData: AuthData,
+	}), "user", "", "", "")
+	if err == nil || !strings.Contains(err.Error(), "Invalid auth") {
+		t.Fatalf("expected a deny error, got: %v", err)
+	}
+}
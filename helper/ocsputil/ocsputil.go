@@ -0,0 +1,539 @@
+// Package ocsputil implements the parts of the Online Certificate Status
+// Protocol (RFC 6960) used by Vault: creating and parsing requests, and
+// creating and verifying basic responses.
+package ocsputil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	// Register the hashes used by OCSP
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// The status of a certificate in a response
+const (
+	Good = iota
+	Revoked
+	Unknown
+)
+
+// ResponseStatus is the status of a whole response. Responses with a status
+// other than Success don't carry certificate statuses.
+type ResponseStatus int
+
+const (
+	Success           ResponseStatus = 0
+	Malformed         ResponseStatus = 1
+	InternalError     ResponseStatus = 2
+	TryLater          ResponseStatus = 3
+	SignatureRequired ResponseStatus = 5
+	Unauthorized      ResponseStatus = 6
+)
+
+func (s ResponseStatus) String() string {
+	switch s {
+	case Success:
+		return "success"
+	case Malformed:
+		return "malformed"
+	case InternalError:
+		return "internal error"
+	case TryLater:
+		return "try later"
+	case SignatureRequired:
+		return "signature required"
+	case Unauthorized:
+		return "unauthorized"
+	}
+	return fmt.Sprintf("unknown response status %d", int(s))
+}
+
+// ResponseError is returned when parsing a response whose status is not
+// Success.
+type ResponseError struct {
+	Status ResponseStatus
+}
+
+func (r ResponseError) Error() string {
+	return "OCSP error: " + r.Status.String()
+}
+
+var (
+	oidBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+		crypto.SHA1:   asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26},
+		crypto.SHA256: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1},
+		crypto.SHA384: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2},
+		crypto.SHA512: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3},
+	}
+
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	signatureAlgorithms = []struct {
+		oid  asn1.ObjectIdentifier
+		algo x509.SignatureAlgorithm
+	}{
+		{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+		{oidSHA256WithRSA, x509.SHA256WithRSA},
+		{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+		{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+		{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}, x509.ECDSAWithSHA1},
+		{oidECDSAWithSHA256, x509.ECDSAWithSHA256},
+		{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+		{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+		{oidEd25519, x509.PureEd25519},
+	}
+)
+
+type certID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type singleRequest struct {
+	Cert certID
+}
+
+type tbsRequest struct {
+	Version       int              `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName pkix.RDNSequence `asn1:"explicit,tag:1,optional"`
+	RequestList   []singleRequest
+}
+
+type ocspRequest struct {
+	TBSRequest tbsRequest
+}
+
+type responseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+}
+
+type singleResponse struct {
+	CertID           certID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          revokedInfo      `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// Request is the certificate whose status is requested
+type Request struct {
+	HashAlgorithm  crypto.Hash
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+// MatchesIssuer returns whether the request is for a certificate issued by
+// the given certificate.
+func (r *Request) MatchesIssuer(issuer *x509.Certificate) bool {
+	nameHash, keyHash, err := issuerHashes(issuer, r.HashAlgorithm)
+	if err != nil {
+		return false
+	}
+	return string(nameHash) == string(r.IssuerNameHash) && string(keyHash) == string(r.IssuerKeyHash)
+}
+
+// Response is the status of a single certificate, as returned by a responder
+type Response struct {
+	Status           int
+	SerialNumber     *big.Int
+	ProducedAt       time.Time
+	ThisUpdate       time.Time
+	NextUpdate       time.Time
+	RevokedAt        time.Time
+	RevocationReason int
+
+	// Certificate is the delegated responder certificate included in the
+	// response, if any
+	Certificate *x509.Certificate
+
+	// IssuerHash is the hash used to identify the issuer. It is only used
+	// when creating responses and defaults to SHA1.
+	IssuerHash crypto.Hash
+}
+
+func hashOID(h crypto.Hash) (asn1.ObjectIdentifier, error) {
+	oid, ok := hashOIDs[h]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function %v", h)
+	}
+	return oid, nil
+}
+
+func hashFromOID(oid asn1.ObjectIdentifier) crypto.Hash {
+	for h, o := range hashOIDs {
+		if o.Equal(oid) {
+			return h
+		}
+	}
+	return 0
+}
+
+// issuerHashes returns the hashes of the name and public key of the issuer
+func issuerHashes(issuer *x509.Certificate, h crypto.Hash) ([]byte, []byte, error) {
+	if !h.Available() {
+		return nil, nil, fmt.Errorf("unsupported hash function %v", h)
+	}
+
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, nil, err
+	}
+
+	hash := h.New()
+	hash.Write(issuer.RawSubject)
+	nameHash := hash.Sum(nil)
+
+	hash.Reset()
+	hash.Write(publicKeyInfo.PublicKey.RightAlign())
+	keyHash := hash.Sum(nil)
+
+	return nameHash, keyHash, nil
+}
+
+func newCertID(h crypto.Hash, issuer *x509.Certificate, serial *big.Int) (certID, error) {
+	oid, err := hashOID(h)
+	if err != nil {
+		return certID{}, err
+	}
+	nameHash, keyHash, err := issuerHashes(issuer, h)
+	if err != nil {
+		return certID{}, err
+	}
+	return certID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oid,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		},
+		NameHash:      nameHash,
+		IssuerKeyHash: keyHash,
+		SerialNumber:  serial,
+	}, nil
+}
+
+// CreateRequest returns the DER encoding of a request for the status of cert
+func CreateRequest(cert, issuer *x509.Certificate) ([]byte, error) {
+	id, err := newCertID(crypto.SHA1, issuer, cert.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspRequest{
+		TBSRequest: tbsRequest{
+			RequestList: []singleRequest{
+				{Cert: id},
+			},
+		},
+	})
+}
+
+// ParseRequest parses a DER encoded request. Only the first certificate of
+// the request is returned.
+func ParseRequest(der []byte) (*Request, error) {
+	var req ocspRequest
+	rest, err := asn1.Unmarshal(der, &req)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data in OCSP request")
+	}
+	if len(req.TBSRequest.RequestList) == 0 {
+		return nil, errors.New("OCSP request contains no request body")
+	}
+
+	id := req.TBSRequest.RequestList[0].Cert
+	h := hashFromOID(id.HashAlgorithm.Algorithm)
+	if h == 0 {
+		return nil, errors.New("OCSP request uses an unknown hash function")
+	}
+	return &Request{
+		HashAlgorithm:  h,
+		IssuerNameHash: id.NameHash,
+		IssuerKeyHash:  id.IssuerKeyHash,
+		SerialNumber:   id.SerialNumber,
+	}, nil
+}
+
+// ParseResponse parses a DER encoded response and returns the status of cert.
+// The signature must be made by issuer, or by a responder certificate
+// included in the response that issuer signed for OCSP signing. If cert is
+// nil the first status of the response is returned.
+func ParseResponse(der []byte, cert, issuer *x509.Certificate) (*Response, error) {
+	var resp responseASN1
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data in OCSP response")
+	}
+	if status := ResponseStatus(resp.Status); status != Success {
+		return nil, ResponseError{Status: status}
+	}
+	if !resp.Response.ResponseType.Equal(oidBasicResponse) {
+		return nil, errors.New("unsupported OCSP response type")
+	}
+
+	var basic basicResponse
+	rest, err = asn1.Unmarshal(resp.Response.Response, &basic)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data in OCSP response")
+	}
+
+	ret := &Response{
+		ProducedAt: basic.TBSResponseData.ProducedAt,
+	}
+
+	signer := issuer
+	if len(basic.Certificates) > 0 {
+		ret.Certificate, err = x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil && !ret.Certificate.Equal(issuer) {
+			if err := ret.Certificate.CheckSignatureFrom(issuer); err != nil {
+				return nil, fmt.Errorf("OCSP responder certificate is not signed by the issuer: %v", err)
+			}
+			if !hasOCSPSigning(ret.Certificate) {
+				return nil, errors.New("OCSP responder certificate is not valid for OCSP signing")
+			}
+		}
+		signer = ret.Certificate
+	}
+	if signer == nil {
+		return nil, errors.New("no certificate to verify the OCSP response signature with")
+	}
+
+	algo := x509.UnknownSignatureAlgorithm
+	for _, sa := range signatureAlgorithms {
+		if sa.oid.Equal(basic.SignatureAlgorithm.Algorithm) {
+			algo = sa.algo
+			break
+		}
+	}
+	if err := signer.CheckSignature(algo, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response signature: %v", err)
+	}
+
+	var single *singleResponse
+	for i, r := range basic.TBSResponseData.Responses {
+		if cert == nil || r.CertID.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			single = &basic.TBSResponseData.Responses[i]
+			break
+		}
+	}
+	if single == nil {
+		return nil, errors.New("OCSP response does not contain the status of the certificate")
+	}
+
+	ret.SerialNumber = single.CertID.SerialNumber
+	ret.ThisUpdate = single.ThisUpdate
+	ret.NextUpdate = single.NextUpdate
+	switch {
+	case bool(single.Good):
+		ret.Status = Good
+	case bool(single.Unknown):
+		ret.Status = Unknown
+	default:
+		ret.Status = Revoked
+		ret.RevokedAt = single.Revoked.RevocationTime
+		ret.RevocationReason = int(single.Revoked.Reason)
+	}
+
+	return ret, nil
+}
+
+func hasOCSPSigning(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateResponse returns the DER encoding of a signed response for the status
+// given in template. The response is signed by priv, which must be the key of
+// responder. If responder is not issuer it is included in the response and
+// must have been issued by issuer for OCSP signing.
+func CreateResponse(issuer, responder *x509.Certificate, template Response, priv crypto.Signer) ([]byte, error) {
+	h := template.IssuerHash
+	if h == 0 {
+		h = crypto.SHA1
+	}
+	id, err := newCertID(h, issuer, template.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	single := singleResponse{
+		CertID:     id,
+		ThisUpdate: template.ThisUpdate.UTC(),
+		NextUpdate: template.NextUpdate.UTC(),
+	}
+	switch template.Status {
+	case Good:
+		single.Good = true
+	case Unknown:
+		single.Unknown = true
+	case Revoked:
+		single.Revoked = revokedInfo{
+			RevocationTime: template.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(template.RevocationReason),
+		}
+	default:
+		return nil, fmt.Errorf("unknown certificate status %d", template.Status)
+	}
+
+	// The responder is identified by the hash of its public key
+	_, keyHash, err := issuerHashes(responder, crypto.SHA1)
+	if err != nil {
+		return nil, err
+	}
+	responderID, err := asn1.Marshal(keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	producedAt := template.ProducedAt
+	if producedAt.IsZero() {
+		producedAt = time.Now()
+	}
+	tbs := responseData{
+		RawResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        2,
+			IsCompound: true,
+			Bytes:      responderID,
+		},
+		ProducedAt: producedAt.UTC().Truncate(time.Second),
+		Responses:  []singleResponse{single},
+	}
+	tbsBytes, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	var sigAlgo pkix.AlgorithmIdentifier
+	hash := crypto.SHA256
+	switch priv.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm:  oidSHA256WithRSA,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		}
+	case *ecdsa.PublicKey:
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm: oidECDSAWithSHA256,
+		}
+	case ed25519.PublicKey:
+		// Ed25519 signs the message itself rather than a digest
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm: oidEd25519,
+		}
+		hash = crypto.Hash(0)
+	default:
+		return nil, errors.New("unsupported OCSP signing key type")
+	}
+	signed := tbsBytes
+	if hash != 0 {
+		digest := hash.New()
+		digest.Write(tbsBytes)
+		signed = digest.Sum(nil)
+	}
+	signature, err := priv.Sign(rand.Reader, signed, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	basic := basicResponse{
+		TBSResponseData:    tbs,
+		SignatureAlgorithm: sigAlgo,
+		Signature: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	}
+	if !responder.Equal(issuer) {
+		basic.Certificates = []asn1.RawValue{
+			{FullBytes: responder.Raw},
+		}
+	}
+	basicBytes, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(responseASN1{
+		Status: asn1.Enumerated(Success),
+		Response: responseBytes{
+			ResponseType: oidBasicResponse,
+			Response:     basicBytes,
+		},
+	})
+}
+
+// CreateErrorResponse returns the DER encoding of an unsigned response with
+// the given status.
+func CreateErrorResponse(status ResponseStatus) []byte {
+	// An enumerated value in a sequence, which cannot fail to encode
+	der, _ := asn1.Marshal(struct {
+		Status asn1.Enumerated
+	}{
+		Status: asn1.Enumerated(status),
+	})
+	return der
+}
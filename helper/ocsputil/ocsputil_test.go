@@ -0,0 +1,169 @@
+package ocsputil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) *x509.Certificate {
+	t.Helper()
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestOCSP_RequestResponse(t *testing.T) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := testCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, caKey.Public(), caKey)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, ca, leafKey.Public(), caKey)
+
+	responderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(43),
+		Subject:      pkix.Name{CommonName: "responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, ca, responderKey.Public(), caKey)
+
+	reqDER, err := CreateRequest(leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := ParseRequest(reqDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.SerialNumber.Cmp(leaf.SerialNumber) != 0 || !req.MatchesIssuer(ca) || req.MatchesIssuer(leaf) {
+		t.Fatalf("bad: request: %#v", req)
+	}
+
+	thisUpdate := time.Now().Truncate(time.Second)
+	revokedAt := thisUpdate.Add(-time.Minute)
+
+	// Signed by the issuer
+	respDER, err := CreateResponse(ca, ca, Response{
+		Status:           Revoked,
+		SerialNumber:     leaf.SerialNumber,
+		ThisUpdate:       thisUpdate,
+		NextUpdate:       thisUpdate.Add(time.Hour),
+		RevokedAt:        revokedAt,
+		RevocationReason: 1,
+	}, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ParseResponse(respDER, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != Revoked || !resp.RevokedAt.Equal(revokedAt) || resp.RevocationReason != 1 ||
+		!resp.ThisUpdate.Equal(thisUpdate) || !resp.NextUpdate.Equal(thisUpdate.Add(time.Hour)) || resp.Certificate != nil {
+		t.Fatalf("bad: response: %#v", resp)
+	}
+
+	// Signed by a delegated responder
+	respDER, err = CreateResponse(ca, responder, Response{
+		Status:       Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   thisUpdate,
+	}, responderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = ParseResponse(respDER, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != Good || !resp.NextUpdate.IsZero() || !resp.Certificate.Equal(responder) {
+		t.Fatalf("bad: response: %#v", resp)
+	}
+
+	// Signed by a delegated responder with an Ed25519 key
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edResponder := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(44),
+		Subject:      pkix.Name{CommonName: "ed25519 responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, ca, edPub, caKey)
+	respDER, err = CreateResponse(ca, edResponder, Response{
+		Status:       Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   thisUpdate,
+	}, edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = ParseResponse(respDER, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != Good || !resp.Certificate.Equal(edResponder) {
+		t.Fatalf("bad: response: %#v", resp)
+	}
+
+	// Responders must be authorized by the issuer
+	respDER, err = CreateResponse(ca, leaf, Response{
+		Status:       Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   thisUpdate,
+	}, leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseResponse(respDER, leaf, ca); err == nil {
+		t.Fatal("expected an error for an unauthorized responder")
+	}
+
+	_, err = ParseResponse(CreateErrorResponse(TryLater), leaf, ca)
+	if respErr, ok := err.(ResponseError); !ok || respErr.Status != TryLater {
+		t.Fatalf("bad: err: %v", err)
+	}
+}
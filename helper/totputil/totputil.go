@@ -0,0 +1,63 @@
+// Package totputil contains the TOTP helpers shared by the TOTP secrets
+// engine and the TOTP login MFA method.
+package totputil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	otplib "github.com/pquerna/otp"
+)
+
+// ParseDigits translates a number of digits to a format the totp library
+// understands.
+func ParseDigits(digits int) (otplib.Digits, error) {
+	switch digits {
+	case 6:
+		return otplib.DigitsSix, nil
+	case 8:
+		return otplib.DigitsEight, nil
+	default:
+		return 0, fmt.Errorf("the digits value can only be 6 or 8")
+	}
+}
+
+// ParseAlgorithm translates an algorithm name to a format the totp library
+// understands.
+func ParseAlgorithm(algorithm string) (otplib.Algorithm, error) {
+	switch algorithm {
+	case "SHA1":
+		return otplib.AlgorithmSHA1, nil
+	case "SHA256":
+		return otplib.AlgorithmSHA256, nil
+	case "SHA512":
+		return otplib.AlgorithmSHA512, nil
+	default:
+		return 0, fmt.Errorf("the algorithm value is not valid")
+	}
+}
+
+// Barcode returns the base64 encoded PNG of a square QR code of the given
+// pixel size for the key.
+func Barcode(key *otplib.Key, size int) (string, error) {
+	barcode, err := key.Image(size, size)
+	if err != nil {
+		return "", errwrap.Wrapf("failed to generate QR code image: {{err}}", err)
+	}
+
+	var buff bytes.Buffer
+	png.Encode(&buff, barcode)
+	return base64.StdEncoding.EncodeToString(buff.Bytes()), nil
+}
+
+// UsedCodeTTL returns how long a validated code must be remembered to
+// prevent its reuse. It takes the key skew, adds two for behind and in
+// front, and multiplies that by the period to cover the full possibility of
+// the validity of the code.
+func UsedCodeTTL(period, skew uint) time.Duration {
+	return time.Duration(int64(time.Second) * int64(period) * int64(2+skew))
+}
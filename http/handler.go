@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/textproto"
@@ -82,6 +83,7 @@ func Handler(props *vault.HandlerProperties) http.Handler {
 	mux.Handle("/v1/sys/unseal", handleSysUnseal(core))
 	mux.Handle("/v1/sys/leader", handleSysLeader(core))
 	mux.Handle("/v1/sys/health", handleSysHealth(core))
+	mux.Handle("/v1/sys/monitor", handleSysMonitor(core))
 	mux.Handle("/v1/sys/generate-root/attempt", handleRequestForwarding(core, handleSysGenerateRootAttempt(core, vault.GenerateStandardRootTokenStrategy)))
 	mux.Handle("/v1/sys/generate-root/update", handleRequestForwarding(core, handleSysGenerateRootUpdate(core, vault.GenerateStandardRootTokenStrategy)))
 	mux.Handle("/v1/sys/rekey/init", handleRequestForwarding(core, handleSysRekeyInit(core, false)))
@@ -98,14 +100,14 @@ func Handler(props *vault.HandlerProperties) http.Handler {
 	}
 	mux.Handle("/v1/sys/", handleRequestForwarding(core, handleLogical(core, false, nil)))
 	mux.Handle("/v1/", handleRequestForwarding(core, handleLogical(core, false, nil)))
-	if core.UIEnabled() == true {
-		if uiBuiltIn {
-			mux.Handle("/ui/", http.StripPrefix("/ui/", gziphandler.GzipHandler(handleUIHeaders(core, handleUI(http.FileServer(&UIAssetWrapper{FileSystem: assetFS()}))))))
-		} else {
-			mux.Handle("/ui/", handleUIHeaders(core, handleUIStub()))
-		}
-		mux.Handle("/", handleRootRedirect())
+	// The UI routes are always registered since the UI can be enabled or
+	// disabled when the server configuration is reloaded
+	if uiBuiltIn {
+		mux.Handle("/ui/", handleUIEnabled(core, http.StripPrefix("/ui/", gziphandler.GzipHandler(handleUIHeaders(core, handleUI(http.FileServer(&UIAssetWrapper{FileSystem: assetFS()})))))))
+	} else {
+		mux.Handle("/ui/", handleUIEnabled(core, handleUIHeaders(core, handleUIStub())))
 	}
+	mux.Handle("/", handleUIEnabled(core, handleRootRedirect()))
 
 	// Wrap the handler in another handler to trigger all help paths.
 	helpWrappedHandler := wrapHelpHandler(mux, core)
@@ -140,8 +142,13 @@ func wrapGenericHandler(h http.Handler, maxRequestSize int64, maxRequestDuration
 		// Start with the request context
 		ctx := r.Context()
 		var cancelFunc context.CancelFunc
-		// Add our timeout
-		ctx, cancelFunc = context.WithTimeout(ctx, maxRequestDuration)
+		// Add our timeout, except for log streams which are expected to be
+		// long lived
+		if r.URL.Path == "/v1/sys/monitor" {
+			ctx, cancelFunc = context.WithCancel(ctx)
+		} else {
+			ctx, cancelFunc = context.WithTimeout(ctx, maxRequestDuration)
+		}
 		// Add a size limiter if desired
 		if maxRequestSize > 0 {
 			ctx = context.WithValue(ctx, "max_request_size", maxRequestSize)
@@ -275,6 +282,18 @@ func stripPrefix(prefix, path string) (string, bool) {
 	return path, true
 }
 
+// handleUIEnabled responds as if the route did not exist while the UI is
+// disabled
+func handleUIEnabled(core *vault.Core, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !core.UIEnabled() {
+			http.NotFound(w, req)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
 func handleUIHeaders(core *vault.Core, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		header := w.Header()
@@ -347,6 +366,31 @@ func (fs *UIAssetWrapper) Open(name string) (http.File, error) {
 }
 
 func parseRequest(r *http.Request, w http.ResponseWriter, out interface{}) error {
+	reader, err := requestBodyReader(r, w)
+	if err != nil {
+		return err
+	}
+	err = jsonutil.DecodeJSONFromReader(reader, out)
+	if err != nil && err != io.EOF {
+		return errwrap.Wrapf("failed to parse JSON input: {{err}}", err)
+	}
+	return err
+}
+
+// parseRawRequest returns the body of a request that is not JSON
+func parseRawRequest(r *http.Request, w http.ResponseWriter) ([]byte, error) {
+	reader, err := requestBodyReader(r, w)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read request body: {{err}}", err)
+	}
+	return body, nil
+}
+
+func requestBodyReader(r *http.Request, w http.ResponseWriter) (io.Reader, error) {
 	// Limit the maximum number of bytes to MaxRequestSize to protect
 	// against an indefinite amount of data being read.
 	reader := r.Body
@@ -355,17 +399,13 @@ func parseRequest(r *http.Request, w http.ResponseWriter, out interface{}) error
 	if maxRequestSize != nil {
 		max, ok := maxRequestSize.(int64)
 		if !ok {
-			return errors.New("could not parse max_request_size from request context")
+			return nil, errors.New("could not parse max_request_size from request context")
 		}
 		if max > 0 {
 			reader = http.MaxBytesReader(w, r.Body, max)
 		}
 	}
-	err := jsonutil.DecodeJSONFromReader(reader, out)
-	if err != nil && err != io.EOF {
-		return errwrap.Wrapf("failed to parse JSON input: {{err}}", err)
-	}
-	return err
+	return reader, nil
 }
 
 // handleRequestForwarding determines whether to forward a request or not,
@@ -18,6 +18,26 @@ import (
 
 type PrepareRequestFunc func(*vault.Core, *logical.Request) error
 
+// ocspRequestContentType is the content type of OCSP requests sent by POST,
+// whose bodies are not JSON
+const ocspRequestContentType = "application/ocsp-request"
+
+// isOCSPRequest returns whether the request is an OCSP request sent to the
+// ocsp path of a PKI mount. Requests to any other path are parsed as JSON,
+// whatever their content type.
+func isOCSPRequest(core *vault.Core, r *http.Request, path string) bool {
+	if r.Header.Get("Content-Type") != ocspRequestContentType {
+		return false
+	}
+	return isPKIPath(core, path, "ocsp")
+}
+
+// isPKIPath returns whether the path is the given path of a PKI mount
+func isPKIPath(core *vault.Core, path, pkiPath string) bool {
+	mount, mountType, ok := core.MatchingMountType(path)
+	return ok && mountType == "pki" && strings.TrimPrefix(path, mount) == pkiPath
+}
+
 func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) (*logical.Request, int, error) {
 	// Determine the path...
 	if !strings.HasPrefix(r.URL.Path, "/v1/") {
@@ -33,6 +53,13 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	switch r.Method {
 	case "DELETE":
 		op = logical.DeleteOperation
+	case "HEAD":
+		// ACME clients fetch new nonces with HEAD requests, which are not
+		// supported anywhere else
+		if !isPKIPath(core, path, "acme/new-nonce") {
+			return nil, http.StatusMethodNotAllowed, nil
+		}
+		op = logical.ReadOperation
 	case "GET":
 		op = logical.ReadOperation
 		// Need to call ParseForm to get query params loaded
@@ -64,7 +91,17 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 
 	// Parse the request if we can
 	var data map[string]interface{}
-	if op == logical.UpdateOperation {
+	switch {
+	case op == logical.UpdateOperation && isOCSPRequest(core, r, path):
+		// OCSP requests are DER encoded, so they are passed on as they are
+		body, err := parseRawRequest(r, w)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		data = map[string]interface{}{
+			logical.HTTPRawBody: body,
+		}
+	case op == logical.UpdateOperation:
 		err := parseRequest(r, w, &data)
 		if err == io.EOF {
 			data = nil
@@ -290,6 +327,25 @@ func respondRaw(w http.ResponseWriter, r *http.Request, resp *logical.Response)
 	}
 
 WRITE_RESPONSE:
+	// Set any additional headers
+	if headersRaw, ok := resp.Data[logical.HTTPRawHeaders]; ok {
+		var headers map[string][]string
+		switch headersRaw.(type) {
+		case http.Header:
+			headers = headersRaw.(http.Header)
+		case map[string][]string:
+			headers = headersRaw.(map[string][]string)
+		default:
+			retErr(w, "cannot decode headers")
+			return
+		}
+		for k, values := range headers {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+
 	// Write the response
 	if contentType != "" {
 		w.Header().Set("Content-Type", contentType)
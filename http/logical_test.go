@@ -253,6 +253,62 @@ func TestLogical_RawHTTP(t *testing.T) {
 	}
 }
 
+func TestLogical_OCSPContentTypeOutsidePKI(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	// Only the ocsp path of PKI mounts gets the raw body, other paths parse
+	// the body as JSON
+	req, err := http.NewRequest("PUT", addr+"/v1/secret/foo", strings.NewReader(`{"data": "bar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/secret/foo")
+	testResponseStatus(t, resp, 200)
+	var actual map[string]interface{}
+	testResponseBody(t, resp, &actual)
+	expected := map[string]interface{}{
+		"data": "bar",
+	}
+	if !reflect.DeepEqual(actual["data"], expected) {
+		t.Fatalf("bad: %#v", actual["data"])
+	}
+}
+
+func TestLogical_HeadNotAllowed(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/secret/foo", map[string]interface{}{
+		"data": "bar",
+	})
+	testResponseStatus(t, resp, 204)
+
+	// HEAD is only supported for ACME nonces
+	req, err := http.NewRequest("HEAD", addr+"/v1/secret/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResponseStatus(t, resp, 405)
+}
+
 func TestLogical_RequestSizeLimit(t *testing.T) {
 	core, _, token := vault.TestCoreUnsealed(t)
 	ln, addr := TestServer(t, core)
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/vault"
+)
+
+// monitorKeepaliveInterval is how often an idle log stream is flushed so
+// that proxies do not time out the connection
+const monitorKeepaliveInterval = 10 * time.Second
+
+// handleSysMonitor streams the log of this node. Unlike other endpoints it is
+// never forwarded to the active node, so that any node can be tailed.
+func handleSysMonitor(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handleSysMonitorGet(core, w, r)
+		default:
+			respondError(w, http.StatusMethodNotAllowed, nil)
+		}
+	})
+}
+
+func handleSysMonitorGet(core *vault.Core, w http.ResponseWriter, r *http.Request) {
+	levelStr := r.URL.Query().Get("log_level")
+	if levelStr == "" {
+		levelStr = "info"
+	}
+	level := log.LevelFromString(levelStr)
+	if level == log.NoLevel {
+		respondError(w, http.StatusBadRequest, fmt.Errorf("unknown log level %q", levelStr))
+		return
+	}
+
+	monitor := core.LogMonitor()
+	if monitor == nil {
+		respondError(w, http.StatusNotImplemented, fmt.Errorf("log monitoring is not available on this node"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	if !authorizeSysMonitor(core, w, r) {
+		return
+	}
+
+	m := monitor.Monitor(level)
+	defer monitor.StopMonitor(m)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(monitorKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			flusher.Flush()
+		case entry, ok := <-m.Logs():
+			if !ok {
+				return
+			}
+			if dropped := m.Dropped(); dropped > 0 {
+				fmt.Fprintf(w, "[monitor] %d log entries dropped, the client is not keeping up\n", dropped)
+			}
+			if _, err := w.Write(entry); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// authorizeSysMonitor checks that the request may stream the log, responding
+// with an error if it may not. The check is a read of the sys/monitor path,
+// which requires sudo. Standbys cannot check tokens themselves, so they ask
+// the active node for the capabilities of the token on that path instead.
+func authorizeSysMonitor(core *vault.Core, w http.ResponseWriter, r *http.Request) bool {
+	req, statusCode, err := buildLogicalRequest(core, w, r)
+	if err != nil || statusCode != 0 {
+		respondError(w, statusCode, err)
+		return false
+	}
+
+	resp, err := core.HandleRequest(r.Context(), req)
+	if err == nil {
+		return true
+	}
+	if !errwrap.Contains(err, consts.ErrStandby.Error()) {
+		respondErrorCommon(w, req, resp, err)
+		return false
+	}
+
+	capabilities, err := sysMonitorCapabilitiesFromActive(core, r)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errwrap.Wrapf("error checking permissions with the active node: {{err}}", err))
+		return false
+	}
+	if strutil.StrListContains(capabilities, "root") ||
+		(strutil.StrListContains(capabilities, "read") && strutil.StrListContains(capabilities, "sudo")) {
+		return true
+	}
+
+	respondError(w, http.StatusForbidden, fmt.Errorf("permission denied"))
+	return false
+}
+
+// sysMonitorCapabilitiesFromActive returns the capabilities of the request's
+// token on sys/monitor, as reported by the active node
+func sysMonitorCapabilitiesFromActive(core *vault.Core, r *http.Request) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"paths": []string{"sys/monitor"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fwdReq, err := http.NewRequest("POST", "/v1/sys/capabilities-self", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	fwdReq.Host = r.Host
+	fwdReq.RemoteAddr = r.RemoteAddr
+	fwdReq.TLS = r.TLS
+	fwdReq.Header.Set(AuthHeaderName, r.Header.Get(AuthHeaderName))
+
+	statusCode, _, respBody, err := core.ForwardRequest(fwdReq)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		// Any failure, e.g. an invalid token, means no capabilities
+		return nil, nil
+	}
+
+	var result struct {
+		Data map[string][]string `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, err
+	}
+	return result.Data["sys/monitor"], nil
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestSysMonitor_standby(t *testing.T) {
+	monitor := logging.NewMonitorWriter(ioutil.Discard, log.Info)
+	logger := logging.NewVaultLoggerWithWriter(monitor, log.Info)
+	monitor.SetLogger(logger)
+
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+		Logger:     logger,
+		LogMonitor: monitor,
+	}, &vault.TestClusterOptions{
+		HandlerFunc: Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+
+	secret, err := cluster.Cores[0].Client.Auth().Token().Create(&api.TokenCreateRequest{
+		Policies: []string{"default"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Streams are served by the node itself, so the standby has to check
+	// the token with the active node
+	standby, err := cluster.Cores[1].Client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	standby.SetToken(cluster.RootToken)
+	if _, err := standby.Sys().Monitor(ctx, "info"); err != nil {
+		t.Fatalf("expected root token to be allowed: %v", err)
+	}
+
+	standby.SetToken(secret.Auth.ClientToken)
+	_, err = standby.Sys().Monitor(ctx, "info")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+
+	standby.SetToken(cluster.RootToken)
+	_, err = standby.Sys().Monitor(ctx, "bogus")
+	if err == nil || !strings.Contains(err.Error(), "unknown log level") {
+		t.Fatalf("expected unknown log level error, got: %v", err)
+	}
+}
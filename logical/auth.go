@@ -85,8 +85,31 @@ type Auth struct {
 	// change the perceived path of the lease, even though they don't change
 	// the request path itself.
 	CreationPath string `json:"creation_path"`
+
+	// MFARequirement is set by the core instead of a client token when the
+	// login must first be completed by validating MFA methods through
+	// sys/mfa/validate
+	MFARequirement *MFARequirement `json:"mfa_requirement"`
 }
 
 func (a *Auth) GoString() string {
 	return fmt.Sprintf("*%#v", *a)
 }
+
+// MFARequirement describes the MFA methods that must be validated to
+// complete a login
+type MFARequirement struct {
+	// MFARequestID identifies the pending login in sys/mfa/validate
+	MFARequestID string `json:"mfa_request_id"`
+
+	// MFAMethods are the methods that all need to be validated
+	MFAMethods []*MFAMethodID `json:"mfa_methods"`
+}
+
+// MFAMethodID identifies an MFA method and tells clients whether a passcode
+// has to be collected for it
+type MFAMethodID struct {
+	Type         string `json:"type"`
+	Name         string `json:"name"`
+	UsesPasscode bool   `json:"uses_passcode"`
+}
@@ -25,6 +25,12 @@ const (
 	// avoided like the HTTPContentType. The value must be an integer.
 	HTTPStatusCode = "http_status_code"
 
+	// HTTPRawHeaders are additional headers of the HTTP response that goes
+	// with the HTTPContentType. This can only be specified for non-secrets,
+	// and should be similarly avoided like the HTTPContentType. The value
+	// must be a map[string][]string, such as an http.Header.
+	HTTPRawHeaders = "http_raw_headers"
+
 	// For unwrapping we may need to know whether the value contained in the
 	// raw body is already JSON-unmarshaled. The presence of this key indicates
 	// that it has already been unmarshaled. That way we don't need to simply
@@ -36,6 +36,7 @@ func LogicalResponseToHTTPResponse(input *Response) *HTTPResponse {
 			LeaseDuration:    int(input.Auth.TTL.Seconds()),
 			Renewable:        input.Auth.Renewable,
 			EntityID:         input.Auth.EntityID,
+			MFARequirement:   input.Auth.MFARequirement,
 		}
 	}
 
@@ -65,6 +66,7 @@ func HTTPResponseToLogicalResponse(input *HTTPResponse) *Response {
 			IdentityPolicies: input.Auth.IdentityPolicies,
 			Metadata:         input.Auth.Metadata,
 			EntityID:         input.Auth.EntityID,
+			MFARequirement:   input.Auth.MFARequirement,
 		}
 		logicalResp.Auth.Renewable = input.Auth.Renewable
 		logicalResp.Auth.TTL = time.Second * time.Duration(input.Auth.LeaseDuration)
@@ -94,6 +96,7 @@ type HTTPAuth struct {
 	LeaseDuration    int               `json:"lease_duration"`
 	Renewable        bool              `json:"renewable"`
 	EntityID         string            `json:"entity_id"`
+	MFARequirement   *MFARequirement   `json:"mfa_requirement,omitempty"`
 }
 
 type HTTPWrapInfo struct {
@@ -271,6 +271,9 @@ type Core struct {
 	// metrics emission and sealing leading to a nil pointer
 	metricsMutex sync.Mutex
 
+	// leaseTTLLock protects the system lease TTLs, which can be changed
+	// when the server configuration is reloaded
+	leaseTTLLock    sync.RWMutex
 	defaultLeaseTTL time.Duration
 	maxLeaseTTL     time.Duration
 
@@ -364,6 +367,13 @@ type Core struct {
 	// rawEnabled indicates whether the Raw endpoint is enabled
 	rawEnabled bool
 
+	// metricSink is the in-memory telemetry sink served by sys/metrics; it
+	// may be nil
+	metricSink *metrics.InmemSink
+
+	// logMonitor streams the server log to sys/monitor; it may be nil
+	logMonitor *logging.MonitorWriter
+
 	// pluginDirectory is the location vault will look for plugin binaries
 	pluginDirectory string
 
@@ -433,6 +443,12 @@ type CoreConfig struct {
 
 	PluginDirectory string `json:"plugin_directory" structs:"plugin_directory" mapstructure:"plugin_directory"`
 
+	// MetricSink is the in-memory telemetry sink exposed via sys/metrics
+	MetricSink *metrics.InmemSink
+
+	// LogMonitor is the writer of the server log, streamed via sys/monitor
+	LogMonitor *logging.MonitorWriter
+
 	ReloadFuncs     *map[string][]reload.ReloadFunc
 	ReloadFuncsLock *sync.RWMutex
 }
@@ -492,6 +508,8 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		clusterPeerClusterAddrsCache:     cache.New(3*HeartbeatInterval, time.Second),
 		enableMlock:                      !conf.DisableMlock,
 		rawEnabled:                       conf.EnableRaw,
+		metricSink:                       conf.MetricSink,
+		logMonitor:                       conf.LogMonitor,
 		replicationState:                 new(uint32),
 		rpcServerActive:                  new(uint32),
 		atomicPrimaryClusterAddrs:        new(atomic.Value),
@@ -1105,6 +1123,60 @@ func (c *Core) UIEnabled() bool {
 	return c.uiConfig.Enabled()
 }
 
+// SetUIEnabled enables or disables the UI
+func (c *Core) SetUIEnabled(enabled bool) {
+	c.uiConfig.SetEnabled(enabled)
+}
+
+// leaseTTLs returns the system default and max lease TTLs
+func (c *Core) leaseTTLs() (time.Duration, time.Duration) {
+	c.leaseTTLLock.RLock()
+	defer c.leaseTTLLock.RUnlock()
+	return c.defaultLeaseTTL, c.maxLeaseTTL
+}
+
+// SetLeaseTTLs changes the system default and max lease TTLs. Zero values
+// select the built-in defaults, as they do in CoreConfig. Existing leases are
+// not affected.
+func (c *Core) SetLeaseTTLs(defaultTTL, maxTTL time.Duration) error {
+	if defaultTTL == 0 {
+		defaultTTL = defaultLeaseTTL
+	}
+	if maxTTL == 0 {
+		maxTTL = maxLeaseTTL
+	}
+	if defaultTTL > maxTTL {
+		return fmt.Errorf("cannot have DefaultLeaseTTL larger than MaxLeaseTTL")
+	}
+
+	c.leaseTTLLock.Lock()
+	defer c.leaseTTLLock.Unlock()
+	c.defaultLeaseTTL = defaultTTL
+	c.maxLeaseTTL = maxTTL
+	return nil
+}
+
+// SetPluginDirectory changes the directory external plugins are run from.
+// Plugins that are already running are not affected.
+func (c *Core) SetPluginDirectory(dir string) error {
+	if dir != "" {
+		var err error
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return errwrap.Wrapf("could not verify plugin directory: {{err}}", err)
+		}
+	}
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	c.pluginDirectory = dir
+	if c.pluginCatalog != nil {
+		c.pluginCatalog.setDirectory(dir)
+	}
+	return nil
+}
+
 // UIHeaders returns configured UI headers
 func (c *Core) UIHeaders() (http.Header, error) {
 	return c.uiConfig.Headers(context.Background())
@@ -1410,6 +1482,12 @@ func (c *Core) Logger() log.Logger {
 	return c.logger
 }
 
+// LogMonitor returns the writer used to stream the server log, or nil if the
+// server log cannot be monitored
+func (c *Core) LogMonitor() *logging.MonitorWriter {
+	return c.logMonitor
+}
+
 func (c *Core) BarrierKeyLength() (min, max int) {
 	min, max = c.barrier.KeyLength()
 	max += shamir.ShareOverhead
@@ -1436,6 +1514,12 @@ func (c *Core) RouterAccess() *RouterAccess {
 	return NewRouterAccess(c)
 }
 
+// MatchingMountType returns the prefix and the type of the mount that serves
+// the API path
+func (c *Core) MatchingMountType(path string) (string, string, bool) {
+	return c.router.MatchingMountType(path)
+}
+
 // IsDRSecondary returns if the current cluster state is a DR secondary.
 func (c *Core) IsDRSecondary() bool {
 	return c.ReplicationState().HasState(consts.ReplicationDRSecondary)
@@ -4,15 +4,18 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/hashicorp/errwrap"
 	log "github.com/hashicorp/go-hclog"
 	memdb "github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/mfa/duo"
 	"github.com/hashicorp/vault/helper/storagepacker"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 const (
@@ -34,10 +37,13 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 	}
 
 	iStore := &IdentityStore{
-		view:   config.StorageView,
-		db:     db,
-		logger: logger,
-		core:   core,
+		view:             config.StorageView,
+		db:               db,
+		logger:           logger,
+		core:             core,
+		mfaPendingLogins: cache.New(mfaPendingLoginTTL, time.Minute),
+		mfaUsedCodes:     cache.New(0, 30*time.Second),
+		mfaDuoAuthClient: duo.NewAuthClient,
 	}
 
 	iStore.entityPacker, err = storagepacker.NewStoragePacker(iStore.view, iStore.logger, "")
@@ -59,8 +65,21 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 			groupPaths(iStore),
 			lookupPaths(iStore),
 			upgradePaths(iStore),
+			oidcPaths(iStore),
+			exportPaths(iStore),
+			mfaPaths(iStore),
 		),
-		Invalidate: iStore.Invalidate,
+		PathsSpecial: &logical.Paths{
+			Root: []string{
+				"export",
+				"import",
+			},
+			Unauthenticated: []string{
+				"oidc/.well-known/*",
+			},
+		},
+		PeriodicFunc: iStore.oidcPeriodicFunc,
+		Invalidate:   iStore.Invalidate,
 	}
 
 	err = iStore.Setup(ctx, config)
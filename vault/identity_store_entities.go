@@ -18,6 +18,8 @@ import (
 // Following are the paths supported:
 // entity - To register a new entity
 // entity/id - To lookup, modify, delete and list entities based on ID
+// entity/name - To lookup, modify, delete and list entities based on name
+// entity/batch-delete - To delete entities in bulk based on ID
 // entity/merge - To merge entities based on ID
 func entityPaths(i *IdentityStore) []*framework.Path {
 	return []*framework.Path{
@@ -102,6 +104,63 @@ vault <command> <path> metadata=key1=value1 metadata=key2=value2
 			HelpSynopsis:    strings.TrimSpace(entityHelp["entity-id-list"][0]),
 			HelpDescription: strings.TrimSpace(entityHelp["entity-id-list"][1]),
 		},
+		{
+			Pattern: "entity/name/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the entity.",
+				},
+				"metadata": {
+					Type: framework.TypeKVPairs,
+					Description: `Metadata to be associated with the entity.
+In CLI, this parameter can be repeated multiple times, and it all gets merged together.
+For example:
+vault <command> <path> metadata=key1=value1 metadata=key2=value2
+					`,
+				},
+				"policies": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Policies to be tied to the entity.",
+				},
+				"disabled": {
+					Type:        framework.TypeBool,
+					Description: "If set true, tokens tied to this identity will not be able to be used (but will not be revoked).",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathEntityNameUpdate(),
+				logical.ReadOperation:   i.pathEntityNameRead(),
+				logical.DeleteOperation: i.pathEntityNameDelete(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(entityHelp["entity-name"][0]),
+			HelpDescription: strings.TrimSpace(entityHelp["entity-name"][1]),
+		},
+		{
+			Pattern: "entity/name/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathEntityNameList(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(entityHelp["entity-name-list"][0]),
+			HelpDescription: strings.TrimSpace(entityHelp["entity-name-list"][1]),
+		},
+		{
+			Pattern: "entity/batch-delete/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Entity IDs to delete",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathEntityBatchDelete(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(entityHelp["entity-batch-delete"][0]),
+			HelpDescription: strings.TrimSpace(entityHelp["entity-batch-delete"][1]),
+		},
 		{
 			Pattern: "entity/merge/?$",
 			Fields: map[string]*framework.FieldSchema{
@@ -294,55 +353,86 @@ func (i *IdentityStore) handleEntityUpdateCommon() framework.OperationFunc {
 			entity.Name = entityName
 		}
 
-		// Update the policies if supplied
-		entityPoliciesRaw, ok := d.GetOk("policies")
-		if ok {
-			entity.Policies = entityPoliciesRaw.([]string)
-		}
+		return i.handleEntityUpdateFields(entity, d)
+	}
+}
 
-		disabledRaw, ok := d.GetOk("disabled")
-		if ok {
-			entity.Disabled = disabledRaw.(bool)
+// pathEntityNameUpdate creates or updates the entity with a given name
+func (i *IdentityStore) pathEntityNameUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		entityName := d.Get("name").(string)
+		if entityName == "" {
+			return logical.ErrorResponse("missing entity name"), nil
 		}
 
-		// Get entity metadata
-		metadata, ok, err := d.GetOkErr("metadata")
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("failed to parse metadata: %v", err)), nil
-		}
-		if ok {
-			entity.Metadata = metadata.(map[string]string)
-		}
-		// ID creation and some validations
-		err = i.sanitizeEntity(entity)
+		i.lock.Lock()
+		defer i.lock.Unlock()
+
+		entity, err := i.MemDBEntityByName(entityName, true)
 		if err != nil {
 			return nil, err
 		}
-
-		// Prepare the response
-		respData := map[string]interface{}{
-			"id": entity.ID,
+		if entity == nil {
+			entity = &identity.Entity{
+				Name: entityName,
+			}
 		}
 
-		var aliasIDs []string
-		for _, alias := range entity.Aliases {
-			aliasIDs = append(aliasIDs, alias.ID)
-		}
+		return i.handleEntityUpdateFields(entity, d)
+	}
+}
 
-		respData["aliases"] = aliasIDs
+// handleEntityUpdateFields updates the policies, disabled flag and metadata of
+// an entity from the request and persists it. The caller must hold the lock.
+func (i *IdentityStore) handleEntityUpdateFields(entity *identity.Entity, d *framework.FieldData) (*logical.Response, error) {
+	// Update the policies if supplied
+	entityPoliciesRaw, ok := d.GetOk("policies")
+	if ok {
+		entity.Policies = entityPoliciesRaw.([]string)
+	}
 
-		// Update MemDB and persist entity object. New entities have not been
-		// looked up yet so we need to take the lock on the entity on upsert
-		if err := i.upsertEntity(entity, nil, true); err != nil {
-			return nil, err
-		}
+	disabledRaw, ok := d.GetOk("disabled")
+	if ok {
+		entity.Disabled = disabledRaw.(bool)
+	}
 
-		// Return ID of the entity that was either created or updated along with
-		// its aliases
-		return &logical.Response{
-			Data: respData,
-		}, nil
+	// Get entity metadata
+	metadata, ok, err := d.GetOkErr("metadata")
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to parse metadata: %v", err)), nil
+	}
+	if ok {
+		entity.Metadata = metadata.(map[string]string)
+	}
+	// ID creation and some validations
+	err = i.sanitizeEntity(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the response
+	respData := map[string]interface{}{
+		"id": entity.ID,
+	}
+
+	var aliasIDs []string
+	for _, alias := range entity.Aliases {
+		aliasIDs = append(aliasIDs, alias.ID)
+	}
+
+	respData["aliases"] = aliasIDs
+
+	// Update MemDB and persist entity object. New entities have not been
+	// looked up yet so we need to take the lock on the entity on upsert
+	if err := i.upsertEntity(entity, nil, true); err != nil {
+		return nil, err
 	}
+
+	// Return ID of the entity that was either created or updated along with
+	// its aliases
+	return &logical.Response{
+		Data: respData,
+	}, nil
 }
 
 // pathEntityIDRead returns the properties of an entity for a given entity ID
@@ -365,6 +455,27 @@ func (i *IdentityStore) pathEntityIDRead() framework.OperationFunc {
 	}
 }
 
+// pathEntityNameRead returns the properties of an entity for a given entity
+// name
+func (i *IdentityStore) pathEntityNameRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		entityName := d.Get("name").(string)
+		if entityName == "" {
+			return logical.ErrorResponse("missing entity name"), nil
+		}
+
+		entity, err := i.MemDBEntityByName(entityName, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil {
+			return nil, nil
+		}
+
+		return i.handleEntityReadCommon(entity)
+	}
+}
+
 func (i *IdentityStore) handleEntityReadCommon(entity *identity.Entity) (*logical.Response, error) {
 	respData := map[string]interface{}{}
 	respData["id"] = entity.ID
@@ -449,34 +560,125 @@ func (i *IdentityStore) pathEntityIDDelete() framework.OperationFunc {
 			return nil, err
 		}
 
-		// If there is no entity for the ID, do nothing
-		if entity == nil {
-			return nil, nil
+		return nil, i.handleEntityDeleteCommon(txn, entity)
+	}
+}
+
+// pathEntityNameDelete deletes the entity for a given entity name
+func (i *IdentityStore) pathEntityNameDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		entityName := d.Get("name").(string)
+		if entityName == "" {
+			return logical.ErrorResponse("missing entity name"), nil
 		}
 
-		// Delete all the aliases in the entity. This function will also remove
-		// the corresponding alias indexes too.
-		err = i.deleteAliasesInEntityInTxn(txn, entity, entity.Aliases)
+		i.lock.Lock()
+		defer i.lock.Unlock()
+
+		// Create a MemDB transaction to delete entity
+		txn := i.db.Txn(true)
+		defer txn.Abort()
+
+		// Fetch the entity using its name
+		entity, err := i.MemDBEntityByNameInTxn(txn, entityName, true)
 		if err != nil {
 			return nil, err
 		}
 
-		// Delete the entity using the same transaction
-		err = i.MemDBDeleteEntityByIDInTxn(txn, entity.ID)
-		if err != nil {
-			return nil, err
+		return nil, i.handleEntityDeleteCommon(txn, entity)
+	}
+}
+
+// pathEntityBatchDelete deletes the entities for the given entity IDs. IDs
+// that do not belong to an entity are skipped.
+func (i *IdentityStore) pathEntityBatchDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		entityIDs := d.Get("entity_ids").([]string)
+		if len(entityIDs) == 0 {
+			return logical.ErrorResponse("missing entity ids to delete"), nil
+		}
+
+		i.lock.Lock()
+		defer i.lock.Unlock()
+
+		// Each entity is deleted in its own transaction so that the entities
+		// deleted before a failure stay consistent between MemDB and storage
+		for _, entityID := range entityIDs {
+			txn := i.db.Txn(true)
+
+			entity, err := i.MemDBEntityByIDInTxn(txn, entityID, true)
+			if err == nil {
+				err = i.handleEntityDeleteCommon(txn, entity)
+			}
+			txn.Abort()
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("failed to delete entity %q: {{err}}", entityID), err)
+			}
 		}
 
-		// Delete the entity from storage
-		err = i.entityPacker.DeleteItem(entity.ID)
+		return nil, nil
+	}
+}
+
+// handleEntityDeleteCommon deletes an entity along with its aliases from MemDB
+// and storage, and commits the transaction. A nil entity is a no-op.
+func (i *IdentityStore) handleEntityDeleteCommon(txn *memdb.Txn, entity *identity.Entity) error {
+	// If there is no entity, do nothing
+	if entity == nil {
+		return nil
+	}
+
+	// Delete all the aliases in the entity. This function will also remove
+	// the corresponding alias indexes too.
+	err := i.deleteAliasesInEntityInTxn(txn, entity, entity.Aliases)
+	if err != nil {
+		return err
+	}
+
+	// Delete the entity using the same transaction
+	err = i.MemDBDeleteEntityByIDInTxn(txn, entity.ID)
+	if err != nil {
+		return err
+	}
+
+	// Delete the entity from storage
+	err = i.entityPacker.DeleteItem(entity.ID)
+	if err != nil {
+		return err
+	}
+
+	// Committing the transaction *after* successfully deleting entity
+	txn.Commit()
+
+	return nil
+}
+
+// pathEntityNameList lists the names of all the valid entities in the
+// identity store
+func (i *IdentityStore) pathEntityNameList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		txn := i.db.Txn(false)
+
+		iter, err := txn.Get(entitiesTable, "name")
 		if err != nil {
-			return nil, err
+			return nil, errwrap.Wrapf("failed to fetch iterator for entities in memdb: {{err}}", err)
 		}
 
-		// Committing the transaction *after* successfully deleting entity
-		txn.Commit()
+		var entityNames []string
+		entityInfo := map[string]interface{}{}
+		for {
+			raw := iter.Next()
+			if raw == nil {
+				break
+			}
+			entity := raw.(*identity.Entity)
+			entityNames = append(entityNames, entity.Name)
+			entityInfo[entity.Name] = map[string]interface{}{
+				"id": entity.ID,
+			}
+		}
 
-		return nil, nil
+		return logical.ListResponseWithInfo(entityNames, entityInfo), nil
 	}
 }
 
@@ -562,6 +764,18 @@ var entityHelp = map[string][2]string{
 		"List all the entity IDs",
 		"",
 	},
+	"entity-name": {
+		"Create, update, read or delete an entity using entity name",
+		"",
+	},
+	"entity-name-list": {
+		"List all the entity names",
+		"",
+	},
+	"entity-batch-delete": {
+		"Delete all of the entities provided",
+		"",
+	},
 	"entity-merge-id": {
 		"Merge two or more entities together",
 		"",
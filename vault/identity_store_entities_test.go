@@ -663,6 +663,134 @@ func TestIdentityStore_EntityCRUD(t *testing.T) {
 	}
 }
 
+func TestIdentityStore_EntityByNameCRUD(t *testing.T) {
+	var err error
+	var resp *logical.Response
+
+	is, _, _ := testIdentityStoreWithGithubAuth(t)
+
+	updateData := map[string]interface{}{
+		"metadata": []string{"someusefulkey=someusefulvalue"},
+		"policies": []string{"testpolicy1", "testpolicy2"},
+	}
+
+	updateReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "entity/name/testentityname",
+		Data:      updateData,
+	}
+
+	// Create the entity
+	resp, err = is.HandleRequest(context.Background(), updateReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	id := resp.Data["id"].(string)
+	if id == "" {
+		t.Fatalf("invalid entity id")
+	}
+
+	// Update the entity, which must keep its ID
+	updateData["policies"] = []string{"updatedpolicy"}
+	resp, err = is.HandleRequest(context.Background(), updateReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["id"] != id {
+		t.Fatalf("bad: entity id changed from %q to %q", id, resp.Data["id"])
+	}
+
+	readReq := &logical.Request{
+		Path:      "entity/name/testentityname",
+		Operation: logical.ReadOperation,
+	}
+
+	resp, err = is.HandleRequest(context.Background(), readReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["id"] != id ||
+		resp.Data["name"] != "testentityname" ||
+		!reflect.DeepEqual(resp.Data["policies"], []string{"updatedpolicy"}) {
+		t.Fatalf("bad: entity response: %#v", resp.Data)
+	}
+
+	listReq := &logical.Request{
+		Path:      "entity/name",
+		Operation: logical.ListOperation,
+	}
+
+	resp, err = is.HandleRequest(context.Background(), listReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"testentityname"}) {
+		t.Fatalf("bad: entity names: %#v", resp.Data["keys"])
+	}
+
+	deleteReq := &logical.Request{
+		Path:      "entity/name/testentityname",
+		Operation: logical.DeleteOperation,
+	}
+
+	resp, err = is.HandleRequest(context.Background(), deleteReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = is.HandleRequest(context.Background(), readReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp != nil {
+		t.Fatalf("expected a nil response; actual: %#v\n", resp)
+	}
+}
+
+func TestIdentityStore_EntityBatchDelete(t *testing.T) {
+	var err error
+	var resp *logical.Response
+
+	is, _, _ := testIdentityStoreWithGithubAuth(t)
+
+	var ids []string
+	for idx := 0; idx < 10; idx++ {
+		resp, err = is.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "entity",
+			Data: map[string]interface{}{
+				"name": fmt.Sprintf("testentityname%d", idx),
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		ids = append(ids, resp.Data["id"].(string))
+	}
+
+	// Unknown IDs are skipped
+	resp, err = is.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "entity/batch-delete",
+		Data: map[string]interface{}{
+			"entity_ids": append(ids[:9:9], "nonexistententityid"),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	for idx, id := range ids {
+		entity, err := is.MemDBEntityByID(id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (entity == nil) != (idx < 9) {
+			t.Fatalf("bad: entity %d: %#v", idx, entity)
+		}
+	}
+}
+
 func TestIdentityStore_MergeEntitiesByID(t *testing.T) {
 	var err error
 	var resp *logical.Response
@@ -0,0 +1,606 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// identityExportVersion is the version of the export format. Imports of
+	// any other version are rejected.
+	identityExportVersion = 1
+
+	identityImportConflictSkip      = "skip"
+	identityImportConflictOverwrite = "overwrite"
+)
+
+// exportedEntity is the exported form of an entity along with its aliases
+type exportedEntity struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Policies        []string          `json:"policies,omitempty"`
+	Disabled        bool              `json:"disabled,omitempty"`
+	MergedEntityIDs []string          `json:"merged_entity_ids,omitempty"`
+	Aliases         []*exportedAlias  `json:"aliases,omitempty"`
+	CreationTime    time.Time         `json:"creation_time"`
+}
+
+// exportedGroup is the exported form of a group along with its alias
+type exportedGroup struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Policies        []string          `json:"policies,omitempty"`
+	MemberEntityIDs []string          `json:"member_entity_ids,omitempty"`
+	ParentGroupIDs  []string          `json:"parent_group_ids,omitempty"`
+	Alias           *exportedAlias    `json:"alias,omitempty"`
+	CreationTime    time.Time         `json:"creation_time"`
+}
+
+// exportedAlias is the exported form of an entity alias or a group alias
+type exportedAlias struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	MountAccessor string            `json:"mount_accessor"`
+	MountType     string            `json:"mount_type"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	CreationTime  time.Time         `json:"creation_time"`
+}
+
+func exportPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "export$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathExportRead(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(exportHelp["export"][0]),
+			HelpDescription: strings.TrimSpace(exportHelp["export"][1]),
+		},
+		{
+			Pattern: "import$",
+			Fields: map[string]*framework.FieldSchema{
+				"version": {
+					Type:        framework.TypeInt,
+					Description: "Version of the export format.",
+				},
+				"entities": {
+					Type:        framework.TypeSlice,
+					Description: "Entities to import, as returned by the export endpoint.",
+				},
+				"groups": {
+					Type:        framework.TypeSlice,
+					Description: "Groups to import, as returned by the export endpoint.",
+				},
+				"mount_accessor_map": {
+					Type: framework.TypeKVPairs,
+					Description: `Mapping of the mount accessors in the export to the mount accessors of this cluster.
+In CLI, this parameter can be repeated multiple times, and it all gets merged together.
+For example:
+vault <command> <path> mount_accessor_map=auth_userpass_1234=auth_userpass_abcd
+					`,
+				},
+				"conflict": {
+					Type:        framework.TypeString,
+					Default:     identityImportConflictSkip,
+					Description: "What to do with entities and groups whose ID already exists, 'skip' or 'overwrite'. Defaults to 'skip'.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathImportUpdate(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(exportHelp["import"][0]),
+			HelpDescription: strings.TrimSpace(exportHelp["import"][1]),
+		},
+	}
+}
+
+// pathExportRead returns all the entities and groups in the identity store
+func (i *IdentityStore) pathExportRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		txn := i.db.Txn(false)
+
+		entities := []*exportedEntity{}
+		iter, err := txn.Get(entitiesTable, "id")
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to fetch iterator for entities in memdb: {{err}}", err)
+		}
+		for raw := iter.Next(); raw != nil; raw = iter.Next() {
+			entity := raw.(*identity.Entity)
+			exported := &exportedEntity{
+				ID:              entity.ID,
+				Name:            entity.Name,
+				Metadata:        entity.Metadata,
+				Policies:        entity.Policies,
+				Disabled:        entity.Disabled,
+				MergedEntityIDs: entity.MergedEntityIDs,
+				CreationTime:    exportTime(entity.CreationTime),
+			}
+			for _, alias := range entity.Aliases {
+				exported.Aliases = append(exported.Aliases, exportAlias(alias))
+			}
+			entities = append(entities, exported)
+		}
+
+		groups := []*exportedGroup{}
+		iter, err = txn.Get(groupsTable, "id")
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to fetch iterator for groups in memdb: {{err}}", err)
+		}
+		for raw := iter.Next(); raw != nil; raw = iter.Next() {
+			group := raw.(*identity.Group)
+			exported := &exportedGroup{
+				ID:              group.ID,
+				Name:            group.Name,
+				Type:            group.Type,
+				Metadata:        group.Metadata,
+				Policies:        group.Policies,
+				MemberEntityIDs: group.MemberEntityIDs,
+				ParentGroupIDs:  group.ParentGroupIDs,
+				CreationTime:    exportTime(group.CreationTime),
+			}
+			if group.Alias != nil {
+				exported.Alias = exportAlias(group.Alias)
+			}
+			groups = append(groups, exported)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"version":  identityExportVersion,
+				"entities": entities,
+				"groups":   groups,
+			},
+		}, nil
+	}
+}
+
+func exportAlias(alias *identity.Alias) *exportedAlias {
+	return &exportedAlias{
+		ID:            alias.ID,
+		Name:          alias.Name,
+		MountAccessor: alias.MountAccessor,
+		MountType:     alias.MountType,
+		Metadata:      alias.Metadata,
+		CreationTime:  exportTime(alias.CreationTime),
+	}
+}
+
+func exportTime(ts *timestamp.Timestamp) time.Time {
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// importTime converts an exported creation time, using the current time if
+// it is missing
+func importTime(t time.Time) *timestamp.Timestamp {
+	ts, err := ptypes.TimestampProto(t)
+	if t.IsZero() || err != nil {
+		return ptypes.TimestampNow()
+	}
+	return ts
+}
+
+// pathImportUpdate imports entities and groups that were exported from this
+// or another cluster. Entities are imported first so that group memberships
+// can refer to them. An item whose ID already exists is skipped or
+// overwritten depending on the conflict mode; an item whose name or alias
+// belongs to a different entity or group is always skipped.
+func (i *IdentityStore) pathImportUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		version := d.Get("version").(int)
+		if version != identityExportVersion {
+			return logical.ErrorResponse(fmt.Sprintf("unsupported export version %d, expected %d", version, identityExportVersion)), nil
+		}
+
+		conflict := d.Get("conflict").(string)
+		switch conflict {
+		case identityImportConflictSkip, identityImportConflictOverwrite:
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("invalid conflict mode %q", conflict)), nil
+		}
+
+		accessors := map[string]string{}
+		mountAccessorMap, ok, err := d.GetOkErr("mount_accessor_map")
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse mount_accessor_map: %v", err)), nil
+		}
+		if ok {
+			accessors = mountAccessorMap.(map[string]string)
+		}
+
+		var entities []*exportedEntity
+		if err := convertImportItems(d.Get("entities"), &entities); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse entities: %v", err)), nil
+		}
+		var groups []*exportedGroup
+		if err := convertImportItems(d.Get("groups"), &groups); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse groups: %v", err)), nil
+		}
+
+		importedEntityIDs, skippedEntities, err := i.importEntities(entities, accessors, conflict)
+		if err != nil {
+			return nil, err
+		}
+
+		importedGroupIDs, skippedGroups, err := i.importGroups(groups, accessors, conflict)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"imported_entity_ids": importedEntityIDs,
+				"skipped_entities":    skippedEntities,
+				"imported_group_ids":  importedGroupIDs,
+				"skipped_groups":      skippedGroups,
+			},
+		}, nil
+	}
+}
+
+// convertImportItems decodes the entities or groups of an import request,
+// which arrive as generic JSON values, into their exported form
+func convertImportItems(raw interface{}, out interface{}) error {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// importAlias converts an exported alias into an alias of this cluster,
+// mapping its mount accessor
+func (i *IdentityStore) importAlias(exported *exportedAlias, canonicalID string, accessors map[string]string) (*identity.Alias, error) {
+	if exported.Name == "" {
+		return nil, fmt.Errorf("alias %q has no name", exported.ID)
+	}
+
+	accessor := exported.MountAccessor
+	if mapped, ok := accessors[accessor]; ok {
+		accessor = mapped
+	}
+	mount := i.core.router.validateMountByAccessor(accessor)
+	if mount == nil {
+		return nil, fmt.Errorf("mount accessor %q of alias %q does not exist; map it with mount_accessor_map", accessor, exported.Name)
+	}
+
+	if err := validateMetadata(exported.Metadata); err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("invalid metadata of alias %q: {{err}}", exported.Name), err)
+	}
+
+	return &identity.Alias{
+		ID:             exported.ID,
+		CanonicalID:    canonicalID,
+		Name:           exported.Name,
+		MountAccessor:  mount.MountAccessor,
+		MountType:      mount.MountType,
+		Metadata:       exported.Metadata,
+		CreationTime:   importTime(exported.CreationTime),
+		LastUpdateTime: ptypes.TimestampNow(),
+	}, nil
+}
+
+func (i *IdentityStore) importEntities(entities []*exportedEntity, accessors map[string]string, conflict string) ([]string, map[string]string, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	importedIDs := []string{}
+	skipped := map[string]string{}
+
+	for _, exported := range entities {
+		if exported.ID == "" {
+			return nil, nil, fmt.Errorf("entity %q has no ID", exported.Name)
+		}
+
+		reason, err := i.importEntity(exported, accessors, conflict)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf(fmt.Sprintf("failed to import entity %q: {{err}}", exported.ID), err)
+		}
+		if reason != "" {
+			skipped[exported.ID] = reason
+			continue
+		}
+		importedIDs = append(importedIDs, exported.ID)
+	}
+
+	return importedIDs, skipped, nil
+}
+
+// importEntity imports a single entity. It returns the reason the entity was
+// skipped, if it was.
+func (i *IdentityStore) importEntity(exported *exportedEntity, accessors map[string]string, conflict string) (string, error) {
+	existing, err := i.MemDBEntityByID(exported.ID, true)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && conflict == identityImportConflictSkip {
+		return "entity ID already exists", nil
+	}
+
+	if exported.Name != "" {
+		byName, err := i.MemDBEntityByName(exported.Name, false)
+		if err != nil {
+			return "", err
+		}
+		if byName != nil && byName.ID != exported.ID {
+			return fmt.Sprintf("entity name %q is in use by entity %q", exported.Name, byName.ID), nil
+		}
+	}
+
+	if err := validateMetadata(exported.Metadata); err != nil {
+		return fmt.Sprintf("invalid metadata: %v", err), nil
+	}
+
+	entity := &identity.Entity{
+		ID:              exported.ID,
+		Name:            exported.Name,
+		Metadata:        exported.Metadata,
+		Policies:        exported.Policies,
+		Disabled:        exported.Disabled,
+		MergedEntityIDs: exported.MergedEntityIDs,
+		BucketKeyHash:   i.entityPacker.BucketKeyHashByItemID(exported.ID),
+		CreationTime:    importTime(exported.CreationTime),
+		LastUpdateTime:  ptypes.TimestampNow(),
+	}
+	if entity.Name == "" {
+		entity.Name, err = i.generateName("entity")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, exportedAlias := range exported.Aliases {
+		alias, err := i.importAlias(exportedAlias, entity.ID, accessors)
+		if err != nil {
+			return err.Error(), nil
+		}
+
+		other, err := i.MemDBAliasByID(alias.ID, false, false)
+		if err != nil {
+			return "", err
+		}
+		if other == nil {
+			other, err = i.MemDBAliasByFactors(alias.MountAccessor, alias.Name, false, false)
+			if err != nil {
+				return "", err
+			}
+		}
+		if other != nil && other.CanonicalID != entity.ID {
+			return fmt.Sprintf("alias %q is in use by entity %q", alias.Name, other.CanonicalID), nil
+		}
+
+		entity.Aliases = append(entity.Aliases, alias)
+	}
+
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	// Aliases of an overwritten entity that are not part of the import are
+	// removed
+	if existing != nil {
+		if err := i.deleteAliasesInEntityInTxn(txn, existing, existing.Aliases); err != nil {
+			return "", err
+		}
+	}
+
+	if err := i.upsertEntityInTxn(txn, entity, nil, true); err != nil {
+		return "", err
+	}
+
+	txn.Commit()
+
+	return "", nil
+}
+
+func (i *IdentityStore) importGroups(groups []*exportedGroup, accessors map[string]string, conflict string) ([]string, map[string]string, error) {
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	importedIDs := []string{}
+	skipped := map[string]string{}
+	parentGroupIDs := map[string][]string{}
+
+	// The groups are first imported without their parents, which may not
+	// have been imported yet
+	for _, exported := range groups {
+		if exported.ID == "" {
+			return nil, nil, fmt.Errorf("group %q has no ID", exported.Name)
+		}
+
+		reason, err := i.importGroup(exported, accessors, conflict)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf(fmt.Sprintf("failed to import group %q: {{err}}", exported.ID), err)
+		}
+		if reason != "" {
+			skipped[exported.ID] = reason
+			continue
+		}
+		importedIDs = append(importedIDs, exported.ID)
+		parentGroupIDs[exported.ID] = exported.ParentGroupIDs
+	}
+
+	// Parents that do not exist, or that would create a cycle, are dropped
+	for _, groupID := range importedIDs {
+		if len(parentGroupIDs[groupID]) == 0 {
+			continue
+		}
+
+		group, err := i.MemDBGroupByID(groupID, true)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var parents []string
+		for _, parentID := range parentGroupIDs[groupID] {
+			parent, err := i.MemDBGroupByID(parentID, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			if parent == nil || parentID == groupID {
+				continue
+			}
+			cycle, err := i.detectCycleDFS(map[string]bool{}, parentID, groupID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if cycle {
+				continue
+			}
+			parents = append(parents, parentID)
+		}
+
+		group.ParentGroupIDs = strutil.RemoveDuplicates(parents, false)
+		if err := i.UpsertGroup(group, true); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return importedIDs, skipped, nil
+}
+
+// importGroup imports a single group without its parent groups. It returns
+// the reason the group was skipped, if it was.
+func (i *IdentityStore) importGroup(exported *exportedGroup, accessors map[string]string, conflict string) (string, error) {
+	existing, err := i.MemDBGroupByID(exported.ID, true)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && conflict == identityImportConflictSkip {
+		return "group ID already exists", nil
+	}
+
+	if exported.Name != "" {
+		byName, err := i.MemDBGroupByName(exported.Name, false)
+		if err != nil {
+			return "", err
+		}
+		if byName != nil && byName.ID != exported.ID {
+			return fmt.Sprintf("group name %q is in use by group %q", exported.Name, byName.ID), nil
+		}
+	}
+
+	groupType := exported.Type
+	if groupType == "" {
+		groupType = groupTypeInternal
+	}
+	if groupType != groupTypeInternal && groupType != groupTypeExternal {
+		return fmt.Sprintf("invalid group type %q", groupType), nil
+	}
+
+	if err := validateMetadata(exported.Metadata); err != nil {
+		return fmt.Sprintf("invalid metadata: %v", err), nil
+	}
+
+	group := &identity.Group{
+		ID:             exported.ID,
+		Name:           exported.Name,
+		Type:           groupType,
+		Metadata:       exported.Metadata,
+		Policies:       exported.Policies,
+		BucketKeyHash:  i.groupPacker.BucketKeyHashByItemID(exported.ID),
+		CreationTime:   importTime(exported.CreationTime),
+		LastUpdateTime: ptypes.TimestampNow(),
+	}
+	if group.Name == "" {
+		group.Name, err = i.generateName("group")
+		if err != nil {
+			return "", err
+		}
+	}
+	if existing != nil {
+		group.ModifyIndex = existing.ModifyIndex
+	}
+
+	// Memberships of entities that were not imported are dropped
+	for _, entityID := range strutil.RemoveDuplicates(exported.MemberEntityIDs, false) {
+		entity, err := i.MemDBEntityByID(entityID, false)
+		if err != nil {
+			return "", err
+		}
+		if entity != nil {
+			group.MemberEntityIDs = append(group.MemberEntityIDs, entityID)
+		}
+	}
+
+	if exported.Alias != nil {
+		if groupType != groupTypeExternal {
+			return "only external groups can have an alias", nil
+		}
+
+		alias, err := i.importAlias(exported.Alias, group.ID, accessors)
+		if err != nil {
+			return err.Error(), nil
+		}
+
+		other, err := i.MemDBAliasByFactors(alias.MountAccessor, alias.Name, false, true)
+		if err != nil {
+			return "", err
+		}
+		if other != nil && other.CanonicalID != group.ID {
+			return fmt.Sprintf("group alias %q is in use by group %q", alias.Name, other.CanonicalID), nil
+		}
+
+		group.Alias = alias
+	}
+
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	if existing != nil && existing.Alias != nil {
+		if err := i.MemDBDeleteAliasByIDInTxn(txn, existing.Alias.ID, true); err != nil {
+			return "", err
+		}
+	}
+	if group.Alias != nil {
+		if err := i.MemDBUpsertAliasInTxn(txn, group.Alias, true); err != nil {
+			return "", err
+		}
+	}
+
+	if err := i.UpsertGroupInTxn(txn, group, true); err != nil {
+		return "", err
+	}
+
+	txn.Commit()
+
+	return "", nil
+}
+
+var exportHelp = map[string][2]string{
+	"export": {
+		"Export all the entities and groups.",
+		`
+Returns all the entities with their aliases and all the groups with their
+aliases, in a versioned format that the import endpoint accepts.
+		`,
+	},
+	"import": {
+		"Import entities and groups.",
+		`
+Imports entities and groups as returned by the export endpoint, keeping their
+IDs. Mount accessors of aliases can be mapped to the mounts of this cluster.
+Entities and groups whose ID already exists are skipped or overwritten
+depending on 'conflict'. Those whose name or alias belongs to a different
+entity or group are always skipped.
+		`,
+	},
+}
@@ -0,0 +1,154 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestIdentityStore_ExportImport(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	ctx := context.Background()
+	is, ghAccessor, _ := testIdentityStoreWithGithubAuth(t)
+
+	request := func(is *IdentityStore, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp
+	}
+
+	resp = request(is, logical.UpdateOperation, "entity", map[string]interface{}{
+		"name":     "testentity1",
+		"metadata": []string{"team=ops"},
+		"policies": []string{"testpolicy"},
+	})
+	entityID1 := resp.Data["id"].(string)
+	resp = request(is, logical.UpdateOperation, "entity-alias", map[string]interface{}{
+		"name":           "githubuser",
+		"mount_accessor": ghAccessor,
+		"canonical_id":   entityID1,
+	})
+	aliasID := resp.Data["id"].(string)
+
+	resp = request(is, logical.UpdateOperation, "entity", map[string]interface{}{
+		"name": "testentity2",
+	})
+	entityID2 := resp.Data["id"].(string)
+
+	resp = request(is, logical.UpdateOperation, "group", map[string]interface{}{
+		"name":              "testgroup1",
+		"member_entity_ids": []string{entityID1, entityID2},
+	})
+	groupID1 := resp.Data["id"].(string)
+	resp = request(is, logical.UpdateOperation, "group", map[string]interface{}{
+		"name":             "testgroup2",
+		"member_group_ids": groupID1,
+	})
+	groupID2 := resp.Data["id"].(string)
+
+	// Simulate moving the export through JSON
+	resp = request(is, logical.ReadOperation, "export", nil)
+	exportJSON, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export map[string]interface{}
+	if err := json.Unmarshal(exportJSON, &export); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore an accidentally deleted entity and group
+	request(is, logical.DeleteOperation, "entity/id/"+entityID1, nil)
+	request(is, logical.DeleteOperation, "group/id/"+groupID1, nil)
+
+	resp = request(is, logical.UpdateOperation, "import", export)
+	if !reflect.DeepEqual(resp.Data["imported_entity_ids"], []string{entityID1}) {
+		t.Fatalf("bad: imported entities: %#v", resp.Data)
+	}
+	if !reflect.DeepEqual(resp.Data["imported_group_ids"], []string{groupID1}) {
+		t.Fatalf("bad: imported groups: %#v", resp.Data)
+	}
+	if skipped := resp.Data["skipped_entities"].(map[string]string); len(skipped) != 1 || skipped[entityID2] == "" {
+		t.Fatalf("bad: skipped entities: %#v", skipped)
+	}
+
+	entity, err := is.MemDBEntityByID(entityID1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entity == nil || entity.Name != "testentity1" || entity.Metadata["team"] != "ops" ||
+		len(entity.Aliases) != 1 || entity.Aliases[0].ID != aliasID || entity.Aliases[0].MountAccessor != ghAccessor {
+		t.Fatalf("bad: restored entity: %#v", entity)
+	}
+	group, err := is.MemDBGroupByID(groupID1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group == nil ||
+		!strutil.EquivalentSlices(group.MemberEntityIDs, []string{entityID1, entityID2}) ||
+		!reflect.DeepEqual(group.ParentGroupIDs, []string{groupID2}) {
+		t.Fatalf("bad: restored group: %#v", group)
+	}
+
+	// Overwrite replaces existing entities
+	request(is, logical.UpdateOperation, "entity/id/"+entityID2, map[string]interface{}{
+		"policies": []string{"changedpolicy"},
+	})
+	export["conflict"] = "overwrite"
+	resp = request(is, logical.UpdateOperation, "import", export)
+	if len(resp.Data["imported_entity_ids"].([]string)) != 2 || len(resp.Data["imported_group_ids"].([]string)) != 2 {
+		t.Fatalf("bad: overwrite import: %#v", resp.Data)
+	}
+	entity, err = is.MemDBEntityByID(entityID2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entity.Policies) != 0 {
+		t.Fatalf("bad: entity was not overwritten: %#v", entity)
+	}
+
+	// Importing into another cluster requires mapping the mount accessors
+	is2, ghAccessor2, _ := testIdentityStoreWithGithubAuth(t)
+	delete(export, "conflict")
+	resp = request(is2, logical.UpdateOperation, "import", export)
+	if skipped := resp.Data["skipped_entities"].(map[string]string); skipped[entityID1] == "" {
+		t.Fatalf("expected the entity with an unknown mount accessor to be skipped: %#v", resp.Data)
+	}
+
+	export["mount_accessor_map"] = []string{ghAccessor + "=" + ghAccessor2}
+	resp = request(is2, logical.UpdateOperation, "import", export)
+	if !reflect.DeepEqual(resp.Data["imported_entity_ids"], []string{entityID1}) {
+		t.Fatalf("bad: imported entities: %#v", resp.Data)
+	}
+	alias, err := is2.MemDBAliasByFactors(ghAccessor2, "githubuser", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alias == nil || alias.CanonicalID != entityID1 || alias.MountType != "github" {
+		t.Fatalf("bad: imported alias: %#v", alias)
+	}
+
+	// Unknown versions are rejected
+	export["version"] = 2
+	resp, err = is2.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "import",
+		Data:      export,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected an error for an unknown version; err:%v resp:%#v", err, resp)
+	}
+}
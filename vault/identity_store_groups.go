@@ -109,6 +109,57 @@ vault <command> <path> metadata=key1=value1 metadata=key2=value2
 			HelpSynopsis:    strings.TrimSpace(groupHelp["group-by-id"][0]),
 			HelpDescription: strings.TrimSpace(groupHelp["group-by-id"][1]),
 		},
+		{
+			Pattern: "group/name/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"type": {
+					Type:        framework.TypeString,
+					Default:     groupTypeInternal,
+					Description: "Type of the group, 'internal' or 'external'. Defaults to 'internal'",
+				},
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the group.",
+				},
+				"metadata": {
+					Type: framework.TypeKVPairs,
+					Description: `Metadata to be associated with the group.
+In CLI, this parameter can be repeated multiple times, and it all gets merged together.
+For example:
+vault <command> <path> metadata=key1=value1 metadata=key2=value2
+					`,
+				},
+				"policies": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Policies to be tied to the group.",
+				},
+				"member_group_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Group IDs to be assigned as group members.",
+				},
+				"member_entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Entity IDs to be assigned as group members.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathGroupNameUpdate(),
+				logical.ReadOperation:   i.pathGroupNameRead(),
+				logical.DeleteOperation: i.pathGroupNameDelete(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupHelp["group-by-name"][0]),
+			HelpDescription: strings.TrimSpace(groupHelp["group-by-name"][1]),
+		},
+		{
+			Pattern: "group/name/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathGroupNameList(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupHelp["group-name-list"][0]),
+			HelpDescription: strings.TrimSpace(groupHelp["group-name-list"][1]),
+		},
 		{
 			Pattern: "group/id/?$",
 			Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -157,6 +208,26 @@ func (i *IdentityStore) pathGroupIDUpdate() framework.OperationFunc {
 	}
 }
 
+func (i *IdentityStore) pathGroupNameUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		groupName := d.Get("name").(string)
+		if groupName == "" {
+			return logical.ErrorResponse("empty group name"), nil
+		}
+
+		i.groupLock.Lock()
+		defer i.groupLock.Unlock()
+
+		// A missing group is created with the given name
+		group, err := i.MemDBGroupByName(groupName, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return i.handleGroupUpdateCommon(req, d, group)
+	}
+}
+
 func (i *IdentityStore) handleGroupUpdateCommon(req *logical.Request, d *framework.FieldData, group *identity.Group) (*logical.Response, error) {
 	var err error
 	var newGroup bool
@@ -268,6 +339,22 @@ func (i *IdentityStore) pathGroupIDRead() framework.OperationFunc {
 	}
 }
 
+func (i *IdentityStore) pathGroupNameRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		groupName := d.Get("name").(string)
+		if groupName == "" {
+			return logical.ErrorResponse("empty group name"), nil
+		}
+
+		group, err := i.MemDBGroupByName(groupName, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return i.handleGroupReadCommon(group)
+	}
+}
+
 func (i *IdentityStore) handleGroupReadCommon(group *identity.Group) (*logical.Response, error) {
 	if group == nil {
 		return nil, nil
@@ -341,35 +428,93 @@ func (i *IdentityStore) pathGroupIDDelete() framework.OperationFunc {
 			return nil, err
 		}
 
-		// If there is no group for the ID, do nothing
-		if group == nil {
-			return nil, nil
-		}
+		return nil, i.handleGroupDeleteCommon(txn, group)
+	}
+}
 
-		// Delete group alias from memdb
-		if group.Type == groupTypeExternal && group.Alias != nil {
-			err = i.MemDBDeleteAliasByIDInTxn(txn, group.Alias.ID, true)
-			if err != nil {
-				return nil, err
-			}
+func (i *IdentityStore) pathGroupNameDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		groupName := d.Get("name").(string)
+		if groupName == "" {
+			return logical.ErrorResponse("empty group name"), nil
 		}
 
-		// Delete the group using the same transaction
-		err = i.MemDBDeleteGroupByIDInTxn(txn, group.ID)
+		// Acquire the lock to modify the group storage entry
+		i.groupLock.Lock()
+		defer i.groupLock.Unlock()
+
+		// Create a MemDB transaction to delete group
+		txn := i.db.Txn(true)
+		defer txn.Abort()
+
+		group, err := i.MemDBGroupByNameInTxn(txn, groupName, false)
 		if err != nil {
 			return nil, err
 		}
 
-		// Delete the group from storage
-		err = i.groupPacker.DeleteItem(group.ID)
+		return nil, i.handleGroupDeleteCommon(txn, group)
+	}
+}
+
+// handleGroupDeleteCommon deletes a group along with its alias from MemDB and
+// storage, and commits the transaction. A nil group is a no-op.
+func (i *IdentityStore) handleGroupDeleteCommon(txn *memdb.Txn, group *identity.Group) error {
+	// If there is no group, do nothing
+	if group == nil {
+		return nil
+	}
+
+	// Delete group alias from memdb
+	if group.Type == groupTypeExternal && group.Alias != nil {
+		err := i.MemDBDeleteAliasByIDInTxn(txn, group.Alias.ID, true)
 		if err != nil {
-			return nil, err
+			return err
 		}
+	}
 
-		// Committing the transaction *after* successfully deleting group
-		txn.Commit()
+	// Delete the group using the same transaction
+	err := i.MemDBDeleteGroupByIDInTxn(txn, group.ID)
+	if err != nil {
+		return err
+	}
 
-		return nil, nil
+	// Delete the group from storage
+	err = i.groupPacker.DeleteItem(group.ID)
+	if err != nil {
+		return err
+	}
+
+	// Committing the transaction *after* successfully deleting group
+	txn.Commit()
+
+	return nil
+}
+
+// pathGroupNameList lists the names of all the groups in the identity store
+func (i *IdentityStore) pathGroupNameList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		txn := i.db.Txn(false)
+
+		iter, err := txn.Get(groupsTable, "name")
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to fetch iterator for group in memdb: {{err}}", err)
+		}
+
+		var groupNames []string
+		groupInfo := map[string]interface{}{}
+		for {
+			raw := iter.Next()
+			if raw == nil {
+				break
+			}
+			group := raw.(*identity.Group)
+			groupNames = append(groupNames, group.Name)
+			groupInfo[group.Name] = map[string]interface{}{
+				"id": group.ID,
+			}
+		}
+
+		return logical.ListResponseWithInfo(groupNames, groupInfo), nil
 	}
 }
 
@@ -452,4 +597,12 @@ var groupHelp = map[string][2]string{
 		"List all the group IDs.",
 		"",
 	},
+	"group-by-name": {
+		"Create, update, read or delete a group using its name.",
+		"",
+	},
+	"group-name-list": {
+		"List all the group names.",
+		"",
+	},
 }
@@ -462,6 +462,85 @@ func TestIdentityStore_GroupsCRUD_ByID(t *testing.T) {
 	}
 }
 
+func TestIdentityStore_GroupsCRUD_ByName(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	is, _, _ := testIdentityStoreWithGithubAuth(t)
+
+	groupReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group/name/testgroupname",
+		Data: map[string]interface{}{
+			"policies": "testpolicy1,testpolicy2",
+			"metadata": []string{"testkey1=testvalue1"},
+		},
+	}
+
+	// Create the group
+	resp, err = is.HandleRequest(context.Background(), groupReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	groupID := resp.Data["id"].(string)
+	if groupID == "" || resp.Data["name"] != "testgroupname" {
+		t.Fatalf("bad: group response: %#v", resp.Data)
+	}
+
+	// Update the group, which must keep its ID
+	groupReq.Data = map[string]interface{}{
+		"policies": "updatedpolicy",
+	}
+	resp, err = is.HandleRequest(context.Background(), groupReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["id"] != groupID {
+		t.Fatalf("bad: group id changed from %q to %q", groupID, resp.Data["id"])
+	}
+
+	readReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "group/name/testgroupname",
+	}
+	resp, err = is.HandleRequest(context.Background(), readReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["id"] != groupID ||
+		!reflect.DeepEqual(resp.Data["policies"], []string{"updatedpolicy"}) ||
+		!reflect.DeepEqual(resp.Data["metadata"], map[string]string{"testkey1": "testvalue1"}) {
+		t.Fatalf("bad: group response: %#v", resp.Data)
+	}
+
+	resp, err = is.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "group/name",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"testgroupname"}) {
+		t.Fatalf("bad: group names: %#v", resp.Data["keys"])
+	}
+
+	resp, err = is.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "group/name/testgroupname",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = is.HandleRequest(context.Background(), readReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp != nil {
+		t.Fatalf("expected a nil response; actual: %#v", resp)
+	}
+}
+
 func TestIdentityStore_GroupMultiCase(t *testing.T) {
 	var resp *logical.Response
 	var err error
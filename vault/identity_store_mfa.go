@@ -0,0 +1,973 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/mfa/duo"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/totputil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+const (
+	// Storage paths of the login MFA methods, relative to the identity
+	// store's view
+	mfaMethodsPrefix     = "mfa/methods/"
+	mfaTOTPSecretsPrefix = "mfa/totp_secrets/"
+
+	mfaMethodTypeTOTP = "totp"
+	mfaMethodTypeDuo  = "duo"
+
+	// loginMFAValidatePath is the login path through which a pending login
+	// is completed
+	loginMFAValidatePath = "sys/mfa/validate"
+
+	// mfaPendingLoginTTL is how long a login waits for its MFA methods to
+	// be validated
+	mfaPendingLoginTTL = 5 * time.Minute
+)
+
+// mfaMethod is the configuration of a login MFA method. The method is
+// enforced on every login through one of its mount accessors, and on every
+// login of one of its entities or of a member of one of its groups.
+type mfaMethod struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	MountAccessors []string `json:"mount_accessors"`
+	EntityIDs      []string `json:"entity_ids"`
+	GroupIDs       []string `json:"group_ids"`
+
+	// TOTP settings
+	Issuer    string           `json:"issuer,omitempty"`
+	Period    uint             `json:"period,omitempty"`
+	KeySize   int              `json:"key_size,omitempty"`
+	QRSize    int              `json:"qr_size,omitempty"`
+	Algorithm otplib.Algorithm `json:"algorithm,omitempty"`
+	Digits    otplib.Digits    `json:"digits,omitempty"`
+	Skew      uint             `json:"skew,omitempty"`
+
+	// Duo settings
+	IntegrationKey string `json:"integration_key,omitempty"`
+	SecretKey      string `json:"secret_key,omitempty"`
+	APIHostname    string `json:"api_hostname,omitempty"`
+	PushInfo       string `json:"push_info,omitempty"`
+	UsernameFormat string `json:"username_format,omitempty"`
+	UsePasscode    bool   `json:"use_passcode,omitempty"`
+}
+
+// mfaTOTPSecret is the TOTP key an entity generated for a TOTP method
+type mfaTOTPSecret struct {
+	Key string `json:"key"`
+}
+
+// pendingMFALogin is a successful login that waits for its MFA methods to
+// be validated before a token is created for it
+type pendingMFALogin struct {
+	req      *logical.Request
+	resp     *logical.Response
+	entityID string
+	username string
+	methods  []string
+}
+
+// mfaValidateFields are the fields of sys/mfa/validate
+var mfaValidateFields = map[string]*framework.FieldSchema{
+	"mfa_request_id": &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "ID of the pending login, as returned in the MFA requirement of the login response.",
+	},
+	"mfa_payload": &framework.FieldSchema{
+		Type:        framework.TypeMap,
+		Description: "Map of the name of each required MFA method to the list of passcodes for it. Methods that don't use a passcode take an empty list.",
+	},
+}
+
+func mfaPaths(i *IdentityStore) []*framework.Path {
+	bindingFields := map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the MFA method.",
+		},
+		"mount_accessors": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "Accessors of the auth mounts whose logins require this method.",
+		},
+		"entity_ids": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "IDs of the entities whose logins require this method.",
+		},
+		"group_ids": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "IDs of the groups whose members' logins require this method.",
+		},
+	}
+
+	totpFields := map[string]*framework.FieldSchema{
+		"issuer": {
+			Type:        framework.TypeString,
+			Description: "The name of the issuing organization shown in authenticator apps.",
+		},
+		"period": {
+			Type:        framework.TypeDurationSecond,
+			Default:     30,
+			Description: "The length of time used to generate a counter for the TOTP code calculation.",
+		},
+		"key_size": {
+			Type:        framework.TypeInt,
+			Default:     20,
+			Description: "The size in bytes of the generated keys.",
+		},
+		"qr_size": {
+			Type:        framework.TypeInt,
+			Default:     200,
+			Description: "The pixel size of the generated square QR code. If this value is 0, a QR code is not returned.",
+		},
+		"algorithm": {
+			Type:        framework.TypeString,
+			Default:     "SHA1",
+			Description: "The hashing algorithm used to generate the TOTP code. Options include SHA1, SHA256 and SHA512.",
+		},
+		"digits": {
+			Type:        framework.TypeInt,
+			Default:     6,
+			Description: "The number of digits in the generated TOTP code. This value can either be 6 or 8.",
+		},
+		"skew": {
+			Type:        framework.TypeInt,
+			Default:     1,
+			Description: "The number of delay periods that are allowed when validating a TOTP code. This value can either be 0 or 1.",
+		},
+	}
+	for k, v := range bindingFields {
+		totpFields[k] = v
+	}
+
+	duoFields := map[string]*framework.FieldSchema{
+		"integration_key": {
+			Type:        framework.TypeString,
+			Description: "Integration key of the Duo Auth API application.",
+		},
+		"secret_key": {
+			Type:        framework.TypeString,
+			Description: "Secret key of the Duo Auth API application.",
+		},
+		"api_hostname": {
+			Type:        framework.TypeString,
+			Description: "API hostname of the Duo account.",
+		},
+		"push_info": {
+			Type:        framework.TypeString,
+			Description: "URL encoded key/value pairs shown in the Duo push notification.",
+		},
+		"username_format": {
+			Type:        framework.TypeString,
+			Default:     "%s",
+			Description: "Format string used to derive the Duo username from the login username, e.g. %s@example.com.",
+		},
+		"use_passcode": {
+			Type:        framework.TypeBool,
+			Description: "Require a passcode instead of sending a push notification.",
+		},
+	}
+	for k, v := range bindingFields {
+		duoFields[k] = v
+	}
+
+	entityFields := map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the TOTP method.",
+		},
+		"entity_id": {
+			Type:        framework.TypeString,
+			Description: "ID of the entity.",
+		},
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "mfa/method/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathMFAMethodList,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["method-list"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["method-list"][1]),
+		},
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "$",
+			Fields:  totpFields,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFAMethodUpdate(mfaMethodTypeTOTP),
+				logical.ReadOperation:   i.pathMFAMethodRead(mfaMethodTypeTOTP),
+				logical.DeleteOperation: i.pathMFAMethodDelete(mfaMethodTypeTOTP),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["method-totp"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["method-totp"][1]),
+		},
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/generate$",
+			Fields:  entityFields,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFATOTPGenerate,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["totp-generate"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["totp-generate"][1]),
+		},
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/admin-generate$",
+			Fields:  entityFields,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFATOTPAdminGenerate,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["totp-admin-generate"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["totp-admin-generate"][1]),
+		},
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/admin-destroy$",
+			Fields:  entityFields,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFATOTPAdminDestroy,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["totp-admin-destroy"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["totp-admin-destroy"][1]),
+		},
+		{
+			Pattern: "mfa/method/duo/" + framework.GenericNameRegex("name") + "$",
+			Fields:  duoFields,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathMFAMethodUpdate(mfaMethodTypeDuo),
+				logical.ReadOperation:   i.pathMFAMethodRead(mfaMethodTypeDuo),
+				logical.DeleteOperation: i.pathMFAMethodDelete(mfaMethodTypeDuo),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(mfaHelp["method-duo"][0]),
+			HelpDescription: strings.TrimSpace(mfaHelp["method-duo"][1]),
+		},
+	}
+}
+
+func (i *IdentityStore) pathMFAMethodList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.mfaLock.RLock()
+	defer i.mfaLock.RUnlock()
+
+	methods, err := i.mfaMethods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	keyInfo := make(map[string]interface{}, len(methods))
+	for _, method := range methods {
+		keys = append(keys, method.Name)
+		keyInfo[method.Name] = map[string]interface{}{
+			"type": method.Type,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+// pathMFAMethodUpdate creates or updates a method of the given type. Method
+// names are unique across types.
+func (i *IdentityStore) pathMFAMethodUpdate(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		method, err := i.mfaMethod(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		isNew := method == nil
+		if isNew {
+			method = &mfaMethod{
+				Name: name,
+				Type: methodType,
+			}
+		}
+		if method.Type != methodType {
+			return logical.ErrorResponse(fmt.Sprintf("an MFA method named %q of type %q already exists", name, method.Type)), nil
+		}
+
+		if raw, ok := d.GetOk("mount_accessors"); ok {
+			method.MountAccessors = strutil.RemoveDuplicates(raw.([]string), false)
+			for _, accessor := range method.MountAccessors {
+				if i.core.router.MatchingMountByAccessor(accessor) == nil {
+					return logical.ErrorResponse(fmt.Sprintf("invalid mount accessor %q", accessor)), nil
+				}
+			}
+		}
+		if raw, ok := d.GetOk("entity_ids"); ok {
+			method.EntityIDs = strutil.RemoveDuplicates(raw.([]string), false)
+		}
+		if raw, ok := d.GetOk("group_ids"); ok {
+			method.GroupIDs = strutil.RemoveDuplicates(raw.([]string), false)
+		}
+
+		switch methodType {
+		case mfaMethodTypeTOTP:
+			if resp := i.updateTOTPMethod(method, d, isNew); resp != nil {
+				return resp, nil
+			}
+		case mfaMethodTypeDuo:
+			if resp := i.updateDuoMethod(method, d, isNew); resp != nil {
+				return resp, nil
+			}
+		}
+
+		entry, err := logical.StorageEntryJSON(mfaMethodsPrefix+name, method)
+		if err != nil {
+			return nil, err
+		}
+		if err := i.view.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+// updateTOTPMethod applies the TOTP settings of a request to a method and
+// returns an error response if they are invalid. Settings of an existing
+// method only change when given, as keys generated with them would
+// otherwise stop validating.
+func (i *IdentityStore) updateTOTPMethod(method *mfaMethod, d *framework.FieldData, isNew bool) *logical.Response {
+	get := func(field string) (interface{}, bool) {
+		raw, ok := d.GetOk(field)
+		if !ok && isNew {
+			return d.Get(field), true
+		}
+		return raw, ok
+	}
+
+	if raw, ok := get("issuer"); ok {
+		method.Issuer = raw.(string)
+	}
+	if method.Issuer == "" {
+		return logical.ErrorResponse("issuer is required")
+	}
+	if raw, ok := get("period"); ok {
+		if raw.(int) <= 0 {
+			return logical.ErrorResponse("the period value must be greater than zero")
+		}
+		method.Period = uint(raw.(int))
+	}
+	if raw, ok := get("key_size"); ok {
+		if raw.(int) <= 0 {
+			return logical.ErrorResponse("the key_size value must be greater than zero")
+		}
+		method.KeySize = raw.(int)
+	}
+	if raw, ok := get("qr_size"); ok {
+		if raw.(int) < 0 {
+			return logical.ErrorResponse("the qr_size value must be greater than or equal to zero")
+		}
+		method.QRSize = raw.(int)
+	}
+	if raw, ok := get("algorithm"); ok {
+		algorithm, err := totputil.ParseAlgorithm(raw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error())
+		}
+		method.Algorithm = algorithm
+	}
+	if raw, ok := get("digits"); ok {
+		digits, err := totputil.ParseDigits(raw.(int))
+		if err != nil {
+			return logical.ErrorResponse(err.Error())
+		}
+		method.Digits = digits
+	}
+	if raw, ok := get("skew"); ok {
+		switch raw.(int) {
+		case 0, 1:
+			method.Skew = uint(raw.(int))
+		default:
+			return logical.ErrorResponse("the skew value must be 0 or 1")
+		}
+	}
+
+	return nil
+}
+
+// updateDuoMethod applies the Duo settings of a request to a method and
+// returns an error response if they are invalid
+func (i *IdentityStore) updateDuoMethod(method *mfaMethod, d *framework.FieldData, isNew bool) *logical.Response {
+	if raw, ok := d.GetOk("integration_key"); ok {
+		method.IntegrationKey = raw.(string)
+	}
+	if raw, ok := d.GetOk("secret_key"); ok {
+		method.SecretKey = raw.(string)
+	}
+	if raw, ok := d.GetOk("api_hostname"); ok {
+		method.APIHostname = raw.(string)
+	}
+	if raw, ok := d.GetOk("push_info"); ok {
+		method.PushInfo = raw.(string)
+	}
+	if raw, ok := d.GetOk("username_format"); ok {
+		method.UsernameFormat = raw.(string)
+	} else if isNew {
+		method.UsernameFormat = d.Get("username_format").(string)
+	}
+	if raw, ok := d.GetOk("use_passcode"); ok {
+		method.UsePasscode = raw.(bool)
+	}
+
+	if method.IntegrationKey == "" || method.SecretKey == "" || method.APIHostname == "" {
+		return logical.ErrorResponse("integration_key, secret_key and api_hostname are required")
+	}
+	if strings.Count(method.UsernameFormat, "%s") != 1 {
+		return logical.ErrorResponse("username_format must contain exactly one %s")
+	}
+
+	return nil
+}
+
+func (i *IdentityStore) pathMFAMethodRead(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		i.mfaLock.RLock()
+		defer i.mfaLock.RUnlock()
+
+		method, err := i.mfaMethod(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if method == nil || method.Type != methodType {
+			return nil, nil
+		}
+
+		data := map[string]interface{}{
+			"name":            method.Name,
+			"type":            method.Type,
+			"mount_accessors": method.MountAccessors,
+			"entity_ids":      method.EntityIDs,
+			"group_ids":       method.GroupIDs,
+		}
+		switch method.Type {
+		case mfaMethodTypeTOTP:
+			data["issuer"] = method.Issuer
+			data["period"] = method.Period
+			data["key_size"] = method.KeySize
+			data["qr_size"] = method.QRSize
+			data["algorithm"] = method.Algorithm.String()
+			data["digits"] = method.Digits
+			data["skew"] = method.Skew
+		case mfaMethodTypeDuo:
+			// The secret key is never returned
+			data["integration_key"] = method.IntegrationKey
+			data["api_hostname"] = method.APIHostname
+			data["push_info"] = method.PushInfo
+			data["username_format"] = method.UsernameFormat
+			data["use_passcode"] = method.UsePasscode
+		}
+
+		return &logical.Response{
+			Data: data,
+		}, nil
+	}
+}
+
+// pathMFAMethodDelete deletes a method along with the TOTP keys generated
+// for it
+func (i *IdentityStore) pathMFAMethodDelete(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+
+		i.mfaLock.Lock()
+		defer i.mfaLock.Unlock()
+
+		method, err := i.mfaMethod(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if method == nil || method.Type != methodType {
+			return nil, nil
+		}
+
+		entityIDs, err := i.view.List(ctx, mfaTOTPSecretsPrefix+name+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, entityID := range entityIDs {
+			if err := i.view.Delete(ctx, mfaTOTPSecretsPrefix+name+"/"+entityID); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := i.view.Delete(ctx, mfaMethodsPrefix+name); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+// pathMFATOTPGenerate generates a TOTP key for the entity of the calling
+// token
+func (i *IdentityStore) pathMFATOTPGenerate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.EntityID == "" {
+		return logical.ErrorResponse("the token is not associated with an entity"), nil
+	}
+	return i.generateTOTPSecret(ctx, d.Get("name").(string), req.EntityID)
+}
+
+// pathMFATOTPAdminGenerate generates a TOTP key for the given entity
+func (i *IdentityStore) pathMFATOTPAdminGenerate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entityID := d.Get("entity_id").(string)
+	if entityID == "" {
+		return logical.ErrorResponse("missing entity_id"), nil
+	}
+	return i.generateTOTPSecret(ctx, d.Get("name").(string), entityID)
+}
+
+// pathMFATOTPAdminDestroy deletes the TOTP key of the given entity so that a
+// new one can be generated
+func (i *IdentityStore) pathMFATOTPAdminDestroy(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	entityID := d.Get("entity_id").(string)
+	if entityID == "" {
+		return logical.ErrorResponse("missing entity_id"), nil
+	}
+
+	i.mfaLock.Lock()
+	defer i.mfaLock.Unlock()
+
+	if err := i.view.Delete(ctx, mfaTOTPSecretsPrefix+name+"/"+entityID); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// generateTOTPSecret generates and stores the TOTP key of an entity for a
+// TOTP method, and returns the URL and QR code used to add the key to an
+// authenticator app. An entity that already has a key must have it
+// destroyed first.
+func (i *IdentityStore) generateTOTPSecret(ctx context.Context, name, entityID string) (*logical.Response, error) {
+	i.mfaLock.Lock()
+	defer i.mfaLock.Unlock()
+
+	method, err := i.mfaMethod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil || method.Type != mfaMethodTypeTOTP {
+		return logical.ErrorResponse(fmt.Sprintf("unknown TOTP method %q", name)), nil
+	}
+
+	entity, err := i.MemDBEntityByID(entityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown entity %q", entityID)), nil
+	}
+
+	existing, err := i.totpSecret(ctx, name, entityID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return logical.ErrorResponse("the entity already has a key for this method; it must be destroyed before a new one can be generated"), nil
+	}
+
+	key, err := totplib.Generate(totplib.GenerateOpts{
+		Issuer:      method.Issuer,
+		AccountName: entity.Name,
+		Period:      method.Period,
+		Digits:      method.Digits,
+		Algorithm:   method.Algorithm,
+		SecretSize:  uint(method.KeySize),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := logical.StorageEntryJSON(mfaTOTPSecretsPrefix+name+"/"+entityID, &mfaTOTPSecret{
+		Key: key.Secret(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := i.view.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"url": key.String(),
+	}
+	if method.QRSize > 0 {
+		barcode, err := totputil.Barcode(key, method.QRSize)
+		if err != nil {
+			return nil, err
+		}
+		data["barcode"] = barcode
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (i *IdentityStore) mfaMethod(ctx context.Context, name string) (*mfaMethod, error) {
+	entry, err := i.view.Get(ctx, mfaMethodsPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var method mfaMethod
+	if err := entry.DecodeJSON(&method); err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+func (i *IdentityStore) mfaMethods(ctx context.Context) ([]*mfaMethod, error) {
+	names, err := i.view.List(ctx, mfaMethodsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []*mfaMethod
+	for _, name := range names {
+		method, err := i.mfaMethod(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if method != nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods, nil
+}
+
+func (i *IdentityStore) totpSecret(ctx context.Context, name, entityID string) (*mfaTOTPSecret, error) {
+	entry, err := i.view.Get(ctx, mfaTOTPSecretsPrefix+name+"/"+entityID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var secret mfaTOTPSecret
+	if err := entry.DecodeJSON(&secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// loginMFAMethods returns the MFA methods that a login through the given
+// mount by the given entity must validate. The entity is nil for logins
+// that don't resolve to one.
+func (i *IdentityStore) loginMFAMethods(ctx context.Context, mountAccessor string, entity *identity.Entity) ([]*mfaMethod, error) {
+	i.mfaLock.RLock()
+	defer i.mfaLock.RUnlock()
+
+	methods, err := i.mfaMethods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	var groupIDs []string
+	if entity != nil {
+		groups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range append(groups, inheritedGroups...) {
+			groupIDs = append(groupIDs, group.ID)
+		}
+	}
+
+	var required []*mfaMethod
+	for _, method := range methods {
+		applies := strutil.StrListContains(method.MountAccessors, mountAccessor)
+		if entity != nil && strutil.StrListContains(method.EntityIDs, entity.ID) {
+			applies = true
+		}
+		for _, groupID := range groupIDs {
+			if strutil.StrListContains(method.GroupIDs, groupID) {
+				applies = true
+			}
+		}
+		if applies {
+			required = append(required, method)
+		}
+	}
+	return required, nil
+}
+
+// createPendingMFALogin holds a successful login until its MFA methods are
+// validated, and returns the response that tells the client which methods
+// to validate. An error response is returned if a method cannot be
+// validated for this login.
+func (i *IdentityStore) createPendingMFALogin(ctx context.Context, req *logical.Request, resp *logical.Response, entity *identity.Entity, methods []*mfaMethod) (*logical.Response, error) {
+	pending := &pendingMFALogin{
+		resp: resp,
+	}
+	if entity != nil {
+		pending.entityID = entity.ID
+	}
+	pending.username = resp.Auth.Metadata["username"]
+	if pending.username == "" && resp.Auth.Alias != nil {
+		pending.username = resp.Auth.Alias.Name
+	}
+
+	requirement := &logical.MFARequirement{}
+	for _, method := range methods {
+		methodID := &logical.MFAMethodID{
+			Type: method.Type,
+			Name: method.Name,
+		}
+
+		switch method.Type {
+		case mfaMethodTypeTOTP:
+			if pending.entityID == "" {
+				return logical.ErrorResponse(fmt.Sprintf("MFA method %q requires the login to resolve to an entity", method.Name)), logical.ErrPermissionDenied
+			}
+			secret, err := i.totpSecret(ctx, method.Name, pending.entityID)
+			if err != nil {
+				return nil, err
+			}
+			if secret == nil {
+				return logical.ErrorResponse(fmt.Sprintf("no TOTP key has been generated for the entity for MFA method %q", method.Name)), logical.ErrPermissionDenied
+			}
+			methodID.UsesPasscode = true
+		case mfaMethodTypeDuo:
+			if pending.username == "" {
+				return logical.ErrorResponse(fmt.Sprintf("MFA method %q requires a username for the login", method.Name)), logical.ErrPermissionDenied
+			}
+			methodID.UsesPasscode = method.UsePasscode
+		}
+
+		pending.methods = append(pending.methods, method.Name)
+		requirement.MFAMethods = append(requirement.MFAMethods, methodID)
+	}
+
+	requestID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	requirement.MFARequestID = requestID
+
+	// Keep a copy of the request without the login credentials
+	loginReq := *req
+	loginReq.Data = nil
+	pending.req = &loginReq
+
+	i.mfaPendingLogins.Set(requestID, pending, mfaPendingLoginTTL)
+
+	return &logical.Response{
+		Auth: &logical.Auth{
+			MFARequirement: requirement,
+		},
+	}, nil
+}
+
+// validateLoginMFA validates the MFA methods of a pending login. On success
+// it returns the original login request and response so that the core can
+// create the token; otherwise it returns an error response. A pending login
+// can only be validated once.
+func (i *IdentityStore) validateLoginMFA(ctx context.Context, req *logical.Request) (*logical.Request, *logical.Response, error) {
+	d := &framework.FieldData{
+		Raw:    req.Data,
+		Schema: mfaValidateFields,
+	}
+
+	requestID := d.Get("mfa_request_id").(string)
+	if requestID == "" {
+		return nil, logical.ErrorResponse("missing mfa_request_id"), logical.ErrInvalidRequest
+	}
+	payload := map[string][]string{}
+	for name, raw := range d.Get("mfa_payload").(map[string]interface{}) {
+		var passcodes []string
+		if err := mapstructure.WeakDecode(raw, &passcodes); err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("invalid passcodes for MFA method %q", name)), logical.ErrInvalidRequest
+		}
+		payload[name] = passcodes
+	}
+
+	raw, ok := i.mfaPendingLogins.Get(requestID)
+	if !ok {
+		return nil, logical.ErrorResponse("invalid or expired MFA request ID"), logical.ErrPermissionDenied
+	}
+	i.mfaPendingLogins.Delete(requestID)
+	pending := raw.(*pendingMFALogin)
+
+	i.mfaLock.RLock()
+	defer i.mfaLock.RUnlock()
+
+	for _, name := range pending.methods {
+		passcodes, ok := payload[name]
+		if !ok {
+			return nil, logical.ErrorResponse(fmt.Sprintf("missing payload for MFA method %q", name)), logical.ErrPermissionDenied
+		}
+
+		method, err := i.mfaMethod(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if method == nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("MFA method %q no longer exists", name)), logical.ErrPermissionDenied
+		}
+
+		switch method.Type {
+		case mfaMethodTypeTOTP:
+			err = i.validateTOTP(ctx, method, pending.entityID, passcodes)
+		case mfaMethodTypeDuo:
+			err = i.validateDuo(method, pending.username, pending.req, passcodes)
+		default:
+			err = fmt.Errorf("unknown type %q", method.Type)
+		}
+		if err != nil {
+			return nil, logical.ErrorResponse(fmt.Sprintf("failed to validate MFA method %q: %v", name, err)), logical.ErrPermissionDenied
+		}
+	}
+
+	return pending.req, pending.resp, nil
+}
+
+// validateTOTP checks a TOTP code against the entity's key. A code can only
+// be used once.
+func (i *IdentityStore) validateTOTP(ctx context.Context, method *mfaMethod, entityID string, passcodes []string) error {
+	if len(passcodes) != 1 {
+		return fmt.Errorf("expected a single passcode")
+	}
+	code := passcodes[0]
+
+	secret, err := i.totpSecret(ctx, method.Name, entityID)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return fmt.Errorf("no TOTP key has been generated for the entity")
+	}
+
+	usedName := fmt.Sprintf("%s_%s_%s", method.Name, entityID, code)
+	if _, ok := i.mfaUsedCodes.Get(usedName); ok {
+		return fmt.Errorf("code already used; wait until the next time period")
+	}
+
+	valid, err := totplib.ValidateCustom(code, secret.Key, time.Now(), totplib.ValidateOpts{
+		Period:    method.Period,
+		Skew:      method.Skew,
+		Digits:    method.Digits,
+		Algorithm: method.Algorithm,
+	})
+	if err != nil && err != otplib.ErrValidateInputInvalidLength {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid passcode")
+	}
+
+	return i.mfaUsedCodes.Add(usedName, nil, totputil.UsedCodeTTL(method.Period, method.Skew))
+}
+
+// validateDuo asks Duo to authenticate the user, either with a push
+// notification or with the given passcode
+func (i *IdentityStore) validateDuo(method *mfaMethod, username string, req *logical.Request, passcodes []string) error {
+	var passcode string
+	switch len(passcodes) {
+	case 0:
+		if method.UsePasscode {
+			return fmt.Errorf("a passcode is required")
+		}
+	case 1:
+		passcode = passcodes[0]
+	default:
+		return fmt.Errorf("expected at most one passcode")
+	}
+
+	client, err := i.mfaDuoAuthClient(&duo.DuoAccess{
+		IKey: method.IntegrationKey,
+		SKey: method.SecretKey,
+		Host: method.APIHostname,
+	}, "")
+	if err != nil {
+		return err
+	}
+
+	var ipAddr string
+	if req.Connection != nil {
+		ipAddr = req.Connection.RemoteAddr
+	}
+
+	return duo.Authenticate(&duo.DuoConfig{
+		UsernameFormat: method.UsernameFormat,
+		PushInfo:       method.PushInfo,
+	}, client, username, "", passcode, ipAddr)
+}
+
+var mfaHelp = map[string][2]string{
+	"method-list": {
+		"List the configured login MFA methods.",
+		"",
+	},
+	"method-totp": {
+		"Create, read, update or delete a TOTP login MFA method.",
+		`
+A TOTP method requires a time-based one-time passcode from an authenticator
+app on every login it applies to. It applies to logins through the auth mounts
+in "mount_accessors", and to logins of the entities in "entity_ids" or of
+members of the groups in "group_ids". Each entity generates its own key
+through the "generate" path before it can log in.
+		`,
+	},
+	"method-duo": {
+		"Create, read, update or delete a Duo login MFA method.",
+		`
+A Duo method sends a push notification, or requires a Duo passcode if
+"use_passcode" is set, on every login it applies to. It applies to logins
+through the auth mounts in "mount_accessors", and to logins of the entities
+in "entity_ids" or of members of the groups in "group_ids". The Duo username
+is derived from the login's username using "username_format".
+		`,
+	},
+	"totp-generate": {
+		"Generate a TOTP key for the entity of the calling token.",
+		`
+Generates the key of the calling token's entity for the TOTP method and
+returns the URL and QR code used to add it to an authenticator app. A key can
+only be generated once; an administrator must destroy it before it can be
+generated again.
+		`,
+	},
+	"totp-admin-generate": {
+		"Generate a TOTP key for an entity.",
+		`
+Generates the key of the given entity for the TOTP method and returns the URL
+and QR code used to add it to an authenticator app.
+		`,
+	},
+	"totp-admin-destroy": {
+		"Destroy the TOTP key of an entity.",
+		`
+Destroys the key of the given entity for the TOTP method so that a new one can
+be generated.
+		`,
+	},
+}
@@ -0,0 +1,245 @@
+package vault
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/duosecurity/duo_api_golang/authapi"
+	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/helper/mfa/duo"
+	"github.com/hashicorp/vault/logical"
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+type testDuoAuthClient struct {
+	username string
+	factor   string
+}
+
+func (c *testDuoAuthClient) Preauth(options ...func(*url.Values)) (*authapi.PreauthResult, error) {
+	result := &authapi.PreauthResult{}
+	result.StatResult.Stat = "OK"
+	result.Response.Result = "auth"
+	return result, nil
+}
+
+func (c *testDuoAuthClient) Auth(factor string, options ...func(*url.Values)) (*authapi.AuthResult, error) {
+	values := url.Values{}
+	for _, o := range options {
+		o(&values)
+	}
+	c.username = values.Get("username")
+	c.factor = factor
+
+	result := &authapi.AuthResult{}
+	result.StatResult.Stat = "OK"
+	result.Response.Result = "allow"
+	return result, nil
+}
+
+func TestIdentityStore_LoginMFA(t *testing.T) {
+	ctx := context.Background()
+	core, _, root := TestCoreUnsealed(t)
+	core.credentialBackends["userpass"] = credUserpass.Factory
+
+	request := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		if req.ClientToken == "" && req.Path != "sys/mfa/validate" && req.Path != "auth/userpass/login/test" {
+			req.ClientToken = root
+		}
+		resp, err := core.HandleRequest(ctx, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %q: err:%v resp:%#v", req.Path, err, resp)
+		}
+		return resp
+	}
+
+	request(&logical.Request{
+		Path:      "sys/auth/userpass",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"type": "userpass",
+		},
+	})
+	request(&logical.Request{
+		Path:      "auth/userpass/users/test",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"password": "foo",
+		},
+	})
+	accessor := core.router.MatchingMountEntry("auth/userpass/").Accessor
+
+	loginReq := func() *logical.Request {
+		return &logical.Request{
+			Path:      "auth/userpass/login/test",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"password": "foo",
+			},
+			Connection: &logical.Connection{
+				RemoteAddr: "127.0.0.1",
+			},
+		}
+	}
+
+	// The first login creates the entity
+	resp := request(loginReq())
+	entityID := resp.Auth.EntityID
+	if entityID == "" {
+		t.Fatal("expected an entity")
+	}
+
+	request(&logical.Request{
+		Path:      "identity/mfa/method/totp/my_totp",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"issuer":     "Vault",
+			"entity_ids": entityID,
+		},
+	})
+
+	// Logins fail until the entity has a key
+	resp, err := core.HandleRequest(ctx, loginReq())
+	if err != logical.ErrPermissionDenied || resp == nil || !resp.IsError() {
+		t.Fatalf("expected permission denied, err:%v resp:%#v", err, resp)
+	}
+
+	resp = request(&logical.Request{
+		Path:      "identity/mfa/method/totp/my_totp/admin-generate",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"entity_id": entityID,
+		},
+	})
+	if resp.Data["barcode"] == "" {
+		t.Fatalf("expected a barcode: %#v", resp.Data)
+	}
+	key, err := otplib.NewKeyFromURL(resp.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() *logical.MFARequirement {
+		t.Helper()
+		resp := request(loginReq())
+		if resp.Auth == nil || resp.Auth.ClientToken != "" || resp.Auth.MFARequirement == nil {
+			t.Fatalf("expected an MFA requirement: %#v", resp)
+		}
+		return resp.Auth.MFARequirement
+	}
+	validate := func(requestID string, payload map[string]interface{}) (*logical.Response, error) {
+		return core.HandleRequest(ctx, &logical.Request{
+			Path:      "sys/mfa/validate",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"mfa_request_id": requestID,
+				"mfa_payload":    payload,
+			},
+		})
+	}
+
+	requirement := login()
+	if len(requirement.MFAMethods) != 1 || requirement.MFAMethods[0].Name != "my_totp" || !requirement.MFAMethods[0].UsesPasscode {
+		t.Fatalf("bad: requirement: %#v", requirement.MFAMethods)
+	}
+
+	// A failed validation consumes the pending login
+	resp, err = validate(requirement.MFARequestID, map[string]interface{}{
+		"my_totp": []string{"000000"},
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, err:%v resp:%#v", err, resp)
+	}
+	code, err := totplib.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = validate(requirement.MFARequestID, map[string]interface{}{
+		"my_totp": []string{code},
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, err:%v resp:%#v", err, resp)
+	}
+
+	requirement = login()
+	resp, err = validate(requirement.MFARequestID, map[string]interface{}{
+		"my_totp": []string{code},
+	})
+	if err != nil || resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		t.Fatalf("expected a token, err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.EntityID != entityID {
+		t.Fatalf("bad: entity ID: %q", resp.Auth.EntityID)
+	}
+	te, err := core.tokenStore.Lookup(ctx, resp.Auth.ClientToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te == nil || te.Path != "auth/userpass/login/test" {
+		t.Fatalf("bad: token entry: %#v", te)
+	}
+
+	// A code can only be used once
+	requirement = login()
+	resp, err = validate(requirement.MFARequestID, map[string]interface{}{
+		"my_totp": []string{code},
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, err:%v resp:%#v", err, resp)
+	}
+
+	request(&logical.Request{
+		Path:      "identity/mfa/method/totp/my_totp",
+		Operation: logical.DeleteOperation,
+	})
+
+	// Duo methods bound to the mount apply to every login through it
+	duoClient := &testDuoAuthClient{}
+	core.identityStore.mfaDuoAuthClient = func(*duo.DuoAccess, string) (duo.AuthClient, error) {
+		return duoClient, nil
+	}
+	request(&logical.Request{
+		Path:      "identity/mfa/method/duo/my_duo",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"integration_key": "ikey",
+			"secret_key":      "skey",
+			"api_hostname":    "api-123.duosecurity.com",
+			"username_format": "%s@example.com",
+			"mount_accessors": accessor,
+		},
+	})
+
+	resp = request(&logical.Request{
+		Path:      "identity/mfa/method",
+		Operation: logical.ListOperation,
+	})
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "my_duo" {
+		t.Fatalf("bad: methods: %#v", resp.Data)
+	}
+	resp = request(&logical.Request{
+		Path:      "identity/mfa/method/duo/my_duo",
+		Operation: logical.ReadOperation,
+	})
+	if _, ok := resp.Data["secret_key"]; ok {
+		t.Fatal("the secret key should not be returned")
+	}
+
+	requirement = login()
+	if len(requirement.MFAMethods) != 1 || requirement.MFAMethods[0].Type != "duo" || requirement.MFAMethods[0].UsesPasscode {
+		t.Fatalf("bad: requirement: %#v", requirement.MFAMethods)
+	}
+	resp, err = validate(requirement.MFARequestID, map[string]interface{}{
+		"my_duo": []string{},
+	})
+	if err != nil || resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		t.Fatalf("expected a token, err:%v resp:%#v", err, resp)
+	}
+	if duoClient.username != "test@example.com" || duoClient.factor != "auto" {
+		t.Fatalf("bad: duo request: %#v", duoClient)
+	}
+}
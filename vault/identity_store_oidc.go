@@ -0,0 +1,1135 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// Storage paths of the OIDC token provider, relative to the identity
+	// store's view
+	oidcConfigStorageKey   = "oidc_config"
+	oidcNamedKeysPrefix    = "oidc_tokens/named_keys/"
+	oidcRolesPrefix        = "oidc_tokens/roles/"
+	oidcPublicKeysPrefix   = "oidc_tokens/public_keys/"
+	oidcIssuerPathSuffix   = "/v1/identity/oidc"
+	oidcDefaultKeyLifetime = 24 * time.Hour
+	oidcMinRotationPeriod  = time.Minute
+)
+
+var (
+	// oidcSupportedAlgorithms lists the signing algorithms a named key may use
+	oidcSupportedAlgorithms = []string{
+		string(jose.RS256), string(jose.RS384), string(jose.RS512),
+		string(jose.ES256), string(jose.ES384), string(jose.ES512),
+		string(jose.EdDSA),
+	}
+
+	// oidcReservedClaims are set by Vault on every token and cannot be
+	// provided by a role's template
+	oidcReservedClaims = []string{"iss", "sub", "aud", "exp", "iat", "nbf", "jti"}
+
+	// oidcTemplateRegex matches a single placeholder in a role's template
+	oidcTemplateRegex = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+)
+
+// oidcConfig is the configuration of the OIDC token provider
+type oidcConfig struct {
+	Issuer string `json:"issuer"`
+}
+
+// namedKey is a set of keys used to sign tokens. The current signing key is
+// replaced every rotation period, and previous keys remain published for the
+// verification TTL so that tokens they signed can still be verified.
+type namedKey struct {
+	Name             string           `json:"name"`
+	Algorithm        string           `json:"algorithm"`
+	RotationPeriod   time.Duration    `json:"rotation_period"`
+	VerificationTTL  time.Duration    `json:"verification_ttl"`
+	AllowedClientIDs []string         `json:"allowed_client_ids"`
+	SigningKey       *jose.JSONWebKey `json:"signing_key"`
+	KeyRing          []*expireableKey `json:"key_ring"`
+	NextRotation     time.Time        `json:"next_rotation"`
+}
+
+// expireableKey is a public key of a named key. A zero ExpireAt marks the
+// current signing key.
+type expireableKey struct {
+	KeyID    string    `json:"key_id"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// oidcRole determines the key, audience, lifetime and extra claims of the
+// tokens issued through it
+type oidcRole struct {
+	Name     string        `json:"name"`
+	Key      string        `json:"key"`
+	Template string        `json:"template"`
+	ClientID string        `json:"client_id"`
+	TTL      time.Duration `json:"ttl"`
+}
+
+// oidcDiscovery is the OpenID provider metadata served at
+// .well-known/openid-configuration
+type oidcDiscovery struct {
+	Issuer        string   `json:"issuer"`
+	Keys          string   `json:"jwks_uri"`
+	ResponseTypes []string `json:"response_types_supported"`
+	Subjects      []string `json:"subject_types_supported"`
+	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
+}
+
+func oidcPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/config/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"issuer": {
+					Type:        framework.TypeString,
+					Description: "Base URL of the issuer of the tokens, e.g. https://vault.example.com:8200. Defaults to the API address of the active node.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCReadConfig,
+				logical.UpdateOperation: i.pathOIDCUpdateConfig,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["config"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["config"][1]),
+		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key.",
+				},
+				"algorithm": {
+					Type:        framework.TypeString,
+					Default:     string(jose.RS256),
+					Description: "Signing algorithm of the key. One of RS256, RS384, RS512, ES256, ES384, ES512 or EdDSA.",
+				},
+				"rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(oidcDefaultKeyLifetime.Seconds()),
+					Description: "How often the signing key is replaced.",
+				},
+				"verification_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(oidcDefaultKeyLifetime.Seconds()),
+					Description: "How long a replaced key is still published for verification. Should be at least the TTL of the roles using the key.",
+				},
+				"allowed_client_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Client IDs of the roles allowed to sign tokens with the key. '*' allows all roles.",
+				},
+			},
+			ExistenceCheck: i.pathOIDCKeyExistenceCheck,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateKey,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateKey,
+				logical.ReadOperation:   i.pathOIDCReadKey,
+				logical.DeleteOperation: i.pathOIDCDeleteKey,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["key"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["key"][1]),
+		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name") + "/rotate/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key.",
+				},
+				"verification_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the replaced key is still published for verification. Defaults to the verification_ttl of the key.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCRotateKey,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["key-rotate"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["key-rotate"][1]),
+		},
+		{
+			Pattern: "oidc/key/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCListKeys,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["key-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["key-list"][1]),
+		},
+		{
+			Pattern: "oidc/role/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the role.",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "Name of the key used to sign tokens.",
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: "JSON object of additional claims. String values may contain placeholders such as {{identity.entity.metadata.<key>}}.",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(oidcDefaultKeyLifetime.Seconds()),
+					Description: "Lifetime of the tokens issued through the role.",
+				},
+			},
+			ExistenceCheck: i.pathOIDCRoleExistenceCheck,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateRole,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateRole,
+				logical.ReadOperation:   i.pathOIDCReadRole,
+				logical.DeleteOperation: i.pathOIDCDeleteRole,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["role"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["role"][1]),
+		},
+		{
+			Pattern: "oidc/role/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCListRoles,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["role-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["role-list"][1]),
+		},
+		{
+			Pattern: "oidc/token/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the role.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCGenerateToken,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["token"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["token"][1]),
+		},
+		{
+			Pattern: "oidc/.well-known/openid-configuration/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCDiscovery,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["discovery"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["discovery"][1]),
+		},
+		{
+			Pattern: "oidc/.well-known/keys/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCKeys,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["keys"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["keys"][1]),
+		},
+	}
+}
+
+func (i *IdentityStore) pathOIDCReadConfig(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := i.oidcConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer": config.Issuer,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCUpdateConfig(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	config, err := i.oidcConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuerRaw, ok := d.GetOk("issuer"); ok {
+		issuer := issuerRaw.(string)
+		if issuer != "" {
+			u, err := url.Parse(issuer)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return logical.ErrorResponse("issuer must be a URL such as https://vault.example.com:8200"), logical.ErrInvalidRequest
+			}
+			if u.RawQuery != "" || u.Fragment != "" || strings.Trim(u.Path, "/") != "" {
+				return logical.ErrorResponse("issuer must not contain a path, query or fragment"), logical.ErrInvalidRequest
+			}
+			issuer = strings.TrimSuffix(issuer, "/")
+		}
+		config.Issuer = issuer
+	}
+
+	entry, err := logical.StorageEntryJSON(oidcConfigStorageKey, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.view.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) oidcConfig(ctx context.Context) (*oidcConfig, error) {
+	entry, err := i.view.Get(ctx, oidcConfigStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &oidcConfig{}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// oidcIssuer returns the value of the iss claim, which is also the base URL
+// of the discovery endpoints
+func (i *IdentityStore) oidcIssuer(ctx context.Context) (string, error) {
+	config, err := i.oidcConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	base := config.Issuer
+	if base == "" {
+		base = strings.TrimSuffix(i.core.redirectAddr, "/")
+	}
+	return base + oidcIssuerPathSuffix, nil
+}
+
+func (i *IdentityStore) pathOIDCKeyExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	key, err := i.oidcNamedKey(ctx, d.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return key != nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	key, err := i.oidcNamedKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	isNew := key == nil
+	if isNew {
+		key = &namedKey{
+			Name: name,
+		}
+	}
+
+	if algorithmRaw, ok := d.GetOk("algorithm"); ok || isNew {
+		if !ok {
+			algorithmRaw = d.Get("algorithm")
+		}
+		algorithm := algorithmRaw.(string)
+		if !strutil.StrListContains(oidcSupportedAlgorithms, algorithm) {
+			return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %q, must be one of %s", algorithm, strings.Join(oidcSupportedAlgorithms, ", "))), logical.ErrInvalidRequest
+		}
+		// A changed algorithm takes effect at the next rotation
+		key.Algorithm = algorithm
+	}
+
+	if rotationPeriodRaw, ok := d.GetOk("rotation_period"); ok || isNew {
+		if !ok {
+			rotationPeriodRaw = d.Get("rotation_period")
+		}
+		key.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	}
+	if key.RotationPeriod < oidcMinRotationPeriod {
+		return logical.ErrorResponse(fmt.Sprintf("rotation_period must be at least %s", oidcMinRotationPeriod)), logical.ErrInvalidRequest
+	}
+
+	if verificationTTLRaw, ok := d.GetOk("verification_ttl"); ok || isNew {
+		if !ok {
+			verificationTTLRaw = d.Get("verification_ttl")
+		}
+		key.VerificationTTL = time.Duration(verificationTTLRaw.(int)) * time.Second
+	}
+
+	if allowedClientIDsRaw, ok := d.GetOk("allowed_client_ids"); ok {
+		key.AllowedClientIDs = allowedClientIDsRaw.([]string)
+	}
+
+	// A new key needs a signing key right away
+	if key.SigningKey == nil {
+		if err := i.rotateOIDCKey(ctx, key, 0); err != nil {
+			return nil, err
+		}
+	} else if next := time.Now().Add(key.RotationPeriod); next.Before(key.NextRotation) {
+		key.NextRotation = next
+	}
+
+	if err := i.putOIDCNamedKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	key, err := i.oidcNamedKey(ctx, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"algorithm":          key.Algorithm,
+			"rotation_period":    int64(key.RotationPeriod.Seconds()),
+			"verification_ttl":   int64(key.VerificationTTL.Seconds()),
+			"allowed_client_ids": key.AllowedClientIDs,
+			"next_rotation":      key.NextRotation.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	key, err := i.oidcNamedKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	roles, err := i.oidcRolesByKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) > 0 {
+		return logical.ErrorResponse(fmt.Sprintf("key %q is in use by roles: %s", name, strings.Join(roles, ", "))), logical.ErrInvalidRequest
+	}
+
+	for _, k := range key.KeyRing {
+		if err := i.view.Delete(ctx, oidcPublicKeysPrefix+k.KeyID); err != nil {
+			return nil, err
+		}
+	}
+	if err := i.view.Delete(ctx, oidcNamedKeysPrefix+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCListKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	keys, err := i.view.List(ctx, oidcNamedKeysPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(keys), nil
+}
+
+func (i *IdentityStore) pathOIDCRotateKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	key, err := i.oidcNamedKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no key named %q", name)), logical.ErrInvalidRequest
+	}
+
+	verificationTTL := key.VerificationTTL
+	if verificationTTLRaw, ok := d.GetOk("verification_ttl"); ok {
+		verificationTTL = time.Duration(verificationTTLRaw.(int)) * time.Second
+	}
+
+	if err := i.rotateOIDCKey(ctx, key, verificationTTL); err != nil {
+		return nil, err
+	}
+	if err := i.putOIDCNamedKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) oidcNamedKey(ctx context.Context, name string) (*namedKey, error) {
+	entry, err := i.view.Get(ctx, oidcNamedKeysPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key namedKey
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (i *IdentityStore) putOIDCNamedKey(ctx context.Context, key *namedKey) error {
+	entry, err := logical.StorageEntryJSON(oidcNamedKeysPrefix+key.Name, key)
+	if err != nil {
+		return err
+	}
+	return i.view.Put(ctx, entry)
+}
+
+// rotateOIDCKey replaces the signing key of the named key with a newly
+// generated one and publishes its public key. The replaced key remains
+// published for verificationTTL, and keys whose verification TTL has passed
+// are removed. The caller must persist the named key.
+func (i *IdentityStore) rotateOIDCKey(ctx context.Context, key *namedKey, verificationTTL time.Duration) error {
+	now := time.Now()
+
+	signingKey, err := generateOIDCSigningKey(key.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	publicKey := signingKey.Public()
+	entry, err := logical.StorageEntryJSON(oidcPublicKeysPrefix+publicKey.KeyID, publicKey)
+	if err != nil {
+		return err
+	}
+	if err := i.view.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	for _, k := range key.KeyRing {
+		if k.ExpireAt.IsZero() {
+			k.ExpireAt = now.Add(verificationTTL)
+		}
+	}
+	if _, err := i.expireOIDCPublicKeys(ctx, key, now); err != nil {
+		return err
+	}
+
+	key.KeyRing = append(key.KeyRing, &expireableKey{KeyID: signingKey.KeyID})
+	key.SigningKey = signingKey
+	key.NextRotation = now.Add(key.RotationPeriod)
+
+	return nil
+}
+
+// expireOIDCPublicKeys removes the public keys of the named key whose
+// verification TTL has passed and reports whether any were removed. The caller
+// must persist the named key.
+func (i *IdentityStore) expireOIDCPublicKeys(ctx context.Context, key *namedKey, now time.Time) (bool, error) {
+	var keyRing []*expireableKey
+	for _, k := range key.KeyRing {
+		if !k.ExpireAt.IsZero() && !k.ExpireAt.After(now) {
+			if err := i.view.Delete(ctx, oidcPublicKeysPrefix+k.KeyID); err != nil {
+				return false, err
+			}
+			continue
+		}
+		keyRing = append(keyRing, k)
+	}
+
+	expired := len(keyRing) != len(key.KeyRing)
+	key.KeyRing = keyRing
+	return expired, nil
+}
+
+func generateOIDCSigningKey(algorithm string) (*jose.JSONWebKey, error) {
+	var privateKey crypto.PrivateKey
+	var err error
+
+	switch jose.SignatureAlgorithm(algorithm) {
+	case jose.RS256, jose.RS384, jose.RS512:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jose.ES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jose.ES384:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jose.ES512:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case jose.EdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to generate signing key: {{err}}", err)
+	}
+
+	keyID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jose.JSONWebKey{
+		Key:       privateKey,
+		KeyID:     keyID,
+		Algorithm: algorithm,
+		Use:       "sig",
+	}, nil
+}
+
+// oidcPeriodicFunc removes public keys whose verification TTL has passed,
+// whether or not their named key is due for rotation, and rotates the named
+// keys that are due
+func (i *IdentityStore) oidcPeriodicFunc(ctx context.Context, req *logical.Request) error {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	names, err := i.view.List(ctx, oidcNamedKeysPrefix)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		key, err := i.oidcNamedKey(ctx, name)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			continue
+		}
+
+		changed, err := i.expireOIDCPublicKeys(ctx, key, now)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to expire public keys of key %q: {{err}}", name), err)
+		}
+
+		if !now.Before(key.NextRotation) {
+			if err := i.rotateOIDCKey(ctx, key, key.VerificationTTL); err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("failed to rotate key %q: {{err}}", name), err)
+			}
+			changed = true
+			i.logger.Debug("rotated oidc key", "name", name)
+		}
+
+		if changed {
+			if err := i.putOIDCNamedKey(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (i *IdentityStore) pathOIDCRoleExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	role, err := i.oidcRole(ctx, d.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return role != nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	role, err := i.oidcRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	isNew := role == nil
+	if isNew {
+		clientID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		role = &oidcRole{
+			Name:     name,
+			ClientID: clientID,
+		}
+	}
+
+	if keyRaw, ok := d.GetOk("key"); ok {
+		role.Key = keyRaw.(string)
+	}
+	if role.Key == "" {
+		return logical.ErrorResponse("missing key"), logical.ErrInvalidRequest
+	}
+	key, err := i.oidcNamedKey(ctx, role.Key)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no key named %q", role.Key)), logical.ErrInvalidRequest
+	}
+
+	if templateRaw, ok := d.GetOk("template"); ok {
+		role.Template = templateRaw.(string)
+	}
+	if role.Template != "" {
+		// Rendering against an empty entity checks the JSON, the
+		// placeholders and the reserved claims
+		if _, err := renderOIDCTemplate(role.Template, &identity.Entity{}, nil); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
+	if ttlRaw, ok := d.GetOk("ttl"); ok || isNew {
+		if !ok {
+			ttlRaw = d.Get("ttl")
+		}
+		role.TTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if role.TTL <= 0 {
+		return logical.ErrorResponse("ttl must be greater than zero"), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON(oidcRolesPrefix+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.view.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	var resp *logical.Response
+	if role.TTL > key.VerificationTTL {
+		resp = &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("the ttl of the role is longer than the verification_ttl of key %q, so its tokens may become unverifiable before they expire", role.Key))
+	}
+	return resp, nil
+}
+
+func (i *IdentityStore) pathOIDCReadRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := i.oidcRole(ctx, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key":       role.Key,
+			"template":  role.Template,
+			"client_id": role.ClientID,
+			"ttl":       int64(role.TTL.Seconds()),
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	if err := i.view.Delete(ctx, oidcRolesPrefix+d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCListRoles(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := i.view.List(ctx, oidcRolesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(roles), nil
+}
+
+func (i *IdentityStore) oidcRole(ctx context.Context, name string) (*oidcRole, error) {
+	entry, err := i.view.Get(ctx, oidcRolesPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role oidcRole
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// oidcRolesByKey returns the names of the roles that sign with the named key
+func (i *IdentityStore) oidcRolesByKey(ctx context.Context, keyName string) ([]string, error) {
+	names, err := i.view.List(ctx, oidcRolesPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	for _, name := range names {
+		role, err := i.oidcRole(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.Key == keyName {
+			roles = append(roles, name)
+		}
+	}
+	return roles, nil
+}
+
+func (i *IdentityStore) pathOIDCGenerateToken(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	if req.EntityID == "" {
+		return logical.ErrorResponse("no entity is associated with the request's token"), logical.ErrInvalidRequest
+	}
+	entity, err := i.MemDBEntityByID(req.EntityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse("the entity associated with the request's token was not found"), logical.ErrInvalidRequest
+	}
+	if entity.Disabled {
+		return logical.ErrorResponse("the entity associated with the request's token is disabled"), logical.ErrPermissionDenied
+	}
+
+	role, err := i.oidcRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no role named %q", name)), logical.ErrInvalidRequest
+	}
+
+	i.oidcLock.RLock()
+	key, err := i.oidcNamedKey(ctx, role.Key)
+	i.oidcLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no key named %q", role.Key)), logical.ErrInvalidRequest
+	}
+	if !strutil.StrListContains(key.AllowedClientIDs, "*") && !strutil.StrListContains(key.AllowedClientIDs, role.ClientID) {
+		return logical.ErrorResponse(fmt.Sprintf("the client ID of role %q is not allowed by key %q", name, role.Key)), logical.ErrInvalidRequest
+	}
+
+	issuer, err := i.oidcIssuer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if role.Template != "" {
+		directGroups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
+		if err != nil {
+			return nil, err
+		}
+		claims, err = renderOIDCTemplate(role.Template, entity, append(directGroups, inheritedGroups...))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	claims["iss"] = issuer
+	claims["sub"] = entity.ID
+	claims["aud"] = role.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(role.TTL).Unix()
+
+	token, err := signOIDCToken(key.SigningKey, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"token":     token,
+			"client_id": role.ClientID,
+			"ttl":       int64(role.TTL.Seconds()),
+		},
+	}, nil
+}
+
+func signOIDCToken(signingKey *jose.JSONWebKey, claims map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(signingKey.Algorithm),
+		Key:       signingKey,
+	}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", errwrap.Wrapf("failed to sign token: {{err}}", err)
+	}
+	return signed.CompactSerialize()
+}
+
+// renderOIDCTemplate parses a role's template and replaces its placeholders
+// with values taken from the entity and its groups. A string that consists of
+// a single placeholder is replaced by the value itself, so that lists such as
+// the group names become JSON arrays.
+func renderOIDCTemplate(template string, entity *identity.Entity, groups []*identity.Group) (map[string]interface{}, error) {
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(template), &claims); err != nil {
+		return nil, errwrap.Wrapf("template must be a JSON object: {{err}}", err)
+	}
+	for _, claim := range oidcReservedClaims {
+		if _, ok := claims[claim]; ok {
+			return nil, fmt.Errorf("template must not set the reserved claim %q", claim)
+		}
+	}
+
+	rendered, err := renderOIDCTemplateValue(claims, entity, groups)
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+func renderOIDCTemplateValue(value interface{}, entity *identity.Entity, groups []*identity.Group) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, elem := range v {
+			rendered, err := renderOIDCTemplateValue(elem, entity, groups)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = rendered
+		}
+		return v, nil
+
+	case []interface{}:
+		for idx, elem := range v {
+			rendered, err := renderOIDCTemplateValue(elem, entity, groups)
+			if err != nil {
+				return nil, err
+			}
+			v[idx] = rendered
+		}
+		return v, nil
+
+	case string:
+		if m := oidcTemplateRegex.FindStringSubmatch(v); m != nil && m[0] == v {
+			return oidcTemplateLookup(m[1], entity, groups)
+		}
+
+		var lookupErr error
+		rendered := oidcTemplateRegex.ReplaceAllStringFunc(v, func(s string) string {
+			name := oidcTemplateRegex.FindStringSubmatch(s)[1]
+			value, err := oidcTemplateLookup(name, entity, groups)
+			if err != nil {
+				lookupErr = err
+				return ""
+			}
+			str, ok := value.(string)
+			if !ok {
+				lookupErr = fmt.Errorf("placeholder %q is a list and must be the whole value of a claim", name)
+				return ""
+			}
+			return str
+		})
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return rendered, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// oidcTemplateLookup returns the value of a template placeholder
+func oidcTemplateLookup(name string, entity *identity.Entity, groups []*identity.Group) (interface{}, error) {
+	const metadataPrefix = "identity.entity.metadata."
+
+	switch {
+	case name == "identity.entity.id":
+		return entity.ID, nil
+	case name == "identity.entity.name":
+		return entity.Name, nil
+	case strings.HasPrefix(name, metadataPrefix) && len(name) > len(metadataPrefix):
+		return entity.Metadata[strings.TrimPrefix(name, metadataPrefix)], nil
+	case name == "identity.entity.groups.ids", name == "identity.entity.groups.names":
+		values := []string{}
+		for _, group := range groups {
+			if name == "identity.entity.groups.ids" {
+				values = append(values, group.ID)
+			} else {
+				values = append(values, group.Name)
+			}
+		}
+		sort.Strings(values)
+		return strutil.RemoveDuplicates(values, false), nil
+	default:
+		return nil, fmt.Errorf("unknown template placeholder %q", name)
+	}
+}
+
+func (i *IdentityStore) pathOIDCDiscovery(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	issuer, err := i.oidcIssuer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(&oidcDiscovery{
+		Issuer:        issuer,
+		Keys:          issuer + "/.well-known/keys",
+		ResponseTypes: []string{"id_token"},
+		Subjects:      []string{"public"},
+		IDTokenAlgs:   oidcSupportedAlgorithms,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcRawResponse(body), nil
+}
+
+func (i *IdentityStore) pathOIDCKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	keyIDs, err := i.view.List(ctx, oidcPublicKeysPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{},
+	}
+	for _, keyID := range keyIDs {
+		entry, err := i.view.Get(ctx, oidcPublicKeysPrefix+keyID)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var key jose.JSONWebKey
+		if err := entry.DecodeJSON(&key); err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, key)
+	}
+
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcRawResponse(body), nil
+}
+
+func oidcRawResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  200,
+			logical.HTTPRawBody:     body,
+			logical.HTTPContentType: "application/json",
+		},
+	}
+}
+
+var oidcHelp = map[string][2]string{
+	"config": {
+		"Configure the OIDC token provider.",
+		`
+The issuer is the base URL placed in the "iss" claim of the tokens, followed
+by /v1/identity/oidc. Relying parties find the discovery document and the
+public keys under that URL. It defaults to the API address of the active node.
+		`,
+	},
+	"key": {
+		"Create, read, update or delete a named key used to sign tokens.",
+		`
+The signing key of a named key is replaced every rotation_period. Replaced keys
+remain published at .well-known/keys for verification_ttl so that the tokens
+they signed can still be verified. Only roles whose client ID is listed in
+allowed_client_ids may use the key.
+		`,
+	},
+	"key-rotate": {
+		"Rotate a named key.",
+		"",
+	},
+	"key-list": {
+		"List the named keys.",
+		"",
+	},
+	"role": {
+		"Create, read, update or delete a role that issues tokens.",
+		`
+Tokens issued through a role are signed with its key, carry its client ID in
+the "aud" claim and expire after its ttl. The template is a JSON object of
+additional claims whose string values may use the placeholders
+{{identity.entity.id}}, {{identity.entity.name}},
+{{identity.entity.metadata.<key>}}, {{identity.entity.groups.ids}} and
+{{identity.entity.groups.names}}.
+		`,
+	},
+	"role-list": {
+		"List the roles.",
+		"",
+	},
+	"token": {
+		"Generate a signed token for the entity of the calling token.",
+		"",
+	},
+	"discovery": {
+		"Read the OpenID provider configuration.",
+		"",
+	},
+	"keys": {
+		"Read the public keys used to verify tokens.",
+		"",
+	},
+}
@@ -0,0 +1,352 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestIdentityStore_OIDC_Token(t *testing.T) {
+	ctx := context.Background()
+	i, _, _ := testIdentityStoreWithGithubAuth(t)
+
+	resp, err := i.HandleRequest(ctx, &logical.Request{
+		Path:      "entity",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":     "testentity",
+			"metadata": []string{"team=ops"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	entityID := resp.Data["id"].(string)
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":              "testgroup",
+			"member_entity_ids": entityID,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/config",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"issuer": "https://vault.example.com:8200/",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"algorithm": "ES256",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	// Reserved claims cannot be templated
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/role/testrole",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"key":      "testkey",
+			"template": `{"sub": "foo"}`,
+		},
+	})
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a reserved claim")
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/role/testrole",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"key":      "testkey",
+			"ttl":      "1h",
+			"template": `{"team": "{{identity.entity.metadata.team}}", "groups": "{{identity.entity.groups.names}}", "greeting": "hello {{identity.entity.name}}"}`,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/role/testrole",
+		Operation: logical.ReadOperation,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	clientID := resp.Data["client_id"].(string)
+
+	tokenReq := &logical.Request{
+		Path:      "oidc/token/testrole",
+		Operation: logical.ReadOperation,
+		EntityID:  entityID,
+	}
+
+	// The key does not allow the role's client ID yet
+	resp, err = i.HandleRequest(ctx, tokenReq)
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a client ID the key does not allow")
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"allowed_client_ids": clientID,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	resp, err = i.HandleRequest(ctx, tokenReq)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	token := resp.Data["token"].(string)
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/.well-known/keys",
+		Operation: logical.ReadOperation,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &jwks); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := jwks.Key(parsed.Headers[0].KeyID)
+	if len(keys) != 1 {
+		t.Fatalf("expected the signing key to be published, got %d keys", len(keys))
+	}
+
+	var std jwt.Claims
+	claims := map[string]interface{}{}
+	if err := parsed.Claims(keys[0], &std, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if err := std.Validate(jwt.Expected{
+		Issuer:   "https://vault.example.com:8200/v1/identity/oidc",
+		Subject:  entityID,
+		Audience: jwt.Audience{clientID},
+		Time:     time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if claims["team"] != "ops" || claims["greeting"] != "hello testentity" {
+		t.Fatalf("bad: claims: %#v", claims)
+	}
+	if !reflect.DeepEqual(claims["groups"], []interface{}{"testgroup"}) {
+		t.Fatalf("bad: groups claim: %#v", claims["groups"])
+	}
+
+	// A token without an entity cannot generate tokens
+	tokenReq.EntityID = ""
+	resp, err = i.HandleRequest(ctx, tokenReq)
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a request without an entity")
+	}
+
+	// A key in use by a role cannot be deleted
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey",
+		Operation: logical.DeleteOperation,
+	})
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected an error deleting a key in use")
+	}
+}
+
+func TestIdentityStore_OIDC_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	i, _, _ := testIdentityStoreWithGithubAuth(t)
+
+	resp, err := i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"rotation_period":  "1m",
+			"verification_ttl": "1h",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	publicKeys := func() []jose.JSONWebKey {
+		t.Helper()
+		resp, err := i.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/.well-known/keys",
+			Operation: logical.ReadOperation,
+		})
+		if err != nil || resp == nil {
+			t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+		}
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &jwks); err != nil {
+			t.Fatal(err)
+		}
+		return jwks.Keys
+	}
+
+	if keys := publicKeys(); len(keys) != 1 {
+		t.Fatalf("expected 1 public key, got %d", len(keys))
+	}
+
+	// Not yet due
+	if err := i.oidcPeriodicFunc(ctx, &logical.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if keys := publicKeys(); len(keys) != 1 {
+		t.Fatalf("expected 1 public key, got %d", len(keys))
+	}
+
+	key, err := i.oidcNamedKey(ctx, "testkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.NextRotation = time.Now().Add(-time.Second)
+	if err := i.putOIDCNamedKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replaced key stays published for the verification TTL
+	if err := i.oidcPeriodicFunc(ctx, &logical.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if keys := publicKeys(); len(keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %d", len(keys))
+	}
+
+	// A rotation with no verification TTL unpublishes the replaced key at once
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey/rotate",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"verification_ttl": 0,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	if keys := publicKeys(); len(keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %d", len(keys))
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey",
+		Operation: logical.DeleteOperation,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	if keys := publicKeys(); len(keys) != 0 {
+		t.Fatalf("expected no public keys, got %d", len(keys))
+	}
+}
+
+func TestIdentityStore_OIDC_VerificationTTL(t *testing.T) {
+	ctx := context.Background()
+	i, _, _ := testIdentityStoreWithGithubAuth(t)
+
+	resp, err := i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"rotation_period":  "1h",
+			"verification_ttl": "1m",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/testkey/rotate",
+		Operation: logical.UpdateOperation,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	publicKeys := func() []jose.JSONWebKey {
+		t.Helper()
+		resp, err := i.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/.well-known/keys",
+			Operation: logical.ReadOperation,
+		})
+		if err != nil || resp == nil {
+			t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+		}
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &jwks); err != nil {
+			t.Fatal(err)
+		}
+		return jwks.Keys
+	}
+
+	if keys := publicKeys(); len(keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %d", len(keys))
+	}
+
+	// Pass the verification TTL of the replaced key, but not the rotation period
+	key, err := i.oidcNamedKey(ctx, "testkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.KeyRing) != 2 || key.KeyRing[0].ExpireAt.IsZero() {
+		t.Fatalf("bad: key ring: %#v", key.KeyRing)
+	}
+	key.KeyRing[0].ExpireAt = time.Now().Add(-time.Second)
+	nextRotation := key.NextRotation
+	if err := i.putOIDCNamedKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := i.oidcPeriodicFunc(ctx, &logical.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	keys := publicKeys()
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 public key, got %d", len(keys))
+	}
+
+	key, err = i.oidcNamedKey(ctx, "testkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.NextRotation.Equal(nextRotation) {
+		t.Fatalf("expected the key not to be rotated")
+	}
+	if len(key.KeyRing) != 1 || key.KeyRing[0].KeyID != keys[0].KeyID || key.SigningKey.KeyID != keys[0].KeyID {
+		t.Fatalf("bad: key ring: %#v, public keys: %#v", key.KeyRing, keys)
+	}
+}
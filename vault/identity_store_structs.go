@@ -7,9 +7,11 @@ import (
 	log "github.com/hashicorp/go-hclog"
 	memdb "github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/mfa/duo"
 	"github.com/hashicorp/vault/helper/storagepacker"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	cache "github.com/patrickmn/go-cache"
 )
 
 const (
@@ -57,6 +59,25 @@ type IdentityStore struct {
 	// groupLock is used to protect modifications to group entries
 	groupLock sync.RWMutex
 
+	// oidcLock is used to protect modifications to the keys, roles and
+	// configuration of the OIDC token provider
+	oidcLock sync.RWMutex
+
+	// mfaLock is used to protect modifications to the login MFA methods and
+	// the TOTP keys of entities
+	mfaLock sync.RWMutex
+
+	// mfaPendingLogins holds the logins that wait for their MFA methods to
+	// be validated, keyed by MFA request ID
+	mfaPendingLogins *cache.Cache
+
+	// mfaUsedCodes holds the TOTP codes that were validated recently to
+	// prevent their reuse
+	mfaUsedCodes *cache.Cache
+
+	// mfaDuoAuthClient creates the Duo client of a Duo MFA method
+	mfaDuoAuthClient func(*duo.DuoAccess, string) (duo.AuthClient, error)
+
 	// logger is the server logger copied over from core
 	logger log.Logger
 
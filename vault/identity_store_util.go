@@ -563,6 +563,18 @@ func (i *IdentityStore) MemDBEntityByName(entityName string, clone bool) (*ident
 
 	txn := i.db.Txn(false)
 
+	return i.MemDBEntityByNameInTxn(txn, entityName, clone)
+}
+
+func (i *IdentityStore) MemDBEntityByNameInTxn(txn *memdb.Txn, entityName string, clone bool) (*identity.Entity, error) {
+	if entityName == "" {
+		return nil, fmt.Errorf("missing entity name")
+	}
+
+	if txn == nil {
+		return nil, fmt.Errorf("txn is nil")
+	}
+
 	entityRaw, err := txn.First(entitiesTable, "name", entityName)
 	if err != nil {
 		return nil, errwrap.Wrapf("failed to fetch entity from memdb using entity name: {{err}}", err)
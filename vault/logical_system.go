@@ -88,6 +88,9 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				"leases/revoke-prefix/*",
 				"leases/revoke-force/*",
 				"leases/lookup/*",
+				"pprof",
+				"pprof/*",
+				"monitor",
 			},
 
 			Unauthenticated: []string{
@@ -96,6 +99,7 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				"replication/status",
 				"internal/ui/mounts",
 				"internal/ui/mounts/*",
+				"mfa/validate",
 			},
 		},
 
@@ -1111,10 +1115,43 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				HelpSynopsis:    strings.TrimSpace(sysHelp["internal-ui-resultant-acl"][0]),
 				HelpDescription: strings.TrimSpace(sysHelp["internal-ui-resultant-acl"][1]),
 			},
+			&framework.Path{
+				Pattern: "monitor$",
+				Fields: map[string]*framework.FieldSchema{
+					"log_level": &framework.FieldSchema{
+						Type:        framework.TypeString,
+						Default:     "info",
+						Description: "Log level of the messages to stream.",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleMonitorAuthorize,
+				},
+				HelpSynopsis:    strings.TrimSpace(sysHelp["monitor"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["monitor"][1]),
+			},
+			&framework.Path{
+				// Requests to this path are handled by the core as part of
+				// the login flow and never reach the system backend
+				Pattern: "mfa/validate$",
+				Fields:  mfaValidateFields,
+
+				HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-validate"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["mfa-validate"][1]),
+			},
+			&framework.Path{
+				Pattern: "metrics$",
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.handleMetricsRead,
+				},
+				HelpSynopsis:    strings.TrimSpace(sysHelp["metrics"][0]),
+				HelpDescription: strings.TrimSpace(sysHelp["metrics"][1]),
+			},
 		},
 	}
 
 	b.Backend.Paths = append(b.Backend.Paths, replicationPaths(b)...)
+	b.Backend.Paths = append(b.Backend.Paths, b.pprofPaths()...)
 
 	if core.rawEnabled {
 		b.Backend.Paths = append(b.Backend.Paths, &framework.Path{
@@ -1191,6 +1228,39 @@ func (b *SystemBackend) handleCORSDelete(ctx context.Context, req *logical.Reque
 	return nil, b.Core.corsConfig.Disable(ctx)
 }
 
+// handleMonitorAuthorize is called by the HTTP handler of sys/monitor before
+// it starts streaming, so that the request is authorized and audited like any
+// other. The log stream itself cannot be carried by a logical response.
+func (b *SystemBackend) handleMonitorAuthorize(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return nil, nil
+}
+
+// handleMetricsRead returns the most recent interval of the in-memory
+// telemetry sink
+func (b *SystemBackend) handleMetricsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if b.Core.metricSink == nil {
+		return logical.ErrorResponse("in-memory telemetry is not available"), logical.ErrUnsupportedPath
+	}
+
+	summary, err := b.Core.metricSink.DisplayMetrics(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
 func (b *SystemBackend) handleTidyLeases(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	go func() {
 		err := b.Core.expiration.Tidy()
@@ -1295,7 +1365,7 @@ func (b *SystemBackend) handlePluginCatalogRead(ctx context.Context, req *logica
 
 	command := ""
 	if !plugin.Builtin {
-		command, err = filepath.Rel(b.Core.pluginCatalog.directory, plugin.Command)
+		command, err = filepath.Rel(b.Core.pluginCatalog.Directory(), plugin.Command)
 		if err != nil {
 			return nil, err
 		}
@@ -1673,9 +1743,9 @@ func (b *SystemBackend) handleMount(ctx context.Context, req *logical.Request, d
 			logical.ErrInvalidRequest
 	}
 
-	if config.DefaultLeaseTTL > b.Core.maxLeaseTTL && config.MaxLeaseTTL == 0 {
+	if _, sysMaxTTL := b.Core.leaseTTLs(); config.DefaultLeaseTTL > sysMaxTTL && config.MaxLeaseTTL == 0 {
 		return logical.ErrorResponse(fmt.Sprintf(
-				"given default lease TTL greater than system max lease TTL of %d", int(b.Core.maxLeaseTTL.Seconds()))),
+				"given default lease TTL greater than system max lease TTL of %d", int(sysMaxTTL.Seconds()))),
 			logical.ErrInvalidRequest
 	}
 
@@ -2475,9 +2545,9 @@ func (b *SystemBackend) handleEnableAuth(ctx context.Context, req *logical.Reque
 			logical.ErrInvalidRequest
 	}
 
-	if config.DefaultLeaseTTL > b.Core.maxLeaseTTL && config.MaxLeaseTTL == 0 {
+	if _, sysMaxTTL := b.Core.leaseTTLs(); config.DefaultLeaseTTL > sysMaxTTL && config.MaxLeaseTTL == 0 {
 		return logical.ErrorResponse(fmt.Sprintf(
-				"given default lease TTL greater than system max lease TTL of %d", int(b.Core.maxLeaseTTL.Seconds()))),
+				"given default lease TTL greater than system max lease TTL of %d", int(sysMaxTTL.Seconds()))),
 			logical.ErrInvalidRequest
 	}
 
@@ -4361,4 +4431,60 @@ This path responds to the following HTTP methods.
 		"Information about a token's resultant ACL. Internal API; its location, inputs, and outputs may change.",
 		"",
 	},
+	"metrics": {
+		"Export the metrics aggregated for telemetry purpose.",
+		`
+Returns the most recently completed interval of the server's in-memory
+telemetry sink, which aggregates metrics over 10 second intervals.
+		`,
+	},
+	"monitor": {
+		"Stream the server log.",
+		`
+Streams the log of the node serving the request at the level given in
+"log_level". This path requires sudo capability.
+		`,
+	},
+	"mfa-validate": {
+		"Complete a login that requires MFA.",
+		`
+Validates the MFA methods listed in the MFA requirement of a login response.
+"mfa_payload" maps the name of each method to the passcodes collected for it.
+If all methods validate, the token of the login is returned. A pending login
+expires after five minutes and can only be validated once.
+		`,
+	},
+	"pprof": {
+		"List the runtime profiles that can be requested.",
+		`
+Lists the runtime profiles exposed under this path. Profiles are served by the
+active node and require sudo capability.
+		`,
+	},
+	"pprof-cmdline": {
+		"Return the command line of the running Vault process.",
+		"The arguments are separated by NUL bytes.",
+	},
+	"pprof-profile": {
+		"Return a CPU profile of the running Vault process.",
+		`
+Collects a CPU profile for the number of seconds given in "seconds" and
+returns it in the protobuf format read by "go tool pprof".
+		`,
+	},
+	"pprof-trace": {
+		"Return an execution trace of the running Vault process.",
+		`
+Collects an execution trace for the number of seconds given in "seconds" and
+returns it in the format read by "go tool trace".
+		`,
+	},
+	"pprof-lookup": {
+		"Return a named runtime profile, such as goroutine or heap.",
+		`
+Returns the named runtime profile in the protobuf format read by "go tool
+pprof". Setting "debug" to a non-zero value returns a human readable text
+version instead; "debug=2" on the goroutine profile returns full stack dumps.
+		`,
+	},
 }
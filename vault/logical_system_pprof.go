@@ -0,0 +1,210 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// pprofMaxSeconds caps the duration of CPU profiles and execution traces
+	// so that a single request cannot hold a profiler open indefinitely.
+	pprofMaxSeconds = 300
+)
+
+// pprofPaths returns the paths serving runtime profiling data. They are
+// registered as root paths and so require sudo capability.
+func (b *SystemBackend) pprofPaths() []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "pprof/?$",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handlePprofIndex,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["pprof"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["pprof"][1]),
+		},
+		&framework.Path{
+			Pattern: "pprof/cmdline$",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handlePprofCmdline,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["pprof-cmdline"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["pprof-cmdline"][1]),
+		},
+		&framework.Path{
+			Pattern: "pprof/profile$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"seconds": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Default:     30,
+					Description: "Duration of the CPU profile in seconds.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handlePprofCPUProfile,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["pprof-profile"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["pprof-profile"][1]),
+		},
+		&framework.Path{
+			Pattern: "pprof/trace$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"seconds": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Default:     1,
+					Description: "Duration of the execution trace in seconds.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handlePprofTrace,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["pprof-trace"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["pprof-trace"][1]),
+		},
+		&framework.Path{
+			Pattern: "pprof/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the runtime profile, e.g. goroutine or heap.",
+				},
+				"debug": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: "If non-zero, returns the profile in a human readable text format instead of the binary protobuf format.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handlePprofLookup,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["pprof-lookup"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["pprof-lookup"][1]),
+		},
+	}
+}
+
+// handlePprofIndex lists the runtime profiles that can be requested
+func (b *SystemBackend) handlePprofIndex(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	var profiles []string
+	for _, p := range pprof.Profiles() {
+		profiles = append(profiles, p.Name())
+	}
+	profiles = append(profiles, "cmdline", "profile", "trace")
+	sort.Strings(profiles)
+
+	return logical.ListResponse(profiles), nil
+}
+
+// handlePprofCmdline returns the command line of the running process
+func (b *SystemBackend) handlePprofCmdline(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return pprofResponse("text/plain; charset=utf-8", []byte(strings.Join(os.Args, "\x00"))), nil
+}
+
+// handlePprofCPUProfile records a CPU profile for the requested duration
+func (b *SystemBackend) handlePprofCPUProfile(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	duration, err := pprofDuration(d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		// This happens when a profile is already being taken
+		return logical.ErrorResponse(fmt.Sprintf("could not enable CPU profiling: %s", err)), logical.ErrInvalidRequest
+	}
+	pprofSleep(ctx, duration)
+	pprof.StopCPUProfile()
+
+	return pprofResponse("application/octet-stream", buf.Bytes()), nil
+}
+
+// handlePprofTrace records an execution trace for the requested duration
+func (b *SystemBackend) handlePprofTrace(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	duration, err := pprofDuration(d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		// This happens when a trace is already being taken
+		return logical.ErrorResponse(fmt.Sprintf("could not enable tracing: %s", err)), logical.ErrInvalidRequest
+	}
+	pprofSleep(ctx, duration)
+	trace.Stop()
+
+	return pprofResponse("application/octet-stream", buf.Bytes()), nil
+}
+
+// handlePprofLookup returns a named runtime profile, such as goroutine or heap
+func (b *SystemBackend) handlePprofLookup(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown profile %q", name)), logical.ErrInvalidRequest
+	}
+
+	debug := d.Get("debug").(int)
+
+	var buf bytes.Buffer
+	if err := profile.WriteTo(&buf, debug); err != nil {
+		return nil, err
+	}
+
+	contentType := "application/octet-stream"
+	if debug != 0 {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	return pprofResponse(contentType, buf.Bytes()), nil
+}
+
+func pprofDuration(d *framework.FieldData) (time.Duration, error) {
+	seconds := d.Get("seconds").(int)
+	if seconds <= 0 || seconds > pprofMaxSeconds {
+		return 0, fmt.Errorf("seconds must be between 1 and %d", pprofMaxSeconds)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// pprofSleep waits for the given duration, returning early if the request is
+// canceled so that the profile collected so far is still returned.
+func pprofSleep(ctx context.Context, duration time.Duration) {
+	select {
+	case <-time.After(duration):
+	case <-ctx.Done():
+	}
+}
+
+func pprofResponse(contentType string, body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestSystemBackend_pprofIndex(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "pprof/")
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	keys := resp.Data["keys"].([]string)
+	for _, expected := range []string{"cmdline", "goroutine", "heap", "profile", "trace"} {
+		if !strutil.StrListContains(keys, expected) {
+			t.Fatalf("expected %q in %v", expected, keys)
+		}
+	}
+}
+
+func TestSystemBackend_pprofLookup(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "pprof/goroutine")
+	req.Data["debug"] = 2
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if resp.Data[logical.HTTPContentType] != "text/plain; charset=utf-8" {
+		t.Fatalf("bad content type: %v", resp.Data[logical.HTTPContentType])
+	}
+	body := resp.Data[logical.HTTPRawBody].([]byte)
+	if !bytes.Contains(body, []byte("goroutine ")) {
+		t.Fatalf("expected goroutine dump, got: %s", body)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "pprof/heap")
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data[logical.HTTPContentType] != "application/octet-stream" {
+		t.Fatalf("bad content type: %v", resp.Data[logical.HTTPContentType])
+	}
+	if len(resp.Data[logical.HTTPRawBody].([]byte)) == 0 {
+		t.Fatal("expected heap profile")
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "pprof/bogus")
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected invalid request, got: %v", err)
+	}
+	if !strings.Contains(resp.Data["error"].(string), "unknown profile") {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestSystemBackend_pprofCPUProfile(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "pprof/profile")
+	req.Data["seconds"] = 0
+	_, err := b.HandleRequest(context.Background(), req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected invalid request, got: %v", err)
+	}
+
+	req.Data["seconds"] = 1
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(resp.Data[logical.HTTPRawBody].([]byte)) == 0 {
+		t.Fatal("expected CPU profile")
+	}
+}
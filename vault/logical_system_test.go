@@ -43,6 +43,9 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"leases/revoke-prefix/*",
 		"leases/revoke-force/*",
 		"leases/lookup/*",
+		"pprof",
+		"pprof/*",
+		"monitor",
 	}
 
 	b := testSystemBackend(t)
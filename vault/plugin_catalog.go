@@ -47,6 +47,20 @@ func (c *Core) setupPluginCatalog() error {
 	return nil
 }
 
+// setDirectory changes the directory external plugins are looked up in
+func (c *PluginCatalog) setDirectory(dir string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.directory = dir
+}
+
+// Directory returns the directory external plugins are looked up in
+func (c *PluginCatalog) Directory() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.directory
+}
+
 // Get retrieves a plugin with the specified name from the catalog. It first
 // looks for external plugins with this name and then looks for builtin plugins.
 // It returns a PluginRunner or an error if no plugin was found.
@@ -88,6 +102,9 @@ func (c *PluginCatalog) Get(ctx context.Context, name string) (*pluginutil.Plugi
 // Set registers a new external plugin with the catalog, or updates an existing
 // external plugin. It takes the name, command and SHA256 of the plugin.
 func (c *PluginCatalog) Set(ctx context.Context, name, command string, args []string, sha256 []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
 	if c.directory == "" {
 		return ErrDirectoryNotConfigured
 	}
@@ -99,9 +116,6 @@ func (c *PluginCatalog) Set(ctx context.Context, name, command string, args []st
 		return consts.ErrPathContainsParentReferences
 	}
 
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
 	// Best effort check to make sure the command isn't breaking out of the
 	// configured plugin directory.
 	commandFull := filepath.Join(c.directory, command)
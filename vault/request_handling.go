@@ -713,8 +713,23 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 		return nil, nil, ErrInternalError
 	}
 
-	// Route the request
-	resp, routeErr := c.router.Route(ctx, req)
+	// Route the request, unless it completes a login that waits for its MFA
+	// methods to be validated. In that case the original login request and
+	// response are restored so that the token can be created for them.
+	var resp *logical.Response
+	var routeErr error
+	var mfaValidated bool
+	if req.Path == loginMFAValidatePath && c.identityStore != nil {
+		var loginReq *logical.Request
+		loginReq, resp, routeErr = c.identityStore.validateLoginMFA(ctx, req)
+		if loginReq == nil {
+			return resp, nil, routeErr
+		}
+		req = loginReq
+		mfaValidated = true
+	} else {
+		resp, routeErr = c.router.Route(ctx, req)
+	}
 	if resp != nil {
 		// If wrapping is used, use the shortest between the request and response
 		var wrapTTL time.Duration
@@ -809,6 +824,19 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 			}
 		}
 
+		// Hold the login until the MFA methods that apply to it are
+		// validated through sys/mfa/validate
+		if !mfaValidated && c.identityStore != nil {
+			methods, err := c.identityStore.loginMFAMethods(ctx, req.MountAccessor, entity)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(methods) > 0 {
+				mfaResp, err := c.identityStore.createPendingMFALogin(ctx, req, resp, entity, methods)
+				return mfaResp, nil, err
+			}
+		}
+
 		// Determine the source of the login
 		source := c.router.MatchingMount(req.Path)
 		source = strings.TrimPrefix(source, credentialRoutePrefix)
@@ -316,6 +316,18 @@ func (r *Router) MatchingMountEntry(path string) *MountEntry {
 	return raw.(*routeEntry).mountEntry
 }
 
+// MatchingMountType returns the prefix and the type of the mount used for a
+// path
+func (r *Router) MatchingMountType(path string) (string, string, bool) {
+	r.l.RLock()
+	mount, raw, ok := r.root.LongestPrefix(path)
+	r.l.RUnlock()
+	if !ok {
+		return "", "", false
+	}
+	return mount, raw.(*routeEntry).mountEntry.Type, true
+}
+
 // MatchingBackend returns the backend used for a path
 func (r *Router) MatchingBackend(path string) logical.Backend {
 	r.l.RLock()
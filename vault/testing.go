@@ -1145,6 +1145,7 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 		coreConfig.Seal = base.Seal
 		coreConfig.DevToken = base.DevToken
 		coreConfig.EnableRaw = base.EnableRaw
+		coreConfig.LogMonitor = base.LogMonitor
 
 		if !coreConfig.DisableMlock {
 			base.DisableMlock = false
@@ -49,6 +49,13 @@ func (c *UIConfig) Enabled() bool {
 	return c.enabled
 }
 
+// SetEnabled enables or disables the UI
+func (c *UIConfig) SetEnabled(enabled bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.enabled = enabled
+}
+
 // Headers returns the response headers that should be returned in the UI
 func (c *UIConfig) Headers(ctx context.Context) (http.Header, error) {
 	c.l.RLock()